@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// plainStyles drops the colors and emphasis of every style, for screen
+// readers
+func plainStyles() {
+	titleStyle = lipgloss.NewStyle()
+	labelStyle = lipgloss.NewStyle()
+	okStyle = lipgloss.NewStyle()
+	errStyle = lipgloss.NewStyle()
+}
+
+// runAccessible is the TUI for screen readers. Instead of redrawing the
+// screen it reads one command per line and answers each with plain lines
+// of text, without colors, spinners or box drawing.
+func runAccessible(cfg config, in io.Reader, out io.Writer) error {
+	plainStyles()
+	fetches := resolve.NewEngine(tuiWorkers, cfg.resolveOptions())
+	defer fetches.Stop()
+	r := bufio.NewReader(in)
+	fmt.Fprintln(out, tr("bibgloss in accessible mode. Enter a DOI to resolve it, help lists the commands."))
+	for {
+		fmt.Fprint(out, tr("DOI or command: "))
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		name, arg, _ := strings.Cut(line, " ")
+		switch name {
+		case "":
+		case "quit", "q":
+			return nil
+		case "help":
+			fmt.Fprintln(out, tr("Commands: a DOI resolves it and offers to import it, list reads the library, show followed by a key reads an entry, new followed by misc, techreport, unpublished or patent asks for an entry without a DOI, quit ends."))
+		case "list":
+			listAccessible(cfg, out)
+		case "show":
+			showAccessible(cfg, strings.TrimSpace(arg), out)
+		case "new":
+			newAccessible(cfg, strings.TrimSpace(arg), r, out)
+		default:
+			resolveAccessible(cfg, fetches, line, r, out)
+		}
+		if err != nil {
+			fmt.Fprintln(out)
+			return nil
+		}
+	}
+}
+
+// resolveAccessible resolves a DOI, reads out what was found and asks
+// whether to import it
+func resolveAccessible(cfg config, fetches *resolve.Engine, doi string, r *bufio.Reader, out io.Writer) {
+	fmt.Fprintln(out, tr("Resolving %s…", doi))
+	var w *Work
+	var similar []Entry
+	switch msg := fetchWork(fetches, cfg, doi)().(type) {
+	case workMsg:
+		w, similar = msg.work, msg.similar
+	case errMsg:
+		fmt.Fprintln(out, tr("Error: %v", msg.error))
+		return
+	default:
+		return
+	}
+	importAccessible(cfg, w, similar, r, out)
+}
+
+// importAccessible reads out a work, and the library entries it may
+// duplicate, and asks whether to import it
+func importAccessible(cfg config, w *Work, similar []Entry, r *bufio.Reader, out io.Writer) {
+	fmt.Fprintln(out, announceWork(w))
+	for _, e := range similar {
+		fmt.Fprintln(out, tr("The library has a similar title as %s: %s.", e.Key, e.Get("title")))
+	}
+	if !askYes(r, out, tr("Import it as %s into %s? [y/N] ", w.Entry.Key, cfg.Library)) {
+		fmt.Fprintln(out, tr("Not imported."))
+		return
+	}
+	for {
+		switch msg := importWork(cfg, w)().(type) {
+		case importedMsg:
+			fmt.Fprintln(out, tr("Imported %s into %s.", msg.key, cfg.Library))
+			if msg.synced != nil {
+				fmt.Fprintln(out, tr("Error: %v", msg.synced))
+			}
+			return
+		case errMsg:
+			var changed *changedError
+			if errors.As(msg.error, &changed) && askYes(r, out, tr("%s changed on disk since bibgloss read it. Reload it and import again? [y/N] ", changed.path)) {
+				forgetFile(changed.path)
+				continue
+			}
+			fmt.Fprintln(out, tr("Error: %v", msg.error))
+			return
+		}
+	}
+}
+
+// newAccessible asks for the fields of a manual entry template one per line
+// and offers to import the entry
+func newAccessible(cfg config, typ string, r *bufio.Reader, out io.Writer) {
+	i, ok := templateIndex(typ)
+	if !ok {
+		fmt.Fprintln(out, tr("Give the type of the entry: new misc, new techreport, new unpublished or new patent."))
+		return
+	}
+	e := bibtex.Entry{Type: entryTemplates[i].Type}
+	for _, f := range entryTemplates[i].Fields {
+		for {
+			question := tr("%s, optional: ", f.Name)
+			if f.Required {
+				question = tr("%s: ", f.Name)
+			}
+			fmt.Fprint(out, question)
+			line, err := r.ReadString('\n')
+			if line = strings.TrimSpace(line); line != "" || !f.Required {
+				e.Set(f.Name, line)
+				break
+			}
+			if err != nil {
+				fmt.Fprintln(out)
+				return
+			}
+			fmt.Fprintln(out, tr("%s is required.", f.Name))
+		}
+	}
+	switch msg := manualWork(cfg, e)().(type) {
+	case workMsg:
+		importAccessible(cfg, msg.work, msg.similar, r, out)
+	case errMsg:
+		fmt.Fprintln(out, tr("Error: %v", msg.error))
+	}
+}
+
+// announceWork sums up a resolved work in sentences
+func announceWork(w *Work) string {
+	e := &w.Entry
+	parts := []string{tr("Found %s.", e.Get("title"))}
+	if authors := bibtex.SplitAuthors(e.Get("author")); len(authors) > 0 {
+		parts = append(parts, tr("By %s.", strings.Join(authors, "; ")))
+	}
+	if y := e.Get("year"); y != "" {
+		parts = append(parts, tr("Published %s.", y))
+	}
+	if w.Citations >= 0 {
+		parts = append(parts, tr("Cited %d times.", w.Citations))
+	}
+	if w.OpenAccess {
+		parts = append(parts, tr("Open access."))
+	}
+	return strings.Join(parts, " ")
+}
+
+// listAccessible reads out the library, one numbered entry per line
+func listAccessible(cfg config, out io.Writer) {
+	entries, err := loadLibrary(cfg.Library)
+	if err != nil {
+		fmt.Fprintln(out, tr("Error: %v", err))
+		return
+	}
+	for i, e := range entries {
+		item := entryItem{entry: e}
+		fmt.Fprintf(out, "%d. %s, %s\n", i+1, item.Title(), item.Description())
+	}
+	fmt.Fprintln(out, tr("%d entries in %s.", len(entries), cfg.Library))
+}
+
+// showAccessible reads out the entry stored under key
+func showAccessible(cfg config, key string, out io.Writer) {
+	entries, err := loadLibrary(cfg.Library)
+	if err != nil {
+		fmt.Fprintln(out, tr("Error: %v", err))
+		return
+	}
+	for _, e := range entries {
+		if e.Key == key {
+			detail := renderDetail(&Work{Entry: e, Citations: -1}, 80)
+			for _, l := range strings.Split(strings.TrimRight(detail, "\n"), "\n") {
+				fmt.Fprintln(out, strings.TrimRight(l, " "))
+			}
+			return
+		}
+	}
+	fmt.Fprintln(out, tr("No entry with key %s.", key))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const airtableAPI = "https://api.airtable.com/v0"
+
+// airtableBatch is the most records Airtable creates per request
+const airtableBatch = 10
+
+// airtableConfig points at the Airtable table entries are exported to
+type airtableConfig struct {
+	// Token is a personal access token with the data.records:write scope
+	Token  string `toml:"token"`
+	BaseID string `toml:"base_id"`
+	// Table is the name or ID of the table in the base
+	Table string `toml:"table"`
+	// Columns maps table fields to entry fields
+	Columns map[string]string `toml:"columns"`
+}
+
+func (a airtableConfig) enabled() bool {
+	return a.Token != "" && a.BaseID != "" && a.Table != ""
+}
+
+func (a airtableConfig) columns() map[string]string {
+	if len(a.Columns) == 0 {
+		return defaultColumns
+	}
+	return a.Columns
+}
+
+// pushAirtable adds a record for every entry to the table. Values are sent
+// as text and converted by Airtable to the type of each field.
+func pushAirtable(a airtableConfig, entries []Entry) error {
+	columns := a.columns()
+	records := make([]map[string]any, 0, len(entries))
+	for _, e := range entries {
+		fields := map[string]string{}
+		for name, field := range columns {
+			if values := columnValues(e, field); len(values) > 0 {
+				fields[name] = strings.Join(values, ", ")
+			}
+		}
+		records = append(records, map[string]any{"fields": fields})
+	}
+	u := fmt.Sprintf("%s/%s/%s", airtableAPI, url.PathEscape(a.BaseID), url.PathEscape(a.Table))
+	h := http.Header{"Authorization": {"Bearer " + a.Token}}
+	for len(records) > 0 {
+		n := min(len(records), airtableBatch)
+		body := map[string]any{"records": records[:n], "typecast": true}
+		if err := sendJSON("airtable", http.MethodPost, u, h, body, nil); err != nil {
+			return err
+		}
+		records = records[n:]
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/library"
+)
+
+// citableKeys returns the keys documents can cite entries by, their
+// aliases included
+func citableKeys(entries []Entry) map[string]bool {
+	keys := library.Keys(entries)
+	for k := range library.Aliases(entries) {
+		keys[k] = true
+	}
+	return keys
+}
+
+// keyTaken refuses renaming the entry old to key when another entry has
+// it as its key or an alias
+func keyTaken(entries []Entry, old, key string) error {
+	for _, e := range entries {
+		if e.Key != old && (e.Key == key || slices.Contains(e.Aliases(), key)) {
+			return errors.New(tr("key %s already exists", key))
+		}
+	}
+	return nil
+}
+
+// aliasTeX writes the aliases of entries as \defbibalias{old}{new} lines,
+// sorted by the old key
+func aliasTeX(entries []Entry) string {
+	aliases := library.Aliases(entries)
+	var b strings.Builder
+	for _, old := range slices.Sorted(maps.Keys(aliases)) {
+		fmt.Fprintf(&b, "\\defbibalias{%s}{%s}\n", old, aliases[old])
+	}
+	return b.String()
+}
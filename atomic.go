@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// lockTimeout is how long a write waits for another bibgloss process to
+// finish with the same file
+const lockTimeout = 10 * time.Second
+
+// writeAtomic replaces path with data through a synced temporary file in the
+// same directory, so a crash leaves either the old or the new content and
+// never a truncated file. The file keeps its permissions. A symlink is
+// followed, the file it points at is replaced.
+func writeAtomic(path string, data []byte) error {
+	path = realPath(path)
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		// renaming would split the file off its other names
+		if linkCount(info) > 1 {
+			return writeInPlace(path, data)
+		}
+	}
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = func() error {
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		if err := f.Chmod(mode); err != nil {
+			return err
+		}
+		return f.Sync()
+	}()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp) // nolint:errcheck
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// realPath follows the symlinks of path, also to a file yet to be created
+func realPath(path string) string {
+	for range 32 {
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			return real
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return path
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return path
+}
+
+// writeInPlace overwrites a file that has hard links, which a crash can
+// leave truncated
+func writeInPlace(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir makes a rename in dir durable. Not every platform can sync a
+// directory, which is no reason to fail the write.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()  // nolint:errcheck
+	d.Close() // nolint:errcheck
+}
+
+var (
+	locksMu sync.Mutex
+	// locks are the files this process holds the lock of, with the number
+	// of callers holding it
+	locks = map[string]*heldLock{}
+)
+
+type heldLock struct {
+	f     *os.File
+	count int
+}
+
+// lockPath returns the lock file of a library or glossary. It lives in the
+// temporary directory so the lock files do not clutter projects.
+func lockPath(path string) string {
+	abs, err := filepath.Abs(realPath(path))
+	if err != nil {
+		abs = path
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(os.TempDir(), "bibgloss-"+hex.EncodeToString(sum[:8])+".lock")
+}
+
+var (
+	mutationsMu sync.Mutex
+	// mutations keep the mutations of a file in this process apart, like
+	// an import and an attach the TUI runs at the same time
+	mutations = map[string]*sync.Mutex{}
+)
+
+// mutationLock returns the lock a mutation of path holds from its snapshot
+// to its last write
+func mutationLock(path string) *sync.Mutex {
+	lp := lockPath(path)
+	mutationsMu.Lock()
+	defer mutationsMu.Unlock()
+	m, ok := mutations[lp]
+	if !ok {
+		m = &sync.Mutex{}
+		mutations[lp] = m
+	}
+	return m
+}
+
+// lockFile takes the write lock of path, shared by every bibgloss process
+// of the machine, and returns the function releasing it. Taking a lock the
+// process already holds only counts, so the writes of a mutation nest in
+// its lock; mutationLock keeps apart goroutines of the process.
+func lockFile(path string) (func(), error) {
+	if dryRun {
+		return func() {}, nil
+	}
+	lp := lockPath(path)
+	locksMu.Lock()
+	defer locksMu.Unlock()
+	if l, ok := locks[lp]; ok {
+		l.count++
+		return func() { unlock(lp) }, nil
+	}
+	f, err := os.OpenFile(lp, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	for deadline := time.Now().Add(lockTimeout); ; {
+		err = tryLock(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLocked) || time.Now().After(deadline) {
+			f.Close() // nolint:errcheck
+			if errors.Is(err, errLocked) {
+				return nil, fmt.Errorf("%s is being written by another bibgloss process", path)
+			}
+			return nil, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	locks[lp] = &heldLock{f: f, count: 1}
+	return func() { unlock(lp) }, nil
+}
+
+func unlock(lp string) {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+	l := locks[lp]
+	if l.count--; l.count > 0 {
+		return
+	}
+	delete(locks, lp)
+	// closing the file releases the lock
+	l.f.Close() // nolint:errcheck
+}
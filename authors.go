@@ -0,0 +1,137 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// nameFields are the name lists whose ORCID iDs are kept in a +ids field
+var nameFields = []string{"author", "editor"}
+
+// orcidAuthor is a person of the library identified by an ORCID iD
+type orcidAuthor struct {
+	ID string
+	// Spellings maps each spelling of the name to the keys of the entries
+	// it is spelled so in
+	Spellings map[string][]string
+}
+
+// entries returns the number of entries naming the person
+func (a orcidAuthor) entries() int {
+	n := 0
+	for _, keys := range a.Spellings {
+		n += len(keys)
+	}
+	return n
+}
+
+// groupAuthors collects the names with an ORCID iD by iD. Those spelled in
+// more than one way come first, then those in the most entries.
+func groupAuthors(entries []Entry) []orcidAuthor {
+	byID := map[string]*orcidAuthor{}
+	for _, e := range entries {
+		for _, field := range nameFields {
+			ids := e.NameIDs(field)
+			for i, name := range splitNames(e.Get(field)) {
+				if ids[i] == "" {
+					continue
+				}
+				a := byID[ids[i]]
+				if a == nil {
+					a = &orcidAuthor{ID: ids[i], Spellings: map[string][]string{}}
+					byID[ids[i]] = a
+				}
+				if !slices.Contains(a.Spellings[name], e.Key) {
+					a.Spellings[name] = append(a.Spellings[name], e.Key)
+				}
+			}
+		}
+	}
+	out := make([]orcidAuthor, 0, len(byID))
+	for _, a := range byID {
+		out = append(out, *a)
+	}
+	slices.SortFunc(out, func(a, b orcidAuthor) int {
+		return cmp.Or(
+			cmp.Compare(len(b.Spellings), len(a.Spellings)),
+			cmp.Compare(b.entries(), a.entries()),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return out
+}
+
+// splitNames splits a name list like bibtex.SplitAuthors, with the whitespace
+// inside names collapsed so that spellings compare equal
+func splitNames(s string) []string {
+	names := bibtex.SplitAuthors(s)
+	for i, n := range names {
+		names[i] = strings.Join(strings.Fields(n), " ")
+	}
+	return names
+}
+
+// writeAuthors lists the iDs with the spellings of their name, marking those
+// spelled in more than one way with a !
+func writeAuthors(w io.Writer, authors []orcidAuthor) {
+	for i, a := range authors {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		mark := ""
+		if len(a.Spellings) > 1 {
+			mark = fmt.Sprintf(" ! spelled %d ways", len(a.Spellings))
+		}
+		fmt.Fprintf(w, "%s%s\n", a.ID, mark)
+		names := slices.Sorted(maps.Keys(a.Spellings))
+		width := 0
+		for _, n := range names {
+			width = max(width, len(n))
+		}
+		for _, n := range names {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, n, strings.Join(a.Spellings[n], " "))
+		}
+	}
+}
+
+// fillAuthorIDs records the iD of a name in the entries that spell it the
+// same way but have no iD for it. Spellings shared by several iDs are left
+// alone. It returns the changed entries by position and the iDs added.
+func fillAuthorIDs(entries []Entry, authors []orcidAuthor) (map[int]*Entry, int) {
+	bySpelling := map[string][]string{}
+	for _, a := range authors {
+		for n := range a.Spellings {
+			bySpelling[n] = append(bySpelling[n], a.ID)
+		}
+	}
+	changes := map[int]*Entry{}
+	added := 0
+	for i, e := range entries {
+		changed := e
+		changed.Fields = slices.Clone(e.Fields)
+		for _, field := range nameFields {
+			if isLocked(&e, field+"+ids") {
+				continue
+			}
+			ids := e.NameIDs(field)
+			filled := false
+			for j, name := range splitNames(e.Get(field)) {
+				if found := bySpelling[name]; ids[j] == "" && len(found) == 1 {
+					ids[j], filled = found[0], true
+					added++
+				}
+			}
+			if filled {
+				changed.SetNameIDs(field, ids)
+				changes[i] = &changed
+			}
+		}
+	}
+	return changes, added
+}
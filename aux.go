@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+var (
+	// auxCiteRe matches BibTeX's \citation{a,b} and biblatex's
+	// \abx@aux@cite{refsection}{key}
+	auxCiteRe = regexp.MustCompile(`\\(?:citation|abx@aux@cite(?:\{[^}]*\})?)\{([^}]*)\}`)
+	// auxBibdataRe matches the bibliographies BibTeX reads
+	auxBibdataRe = regexp.MustCompile(`\\bibdata\{([^}]*)\}`)
+	// auxInputRe matches the aux files of \include'd chapters
+	auxInputRe = regexp.MustCompile(`\\@input\{([^}]*)\}`)
+	// bcfSourceRe matches the bibliographies biber reads from the .bcf
+	bcfSourceRe = regexp.MustCompile(`<bcf:datasource[^>]*datatype="bibtex"[^>]*>([^<]+)</bcf:datasource>`)
+)
+
+// auxFile returns the .aux file of a job name or path
+func auxFile(name string) string {
+	if filepath.Ext(name) == ".aux" {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".aux"
+}
+
+// auxBibliography returns the first .bib file named by \bibdata in the aux
+// data, or by the .bcf biber reads next to it. Names are relative to the
+// directory LaTeX ran in, or to the aux file when it was written elsewhere.
+func auxBibliography(path, data string) (string, error) {
+	var names []string
+	for _, m := range auxBibdataRe.FindAllStringSubmatch(data, -1) {
+		names = append(names, strings.Split(m[1], ",")...)
+	}
+	if len(names) == 0 {
+		bcf, err := os.ReadFile(strings.TrimSuffix(path, ".aux") + ".bcf")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		for _, m := range bcfSourceRe.FindAllStringSubmatch(string(bcf), -1) {
+			names = append(names, m[1])
+		}
+	}
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if filepath.Ext(name) != ".bib" {
+			name += ".bib"
+		}
+		if _, err := os.Stat(name); err != nil {
+			if alt := filepath.Join(filepath.Dir(path), name); alt != name {
+				if _, err := os.Stat(alt); err == nil {
+					name = alt
+				}
+			}
+		}
+		names[i] = name
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	return names[0], nil
+}
+
+// resolveAux fetches the entries cited in a LaTeX run that are missing from
+// its bibliography and whose keys are DOIs. It is meant to run between
+// latex and bibtex or biber: keys it cannot resolve are reported, not
+// failed on, so the build goes on and bibtex reports them as usual.
+func resolveAux(cfg config, name string, out io.Writer) error {
+	path := auxFile(name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, m := range auxInputRe.FindAllStringSubmatch(string(data), -1) {
+		if more, err := os.ReadFile(filepath.Join(filepath.Dir(path), m[1])); err == nil {
+			data = append(data, more...)
+		}
+	}
+	bib, err := auxBibliography(path, string(data))
+	if err != nil {
+		return err
+	}
+	if bib == "" {
+		bib = cfg.Library
+	}
+	// hooks are told the library the entries go into
+	cfg.Library = bib
+	entries, err := loadLibrary(bib)
+	if err != nil {
+		return err
+	}
+	keys := citableKeys(entries)
+	for _, m := range auxCiteRe.FindAllStringSubmatch(string(data), -1) {
+		for _, key := range strings.Split(m[1], ",") {
+			key = strings.TrimSpace(key)
+			if key == "" || key == "*" || keys[key] || !looksLikeDOI(resolve.CleanDOI(key)) {
+				continue
+			}
+			keys[key] = true
+			w, err := fetchCitation(cfg, bib, key)
+			if err != nil {
+				fmt.Fprintf(out, "bibgloss: %s: %v\n", key, err)
+				continue
+			}
+			fmt.Fprintf(out, "bibgloss: added %s to %s\n", key, bib)
+			if err := syncImported(cfg, w.Entry, w.Abstract); err != nil {
+				fmt.Fprintf(out, "bibgloss: %s: %v\n", key, err)
+			}
+		}
+	}
+	return nil
+}
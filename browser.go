@@ -0,0 +1,271 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type libraryMsg struct {
+	entries []Entry
+	undo    *snapshot
+}
+
+// metricsMsg carries the counts recorded by bibgloss enrich
+type metricsMsg struct{ metrics map[string]entryMetrics }
+
+// librarySort is the order of the library list
+type librarySort int
+
+const (
+	sortFile librarySort = iota
+	sortCitations
+	sortReferences
+)
+
+func (s librarySort) String() string {
+	switch s {
+	case sortCitations:
+		return tr("by citations")
+	case sortReferences:
+		return tr("by references")
+	}
+	return ""
+}
+
+// entryItem adapts a library entry to the list component
+type entryItem struct {
+	entry Entry
+	// metrics is nil for entries never enriched
+	metrics *entryMetrics
+}
+
+func (i entryItem) Title() string {
+	if t := i.entry.Get("title"); t != "" {
+		return t
+	}
+	return i.entry.Key
+}
+
+func (i entryItem) Description() string {
+	parts := []string{i.entry.Key}
+	if a := bibtex.SplitAuthors(i.entry.Get("author")); len(a) > 0 {
+		parts = append(parts, a[0])
+	}
+	if y := i.entry.Get("year"); y != "" {
+		parts = append(parts, y)
+	}
+	if i.metrics != nil {
+		parts = append(parts, tr("%d cites", i.metrics.Citations), tr("%d refs", i.metrics.References))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// work wraps the entry for the detail view and the preview
+func (i entryItem) work() *Work {
+	w := &Work{Entry: i.entry, Citations: -1}
+	if i.metrics != nil {
+		w.Citations = i.metrics.Citations
+	}
+	return w
+}
+
+func (i entryItem) FilterValue() string {
+	return i.entry.Key + " " + i.entry.Get("title") + " " + i.entry.Get("author")
+}
+
+func newLibraryList() list.Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 80, 20)
+	l.Title = tr("Library")
+	l.FilterInput.Prompt = tr("Filter: ")
+	l.SetShowHelp(false)
+	l.DisableQuitKeybindings()
+	return l
+}
+
+// loadLibraryCmd reads the library file in the background
+func loadLibraryCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		// what is shown now is what later edits are checked against
+		forgetFile(path)
+		entries, err := loadLibrary(path)
+		if err != nil {
+			return errMsg{err}
+		}
+		return libraryMsg{entries: entries}
+	}
+}
+
+// loadMetricsCmd reads the metrics sidecar of the library in the background
+func loadMetricsCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		metrics, err := loadMetrics(path)
+		if err != nil {
+			return errMsg{err}
+		}
+		return metricsMsg{metrics}
+	}
+}
+
+// entryItems wraps entries for the list component in the given order.
+// Entries without counts sort last.
+func entryItems(entries []Entry, metrics map[string]entryMetrics, order librarySort) []list.Item {
+	items := make([]list.Item, len(entries))
+	for i, e := range entries {
+		item := entryItem{entry: e}
+		if m, ok := metrics[e.Key]; ok {
+			item.metrics = &m
+		}
+		items[i] = item
+	}
+	if order == sortFile {
+		return items
+	}
+	count := func(it list.Item) int {
+		m := it.(entryItem).metrics
+		switch {
+		case m == nil:
+			return -1
+		case order == sortReferences:
+			return m.References
+		}
+		return m.Citations
+	}
+	slices.SortStableFunc(items, func(a, b list.Item) int { return cmp.Compare(count(b), count(a)) })
+	return items
+}
+
+// openLink opens an entry's DOI or URL without blocking the UI
+func openLink(e *Entry) tea.Cmd {
+	return func() tea.Msg {
+		if err := openBrowser(entryLink(e)); err != nil {
+			return errMsg{err}
+		}
+		return nil
+	}
+}
+
+// removeEntry deletes an entry from the library
+func removeEntry(path, key string) tea.Cmd {
+	return func() tea.Msg {
+		s, err := mutate(path, tr("delete %s", key), func() error {
+			return rewriteEntry(path, key, nil)
+		})
+		if err != nil {
+			return errMsg{err}
+		}
+		entries, err := loadLibrary(path)
+		if err != nil {
+			return errMsg{err}
+		}
+		return libraryMsg{entries, s}
+	}
+}
+
+// renameKey changes the citation key of an entry, keeping the old one as an
+// alias, and refuses keys that are already taken
+func renameKey(path string, e Entry, key string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := loadLibrary(path)
+		if err != nil {
+			return errMsg{err}
+		}
+		if err := keyTaken(entries, e.Key, key); err != nil {
+			return errMsg{err}
+		}
+		s, err := mutate(path, tr("rename %s to %s", e.Key, key), func() error {
+			return editEntry(path, e.Key, func(e *Entry) { e.Rename(key) })
+		})
+		if err != nil {
+			return errMsg{err}
+		}
+		if entries, err = loadLibrary(path); err != nil {
+			return errMsg{err}
+		}
+		return libraryMsg{entries, s}
+	}
+}
+
+// refreshList shows the loaded entries matching the current tag filter
+func (m *model) refreshList() tea.Cmd {
+	m.list.Title = tr("Library")
+	if m.cfg.Profile != "" {
+		m.list.Title += " · " + m.cfg.Profile
+	}
+	if m.tagFilter != "" {
+		m.list.Title += " · " + m.tagFilter
+	}
+	if m.sortBy != sortFile {
+		m.list.Title += " · " + m.sortBy.String()
+	}
+	cmd := m.list.SetItems(entryItems(filterByTag(m.entries, m.tagFilter), m.metrics, m.sortBy))
+	m.setPreview()
+	return cmd
+}
+
+// askFor opens the prompt below the library list or detail view
+func (m *model) askFor(p prompt, label, value string) {
+	m.prompt = p
+	m.ask.Prompt = label
+	m.ask.SetValue(value)
+	m.ask.CursorEnd()
+	m.ask.Focus()
+}
+
+// updatePrompt handles key presses while a prompt is open
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.prompt = promptNone
+		return m, nil
+	case "enter":
+		p := m.prompt
+		m.prompt = promptNone
+		m.ask.Blur()
+		switch p {
+		case promptTags:
+			if item, ok := m.list.SelectedItem().(entryItem); ok {
+				e := item.entry
+				tagged := withTags(e, m.ask.Value())
+				return m, reviewEdit(m.cfg.Library, e.Key, &tagged, tr("edit tags of %s", e.Key), saveTags(m.cfg.Library, e, m.ask.Value()))
+			}
+		case promptRename:
+			key := strings.TrimSpace(m.ask.Value())
+			if err := bibtex.ValidKey(key); err != nil {
+				m.err = err
+				return m, nil
+			}
+			if item, ok := m.list.SelectedItem().(entryItem); ok && key != item.entry.Key {
+				old := item.entry.Key
+				renamed := item.entry
+				renamed.Fields = slices.Clone(renamed.Fields)
+				renamed.Rename(key)
+				return m, reviewEdit(m.cfg.Library, old, &renamed, tr("rename %s to %s", old, key), renameKey(m.cfg.Library, item.entry, key))
+			}
+		case promptTagFilter:
+			m.tagFilter = strings.TrimSpace(m.ask.Value())
+			return m, m.refreshList()
+		case promptKey:
+			key := strings.TrimSpace(m.ask.Value())
+			if err := bibtex.ValidKey(key); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.err = nil
+			m.work.Entry.Key = key
+			m.setDetail()
+			return m, onConflict(importWork(m.cfg, m.work))
+		case promptSearch:
+			m.search = m.ask.Value()
+			m.searchDetail(1)
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.ask, cmd = m.ask.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// hookMarker identifies hooks written by bibgloss hook install
+const hookMarker = "# installed by bibgloss hook install"
+
+// hookScript checks what is about to be committed, not the working tree
+const hookScript = `#!/bin/sh
+` + hookMarker + `
+exec bibgloss check --staged
+`
+
+// checkBib runs the pre-commit checks on a bibliography: it must parse, keys
+// must be unique and cited works must not be retracted. Problems fail the
+// check; warnings, like an unreachable CrossRef, do not. open reads the
+// file, retractions are looked up n at a time.
+func checkBib(path string, open func(string) (io.ReadCloser, error), retractions bool, n int) (problems, warnings []string) {
+	r, err := open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}, nil
+	}
+	defer r.Close() // nolint:errcheck
+	// the file is streamed, only keys and DOIs are kept
+	seen := map[string]bool{}
+	var cited []Entry
+	err = bibtex.Each(r, func(e *Entry) error {
+		if seen[e.Key] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate key %s", path, e.Key))
+		}
+		seen[e.Key] = true
+		if doi := e.Get("doi"); doi != "" {
+			cited = append(cited, Entry{Key: e.Key, Fields: []Field{{Name: "doi", Value: doi}}})
+		}
+		return nil
+	})
+	var syntax bibtex.ParseErrors
+	if errors.As(err, &syntax) {
+		for _, e := range syntax {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, e))
+		}
+	} else if err != nil {
+		return append(problems, fmt.Sprintf("%s: %v", path, err)), nil
+	}
+	if !retractions {
+		return problems, nil
+	}
+
+	var mu sync.Mutex
+	failed := 0
+	var lastErr error
+	dupes := len(problems)
+	inParallel(n, cited, func(_ int, e Entry) {
+		doi := e.Get("doi")
+		notice, err := resolve.FetchRetraction(doi)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil && !errors.Is(err, resolve.ErrNotFound):
+			failed++
+			lastErr = err
+		case notice != "":
+			problems = append(problems, fmt.Sprintf("%s: %s (%s) is retracted, see https://doi.org/%s", path, e.Key, doi, notice))
+		}
+	})
+	// lookups finish in any order
+	slices.Sort(problems[dupes:])
+	if failed > 0 {
+		warnings = append(warnings, fmt.Sprintf("%s: retraction check skipped for %d entries: %v", path, failed, lastErr))
+	}
+	return problems, warnings
+}
+
+// stagedBibs lists the .bib files below the working directory that are
+// added or modified in the index
+func stagedBibs() ([]string, error) {
+	out, err := git("", "diff", "--cached", "--name-only", "-z", "--relative", "--diff-filter=ACM", "--", "*.bib")
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(out, func(r rune) bool { return r == 0 }), nil
+}
+
+// openStaged reads a file as it is in the index, the content the next
+// commit gets. A file the index does not have does not exist.
+func openStaged(path string) (io.ReadCloser, error) {
+	spec := ":./" + filepath.ToSlash(path)
+	if _, err := git("", "cat-file", "-e", spec); err != nil {
+		if _, err := git("", "rev-parse", "--git-dir"); err != nil {
+			return nil, err
+		}
+		return nil, fs.ErrNotExist
+	}
+	// unlike git, the blob is not trimmed, so lines keep their numbers
+	data, err := exec.Command("git", "cat-file", "blob", spec).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// hookPath returns where git looks for the pre-commit hook of the
+// repository in the working directory
+func hookPath() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", errors.New("not inside a git repository")
+	}
+	return filepath.Join(strings.TrimSpace(string(out)), "pre-commit"), nil
+}
+
+// installHook writes the pre-commit hook, refusing to replace a hook that
+// was not written by bibgloss unless forced
+func installHook(force bool) (string, error) {
+	path, err := hookPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil && !bytes.Contains(data, []byte(hookMarker)) && !force {
+		return "", fmt.Errorf("%s already exists, use --force to replace it", path)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(hookScript), 0o755)
+}
+
+// uninstallHook removes the pre-commit hook if bibgloss wrote it
+func uninstallHook() (string, error) {
+	path, err := hookPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%s does not exist", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Contains(data, []byte(hookMarker)) {
+		return "", fmt.Errorf("%s was not installed by bibgloss, leaving it alone", path)
+	}
+	return path, os.Remove(path)
+}
+
+// printCheck writes problems and warnings one per line and returns an
+// error if there were problems
+func printCheck(w io.Writer, problems, warnings []string) error {
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	for _, p := range warnings {
+		fmt.Fprintln(w, "warning:", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// completionKindReference is the LSP CompletionItemKind editors show
+// citations with
+const completionKindReference = 18
+
+// completionItem is an LSP completion item, the shape LaTeX Workshop and
+// other TeX editors read completion data in
+type completionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Detail        string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+	// FilterText lets editors match on title and authors, not only the key
+	FilterText string `json:"filterText"`
+}
+
+// citationIndex renders the completion items of a library as indented JSON
+func citationIndex(entries []Entry) ([]byte, error) {
+	items := make([]completionItem, 0, len(entries))
+	for _, e := range entries {
+		title := unbrace.Replace(e.Get("title"))
+		authors := strings.Join(noteAuthors(e), ", ")
+		var doc []string
+		if authors != "" {
+			doc = append(doc, authors)
+		}
+		if y := e.Get("year"); y != "" {
+			doc = append(doc, y)
+		}
+		if v := entryContainer(e); v != "" {
+			doc = append(doc, v)
+		}
+		items = append(items, completionItem{
+			Label:         e.Key,
+			Kind:          completionKindReference,
+			Detail:        title,
+			Documentation: strings.Join(doc, " · "),
+			FilterText:    strings.Join([]string{e.Key, title, authors}, " "),
+		})
+	}
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// writeCitationIndex regenerates the index file of a library, leaving it
+// untouched when nothing changed so editors watching it are not woken
+func writeCitationIndex(path string, entries []Entry) error {
+	data, err := citationIndex(entries)
+	if err != nil {
+		return err
+	}
+	if old, err := readFile(path); err == nil && string(old) == string(data) {
+		return nil
+	}
+	return writeFile(path, data)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// clipboardInterval is how often the clipboard watcher reads the clipboard
+const clipboardInterval = time.Second
+
+// maxClipLength is the longest copied text looked at for an identifier
+const maxClipLength = 300
+
+// clipboardMsg is a reading of the clipboard
+type clipboardMsg struct {
+	text string
+	err  error
+}
+
+// watchClipboard reads the clipboard after clipboardInterval
+func watchClipboard() tea.Cmd {
+	return tea.Tick(clipboardInterval, func(time.Time) tea.Msg {
+		text, err := clipboard.ReadAll()
+		return clipboardMsg{text, err}
+	})
+}
+
+// clipIdentifier returns the DOI or arXiv identifier copied text consists
+// of. Longer text is not looked into, copying a paragraph that cites a DOI
+// is not asking for it.
+func clipIdentifier(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxClipLength || strings.ContainsAny(text, "\r\n") {
+		return "", false
+	}
+	if _, ok := resolve.ArXivDOI(text); ok {
+		return text, true
+	}
+	if doi := resolve.CleanDOI(text); resolve.ValidateDOI(doi) == nil {
+		return doi, true
+	}
+	return "", false
+}
+
+// onClipboard handles a reading of the clipboard: text copied since the
+// last one is queued when it is an identifier. What the clipboard held at
+// start is only remembered.
+func (m *model) onClipboard(msg clipboardMsg) tea.Cmd {
+	if msg.err != nil {
+		m.err = fmt.Errorf("%s: %w", tr("clipboard watcher stopped"), msg.err)
+		return nil
+	}
+	changed := m.clipRead && msg.text != m.clipText
+	m.clipText, m.clipRead = msg.text, true
+	id, ok := clipIdentifier(msg.text)
+	if !changed || !ok {
+		return watchClipboard()
+	}
+	m.message = tr("resolving %s from the clipboard…", id)
+	return tea.Batch(watchClipboard(), m.enqueue(id))
+}
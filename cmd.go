@@ -0,0 +1,2198 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/glossary"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+// annotationUnattended marks commands that write without asking, because
+// nobody is watching when they do
+const annotationUnattended = "unattended"
+
+// settings is the configuration shared by all commands
+type settings struct {
+	config
+	// flags holds the values bound to the command line flags
+	flags      config
+	configFile string
+	// projectFile is the .bibgloss.toml applying in the working directory
+	projectFile string
+}
+
+// load layers the profile picked by --profile or $BIBGLOSS_PROFILE over the
+// config file, and the flags given to cmd over both
+func (s *settings) load(cmd *cobra.Command) error {
+	file, err := loadConfig(s.configFile, s.projectFile)
+	if err != nil {
+		return withCode(exitInvalid, err)
+	}
+	profile := os.Getenv(envPrefix + "PROFILE")
+	if cmd.Flags().Changed("profile") {
+		profile = s.flags.Profile
+	}
+	if file, err = file.withProfile(profile); err != nil {
+		return withCode(exitInvalid, err)
+	}
+	s.config = mergeFlags(file, s.flags, cmd.Flags())
+	return withCode(exitInvalid, s.config.validate())
+}
+
+// newRootCmd builds the command tree. Without a subcommand the TUI is
+// started, or identifiers are resolved plainly when not on a terminal.
+// finish is called with the result of Execute, whether or not it failed.
+func newRootCmd() (root *cobra.Command, finish func(error) error) {
+	s := &settings{flags: defaultConfig(), configFile: configPath(), projectFile: projectPath()}
+	cfg := &s.config
+	var verbosity int
+	var logFile string
+	var noCache bool
+	var timings bool
+	var logs io.Closer
+	var confirm bool
+	// ran is the command that ran, set once the config is loaded
+	var ran *cobra.Command
+	var ranArgs []string
+	root = &cobra.Command{
+		Use:           "bibgloss [identifier...]",
+		Short:         "Bibliography and glossary manager",
+		Args:          cobra.ArbitraryArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if noCache {
+				resolve.CacheDir = ""
+			}
+			tui := !cmd.HasParent() && len(args) == 0 && interactive()
+			var err error
+			if logs, err = setupLogging(verbosity, logFile, tui); err != nil {
+				return err
+			}
+			registerPlugins(pluginsDir())
+			if timings {
+				t := resolve.RecordTimings()
+				// finalizers also run when the command fails
+				cobra.OnFinalize(func() { printTimings(cmd.ErrOrStderr(), t) })
+			}
+			// config init and doctor must work even when the existing file
+			// is broken
+			if cmd.HasParent() && cmd.Parent().Name() == "config" || cmd.Name() == "doctor" {
+				return nil
+			}
+			if err := s.load(cmd); err != nil {
+				return err
+			}
+			ran, ranArgs = cmd, args
+			historyCommand = cmd.CommandPath()
+			resolve.Offline = s.Offline
+			if s.Accessible {
+				plainStyles()
+			}
+			// subcommands stage their changes so they can be reviewed
+			// before anything is written
+			_, unattended := cmd.Annotations[annotationUnattended]
+			if cmd.HasParent() && !unattended && !dryRun && !assumeYes && interactive() {
+				dryRun, confirm = true, true
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 || !interactive() {
+				return runPlain(*cfg, fetchOptions{}, args, os.Stdin, os.Stdout, os.Stderr)
+			}
+			return runTUI(*cfg)
+		},
+	}
+	// finish applies or prints the changes the command staged. It runs when
+	// the command failed too: a batch failing in part keeps what worked and
+	// its exit code.
+	finish = func(runErr error) error {
+		if logs != nil {
+			defer logs.Close() // nolint:errcheck
+		}
+		if ran == nil {
+			return runErr
+		}
+		out, errOut := ran.OutOrStdout(), ran.ErrOrStderr()
+		switch {
+		case confirm && len(staged) > 0:
+			for {
+				err := confirmStaged(ran.InOrStdin(), out)
+				if !errors.Is(err, errReapply) {
+					return errors.Join(runErr, err)
+				}
+				// the command runs again on the files as they are now
+				clear(staged)
+				clear(stagedLabels)
+				afterCommit = nil
+				forgetFiles()
+				dryRun = true
+				runErr = ran.RunE(ran, ranArgs)
+				if len(staged) == 0 {
+					fmt.Fprintln(errOut, "no changes left to apply")
+					return runErr
+				}
+			}
+		case confirm:
+			// nothing to review, what the command started still happens
+			return errors.Join(runErr, runAfterCommit())
+		case dryRun && !confirm:
+			if len(staged) == 0 {
+				fmt.Fprintln(errOut, "dry run: no changes")
+			}
+			for _, s := range afterCommit {
+				fmt.Fprintln(errOut, "dry run: would "+s.label)
+			}
+			return errors.Join(runErr, printStaged(out))
+		}
+		return runErr
+	}
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return withCode(exitInvalid, err)
+	})
+	f := root.PersistentFlags()
+	f.StringVar(&s.configFile, "config", s.configFile, "config file")
+	f.StringVar(&s.flags.Library, "bib", s.flags.Library, "library file entries are imported into")
+	f.StringVar(&s.flags.Profile, "profile", "", "named library of the config's [profiles] to work on")
+	f.StringVar(&s.flags.Papers, "papers", s.flags.Papers, "directory downloaded PDFs are stored in")
+	f.StringVar(&s.flags.Email, "email", s.flags.Email, "contact email sent to Unpaywall")
+	f.StringVar(&s.flags.KeyTemplate, "key-template", s.flags.KeyTemplate, "citation key template")
+	f.StringVar(&s.flags.Format, "format", s.flags.Format, "entry dialect (bibtex or biblatex)")
+	f.CountVarP(&verbosity, "verbose", "v", "log resolver activity (-vv logs every request)")
+	f.StringVar(&logFile, "log-file", "", "write logs to this file instead of stderr")
+	f.BoolVar(&noCache, "no-cache", false, "do not read or write the response cache")
+	f.BoolVar(&timings, "timings", false, "print resolver latencies, cache hits, retries and connections when done")
+	f.BoolVar(&s.flags.Offline, "offline", s.flags.Offline, "answer from the response cache only")
+	f.IntVarP(&s.flags.Concurrency, "concurrency", "j", s.flags.Concurrency, "lookups run in parallel by batch commands, requests to each API stay rate limited")
+	f.BoolVar(&dryRun, "dry-run", false, "print a diff of the changes to the library and glossary instead of writing them")
+	f.BoolVarP(&assumeYes, "yes", "y", false, "write changes without showing them for confirmation")
+	root.Flags().StringVar(&s.flags.Keymap, "keymap", s.flags.Keymap, "key binding profile (default or vim)")
+	root.Flags().StringVar(&s.flags.Theme, "theme", s.flags.Theme, "color theme (default or mono)")
+	root.Flags().StringVar(&s.flags.Language, "language", s.flags.Language, "TUI language (en or de, default from LANG)")
+	root.Flags().BoolVar(&s.flags.Inline, "inline", s.flags.Inline, "run inline and keep fetched BibTeX in the scrollback (ctrl+t toggles)")
+	root.Flags().BoolVar(&s.flags.WatchClipboard, "watch-clipboard", s.flags.WatchClipboard, "resolve DOIs and arXiv identifiers copied to the clipboard and queue them on ctrl+y")
+	f.BoolVar(&s.flags.Accessible, "accessible", s.flags.Accessible, "plain text for screen readers, without colors, spinners or screen drawing")
+
+	root.AddCommand(
+		newFetchCmd(cfg),
+		newLintCmd(cfg),
+		newDedupeCmd(cfg),
+		newShowCmd(s),
+		newSearchCmd(cfg),
+		newStatsCmd(cfg),
+		newUpdateCmd(s),
+		newReferencesCmd(cfg),
+		newRenameCmd(s),
+		newAliasesCmd(cfg),
+		newHistoryCmd(s),
+		newLockCmd(s),
+		newUnlockCmd(s),
+		newGlossaryCmd(cfg),
+		newConfigCmd(s),
+		newDoctorCmd(s),
+		newSelfTestCmd(s),
+		newWatchCmd(cfg),
+		newServeCmd(cfg),
+		newRPCCmd(cfg),
+		newMCPCmd(cfg),
+		newCheckCmd(cfg),
+		newHookCmd(),
+		newZoteroCmd(s),
+		newImportCmd(cfg),
+		newOverleafCmd(cfg),
+		newSyncCmd(cfg),
+		newObsidianCmd(s),
+		newOrgRoamCmd(s),
+		newIndexCmd(cfg),
+		newEnrichCmd(s),
+		newTranslateCmd(s),
+		newFundingCmd(s),
+		newLicensesCmd(s),
+		newAuthorsCmd(s),
+		newFindDOICmd(s),
+		newVenuesCmd(s),
+		newEditionsCmd(s),
+		newHighlightsCmd(s),
+		newPandocCmd(cfg),
+		newExportCmd(s),
+		newResolveAuxCmd(cfg),
+		newFollowCmd(cfg),
+		newStylesCmd(),
+		newCiteCmd(s),
+		newReportCmd(s),
+	)
+	_ = root.RegisterFlagCompletionFunc("keymap", cobra.FixedCompletions([]string{keymapDefault, keymapVim}, cobra.ShellCompDirectiveNoFileComp))
+	_ = root.RegisterFlagCompletionFunc("theme", cobra.FixedCompletions([]string{themeDefault, themeMono}, cobra.ShellCompDirectiveNoFileComp))
+	_ = root.RegisterFlagCompletionFunc("language", cobra.FixedCompletions(languageNames(), cobra.ShellCompDirectiveNoFileComp))
+	_ = root.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		file, err := loadConfig(s.configFile, s.projectFile)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var names []cobra.Completion
+		for _, name := range file.profileNames() {
+			names = append(names, cobra.CompletionWithDesc(name, file.Profiles[name].Library))
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = root.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{bibtex.FormatBibTeX, bibtex.FormatBibLaTeX}, cobra.ShellCompDirectiveNoFileComp))
+	_ = root.MarkPersistentFlagFilename("bib", "bib")
+	_ = root.MarkPersistentFlagFilename("config", "toml")
+	return root, finish
+}
+
+func newConfigCmd(s *settings) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the configuration file",
+	}
+	var force, project bool
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Write a commented default configuration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, template := s.configFile, configTemplate
+			if project {
+				path, template = projectFile, projectTemplate
+			}
+			if err := initConfig(path, template, force); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "wrote", path)
+			return nil
+		},
+	}
+	initCmd.Flags().BoolVar(&force, "force", false, "overwrite an existing file")
+	initCmd.Flags().BoolVar(&project, "project", false, "write the project settings file "+projectFile+" into the working directory instead")
+	path := &cobra.Command{
+		Use:   "path",
+		Short: "Print the location of the configuration file",
+		Long:  "Print the location of the configuration file, followed by the project settings file applying in the working directory when there is one.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintln(cmd.OutOrStdout(), s.configFile)
+			if s.projectFile != "" {
+				fmt.Fprintln(cmd.OutOrStdout(), s.projectFile)
+			}
+		},
+	}
+	env := &cobra.Command{
+		Use:   "env",
+		Short: "List the environment variables overriding settings",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, name := range envNames() {
+				value, set := os.LookupEnv(name)
+				if !set {
+					value = "(unset)"
+				} else if (strings.HasPrefix(name, envPrefix+"API_KEYS_") || strings.HasSuffix(name, "_API_KEY") || strings.HasSuffix(name, "_TOKEN")) && value != "" {
+					value = "(set)"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s=%s\n", name, value)
+			}
+		},
+	}
+	cmd.AddCommand(initCmd, path, env)
+	return cmd
+}
+
+func newDoctorCmd(s *settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the configuration, library, cache and API access",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := s.load(cmd)
+			resolve.Offline = s.Offline
+			checks := runDoctor(s.configFile, s.projectFile, s.config, err)
+			if failed := printChecks(cmd.OutOrStdout(), checks); failed > 0 {
+				return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+			}
+			return nil
+		},
+	}
+}
+
+func newSelfTestCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var record, replay bool
+	var cassette string
+	cmd := &cobra.Command{
+		Use:   "selftest [name...]",
+		Short: "Look up known identifiers with every resolver and check the answers",
+		Long:  "Resolve identifiers whose records are known with each resolver, CrossRef, DataCite, OpenAlex, Unpaywall, Semantic Scholar, OpenLibrary, ORCID, the patent offices and Crossref Event Data, or with those named, and report which answer as expected, with the configured API keys and bypassing the cache. --record stores the responses in a cassette, with keys and email addresses replaced, and --replay answers from it without the network, to tell a change of bibgloss from a change of an API.",
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			var names []cobra.Completion
+			for _, t := range selfTests {
+				names = append(names, t.name)
+			}
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if record && replay {
+				return withCode(exitInvalid, errors.New("--record and --replay exclude each other"))
+			}
+			// the answers of the APIs are checked, not those of the cache
+			cacheDir := resolve.CacheDir
+			resolve.CacheDir = ""
+			defer func() { resolve.CacheDir = cacheDir }()
+			var c *resolve.Cassette
+			switch {
+			case record && resolve.Offline:
+				return withCode(exitInvalid, errors.New("cannot record offline"))
+			case record:
+				c = resolve.RecordCassette(cassette)
+			case replay:
+				var err error
+				if c, err = resolve.ReplayCassette(cassette); err != nil {
+					return withCode(exitInvalid, fmt.Errorf("no cassette to replay, record one with --record: %w", err))
+				}
+			}
+			checks, err := runSelfTests(*cfg, args)
+			if c != nil {
+				if err := c.Close(); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			failed := printChecks(w, checks)
+			if record {
+				fmt.Fprintf(w, "recorded %d responses in %s\n", c.Len(), cassette)
+			}
+			if failed > 0 {
+				return withCode(exitPartial, fmt.Errorf("%d of %d self-tests failed", failed, len(checks)))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&record, "record", false, "record the responses in the cassette")
+	cmd.Flags().BoolVar(&replay, "replay", false, "answer from the cassette instead of the network")
+	cmd.Flags().StringVar(&cassette, "cassette", cassettePath(), "file the responses are recorded in")
+	return cmd
+}
+
+func newWatchCmd(cfg *config) *cobra.Command {
+	var opts watchOptions
+	cmd := &cobra.Command{
+		Use:         "watch [dir]",
+		Short:       "Watch a LaTeX, Quarto or R Markdown project, fetching cited DOIs and re-linting on changes",
+		Long:        "Watch the .tex, .qmd, .Rmd and .bib files of a project. Citation keys that are DOIs, like \\cite{10.1000/xyz} or @10.1000/xyz, are resolved into the library under that key; other missing keys and lint findings are reported with a terminal bell, and with --notify as a desktop notification.",
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) == 1 {
+				dir = args[0]
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			return watchProject(ctx, dir, *cfg, cmd.OutOrStdout(), opts)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		},
+	}
+	cmd.Flags().BoolVar(&opts.Notify, "notify", false, "send desktop notifications")
+	cmd.Flags().StringVar(&opts.Index, "index", "", "keep a citation completion index for editors in this file")
+	cmd.Flags().BoolVar(&opts.ProjectBib, "project-bib", false, "use the bibliography named by \\bibliography or the YAML bibliography setting instead of the library")
+	_ = cmd.MarkFlagFilename("index", "json")
+	return cmd
+}
+
+func newPandocCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "pandoc [format]",
+		Short: "Run as a pandoc JSON filter resolving @doi: citations",
+		Long: `Run as a pandoc JSON filter. Citations like [@doi:10.1000/xyz] are resolved,
+added to the bibliography named in the document's metadata, or the library,
+and rewritten to their keys so citeproc finds them:
+
+  pandoc --filter pandoc-bibgloss --citeproc paper.md -o paper.pdf
+
+with pandoc-bibgloss a symlink to bibgloss, or a script running bibgloss pandoc.`,
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pandocFilter(*cfg, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+}
+
+func newFollowCmd(cfg *config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "follow",
+		Short: "Get alerts about new works citing followed papers",
+		Long:  "Follow papers, yours for example, and get alerts when new works cite them. Alerts are found by follow check or follow watch, posted to follow.webhook and listed in the TUI inbox on ctrl+n.",
+	}
+	add := &cobra.Command{
+		Use:   "add <doi...>",
+		Short: "Follow papers, the works already citing them are not reported",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return followWork(followPath(), args, cfg.resolveOptions())
+		},
+	}
+	remove := &cobra.Command{
+		Use:   "remove <doi...>",
+		Short: "Stop following papers",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return unfollowWork(followPath(), args)
+		},
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the followed papers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := loadFollow(followPath())
+			if err != nil {
+				return err
+			}
+			for _, f := range s.Papers {
+				checked := "never checked"
+				if !f.Checked.IsZero() {
+					checked = "checked " + f.Checked.Format(time.DateTime)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%d citing, %s\n", f.DOI, f.Title, len(f.Seen), checked)
+			}
+			return nil
+		},
+	}
+	check := &cobra.Command{
+		Use:   "check",
+		Short: "Look for new citing works once, e.g. from cron",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFollowCheck(followPath(), *cfg, cmd.OutOrStdout())
+		},
+	}
+	watch := &cobra.Command{
+		Use:         "watch",
+		Short:       "Look for new citing works every follow.interval until stopped",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// validated with the config
+			interval, _ := time.ParseDuration(cfg.Follow.Interval)
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			return followDaemon(ctx, followPath(), *cfg, interval, cmd.OutOrStdout())
+		},
+	}
+	cmd.AddCommand(add, remove, list, check, watch)
+	return cmd
+}
+
+func newStylesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "styles",
+		Short: "Manage the CSL styles bibgloss cite renders with",
+		Long:  "Download citation styles from the official CSL repository into $XDG_DATA_HOME/bibgloss/styles. Names are the file names of the repository, e.g. apa, ieee or nature.",
+	}
+	add := &cobra.Command{
+		Use:   "add <style...>",
+		Short: "Download styles and the parents of dependent ones",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				if err := installStyle(stylesDir(), name, cmd.OutOrStdout()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the installed styles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			styles, err := installedStyles(stylesDir())
+			if err != nil {
+				return err
+			}
+			for _, s := range styles {
+				title := s.Title
+				if s.Parent != "" {
+					title += ", based on " + s.Parent
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", s.Name, title)
+			}
+			return nil
+		},
+	}
+	update := &cobra.Command{
+		Use:   "update",
+		Short: "Download the installed styles again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateStyles(stylesDir(), cmd.OutOrStdout())
+		},
+	}
+	cmd.AddCommand(add, list, update)
+	return cmd
+}
+
+func newCiteCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	var style, to string
+	cmd := &cobra.Command{
+		Use:               "cite <key...>",
+		Short:             "Print formatted references in a CSL style",
+		Long:              "Render entries as a bibliography in the style config or --style names, an installed style or a .csl file, using pandoc's citeproc.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("style") {
+				style = cfg.Style
+			}
+			csl, err := stylePath(stylesDir(), style)
+			if err != nil {
+				return err
+			}
+			out, err := renderCitations(csl, entries, to)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(cmd.OutOrStdout(), out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&style, "style", "", "CSL style name or .csl file instead of the configured one")
+	cmd.Flags().StringVar(&to, "to", "plain", "pandoc output format, e.g. plain, markdown or html")
+	cmd.Flags().BoolVar(&all, "all", false, "format every entry of the library")
+	_ = cmd.RegisterFlagCompletionFunc("style", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		styles, _ := installedStyles(stylesDir())
+		var names []cobra.Completion
+		for _, s := range styles {
+			names = append(names, s.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func newReportCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	var tags []string
+	var output string
+	opts := reportOptions{Title: "Reading list"}
+	cmd := &cobra.Command{
+		Use:               "report <key...>",
+		Short:             "Write a Markdown reading list with notes, grouped by tag",
+		Long:              "Write library entries as a Markdown report with a section per tag, entries sorted by year, and the reader's notes: the annote field and the Notes section of the Obsidian or org-roam note. pandoc turns it into a PDF: pandoc report.md -o report.pdf",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all || len(tags) > 0 && len(args) == 0)
+			if err != nil {
+				return err
+			}
+			if len(tags) > 0 {
+				entries = slices.DeleteFunc(entries, func(e Entry) bool {
+					return !slices.ContainsFunc(tags, func(t string) bool { return hasTag(&e, t) })
+				})
+				if len(entries) == 0 {
+					return withCode(exitNotFound, fmt.Errorf("no entries tagged %s", strings.Join(tags, " or ")))
+				}
+			}
+			report, err := renderReport(*cfg, entries, opts)
+			if err != nil {
+				return err
+			}
+			if output != "" && output != "-" {
+				return writeFile(output, []byte(report))
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), report)
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library")
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "only entries with one of these tags, all of them when no key is given")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the report to a file instead of stdout")
+	cmd.Flags().StringVar(&opts.Title, "title", opts.Title, "title of the report")
+	cmd.Flags().BoolVar(&opts.Abstracts, "abstracts", false, "quote the abstract of every entry and its translation")
+	_ = cmd.MarkFlagFilename("output", "md")
+	return cmd
+}
+
+func newResolveAuxCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resolve-aux <job[.aux]>",
+		Short: "Fetch cited DOIs missing from the bibliography of a LaTeX run",
+		Long: `Read the .aux file of a LaTeX run and add the cited keys that are DOIs but
+missing from the bibliography named by \bibdata, or by the .bcf for biber.
+Keys that cannot be resolved are reported and the command still succeeds, so
+it fits between latex and bibtex, e.g. in .latexmkrc:
+
+  $bibtex = 'bibgloss resolve-aux %B && bibtex %O %B';
+  $biber = 'bibgloss resolve-aux %B && biber %O %B';`,
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return resolveAux(*cfg, args[0], cmd.ErrOrStderr())
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+			return []cobra.Completion{"aux"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+	}
+}
+
+func newIndexCmd(cfg *config) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Print a citation completion index for TeX editors",
+		Long:  "Print the keys, titles and authors of the library as a JSON array of LSP completion items, the shape LaTeX Workshop and other editors read. watch --index keeps such a file current.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := loadLibrary(cfg.Library)
+			if err != nil {
+				return err
+			}
+			if output != "" && output != "-" {
+				return writeCitationIndex(output, entries)
+			}
+			data, err := citationIndex(entries)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the index to a file instead of stdout")
+	_ = cmd.MarkFlagFilename("output", "json")
+	return cmd
+}
+
+func newEnrichCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	cmd := &cobra.Command{
+		Use:               "enrich <key...>",
+		Short:             "Record current citation and reference counts from OpenAlex",
+		Long:              "Look up how often the entries are cited and how many works they reference on OpenAlex or Semantic Scholar, whichever is less busy, and keep the counts in a sidecar next to the library, refs.bib -> refs.metrics.json. The library itself is not changed; the browser shows the counts and sorts by them with s.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			updated, err := enrichMetrics(*cfg, entries)
+			fmt.Fprintf(cmd.OutOrStdout(), "enriched %d entries\n", updated)
+			if err != nil && updated > 0 {
+				return withCode(exitPartial, err)
+			}
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "enrich every entry of the library")
+	return cmd
+}
+
+func newTranslateCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all, force bool
+	cmd := &cobra.Command{
+		Use:               "translate <key...>",
+		Short:             "Translate abstracts in other languages for reading",
+		Long:              "Send the abstracts of the entries to the service in [translate] of the config, DeepL or a LibreTranslate server, and keep the translations in a sidecar next to the library, refs.bib -> refs.annotations.json. The library and the bibliographies made from it are not changed. Abstracts already in translate.to are skipped, and translations made before are printed again unless --force asks for new ones. bibgloss report --abstracts quotes them below the abstract.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			outcomes, err := translateAbstracts(*cfg, entries, force)
+			translated := 0
+			for _, o := range outcomes {
+				if o.Skipped != "" {
+					if !all {
+						fmt.Fprintf(cmd.ErrOrStderr(), "%s: skipped, %s\n", o.Key, o.Skipped)
+					}
+					continue
+				}
+				translated++
+				fmt.Fprintf(cmd.OutOrStdout(), "%s (%s → %s)\n%s\n\n", o.Key, cmp.Or(o.From, "?"), o.To, o.Translation)
+			}
+			if err != nil && translated > 0 {
+				return withCode(exitPartial, err)
+			}
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "translate every entry of the library")
+	cmd.Flags().BoolVar(&force, "force", false, "translate again those translated before")
+	return cmd
+}
+
+func newFundingCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	var orcid string
+	cmd := &cobra.Command{
+		Use:               "funding <key...>",
+		Short:             "Summarize the funders and awards of works for acknowledgments",
+		Long:              "Collect the funders and award numbers CrossRef records for the entries, or for the works on an ORCID record with --orcid, and print an acknowledgments sentence followed by a Markdown table of the funders.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var works []fundedWork
+			if orcid != "" {
+				dois, err := resolve.FetchORCIDWorks(orcid)
+				if errors.Is(err, resolve.ErrInvalid) {
+					return withCode(exitInvalid, err)
+				}
+				if err != nil {
+					return err
+				}
+				for _, doi := range dois {
+					works = append(works, fundedWork{doi, doi})
+				}
+			} else {
+				entries, err := selectEntries(cfg.Library, args, all)
+				if err != nil {
+					return err
+				}
+				for _, e := range entries {
+					if doi := e.Get("doi"); doi != "" {
+						works = append(works, fundedWork{e.Key, doi})
+					}
+				}
+			}
+			funders, err := collectFunding(cfg.Concurrency, works)
+			if len(funders) == 0 {
+				if err != nil {
+					return err
+				}
+				return withCode(exitNotFound, fmt.Errorf("no funders recorded for the %d works with a DOI", len(works)))
+			}
+			out := cmd.OutOrStdout()
+			writeAcknowledgments(out, funders)
+			fmt.Fprintln(out)
+			writeFundingTable(out, funders)
+			if err != nil {
+				return withCode(exitPartial, err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library")
+	cmd.Flags().StringVar(&orcid, "orcid", "", "the works on this ORCID record instead of library entries")
+	return cmd
+}
+
+func newLicensesCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	cmd := &cobra.Command{
+		Use:               "licenses <key...>",
+		Short:             "List the cited software and datasets by license",
+		Long:              "Group the software and dataset entries by the license in their license field. Entries without one are looked up at DataCite by their DOI, or at GitHub by their url, and marked with a *.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			licensed, err := entryLicenses(entries)
+			if len(licensed) == 0 && err == nil {
+				return withCode(exitNotFound, errors.New("no software or dataset entries"))
+			}
+			writeLicenses(cmd.OutOrStdout(), licensed)
+			if err != nil {
+				return withCode(exitPartial, err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library")
+	return cmd
+}
+
+func newAuthorsCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all, fill bool
+	cmd := &cobra.Command{
+		Use:               "authors <key...>",
+		Short:             "Group entries by the ORCID iDs of their authors",
+		Long:              "List the ORCID iDs recorded in author+ids and editor+ids fields with each spelling of the name and the entries using it. iDs whose name is spelled in more than one way come first, marked with a !. With --fill, names spelled like one with an iD get that iD recorded as well.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			authors := groupAuthors(entries)
+			if len(authors) == 0 {
+				return withCode(exitNotFound, errors.New("no ORCID iDs recorded, bibgloss update <key> fetches them"))
+			}
+			writeAuthors(cmd.OutOrStdout(), authors)
+			if !fill {
+				return nil
+			}
+			added := 0
+			_, err = mutate(cfg.Library, "fill ORCID iDs", func() error {
+				// the whole library is filled, not only the selected entries
+				lib, err := loadLibrary(cfg.Library)
+				if err != nil {
+					return err
+				}
+				var changes map[int]*Entry
+				if changes, added = fillAuthorIDs(lib, authors); len(changes) == 0 {
+					return nil
+				}
+				return editLibrary(cfg.Library, changes)
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "\nrecorded %d ORCID iDs\n", added)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library")
+	cmd.Flags().BoolVar(&fill, "fill", false, "record the iD of names spelled like one with an iD")
+	return cmd
+}
+
+func newFindDOICmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	var minScore float64
+	cmd := &cobra.Command{
+		Use:               "find-doi <key...>",
+		Short:             "Look up the DOIs of entries without one",
+		Long:              "Search CrossRef by the title, year and first author of entries without a DOI and show the best match with its confidence from 0 to 1. Matches scoring at least --min-score fill in the doi field, shown for confirmation like other changes.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			matches, err := findDOIs(cfg.Concurrency, entries)
+			if len(matches) == 0 && err == nil {
+				return withCode(exitNotFound, errors.New("no entries without a DOI"))
+			}
+			writeDOIMatches(cmd.OutOrStdout(), matches, minScore)
+			filled := 0
+			if _, werr := mutate(cfg.Library, "find DOIs", func() error {
+				lib, err := loadLibrary(cfg.Library)
+				if err != nil {
+					return err
+				}
+				changes := doiChanges(lib, matches, minScore)
+				if filled = len(changes); filled == 0 {
+					return nil
+				}
+				return editLibrary(cfg.Library, changes)
+			}); werr != nil {
+				return werr
+			}
+			switch {
+			case err != nil:
+				return withCode(exitPartial, err)
+			case filled == 0:
+				return withCode(exitNotFound, fmt.Errorf("no match scored %.2f or more", minScore))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library")
+	cmd.Flags().Float64Var(&minScore, "min-score", 0.8, "lowest confidence from 0 to 1 a match needs to be filled in")
+	return cmd
+}
+
+func newVenuesCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	cmd := &cobra.Command{
+		Use:               "venues <key...>",
+		Short:             "Normalize the conference names of entries",
+		Long:              "Replace the booktitle of proceedings by the canonical name of their conference and fill in its short series name. The built-in table of venues is extended and overridden by [[venues]] tables of the configuration; fetched entries are normalized the same way.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selected, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			keys := map[string]bool{}
+			for _, e := range selected {
+				keys[e.Key] = true
+			}
+			changed := 0
+			if _, err := mutate(cfg.Library, "normalize venues", func() error {
+				lib, err := loadLibrary(cfg.Library)
+				if err != nil {
+					return err
+				}
+				changes := venueChanges(lib, cfg.venues())
+				for i := range changes {
+					if !keys[lib[i].Key] {
+						delete(changes, i)
+					}
+				}
+				writeVenueChanges(cmd.OutOrStdout(), lib, changes)
+				if changed = len(changes); changed == 0 {
+					return nil
+				}
+				return editLibrary(cfg.Library, changes)
+			}); err != nil {
+				return err
+			}
+			if changed == 0 {
+				return withCode(exitNotFound, errors.New("no booktitle to normalize"))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library")
+	return cmd
+}
+
+func newEditionsCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	cmd := &cobra.Command{
+		Use:               "editions <key...>",
+		Short:             "Check cited books for newer editions",
+		Long:              "Look up the editions of @book entries on OpenLibrary, by their ISBN or else their title and first author, or on Google Books when OpenLibrary does not know them, and list the books with a later numbered edition than the one cited, with its year and ISBN. Reprints and translations do not count. The library is left as it is.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			checks, err := checkEditions(cfg.Concurrency, entries)
+			if len(checks) == 0 && err == nil {
+				return withCode(exitNotFound, errors.New("no books to check"))
+			}
+			outdated := writeEditionChecks(cmd.OutOrStdout(), checks)
+			switch {
+			case err != nil:
+				return withCode(exitPartial, err)
+			case outdated == 0:
+				return withCode(exitNotFound, fmt.Errorf("no newer editions of %d books found", len(checks)))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library")
+	return cmd
+}
+
+func newHighlightsCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var all, notes bool
+	cmd := &cobra.Command{
+		Use:               "highlights <key...>",
+		Short:             "Extract the highlights and notes of entries' PDFs",
+		Long:              "Read the annotations of the PDFs in the file field of entries and print them as Markdown: highlights, underlines and the like as quotes, notes as list items, each with its page. What a highlight quotes is what the PDF reader stored with it, the highlighted text for most readers or the comment on it for some. With --notes they go into the Highlights section of the entries' Obsidian and org-roam notes instead, which are created when missing; the rest of a note is left as it is.",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if notes && !cfg.Obsidian.enabled() && !cfg.OrgRoam.enabled() {
+				return withCode(exitInvalid, errors.New("set obsidian.vault or org_roam.directory in the config"))
+			}
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			var errs []error
+			var missing []string
+			found := 0
+			for _, e := range entries {
+				anns, err := pdfHighlights(cfg.Library, &e)
+				switch {
+				case errors.Is(err, errNoPDF):
+					if !all {
+						missing = append(missing, e.Key)
+					}
+					continue
+				case err != nil:
+					errs = append(errs, fmt.Errorf("%s: %w", e.Key, err))
+					continue
+				case len(anns) == 0:
+					continue
+				}
+				found++
+				if notes {
+					written, err := writeHighlights(*cfg, e, anns)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("%s: %w", e.Key, err))
+					}
+					for _, path := range written {
+						fmt.Fprintf(w, "%s  %d annotations -> %s\n", e.Key, len(anns), path)
+					}
+					continue
+				}
+				if len(entries) > 1 {
+					fmt.Fprintf(w, "## %s\n\n%s\n\n", e.Key, renderHighlights(anns, false))
+					continue
+				}
+				fmt.Fprintln(w, renderHighlights(anns, false))
+			}
+			switch {
+			case len(errs) > 0:
+				return withCode(exitPartial, errors.Join(errs...))
+			case found == 0 && len(missing) > 0:
+				return withCode(exitNotFound, fmt.Errorf("no PDF in the file field of %s", strings.Join(missing, ", ")))
+			case found == 0:
+				return withCode(exitNotFound, errors.New("no annotations found"))
+			case len(missing) > 0:
+				return withCode(exitPartial, fmt.Errorf("no PDF in the file field of %s", strings.Join(missing, ", ")))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "every entry of the library with a PDF")
+	cmd.Flags().BoolVar(&notes, "notes", false, "write them into the entries' notes")
+	return cmd
+}
+
+func newServeCmd(cfg *config) *cobra.Command {
+	var addr string
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve resolving and library search over HTTP",
+		Long: `Serve a read-only HTTP API:
+
+  GET  /resolve/{doi}?format=bibtex|biblatex|json
+  POST /batch            {"identifiers": [...]} or one identifier per line
+  GET  /library/search?q=...
+  GET  /library/{key}`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			srv := &http.Server{
+				Addr:              addr,
+				Handler:           newServer(*cfg),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			go func() {
+				<-ctx.Done()
+				srv.Shutdown(context.Background()) // nolint:errcheck
+			}()
+			fmt.Fprintf(cmd.ErrOrStderr(), "listening on http://%s\n", addr)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	return cmd
+}
+
+func newRPCCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rpc",
+		Short: "Answer JSON-RPC requests on stdin for editor integrations",
+		Long: `Answer JSON-RPC 2.0 requests on stdin, framed with Content-Length headers
+like the language server protocol. Methods:
+
+  resolve    {"identifier", "format"}  the record of a work
+  search     {"query"}                 matching library entries
+  insertKey  {"identifier"}            the key to cite, importing the work if needed
+  lint       {"file"}                  findings for the library or file
+  shutdown, exit
+
+Failures have the error code -32000 minus the exit code of the CLI.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return newRPCServer(*cfg, cmd.OutOrStdout()).serve(cmd.InOrStdin())
+		},
+	}
+}
+
+func newMCPCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "mcp",
+		Short: "Run a Model Context Protocol server on stdio for AI assistants",
+		Long: `Run a Model Context Protocol server on stdio. Assistants get the tools
+resolve_identifier, search_papers, search_library and append_to_library, so
+citations they insert come from the resolvers instead of from memory.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return newMCPServer(*cfg, cmd.OutOrStdout()).serve(cmd.InOrStdin())
+		},
+	}
+}
+
+func newCheckCmd(cfg *config) *cobra.Command {
+	var noRetractions, fromIndex bool
+	cmd := &cobra.Command{
+		Use:               "check [file.bib...]",
+		Short:             "Validate bibliographies for a pre-commit hook",
+		Long:              "Fail if a bibliography does not parse, has duplicate keys or cites retracted work. Output is one line per problem. Problems reaching CrossRef are warnings and do not fail the check.\n\nWith --staged the files are checked as they are staged in git, by default every staged .bib file, which is what the pre-commit hook does.",
+		ValidArgsFunction: completeBibFiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			open := openLibrary
+			switch {
+			case fromIndex:
+				open = openStaged
+				if len(args) == 0 {
+					var err error
+					if args, err = stagedBibs(); err != nil {
+						return err
+					}
+				}
+			case len(args) == 0:
+				args = []string{cfg.Library}
+			}
+			var problems, warnings []string
+			for _, path := range args {
+				p, w := checkBib(path, open, !noRetractions, cfg.Concurrency)
+				problems = append(problems, p...)
+				warnings = append(warnings, w...)
+			}
+			return printCheck(cmd.OutOrStdout(), problems, warnings)
+		},
+	}
+	cmd.Flags().BoolVar(&noRetractions, "no-retractions", false, "skip looking up retractions on CrossRef")
+	cmd.Flags().BoolVar(&fromIndex, "staged", false, "check the files as staged in git instead of the working tree")
+	return cmd
+}
+
+func newHookCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Manage the git pre-commit hook running bibgloss check",
+	}
+	var force bool
+	install := &cobra.Command{
+		Use:   "install",
+		Short: "Install a pre-commit hook checking staged .bib files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := installHook(force)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "installed", path)
+			return nil
+		},
+	}
+	install.Flags().BoolVar(&force, "force", false, "replace an existing hook")
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the pre-commit hook",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := uninstallHook()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "removed", path)
+			return nil
+		},
+	}
+	cmd.AddCommand(install, uninstall)
+	return cmd
+}
+
+func newObsidianCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	cmd := &cobra.Command{
+		Use:   "obsidian",
+		Short: "Write literature notes into an Obsidian vault",
+	}
+	cmd.AddCommand(newNotesCmd(s, func() error {
+		if !cfg.Obsidian.enabled() {
+			return withCode(exitInvalid, errors.New("set obsidian.vault in the config"))
+		}
+		return nil
+	}, func(e Entry) (bool, error) {
+		return writeNote(notePath(cfg.Obsidian, e), literatureNote(cfg.Obsidian, e, ""))
+	}))
+	return cmd
+}
+
+func newOrgRoamCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	enabled := func() error {
+		if !cfg.OrgRoam.enabled() {
+			return withCode(exitInvalid, errors.New("set org_roam.directory in the config"))
+		}
+		return nil
+	}
+	cmd := &cobra.Command{
+		Use:   "org-roam",
+		Short: "Write org notes for citar and org-roam-bibtex",
+	}
+	elisp := &cobra.Command{
+		Use:   "elisp",
+		Short: "Print the Emacs settings pointing citar and org-roam-bibtex at the library",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := enabled(); err != nil {
+				return err
+			}
+			return printElisp(cmd.OutOrStdout(), *cfg)
+		},
+	}
+	cmd.AddCommand(newNotesCmd(s, enabled, func(e Entry) (bool, error) {
+		return writeNote(orgNotePath(cfg.OrgRoam, e), orgNote(cfg.OrgRoam, e, ""))
+	}), elisp)
+	return cmd
+}
+
+// newNotesCmd writes notes for library entries with write, once enabled
+// confirms the notes are configured
+func newNotesCmd(s *settings, enabled func() error, write func(Entry) (bool, error)) *cobra.Command {
+	cfg := &s.config
+	var all bool
+	cmd := &cobra.Command{
+		Use:               "notes <key...>",
+		Short:             "Write the notes of library entries, keeping existing ones",
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := enabled(); err != nil {
+				return err
+			}
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			written := 0
+			for _, e := range entries {
+				wrote, err := write(e)
+				if err != nil {
+					return err
+				}
+				if wrote {
+					written++
+				}
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "wrote %d notes, %d existed\n", written, len(entries)-written)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "write notes for every entry of the library")
+	return cmd
+}
+
+func newExportCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Add library entries to a Notion or Airtable reading list",
+	}
+	target := func(name, short, missing string, enabled func() bool, push func([]Entry) error) *cobra.Command {
+		var all bool
+		c := &cobra.Command{
+			Use:               name + " <key...>",
+			Short:             short,
+			ValidArgsFunction: completeKeys(s),
+			Annotations:       map[string]string{annotationUnattended: ""},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if !enabled() {
+					return withCode(exitInvalid, errors.New(missing))
+				}
+				entries, err := selectEntries(cfg.Library, args, all)
+				if err != nil {
+					return err
+				}
+				if dryRun {
+					for _, e := range entries {
+						fmt.Fprintf(cmd.OutOrStdout(), "would export %s\n", e.Key)
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "would export %d entries to %s\n", len(entries), name)
+					return nil
+				}
+				if err := push(entries); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "exported %d entries to %s\n", len(entries), name)
+				return nil
+			},
+		}
+		c.Flags().BoolVar(&all, "all", false, "export every entry of the library")
+		return c
+	}
+	cmd.AddCommand(
+		target("notion", "Add a page per entry to a Notion database",
+			"set notion.token and notion.database_id in the config",
+			func() bool { return cfg.Notion.enabled() },
+			func(entries []Entry) error { return pushNotion(cfg.Notion, entries) }),
+		target("airtable", "Add a record per entry to an Airtable table",
+			"set airtable.token, airtable.base_id and airtable.table in the config",
+			func() bool { return cfg.Airtable.enabled() },
+			func(entries []Entry) error { return pushAirtable(cfg.Airtable, entries) }),
+	)
+	return cmd
+}
+
+func newSyncCmd(cfg *config) *cobra.Command {
+	var opts syncOptions
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Merge the library with a team's shared one over git",
+		Long: `Pull the shared library from the git remote in [sync] of the config, merge the
+entries added, changed or deleted here since the last sync into it and push
+the result back, then write it to the library. Entries are merged one by one,
+not line by line: fields changed on one side are taken from it, a field
+changed on both keeps the value here, and an entry added here that another
+member added too, by DOI or by title and year, is merged into theirs and
+leaves its key as an alias. --dry-run shows the merge without pushing it.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return syncLibrary(*cfg, opts, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().BoolVar(&opts.NoPush, "no-push", false, "commit the merge in the clone without pushing it")
+	return cmd
+}
+
+func newOverleafCmd(cfg *config) *cobra.Command {
+	var opts overleafOptions
+	cmd := &cobra.Command{
+		Use:   "overleaf <project>",
+		Short: "Add the citations an Overleaf project is missing to its bibliography",
+		Long: `Clone an Overleaf project over git, or pull an existing clone, and audit its
+citations. Keys missing from the project's .bib are copied from the library,
+or resolved when they are DOIs, then committed and pushed back. The project is
+given by its id, its editor URL or a git remote; Overleaf asks for a git
+authentication token as the password.`,
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{annotationUnattended: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return syncOverleaf(*cfg, args[0], opts, cmd.OutOrStdout())
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&opts.Dir, "dir", "", "local clone of the project (default the project id)")
+	f.StringVar(&opts.Bib, "file", "", "project bibliography, relative to the clone (default from \\bibliography)")
+	f.BoolVar(&opts.NoPush, "no-push", false, "commit the added entries without pushing")
+	_ = cmd.MarkFlagDirname("dir")
+	_ = cmd.MarkFlagFilename("file", "bib")
+	return cmd
+}
+
+func newZoteroCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	cmd := &cobra.Command{
+		Use:   "zotero",
+		Short: "Mirror library entries to Zotero",
+	}
+	var all bool
+	push := &cobra.Command{
+		Use:               "push <key...>",
+		Short:             "Create Zotero items for library entries, tags become collections",
+		Long:              "Create Zotero items for library entries, putting each into the collections named like its tags. Entries Zotero has an item of already, by DOI or citation key, are left out. --dry-run lists the items that would be created without creating them.",
+		Annotations:       map[string]string{annotationUnattended: ""},
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cfg.Zotero.enabled() {
+				return withCode(exitInvalid, errors.New("set zotero.api_key and zotero.library_id in the config"))
+			}
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				missing, err := zoteroMissing(cfg.Zotero, entries)
+				if err != nil {
+					return err
+				}
+				for _, e := range missing {
+					fmt.Fprintf(cmd.OutOrStdout(), "would create a Zotero item for %s\n", e.Key)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "would create %d Zotero items, %d are there already\n", len(missing), len(entries)-len(missing))
+				return nil
+			}
+			created, err := pushZotero(cfg.Zotero, entries)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "created %d Zotero items, %d were there already\n", created, len(entries)-created)
+			return nil
+		},
+	}
+	push.Flags().BoolVar(&all, "all", false, "push every entry of the library")
+	cmd.AddCommand(push)
+	return cmd
+}
+
+func newImportCmd(cfg *config) *cobra.Command {
+	var from string
+	cmd := &cobra.Command{
+		Use:   "import <export>",
+		Short: "Merge an export of another reference manager into the library",
+		Long:  "Merge an export of another reference manager into the library: Zotero Better BibTeX or JabRef .bib files, Mendeley or EndNote .ris files and EndNote .xml files. Exported citation keys are kept, entries without one get a key from the key template. Works already in the library are skipped and attachment paths in file fields are rewritten relative to the library.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if from == "" {
+				var err error
+				if from, err = importerFor(path); err != nil {
+					return err
+				}
+			}
+			read, ok := importers[from]
+			if !ok {
+				return withCode(exitInvalid, fmt.Errorf("unknown import format %q", from))
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close() // nolint:errcheck
+			entries, err := read(f)
+			// the entries around a syntax error are still imported
+			var syntax bibtex.ParseErrors
+			if errors.As(err, &syntax) {
+				err = nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			res, err := importEntries(*cfg, entries, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			for _, e := range syntax {
+				res.Skipped = append(res.Skipped, fmt.Sprintf("%s: %v", path, e))
+			}
+			for _, s := range res.Skipped {
+				fmt.Fprintln(cmd.ErrOrStderr(), "skipped", s)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "added %d, merged %d, skipped %d entries\n", res.Added, res.Merged, len(res.Skipped))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "export format, guessed from the extension by default")
+	_ = cmd.RegisterFlagCompletionFunc("from", func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		var names []cobra.Completion
+		for name := range importers {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// completeKeys completes citation keys from the configured library
+func completeKeys(s *settings) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		// completion skips the pre-run hooks that load the config
+		if err := s.load(cmd); err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		x, err := openIndex(s.Library)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var keys []cobra.Completion
+		for _, e := range x.Entries {
+			if strings.HasPrefix(e.Key, toComplete) {
+				keys = append(keys, cobra.CompletionWithDesc(e.Key, e.Get("title")))
+			}
+		}
+		return keys, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeBibFiles limits file completion to .bib files
+func completeBibFiles(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return []cobra.Completion{"bib"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// selectEntries returns the entries of the library under keys, or all of
+// them
+func selectEntries(path string, keys []string, all bool) ([]Entry, error) {
+	if len(keys) == 0 && !all {
+		return nil, withCode(exitInvalid, errors.New("no key given, use --all for the whole library"))
+	}
+	var entries []Entry
+	if all {
+		var err error
+		if entries, err = loadLibrary(path); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range keys {
+		e, err := findEntry(path, key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// findEntry returns the library entry stored under key
+func findEntry(path, key string) (Entry, error) {
+	x, err := openIndex(path)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, e := range x.Entries {
+		if e.Key == key {
+			return e, nil
+		}
+	}
+	// a key renamed or merged away finds the entry that has it as an alias
+	for _, e := range x.Entries {
+		if slices.Contains(e.Aliases(), key) {
+			return e, nil
+		}
+	}
+	return Entry{}, withCode(exitNotFound, fmt.Errorf("%s: no entry with key %s", path, key))
+}
+
+func newShowCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	return &cobra.Command{
+		Use:               "show <key>",
+		Short:             "Print a library entry",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := findEntry(cfg.Library, args[0])
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), e.BibTeX())
+			return err
+		},
+	}
+}
+
+func newReferencesCmd(cfg *config) *cobra.Command {
+	var picks string
+	cmd := &cobra.Command{
+		Use:   "references <doi>",
+		Short: "List the reference list of a paper and import works from it",
+		Long:  "List the works a paper cites, numbered in the order of its reference list as deposited with CrossRef, or from OpenAlex and Semantic Scholar when CrossRef has none. --import takes numbers and ranges like 1,3-7, or all, and imports those works that are not in the library yet.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			doi := resolve.CleanDOI(args[0])
+			if d, ok := resolve.ArXivDOI(args[0]); ok {
+				doi = d
+			}
+			papers, err := referenceList(doi, cfg.resolveOptions())
+			if err != nil {
+				return err
+			}
+			if len(papers) == 0 {
+				return withCode(exitNotFound, fmt.Errorf("no references with a DOI found for %s", doi))
+			}
+			entries, err := loadLibrary(cfg.Library)
+			if err != nil {
+				return err
+			}
+			refs := make([]relatedPaper, len(papers))
+			for i, p := range papers {
+				refs[i] = relatedPaper{p, true}
+			}
+			items := relatedItems(refs, entries, nil)
+			out := cmd.OutOrStdout()
+			if picks == "" {
+				for i, it := range items {
+					item := it.(relatedItem)
+					line := fmt.Sprintf("%3d  %s  %s", i+1, item.paper.DOI, item.Title())
+					if item.paper.Year > 0 {
+						line += fmt.Sprintf(" (%d)", item.paper.Year)
+					}
+					if item.have != "" {
+						line += " [" + item.have + "]"
+					}
+					fmt.Fprintln(out, line)
+				}
+				return nil
+			}
+			chosen, err := parsePicks(picks, len(items))
+			if err != nil {
+				return withCode(exitInvalid, err)
+			}
+			failed := 0
+			for _, n := range chosen {
+				item := items[n-1].(relatedItem)
+				if item.have != "" {
+					fmt.Fprintf(cmd.ErrOrStderr(), "skipped %s, in the library as %s\n", item.paper.DOI, item.have)
+					continue
+				}
+				switch msg := importDOI(*cfg, item.paper.DOI)().(type) {
+				case importedMsg:
+					fmt.Fprintf(out, "added %s\n", msg.key)
+					if len(msg.similar) > 0 {
+						fmt.Fprintf(cmd.ErrOrStderr(), "bibgloss: %s: the library has a similar title as %s\n", msg.key, joinList(msg.similar))
+					}
+					if msg.synced != nil {
+						fmt.Fprintf(cmd.ErrOrStderr(), "bibgloss: %s: %v\n", msg.key, msg.synced)
+					}
+				case errMsg:
+					fmt.Fprintf(cmd.ErrOrStderr(), "bibgloss: %v\n", msg.error)
+					failed++
+				}
+			}
+			if failed > 0 {
+				return withCode(exitPartial, fmt.Errorf("%d of %d references could not be imported", failed, len(chosen)))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&picks, "import", "", "import the works with these numbers, like 1,3-7, or all")
+	return cmd
+}
+
+func newRenameCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	return &cobra.Command{
+		Use:               "rename <key> <new-key>",
+		Short:             "Change the citation key of a library entry",
+		Long:              "Change the citation key of an entry. The old key is kept as an alias in its ids field, which biblatex and JabRef resolve, so documents citing it still work.",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := args[1]
+			if err := bibtex.ValidKey(key); err != nil {
+				return withCode(exitInvalid, err)
+			}
+			e, err := findEntry(cfg.Library, args[0])
+			if err != nil {
+				return err
+			}
+			entries, err := loadLibrary(cfg.Library)
+			if err != nil {
+				return err
+			}
+			if err := keyTaken(entries, e.Key, key); err != nil {
+				return withCode(exitInvalid, err)
+			}
+			if _, err := mutate(cfg.Library, "rename "+e.Key+" to "+key, func() error {
+				return editEntry(cfg.Library, e.Key, func(e *Entry) { e.Rename(key) })
+			}); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "renamed %s to %s\n", e.Key, key)
+			return err
+		},
+	}
+}
+
+func newAliasesCmd(cfg *config) *cobra.Command {
+	var tex bool
+	var output string
+	cmd := &cobra.Command{
+		Use:   "aliases",
+		Short: "List the former keys of library entries",
+		Long:  "List the keys entries had before they were renamed or merged, kept in their ids field. --tex writes them as \\defbibalias{old}{new} lines for documents still citing the old keys.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := loadLibrary(cfg.Library)
+			if err != nil {
+				return err
+			}
+			out := aliasTeX(entries)
+			if !tex {
+				aliases := library.Aliases(entries)
+				var b strings.Builder
+				for _, old := range slices.Sorted(maps.Keys(aliases)) {
+					fmt.Fprintf(&b, "%s -> %s\n", old, aliases[old])
+				}
+				out = b.String()
+			}
+			if output != "" && output != "-" {
+				return writeFile(output, []byte(out))
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), out)
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&tex, "tex", false, "write \\defbibalias lines")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the aliases to a file instead of stdout")
+	_ = cmd.MarkFlagFilename("output", "tex")
+	return cmd
+}
+
+func newHistoryCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	var blame bool
+	cmd := &cobra.Command{
+		Use:               "history <key>",
+		Short:             "Show how a library entry changed over time",
+		Long:              "Print the changes bibgloss made to an entry, oldest first: when, by which command and what each field was before and after. Every write to the library logs them in a sidecar next to it, refs.bib -> refs.history.jsonl. Entries are followed through renames and the duplicates merged into them, and removed ones keep their history. --blame prints for each field of the entry the change that last set it.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := args[0]
+			e, err := findEntry(cfg.Library, key)
+			if err == nil {
+				key = e.Key
+			} else if blame {
+				return err
+			}
+			records, herr := entryHistory(cfg.Library, key, e.Aliases())
+			if herr != nil {
+				return herr
+			}
+			if blame {
+				writeBlame(cmd.OutOrStdout(), e, records)
+				return nil
+			}
+			if len(records) == 0 {
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "no changes to %s recorded\n", key)
+				return nil
+			}
+			writeHistory(cmd.OutOrStdout(), records)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&blame, "blame", false, "print the change that last set each field")
+	return cmd
+}
+
+func newLockCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	return &cobra.Command{
+		Use:               "lock <key> [field...]",
+		Short:             "Protect fields of a library entry from update and other refreshes",
+		Long:              "Add fields to the locked field of an entry, which update, venues, find-doi, authors --fill and imports merging into the entry leave as they are. type locks the entry type. Without fields, print the locked ones.",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := findEntry(cfg.Library, args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				for _, name := range lockedFields(&e) {
+					fmt.Fprintln(cmd.OutOrStdout(), name)
+				}
+				return nil
+			}
+			for _, name := range args[1:] {
+				if name == lockedField || strings.ContainsAny(name, " ,;={}") {
+					return withCode(exitInvalid, fmt.Errorf("%s cannot be locked", name))
+				}
+			}
+			if _, err := mutate(cfg.Library, "lock fields of "+e.Key, func() error {
+				return editEntry(cfg.Library, e.Key, func(e *Entry) {
+					locked := lockedFields(e)
+					for _, name := range args[1:] {
+						locked = append(locked, strings.ToLower(name))
+					}
+					setLocks(e, locked)
+				})
+			}); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "locked %s of %s\n", joinList(args[1:]), e.Key)
+			return err
+		},
+	}
+}
+
+func newUnlockCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	return &cobra.Command{
+		Use:               "unlock <key> [field...]",
+		Short:             "Let update and other refreshes change locked fields again",
+		Long:              "Remove fields from the locked field of an entry, all of them when none is given.",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := findEntry(cfg.Library, args[0])
+			if err != nil {
+				return err
+			}
+			locked := lockedFields(&e)
+			if len(locked) == 0 {
+				return withCode(exitNotFound, fmt.Errorf("%s has no locked fields", e.Key))
+			}
+			unlock := args[1:]
+			if len(unlock) == 0 {
+				unlock = locked
+			}
+			for _, name := range unlock {
+				if !slices.Contains(locked, strings.ToLower(name)) {
+					return withCode(exitNotFound, fmt.Errorf("%s of %s is not locked", name, e.Key))
+				}
+			}
+			if _, err := mutate(cfg.Library, "unlock fields of "+e.Key, func() error {
+				return editEntry(cfg.Library, e.Key, func(e *Entry) {
+					setLocks(e, slices.DeleteFunc(lockedFields(e), func(name string) bool {
+						return slices.ContainsFunc(unlock, func(u string) bool { return strings.EqualFold(u, name) })
+					}))
+				})
+			}); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "unlocked %s of %s\n", joinList(unlock), e.Key)
+			return err
+		},
+	}
+}
+
+func newSearchCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "search <word...>",
+		Short: "List the library entries containing every word",
+		Long:  "Search the keys, titles, authors, years and keywords of the library, ignoring case. Words match inside longer ones. Large libraries are searched through an index that is rebuilt when the file changes.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			x, err := openIndex(cfg.Library)
+			if err != nil {
+				return err
+			}
+			found := x.Search(strings.Join(args, " "))
+			for _, e := range found {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", e.Key, unbrace.Replace(e.Get("title")))
+			}
+			if len(found) == 0 {
+				return withCode(exitNotFound, errors.New("no entries found"))
+			}
+			return nil
+		},
+	}
+}
+
+func newStatsCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize the library",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			x, err := openIndex(cfg.Library)
+			if err != nil {
+				return err
+			}
+			s := x.Stats()
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "%d entries in %s\n", s.Entries, cfg.Library)
+			if s.Entries == 0 {
+				return nil
+			}
+			fmt.Fprintf(out, "%d with a DOI, %d with a file, %d duplicates\n", s.DOIs, s.Files, s.Duplicate)
+			var types []string
+			for _, t := range library.ByCount(s.Types) {
+				types = append(types, fmt.Sprintf("%s %d", t, s.Types[t]))
+			}
+			fmt.Fprintln(out, "types:", strings.Join(types, ", "))
+			if len(s.Years) > 0 {
+				years := slices.Sorted(maps.Keys(s.Years))
+				top := library.ByCount(s.Years)[0]
+				fmt.Fprintf(out, "years: %s to %s, most in %s (%d)\n", years[0], years[len(years)-1], top, s.Years[top])
+			}
+			return nil
+		},
+	}
+}
+
+func newUpdateCmd(s *settings) *cobra.Command {
+	cfg := &s.config
+	return &cobra.Command{
+		Use:               "update <key>",
+		Short:             "Refresh a library entry from its DOI",
+		Long:              "Resolve the entry's DOI again and replace the fields the resolvers return. The key, fields the resolvers do not know about, like keywords and file, and fields locked with bibgloss lock are kept.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeKeys(s),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := findEntry(cfg.Library, args[0])
+			if err != nil {
+				return err
+			}
+			doi := e.Get("doi")
+			if doi == "" {
+				return withCode(exitInvalid, fmt.Errorf("%s has no DOI", e.Key))
+			}
+			w, err := resolve.Resolve(doi, cfg.resolveOptions())
+			if err != nil {
+				return err
+			}
+			updated := e
+			updated.Type = w.Entry.Type
+			updated.Fields = append([]Field(nil), e.Fields...)
+			for _, f := range w.Entry.Fields {
+				updated.Set(f.Name, f.Value)
+			}
+			if kept := keepLocked(e, &updated); len(kept) > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "kept the locked %s of %s\n", joinList(kept), e.Key)
+			}
+			if _, err := mutate(cfg.Library, "update "+e.Key, func() error {
+				return rewriteEntry(cfg.Library, e.Key, &updated)
+			}); err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), updated.BibTeX())
+			return err
+		},
+	}
+}
+
+// runTUI starts the interactive program
+func runTUI(cfg config) error {
+	setLanguage(cfg.Language)
+	if cfg.Accessible {
+		return runAccessible(cfg, os.Stdin, os.Stdout)
+	}
+	applyTheme(cfg.Theme)
+	m, err := initialModel(cfg)
+	if err != nil {
+		return err
+	}
+	if m.restore, err = loadSession(sessionPath(), cfg.Library); err != nil {
+		slog.Warn("session not restored", "err", err)
+	}
+	var opts []tea.ProgramOption
+	if !cfg.Inline {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	final, err := tea.NewProgram(m, opts...).Run()
+	if m, ok := final.(model); ok {
+		if err := saveSession(sessionPath(), m.session()); err != nil {
+			slog.Warn("session not saved", "err", err)
+		}
+	}
+	return err
+}
+
+func newFetchCmd(cfg *config) *cobra.Command {
+	var opts fetchOptions
+	cmd := &cobra.Command{
+		Use:   "fetch <identifier...|->",
+		Short: "Resolve identifiers and print their BibTeX",
+		Long:  "Resolve identifiers and print their BibTeX. An identifier of - reads one identifier per line from stdin and prints each result as soon as it is resolved.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && opts.Input == "" {
+				return withCode(exitInvalid, errors.New("no identifier given"))
+			}
+			if opts.Input != "" && opts.Report == "" {
+				opts.Report = opts.Input + ".failures.jsonl"
+			}
+			return runPlain(*cfg, opts, args, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+	cmd.Flags().StringVar(&opts.Input, "input", "", "file with one identifier per line")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "write resolved entries to this file instead of stdout")
+	cmd.Flags().BoolVar(&opts.Append, "append", false, "append to an existing output file")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "overwrite an existing output file")
+	cmd.Flags().StringVar(&opts.Report, "report", "", "JSON lines file failures are written to (default <input>.failures.jsonl)")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "print one JSON object per identifier as soon as it is resolved")
+	return cmd
+}
+
+func newLintCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:               "lint [file.bib]",
+		Short:             "Check a bibliography for duplicate keys and missing fields",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeBibFiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := cfg.Library
+			if len(args) == 1 {
+				path = args[0]
+			}
+			entries, err := loadLibrary(path)
+			// syntax errors are reported with the issues of the entries
+			// that parse
+			var syntax bibtex.ParseErrors
+			if !errors.As(err, &syntax) && err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			issues := append(syntaxIssues(syntax), lintEntries(entries)...)
+			if data, err := readFile(path); err == nil {
+				issues = append(issues, lintGroups(data, entries)...)
+			}
+			for _, i := range issues {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", path, i)
+			}
+			if len(issues) > 0 {
+				return fmt.Errorf("%d issues found", len(issues))
+			}
+			return nil
+		},
+	}
+}
+
+func newDedupeCmd(cfg *config) *cobra.Command {
+	return &cobra.Command{
+		Use:               "dedupe [file.bib]",
+		Short:             "Merge entries that describe the same work",
+		Long:              "Entries sharing a DOI, or a title and year, are merged into the first of them. Fields missing from the kept entry are taken from its duplicates.",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeBibFiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := cfg.Library
+			if len(args) == 1 {
+				path = args[0]
+			}
+			x, err := openIndex(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if len(x.Duplicates) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "no duplicates found")
+				return nil
+			}
+			// merging rewrites the file, which needs the entries' positions
+			entries, err := loadLibrary(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			groups := library.FindDuplicates(entries)
+			for _, g := range groups {
+				for _, i := range g[1:] {
+					fmt.Fprintf(cmd.OutOrStdout(), "merged %s into %s\n", entries[i].Key, entries[g[0]].Key)
+				}
+			}
+			_, err = mutate(path, "dedupe", func() error {
+				return editLibrary(path, library.DedupeChanges(entries, groups))
+			})
+			return err
+		},
+	}
+}
+
+func newGlossaryCmd(cfg *config) *cobra.Command {
+	var path string
+	cmd := &cobra.Command{
+		Use:   "glossary",
+		Short: "Manage glossary and acronym definitions",
+	}
+	cmd.PersistentFlags().StringVar(&path, "glossary", "", "glossary file instead of the configured glossaries")
+	// files are the glossaries a command works on, add writes to the first
+	files := func() []string {
+		if path != "" {
+			return []string{path}
+		}
+		return cfg.Glossaries
+	}
+
+	var g glossary.Entry
+	var acronym bool
+	add := &cobra.Command{
+		Use:   "add [key]",
+		Short: "Add a glossary entry or acronym",
+		Long:  "Add a glossary entry or acronym to the first glossary. Without a key, one is made from the term or the long form of the acronym with glossary_key_template, {long} by default: --description \"portable document format\" gives portable-document-format, or portable-document-format-2 when another definition took it.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			g.Kind = glossary.KindEntry
+			if acronym {
+				g.Kind = glossary.KindAcronym
+			}
+			if g.Name == "" || g.Description == "" {
+				return errors.New("both --name and --description are required")
+			}
+			if len(args) == 1 {
+				g.Key = args[0]
+			} else {
+				key, err := glossaryKey(files(), cfg.GlossaryKeyTemplate, g)
+				if err != nil {
+					return err
+				}
+				g.Key = key
+			}
+			if err := bibtex.ValidKey(g.Key); err != nil {
+				return err
+			}
+			dest := files()[0]
+			if _, err := mutate(dest, "glossary add "+g.Key, func() error {
+				return appendGlossary(dest, g)
+			}); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "added %s to %s\n", g.Key, dest)
+			return nil
+		},
+	}
+	add.Flags().StringVar(&g.Name, "name", "", "term, or short form of an acronym")
+	add.Flags().StringVar(&g.Description, "description", "", "description, or long form of an acronym")
+	add.Flags().BoolVar(&acronym, "acronym", false, "define an acronym instead of a glossary entry")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List glossary definitions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, path := range files() {
+				entries, err := loadGlossary(path)
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				for _, g := range entries {
+					fmt.Fprintf(cmd.OutOrStdout(), "%-20s %-8s %s: %s\n", g.Key, g.Kind, g.Name, g.Description)
+				}
+			}
+			return nil
+		},
+	}
+
+	var all bool
+	link := &cobra.Command{
+		Use:   "link <key...>",
+		Short: "Add glossary entries citing the cited software and datasets",
+		Long:  "Add a glossary entry for every software or dataset among the library entries, under the entry's key, named after it and described by the first sentence of its abstract followed by \\cite of the entry. Keys the glossary defines already are skipped. glossary_links = true in the config does this on import.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := selectEntries(cfg.Library, args, all)
+			if err != nil {
+				return err
+			}
+			dest := files()[0]
+			linked := 0
+			if _, err := mutate(dest, "glossary link", func() error {
+				for _, e := range entries {
+					added, err := linkGlossary(dest, e, "")
+					if err != nil {
+						return err
+					}
+					if added {
+						linked++
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "added %d glossary entries to %s\n", linked, dest)
+			return nil
+		},
+	}
+	link.Flags().BoolVar(&all, "all", false, "every entry of the library")
+
+	cmd.AddCommand(add, list, link)
+	return cmd
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/glossary"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/spf13/pflag"
+)
+
+// config holds the settings from the config file and the command line
+type config struct {
+	Library string `toml:"library"`
+	Papers  string `toml:"papers"`
+	Email   string `toml:"email"`
+	Keymap  string `toml:"keymap"`
+	// KeyTemplate is expanded by bibtex.FormatKey for new entries
+	KeyTemplate string `toml:"key_template"`
+	// Inline runs without the alternate screen so results stay in the
+	// terminal scrollback
+	Inline bool `toml:"inline"`
+	// Accessible replaces the TUI by plain lines for screen readers and
+	// drops the colors of everything else
+	Accessible bool `toml:"accessible"`
+	// WatchClipboard resolves the DOIs and arXiv identifiers copied while
+	// the TUI runs
+	WatchClipboard bool `toml:"watch_clipboard"`
+	// Format is the entry dialect written to stdout and the library
+	Format string `toml:"format"`
+	// Style is the CSL style bibgloss cite renders with
+	Style string `toml:"style"`
+	// Glossaries are the glossary files; glossary add writes to the first
+	Glossaries []string `toml:"glossaries"`
+	// GlossaryLinks adds a glossary entry for every imported software or
+	// dataset to the first glossary
+	GlossaryLinks bool `toml:"glossary_links"`
+	// GlossaryKeyTemplate is expanded by glossary.FormatKey for definitions
+	// added without a key
+	GlossaryKeyTemplate string `toml:"glossary_key_template"`
+	// Resolvers is the enrichment chain asked after CrossRef
+	Resolvers []string `toml:"resolvers"`
+	Theme     string   `toml:"theme"`
+	// Language of the TUI, empty to follow the locale
+	Language string `toml:"language"`
+	// Offline answers from the response cache only
+	Offline bool `toml:"offline"`
+	// Concurrency is the number of lookups batch commands run at the same
+	// time
+	Concurrency int `toml:"concurrency"`
+	// Romanize records romanized forms of names in non-Latin scripts
+	Romanize bool    `toml:"romanize"`
+	APIKeys  apiKeys `toml:"api_keys"`
+	// Zotero mirrors imported entries into a Zotero library when set
+	Zotero zoteroConfig `toml:"zotero"`
+	// Obsidian writes a literature note for imported entries when set
+	Obsidian obsidianConfig `toml:"obsidian"`
+	// OrgRoam writes an org note for imported entries when set
+	OrgRoam orgRoamConfig `toml:"org_roam"`
+	// Notion and Airtable receive entries with bibgloss export
+	Notion   notionConfig   `toml:"notion"`
+	Airtable airtableConfig `toml:"airtable"`
+	Follow   followConfig   `toml:"follow"`
+	// Sync shares the library with a team over a git remote
+	Sync syncConfig `toml:"sync"`
+	// Translate is the service bibgloss translate sends abstracts to
+	Translate translateConfig `toml:"translate"`
+	// Hooks run shell commands on every import
+	Hooks hooksConfig `toml:"hooks"`
+	// Venues come before bibtex.DefaultVenues when normalizing booktitles
+	Venues []venueConfig `toml:"venues"`
+	// Profiles are named libraries with their own settings, picked with
+	// --profile, $BIBGLOSS_PROFILE or ctrl+p in the TUI
+	Profiles map[string]profileConfig `toml:"profiles"`
+	// Profile is the name of the profile in use, unprofiled the settings
+	// before it was applied
+	Profile    string `toml:"-"`
+	unprofiled *config
+}
+
+type apiKeys struct {
+	OpenAlex        string `toml:"openalex"`
+	SemanticScholar string `toml:"semantic_scholar"`
+	// EPO and EPOSecret are the consumer key and secret of EPO Open Patent
+	// Services
+	EPO       string `toml:"epo"`
+	EPOSecret string `toml:"epo_secret"`
+	// DeepL and LibreTranslate authenticate abstract translations
+	DeepL          string `toml:"deepl"`
+	LibreTranslate string `toml:"libretranslate"`
+}
+
+// defaultConfig returns the settings used when neither file nor flags set
+// them
+func defaultConfig() config {
+	return config{
+		Library:             "references.bib",
+		Papers:              "papers",
+		Keymap:              keymapDefault,
+		KeyTemplate:         bibtex.DefaultKeyTemplate,
+		Format:              bibtex.FormatBibTeX,
+		Style:               "apa",
+		Glossaries:          []string{"glossary.tex"},
+		GlossaryKeyTemplate: glossary.DefaultKeyTemplate,
+		Resolvers:           resolve.DefaultResolvers,
+		Theme:               themeDefault,
+		Zotero:              zoteroConfig{LibraryType: "user"},
+		Obsidian:            obsidianConfig{Folder: "Reading notes", FileName: "@{{citekey}}"},
+		OrgRoam:             orgRoamConfig{FileName: "{{citekey}}"},
+		Follow:              followConfig{Interval: "24h"},
+		Translate:           translateConfig{To: "en"},
+		Concurrency:         8,
+	}
+}
+
+// resolveOptions returns the resolver settings of the configuration
+func (c config) resolveOptions() resolve.Options {
+	return resolve.Options{
+		Email:              c.Email,
+		Resolvers:          withPlugins(c.Resolvers),
+		OpenAlexKey:        c.APIKeys.OpenAlex,
+		SemanticScholarKey: c.APIKeys.SemanticScholar,
+		EPOKey:             c.APIKeys.EPO,
+		EPOSecret:          c.APIKeys.EPOSecret,
+		Venues:             c.venues(),
+		Romanize:           c.Romanize,
+	}
+}
+
+// configPath returns $BIBGLOSS_CONFIG or $XDG_CONFIG_HOME/bibgloss/config.toml,
+// falling back to ~/.config when the latter is unset
+func configPath() string {
+	if p := os.Getenv(envPrefix + "CONFIG"); p != "" {
+		return p
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "bibgloss", "config.toml")
+}
+
+// loadConfig reads the config file on top of the defaults, the project
+// file, when there is one, on top of that and applies the environment
+// overrides. A missing file is not an error.
+func loadConfig(path, project string) (config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		md, err := toml.DecodeFile(path, &cfg)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return cfg, fmt.Errorf("%s: unknown setting %s", path, undecoded[0])
+		}
+	}
+	if project != "" {
+		if err := applyProject(&cfg, project); err != nil {
+			return cfg, err
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.validate()
+}
+
+// validate rejects values the program cannot work with
+func (c config) validate() error {
+	switch c.Keymap {
+	case keymapDefault, keymapVim:
+	default:
+		return fmt.Errorf("unknown keymap %q", c.Keymap)
+	}
+	if _, ok := bibtex.Formats[c.Format]; !ok {
+		return fmt.Errorf("unknown format %q", c.Format)
+	}
+	if _, ok := themes[c.Theme]; !ok {
+		return fmt.Errorf("unknown theme %q", c.Theme)
+	}
+	if _, ok := catalogs[c.Language]; !ok && c.Language != "" {
+		return fmt.Errorf("unknown language %q", c.Language)
+	}
+	for _, r := range c.Resolvers {
+		if _, ok := resolve.Enrichers[r]; !ok {
+			return fmt.Errorf("unknown resolver %q", r)
+		}
+	}
+	if t := c.Zotero.LibraryType; t != "user" && t != "group" {
+		return fmt.Errorf("zotero.library_type must be user or group, not %q", t)
+	}
+	if _, ok := translators[c.Translate.Service]; !ok && c.Translate.Service != "" {
+		return fmt.Errorf("unknown translation service %q, use deepl or libretranslate", c.Translate.Service)
+	}
+	if bibtex.LanguageCode(c.Translate.To) == "" {
+		return fmt.Errorf("translate.to must be a language like en or english, not %q", c.Translate.To)
+	}
+	if err := glossary.ValidateKeyTemplate(c.GlossaryKeyTemplate); err != nil {
+		return fmt.Errorf("glossary_key_template: %w", err)
+	}
+	if d, err := time.ParseDuration(c.Follow.Interval); err != nil || d <= 0 {
+		return fmt.Errorf("follow.interval must be a duration like 24h, not %q", c.Follow.Interval)
+	}
+	for name, p := range c.Profiles {
+		if p.Library == "" {
+			return fmt.Errorf("profiles.%s needs a library", name)
+		}
+	}
+	for i, v := range c.Venues {
+		if v.Name == "" || len(v.Match) == 0 {
+			return fmt.Errorf("venues[%d] needs a name and at least one match", i)
+		}
+	}
+	if len(c.Glossaries) == 0 {
+		return errors.New("glossaries needs at least one file")
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, not %d", c.Concurrency)
+	}
+	return nil
+}
+
+// flagFields copies a setting between configs, keyed by flag name
+var flagFields = map[string]func(dst, src *config){
+	"bib":             func(d, s *config) { d.Library = s.Library },
+	"papers":          func(d, s *config) { d.Papers = s.Papers },
+	"email":           func(d, s *config) { d.Email = s.Email },
+	"keymap":          func(d, s *config) { d.Keymap = s.Keymap },
+	"key-template":    func(d, s *config) { d.KeyTemplate = s.KeyTemplate },
+	"inline":          func(d, s *config) { d.Inline = s.Inline },
+	"accessible":      func(d, s *config) { d.Accessible = s.Accessible },
+	"watch-clipboard": func(d, s *config) { d.WatchClipboard = s.WatchClipboard },
+	"format":          func(d, s *config) { d.Format = s.Format },
+	"theme":           func(d, s *config) { d.Theme = s.Theme },
+	"language":        func(d, s *config) { d.Language = s.Language },
+	"offline":         func(d, s *config) { d.Offline = s.Offline },
+	"concurrency":     func(d, s *config) { d.Concurrency = s.Concurrency },
+}
+
+// mergeFlags layers the flags given on the command line over file
+func mergeFlags(file config, flags config, set *pflag.FlagSet) config {
+	set.Visit(func(f *pflag.Flag) {
+		if copyField, ok := flagFields[f.Name]; ok {
+			copyField(&file, &flags)
+		}
+	})
+	return file
+}
+
+// configTemplate is written by config init
+const configTemplate = `# BibGloss configuration
+#
+# Every setting can be overridden with a BIBGLOSS_* environment variable
+# named after its key, e.g. BIBGLOSS_LIBRARY or BIBGLOSS_API_KEYS_OPENALEX.
+# A .bibgloss.toml in a project directory, or a parent of the working
+# directory, overrides library, papers, key_template, format, style,
+# glossaries, glossary_links and glossary_key_template for the commands run
+# in it; write one with config init --project.
+
+# library entries are imported into
+library = "references.bib"
+
+# directory downloaded PDFs are stored in
+papers = "papers"
+
+# contact email sent to Unpaywall, required for PDF lookups
+email = ""
+
+# citation key template, see {auth} {authors} {year} {title} {shorttitle}
+key_template = "{auth}{year}{title}"
+
+# entry dialect: bibtex or biblatex
+format = "bibtex"
+
+# keep romanized forms of Cyrillic, Greek and Korean author names by
+# position in author+roman, like author+roman = {1=Ivanov, Ivan}
+romanize = false
+
+# CSL style of bibgloss cite, installed with bibgloss styles add, or a .csl file
+style = "apa"
+
+# glossary files; glossary add writes to the first, glossary list reads all
+glossaries = ["glossary.tex"]
+
+# add a glossary entry citing it for every imported software or dataset,
+# named after it and described by the first sentence of its abstract
+glossary_links = false
+
+# key of definitions added without one, from the term or the long form of
+# an acronym: {long} (first words, hyphenated), {short} (the acronym, the
+# initials of a term) and {initials}; taken keys get -2, -3…
+glossary_key_template = "{long}"
+
+# enrichment resolvers asked after CrossRef, in order. Plugins installed in
+# ~/.local/share/bibgloss/plugins run after those listed unless named here.
+resolvers = ["openalex", "unpaywall", "semanticscholar"]
+
+# key bindings: default or vim
+keymap = "default"
+
+# colors: default or mono
+theme = "default"
+
+# TUI language: en or de, empty to follow LANG
+language = ""
+
+# start without the alternate screen
+inline = false
+
+# plain text for screen readers: no colors or spinners, and the TUI asks
+# one question per line instead of drawing screens
+accessible = false
+
+# resolve the DOIs and arXiv identifiers copied while the TUI runs and queue
+# them on ctrl+y; needs xclip, xsel or wl-clipboard on Linux
+watch_clipboard = false
+
+# answer from the response cache only, never touch the network
+offline = false
+
+# lookups batch commands like fetch, check and enrich run at the same time;
+# requests to each API stay rate limited
+concurrency = 8
+
+# epo and epo_secret are the consumer key of EPO Open Patent Services,
+# https://developers.epo.org; patents are looked up at Google Patents without
+[api_keys]
+openalex = ""
+semantic_scholar = ""
+epo = ""
+epo_secret = ""
+deepl = ""
+libretranslate = ""
+
+# mirror imported entries to Zotero, tags become collections. The key needs
+# write access: https://www.zotero.org/settings/keys
+[zotero]
+api_key = ""
+# the userID shown on the keys page, or the group's number
+library_id = ""
+library_type = "user"
+
+# write a Markdown literature note for imported entries into an Obsidian
+# vault. Names and the template use obsidian-citation-plugin's variables:
+# {{citekey}} {{title}} {{authorString}} {{year}} {{containerTitle}} {{DOI}}
+# {{URL}} {{abstract}}. An empty template uses the built-in one.
+[obsidian]
+vault = ""
+folder = "Reading notes"
+file_name = "@{{citekey}}"
+template = ""
+
+# write an org note for imported entries, named after the key and tagged
+# with ROAM_REFS so citar and org-roam-bibtex find it. The template takes the
+# variables of [obsidian]. bibgloss org-roam elisp prints the Emacs settings.
+[org_roam]
+directory = ""
+file_name = "{{citekey}}"
+template = ""
+
+# reading list databases for bibgloss export. Columns map a column name to an
+# entry field, or to key, type or link; the default fills Name, Authors, Year,
+# DOI and Key. Share the Notion database with the integration the token is of.
+[notion]
+token = ""
+database_id = ""
+
+[airtable]
+token = ""
+base_id = ""
+table = ""
+
+# [notion.columns]
+# Name = "title"
+# Authors = "author"
+# Year = "year"
+# Tags = "keywords"
+
+# citation alerts for papers added with bibgloss follow add. follow watch
+# checks every interval and posts new citing works to the webhook, if set;
+# the TUI lists them on ctrl+n.
+[follow]
+webhook = ""
+interval = "24h"
+
+# bibgloss sync merges the library with the team's shared one in a git
+# repository, entry by entry, and pushes the result. file is the library's
+# path in the repository, the name of the library by default; the clone
+# lives in dir, under $XDG_DATA_HOME/bibgloss/sync by default.
+[sync]
+remote = ""
+file = ""
+dir = ""
+
+# bibgloss translate sends abstracts in other languages to DeepL or a
+# LibreTranslate server, with the key in api_keys.deepl or
+# api_keys.libretranslate. url defaults to the DeepL API of the key and is
+# needed for LibreTranslate, like https://libretranslate.example.org.
+[translate]
+service = ""
+url = ""
+to = "en"
+
+# shell commands run on every import with the entry as JSON on stdin:
+# {"type", "key", "fields", "abstract", "library"}. A pre_import command may
+# print the entry changed, a new key included, and stops the import when it
+# fails; post_import commands run once the entry is written.
+[hooks]
+pre_import = []
+post_import = []
+
+# conference names are normalized to a canonical booktitle and series by a
+# built-in table; a venue listed here comes first. A venue matches when one
+# of its match names occurs in the booktitle, ignoring case, punctuation,
+# years, ordinals and abbreviations like Proc., Intl. and Conf.
+# [[venues]]
+# name = "Proceedings of the International Conference on Document Analysis and Recognition"
+# series = "ICDAR"
+# match = ["icdar", "international conference on document analysis and recognition"]
+
+# named libraries picked with --profile, $BIBGLOSS_PROFILE or ctrl+p in the
+# TUI. A profile needs a library and may set papers, key_template, format,
+# style, glossaries, glossary_links and glossary_key_template; the settings
+# above fill the rest.
+# [profiles.thesis]
+# library = "/home/me/thesis/references.bib"
+# format = "biblatex"
+# key_template = "{auth}{year}"
+#
+# [profiles.lab]
+# library = "/srv/lab/shared.bib"
+# papers = "/srv/lab/papers"
+`
+
+// initConfig writes the commented default configuration to path
+func initConfig(path, template string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(template), 0o644)
+}
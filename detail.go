@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true)
+	labelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	okStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	errStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+// renderDetail renders everything known about a work for the detail screen
+func renderDetail(w *Work, width int) string {
+	if width <= 0 {
+		width = 80
+	}
+	wrap := lipgloss.NewStyle().Width(width)
+	e := &w.Entry
+
+	var b strings.Builder
+	b.WriteString(wrap.Render(titleStyle.Render(e.Get("title"))) + "\n")
+	b.WriteString(wrap.Render(strings.Join(bibtex.SplitAuthors(e.Get("author")), "; ")) + "\n\n")
+
+	citations := tr("unknown")
+	if w.Citations >= 0 {
+		citations = fmt.Sprint(w.Citations) + source(w, "citations")
+	}
+	oa := tr("closed")
+	if w.OpenAccess {
+		oa = okStyle.Render(w.OAStatus)
+		if w.OAURL != "" {
+			oa += " " + w.OAURL
+		}
+	} else if w.OAStatus != "" {
+		oa = w.OAStatus
+	}
+	fmt.Fprintf(&b, "%s %s\n", labelStyle.Render(fmt.Sprintf("%-12s", tr("Citations:"))), citations)
+	fmt.Fprintf(&b, "%s %s%s\n", labelStyle.Render(fmt.Sprintf("%-12s", tr("Open access:"))), oa, source(w, "oa"))
+	if w.PDFURL != "" {
+		fmt.Fprintf(&b, "%s %s%s\n", labelStyle.Render(fmt.Sprintf("%-12s", tr("PDF:"))), w.PDFURL, source(w, "pdf"))
+	}
+	b.WriteString("\n")
+
+	b.WriteString(titleStyle.Render(tr("Abstract")) + source(w, "abstract") + "\n")
+	if w.Abstract != "" {
+		b.WriteString(wrap.Render(w.Abstract) + "\n\n")
+	} else {
+		b.WriteString(labelStyle.Render(tr("no abstract available")) + "\n\n")
+	}
+
+	b.WriteString(titleStyle.Render(tr("Fields")) + "\n")
+	fmt.Fprintf(&b, "%s %s\n", labelStyle.Render(fmt.Sprintf("%12s", "type")), e.Type)
+	fmt.Fprintf(&b, "%s %s\n", labelStyle.Render(fmt.Sprintf("%12s", "key")), e.Key)
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "%s %s%s\n", labelStyle.Render(fmt.Sprintf("%12s", f.Name)), f.Value, source(w, f.Name))
+	}
+	return b.String()
+}
+
+// source renders the provenance of a field, if known
+func source(w *Work, name string) string {
+	if src, ok := w.Sources[name]; ok {
+		return labelStyle.Render("  [" + src + "]")
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// check is the outcome of one doctor diagnostic
+type check struct {
+	Name   string
+	Status checkStatus
+	Detail string
+	// Fix tells the user what to do about a warning or failure
+	Fix string
+}
+
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) String() string {
+	return [...]string{"ok", "warn", "FAIL"}[s]
+}
+
+// probes are cheap requests showing whether an API is reachable
+var probes = map[string]string{
+	resolve.SourceCrossref:        "https://api.crossref.org/works?rows=0",
+	resolve.SourceOpenAlex:        "https://api.openalex.org/works?per-page=1",
+	resolve.SourceUnpaywall:       "https://api.unpaywall.org/",
+	resolve.SourceSemanticScholar: "https://api.semanticscholar.org/graph/v1/paper/DOI:10.1016/j.icarus.2016.12.026?fields=title",
+}
+
+// resolverSources maps resolver names of the configuration to their source
+var resolverSources = map[string]string{
+	"openalex":        resolve.SourceOpenAlex,
+	"unpaywall":       resolve.SourceUnpaywall,
+	"semanticscholar": resolve.SourceSemanticScholar,
+}
+
+// runDoctor runs every diagnostic. When the config could not be loaded, the
+// remaining checks use the defaults.
+func runDoctor(path, project string, cfg config, cfgErr error) []check {
+	var checks []check
+	if cfgErr != nil {
+		checks = append(checks, check{"config", checkFail, cfgErr.Error(), "fix the file or regenerate it with bibgloss config init --force"})
+		cfg = defaultConfig()
+	} else if _, err := os.Stat(path); err != nil {
+		checks = append(checks, check{"config", checkWarn, path + " not found, using defaults", "run bibgloss config init"})
+	} else {
+		checks = append(checks, check{"config", checkOK, path, ""})
+	}
+	if project != "" && cfgErr == nil {
+		checks = append(checks, check{"project", checkOK, project, ""})
+	}
+
+	checks = append(checks, checkLibrary(cfg.Library))
+	checks = append(checks, checkPapers(cfg.Papers))
+	checks = append(checks, checkCache())
+
+	if cfg.Offline {
+		return append(checks, check{"network", checkWarn, "offline mode, APIs not checked", "unset offline to resolve new identifiers"})
+	}
+	checks = append(checks, checkAPI(resolve.SourceCrossref))
+	for _, r := range cfg.resolveOptions().Resolvers {
+		if p, ok := pluginNamed(r); ok {
+			checks = append(checks, check{r, checkOK, "plugin " + p.Path, ""})
+			continue
+		}
+		if r == "unpaywall" && cfg.Email == "" {
+			checks = append(checks, check{resolve.SourceUnpaywall, checkWarn, "no contact email configured", "set email in the config or BIBGLOSS_EMAIL"})
+			continue
+		}
+		checks = append(checks, checkAPI(resolverSources[r]))
+	}
+	return checks
+}
+
+func checkLibrary(path string) check {
+	if _, err := os.Stat(path); err != nil {
+		return check{"library", checkWarn, path + " does not exist yet", "import an entry or set library in the config"}
+	}
+	entries, err := loadLibrary(path)
+	if err != nil {
+		return check{"library", checkFail, fmt.Sprintf("%s: %v", path, err), "repair the file, an older version is in " + path + ".bak"}
+	}
+	if issues := lintEntries(entries); len(issues) > 0 {
+		return check{"library", checkWarn, fmt.Sprintf("%s: %d entries, %d lint issues", path, len(entries), len(issues)), "run bibgloss lint"}
+	}
+	return check{"library", checkOK, fmt.Sprintf("%s: %d entries", path, len(entries)), ""}
+}
+
+func checkPapers(dir string) check {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return check{"papers", checkOK, dir + " will be created on the first download", ""}
+	}
+	if !info.IsDir() {
+		return check{"papers", checkFail, dir + " is not a directory", "point papers at a directory"}
+	}
+	if err := writable(dir); err != nil {
+		return check{"papers", checkFail, err.Error(), "fix the permissions of " + dir}
+	}
+	return check{"papers", checkOK, dir, ""}
+}
+
+func checkCache() check {
+	if resolve.CacheDir == "" {
+		return check{"cache", checkWarn, "disabled", "drop --no-cache to speed up repeated lookups"}
+	}
+	files, err := os.ReadDir(resolve.CacheDir)
+	if os.IsNotExist(err) {
+		return check{"cache", checkOK, resolve.CacheDir + " is empty", ""}
+	}
+	if err != nil {
+		return check{"cache", checkFail, err.Error(), "remove " + resolve.CacheDir}
+	}
+	if err := writable(resolve.CacheDir); err != nil {
+		return check{"cache", checkFail, err.Error(), "fix the permissions of " + resolve.CacheDir}
+	}
+	var size int64
+	expired := 0
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		size += info.Size()
+		if time.Since(info.ModTime()) > resolve.CacheTTL {
+			expired++
+		}
+	}
+	return check{"cache", checkOK, fmt.Sprintf("%s: %d responses, %d expired, %d KiB", resolve.CacheDir, len(files), expired, size/1024), ""}
+}
+
+// checkAPI sends the probe request of a source, bypassing the cache
+func checkAPI(source string) check {
+	start := time.Now()
+	res, err := resolve.Client.Get(probes[source])
+	if err != nil {
+		return check{source, checkFail, err.Error(), "check your network connection and proxy settings"}
+	}
+	res.Body.Close() // nolint:errcheck
+	detail := fmt.Sprintf("%s in %s", res.Status, time.Since(start).Round(time.Millisecond))
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		return check{source, checkWarn, detail, "you are rate limited, wait or configure an API key"}
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return check{source, checkFail, detail, "check the API key in the config"}
+	case res.StatusCode >= 500:
+		return check{source, checkWarn, detail, "the service has problems, try again later"}
+	}
+	return check{source, checkOK, detail, ""}
+}
+
+// writable tries to create a file in dir
+func writable(dir string) error {
+	f, err := os.CreateTemp(dir, ".bibgloss-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()           // nolint:errcheck
+	os.Remove(f.Name()) // nolint:errcheck
+	return nil
+}
+
+// printChecks writes a report and returns the number of failures
+func printChecks(w io.Writer, checks []check) int {
+	failed := 0
+	for _, c := range checks {
+		fmt.Fprintf(w, "%-4s  %-16s %s\n", c.Status, c.Name, c.Detail)
+		if c.Fix != "" {
+			fmt.Fprintf(w, "      %-16s → %s\n", "", c.Fix)
+		}
+		if c.Status == checkFail {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// ordinals spell out edition numbers, in English and German
+var ordinals = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6,
+	"seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10, "eleventh": 11, "twelfth": 12,
+	"erste": 1, "zweite": 2, "dritte": 3, "vierte": 4, "fünfte": 5, "sechste": 6,
+}
+
+// editionRe finds the edition in how a catalog names it or in a title,
+// like "3rd ed.", "Third Edition" or "2. Aufl."
+var editionRe = regexp.MustCompile(`(?i)\b(\d+|[a-zü]+)(?:st|nd|rd|th|\.)?\s*(?:ed\b|edition|éd|aufl)`)
+
+// editionNumber returns the number of an edition named like "3rd ed.", 0
+// when it has none
+func editionNumber(s string) int {
+	for _, m := range editionRe.FindAllStringSubmatch(s, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			return n
+		}
+		if n := ordinals[strings.ToLower(m[1])]; n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// entryEdition returns the edition of a book entry, whose edition field
+// holds a number or an ordinal like "Second", the first without one
+func entryEdition(e *Entry) int {
+	s := strings.TrimSpace(unbrace.Replace(e.Get("edition")))
+	if s == "" {
+		return 1
+	}
+	if n := editionNumber(s + " ed"); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// editionCheck is the newest edition found for a book entry
+type editionCheck struct {
+	entry Entry
+	newer resolve.Edition
+	found bool
+}
+
+// newerEdition picks among the editions of a book the latest one after the
+// edition of the entry. Catalogs list reprints, translations and other
+// books under a work too, so only editions with the same main title and a
+// higher edition number count.
+func newerEdition(e *Entry, editions []resolve.Edition) (resolve.Edition, bool) {
+	main := func(title string) string {
+		title, _, _ = strings.Cut(title, ":")
+		return library.NormalizeTitle(title)
+	}
+	title := main(unbrace.Replace(e.Get("title")))
+	current := entryEdition(e)
+	year, _ := strconv.Atoi(e.Get("year"))
+	var best resolve.Edition
+	bestNum := current
+	for _, ed := range editions {
+		if main(ed.Title) != title {
+			continue
+		}
+		n := editionNumber(ed.Edition + " " + ed.Title + " " + ed.Subtitle)
+		if n <= current || year > 0 && ed.Year > 0 && ed.Year <= year {
+			continue
+		}
+		if n > bestNum || n == bestNum && (ed.Year > best.Year || ed.Year == best.Year && best.ISBN == "" && ed.ISBN != "") {
+			best, bestNum = ed, n
+		}
+	}
+	return best, bestNum > current
+}
+
+// checkEditions looks up the editions of the books among entries, n at a
+// time
+func checkEditions(n int, entries []Entry) ([]editionCheck, error) {
+	var books []Entry
+	for _, e := range entries {
+		if e.Type == "book" && e.Get("title") != "" {
+			books = append(books, e)
+		}
+	}
+	checks := make([]editionCheck, len(books))
+	errs := make([]error, len(books))
+	inParallel(n, books, func(i int, e Entry) {
+		checks[i].entry = e
+		author := ""
+		names := bibtex.SplitAuthors(cmp.Or(e.Get("author"), e.Get("editor")))
+		if len(names) > 0 {
+			author = bibtex.FamilyName(names[0])
+		}
+		isbn, _, _ := strings.Cut(e.Get("isbn"), " ")
+		editions, err := resolve.FetchEditions(isbn, unbrace.Replace(e.Get("title")), author)
+		if err != nil && !errors.Is(err, resolve.ErrNotFound) {
+			errs[i] = fmt.Errorf("%s: %w", e.Key, err)
+			return
+		}
+		checks[i].newer, checks[i].found = newerEdition(&e, editions)
+	})
+	return checks, errors.Join(errs...)
+}
+
+// writeEditionChecks lists the books with a newer edition than the one
+// cited, returning how many there are
+func writeEditionChecks(w io.Writer, checks []editionCheck) int {
+	outdated := 0
+	for _, c := range checks {
+		if !c.found {
+			continue
+		}
+		outdated++
+		was := fmt.Sprintf("%s ed.", ordinal(entryEdition(&c.entry)))
+		if y := c.entry.Get("year"); y != "" {
+			was += " " + y
+		}
+		now := fmt.Sprintf("%s ed.", ordinal(editionNumber(c.newer.Edition+" "+c.newer.Title+" "+c.newer.Subtitle)))
+		if c.newer.Year > 0 {
+			now += fmt.Sprintf(" %d", c.newer.Year)
+		}
+		details := []string{}
+		if c.newer.ISBN != "" {
+			details = append(details, "ISBN "+c.newer.ISBN)
+		}
+		if c.newer.Publisher != "" {
+			details = append(details, c.newer.Publisher)
+		}
+		details = append(details, c.newer.Source)
+		fmt.Fprintf(w, "%s  %s -> %s (%s)\n", c.entry.Key, was, now, strings.Join(details, ", "))
+	}
+	return outdated
+}
+
+// ordinal spells a number like 1st, 2nd, 3rd, 11th
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// endnoteTypes maps EndNote reference type names to BibTeX entry types
+var endnoteTypes = map[string]string{
+	"Journal Article":        "article",
+	"Magazine Article":       "article",
+	"Electronic Article":     "article",
+	"Book":                   "book",
+	"Edited Book":            "book",
+	"Electronic Book":        "book",
+	"Book Section":           "incollection",
+	"Conference Paper":       "inproceedings",
+	"Conference Proceedings": "inproceedings",
+	"Thesis":                 "phdthesis",
+	"Report":                 "techreport",
+	"Government Document":    "techreport",
+}
+
+// enText is a text node of an EndNote export, which may be wrapped in
+// <style> elements carrying the formatting
+type enText struct {
+	Text   string   `xml:",chardata"`
+	Styles []string `xml:"style"`
+}
+
+func (t enText) String() string {
+	return strings.TrimSpace(t.Text + strings.Join(t.Styles, ""))
+}
+
+type endnoteRecord struct {
+	RefType struct {
+		Name string `xml:"name,attr"`
+	} `xml:"ref-type"`
+	Authors   []enText `xml:"contributors>authors>author"`
+	Editors   []enText `xml:"contributors>secondary-authors>author"`
+	Title     enText   `xml:"titles>title"`
+	Secondary enText   `xml:"titles>secondary-title"`
+	Journal   enText   `xml:"periodical>full-title"`
+	Year      enText   `xml:"dates>year"`
+	Pages     enText   `xml:"pages"`
+	Volume    enText   `xml:"volume"`
+	Number    enText   `xml:"number"`
+	Publisher enText   `xml:"publisher"`
+	Location  enText   `xml:"pub-location"`
+	ISBN      enText   `xml:"isbn"`
+	DOI       enText   `xml:"electronic-resource-num"`
+	URLs      []enText `xml:"urls>related-urls>url"`
+	PDFs      []enText `xml:"urls>pdf-urls>url"`
+	Abstract  enText   `xml:"abstract"`
+	Keywords  []enText `xml:"keywords>keyword"`
+}
+
+// parseEndNote reads an EndNote XML export. Entries have no keys yet.
+func parseEndNote(r io.Reader) ([]Entry, error) {
+	var doc struct {
+		Records []endnoteRecord `xml:"records>record"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	out := make([]Entry, 0, len(doc.Records))
+	for _, rec := range doc.Records {
+		out = append(out, rec.entry())
+	}
+	return out, nil
+}
+
+func (rec endnoteRecord) entry() Entry {
+	join := func(texts []enText, sep string) string {
+		parts := make([]string, 0, len(texts))
+		for _, t := range texts {
+			if s := t.String(); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, sep)
+	}
+
+	e := Entry{Type: endnoteTypes[rec.RefType.Name]}
+	if e.Type == "" {
+		e.Type = "misc"
+	}
+	e.Set("author", join(rec.Authors, " and "))
+	e.Set("editor", join(rec.Editors, " and "))
+	e.Set("title", rec.Title.String())
+	container := rec.Secondary.String()
+	if container == "" {
+		container = rec.Journal.String()
+	}
+	switch e.Type {
+	case "article":
+		e.Set("journal", container)
+	case "inproceedings", "incollection":
+		e.Set("booktitle", container)
+	}
+	e.Set("year", yearRe.FindString(rec.Year.String()))
+	e.Set("pages", strings.Replace(rec.Pages.String(), "-", "--", 1))
+	e.Set("volume", rec.Volume.String())
+	e.Set("number", rec.Number.String())
+	switch e.Type {
+	case "phdthesis":
+		e.Set("school", rec.Publisher.String())
+	case "techreport":
+		e.Set("institution", rec.Publisher.String())
+	default:
+		e.Set("publisher", rec.Publisher.String())
+	}
+	e.Set("address", rec.Location.String())
+	if isbn := rec.ISBN.String(); isbn != "" {
+		if e.Type == "book" || e.Type == "incollection" {
+			e.Set("isbn", isbn)
+		} else {
+			e.Set("issn", isbn)
+		}
+	}
+	e.Set("doi", resolve.CleanDOI(rec.DOI.String()))
+	if len(rec.URLs) > 0 {
+		e.Set("url", rec.URLs[0].String())
+	}
+	e.Set("abstract", rec.Abstract.String())
+	e.Set("keywords", join(rec.Keywords, ", "))
+	for i := range rec.PDFs {
+		rec.PDFs[i].Text = strings.TrimPrefix(rec.PDFs[i].String(), "internal-pdf://")
+		rec.PDFs[i].Styles = nil
+	}
+	e.Set("file", join(rec.PDFs, ";"))
+	return e
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// The entry types of the library packages, used under their old names
+// throughout the command
+type (
+	Entry = bibtex.Entry
+	Field = bibtex.Field
+	Work  = resolve.Work
+)
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts the environment variables overriding settings
+const envPrefix = "BIBGLOSS_"
+
+// applyEnv overrides settings from BIBGLOSS_* environment variables. The
+// variable name is the upper-cased TOML key, nested tables joined with an
+// underscore: library -> BIBGLOSS_LIBRARY, api_keys.openalex ->
+// BIBGLOSS_API_KEYS_OPENALEX. Lists are comma separated, maps are comma
+// separated name=value pairs.
+func applyEnv(cfg *config) error {
+	return applyEnvStruct(reflect.ValueOf(cfg).Elem(), envPrefix)
+}
+
+func applyEnvStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("toml")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + strings.ToUpper(tag)
+		field := v.Field(i)
+		if !envSetting(field.Type()) {
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			if err := applyEnvStruct(field, name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetInt(int64(n))
+		case reflect.Slice:
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		case reflect.Map:
+			items := map[string]string{}
+			for _, item := range strings.Split(value, ",") {
+				k, v, ok := strings.Cut(item, "=")
+				if !ok {
+					return fmt.Errorf("%s: %q is not a name=value pair", name, item)
+				}
+				items[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("%s: unsupported setting type %s", name, field.Kind())
+		}
+	}
+	return nil
+}
+
+// envNames lists the environment variables applyEnv understands
+func envNames() []string {
+	var names []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			tag := t.Field(i).Tag.Get("toml")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			name := prefix + strings.ToUpper(tag)
+			if !envSetting(t.Field(i).Type) {
+				continue
+			}
+			if t.Field(i).Type.Kind() == reflect.Struct {
+				walk(t.Field(i).Type, name+"_")
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	walk(reflect.TypeOf(config{}), envPrefix)
+	return names
+}
+
+// envSetting reports whether a setting can come from the environment. Lists
+// of tables like [[venues]] and tables of tables like [profiles.thesis] are
+// only read from the config file.
+func envSetting(t reflect.Type) bool {
+	return t.Kind() != reflect.Slice && t.Kind() != reflect.Map || t.Elem().Kind() == reflect.String
+}
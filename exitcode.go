@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// exit codes of the command line interface
+const (
+	exitOK       = 0
+	exitPartial  = 1
+	exitNotFound = 2
+	exitNetwork  = 3
+	exitInvalid  = 4
+)
+
+// exitError attaches an exit code to an error
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withCode wraps err so that the process exits with code
+func withCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code, err}
+}
+
+// exitCode maps an error to the exit code the process should end with
+func exitCode(err error) int {
+	var ee *exitError
+	var ne net.Error
+	var he *resolve.HTTPError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ee):
+		return ee.code
+	case errors.Is(err, resolve.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, resolve.ErrInvalid):
+		return exitInvalid
+	case errors.As(err, &ne), errors.As(err, &he), errors.Is(err, resolve.ErrOffline):
+		return exitNetwork
+	}
+	return exitPartial
+}
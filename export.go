@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// defaultColumns maps the columns of a reading list database to the entry
+// fields they are filled from when the config maps none
+var defaultColumns = map[string]string{
+	"Name":    "title",
+	"Authors": "author",
+	"Year":    "year",
+	"DOI":     "doi",
+	"Key":     "key",
+}
+
+// columnValues returns the values of an entry for a column. Authors and
+// keywords are lists, so multi-select columns get one option per name.
+// The pseudo fields key, type and link stand for the citation key, the
+// entry type and the DOI or URL link.
+func columnValues(e Entry, field string) []string {
+	var values []string
+	switch field {
+	case "key":
+		values = []string{e.Key}
+	case "type":
+		values = []string{e.Type}
+	case "link":
+		values = []string{entryLink(&e)}
+	case "author", "editor":
+		values = givenFirst(e.Get(field))
+	case "keywords":
+		values = entryTags(&e)
+	default:
+		values = []string{unbrace.Replace(e.Get(field))}
+	}
+	return slices.DeleteFunc(values, func(v string) bool { return v == "" })
+}
+
+// sortedColumns returns the column names of a mapping in a stable order
+func sortedColumns(columns map[string]string) []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// sendJSON calls a JSON web API whose answers must not be cached: the
+// services the library is exported to hold the user's own data, and
+// citation alerts must see new works.
+func sendJSON(service, method, u string, h http.Header, body, v any) error {
+	if resolve.Offline {
+		return fmt.Errorf("%s: %w", service, resolve.ErrOffline)
+	}
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return err
+	}
+	for k, vs := range h {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := resolve.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", service, resolve.RedactError(err))
+	}
+	defer res.Body.Close() // nolint:errcheck
+	slog.Debug("response", "url", u, "status", res.StatusCode)
+	if res.StatusCode/100 != 2 {
+		// both APIs explain refused requests in a message field
+		var msg struct {
+			Message string `json:"message"`
+			Error   struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		status := res.Status
+		if json.NewDecoder(res.Body).Decode(&msg) == nil {
+			if m := msg.Message + msg.Error.Message; m != "" {
+				status += ", " + m
+			}
+		}
+		return fmt.Errorf("%s: %w", service, &resolve.HTTPError{URL: u, Status: status})
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// doiSearchRows is the number of CrossRef hits scored per entry
+const doiSearchRows = 5
+
+// doiMatch is the best scoring search hit for an entry without a DOI
+type doiMatch struct {
+	entry Entry
+	paper resolve.Paper
+	score float64
+	found bool
+}
+
+// findDOIs searches CrossRef for the works of entries without a DOI by their
+// title, year and first author, n at a time, keeping the best match of each
+func findDOIs(n int, entries []Entry) ([]doiMatch, error) {
+	var todo []Entry
+	for _, e := range entries {
+		if e.Get("doi") == "" && e.Get("title") != "" {
+			todo = append(todo, e)
+		}
+	}
+	matches := make([]doiMatch, len(todo))
+	errs := make([]error, len(todo))
+	inParallel(n, todo, func(i int, e Entry) {
+		matches[i].entry = e
+		author := ""
+		if a := bibtex.SplitAuthors(e.Get("author")); len(a) > 0 {
+			author = bibtex.FamilyName(a[0])
+		}
+		papers, err := resolve.SearchCrossref(strings.TrimSpace(e.Get("title")+" "+e.Get("year")), author, doiSearchRows)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", e.Key, err)
+			return
+		}
+		for _, p := range papers {
+			if s := library.MatchScore(&e, p); !matches[i].found || s > matches[i].score {
+				matches[i].paper, matches[i].score, matches[i].found = p, s, true
+			}
+		}
+	})
+	return matches, errors.Join(errs...)
+}
+
+// writeDOIMatches lists the best match of each entry with its score, and
+// why entries are left without a DOI
+func writeDOIMatches(w io.Writer, matches []doiMatch, minScore float64) {
+	for _, m := range matches {
+		switch {
+		case !m.found:
+			fmt.Fprintf(w, "%s  no match\n", m.entry.Key)
+		case m.score < minScore:
+			fmt.Fprintf(w, "%s  no match, best %s scored %.2f: %s\n", m.entry.Key, m.paper.DOI, m.score, m.paper.Title)
+		default:
+			fmt.Fprintf(w, "%s  %s  %.2f  %s (%d)\n", m.entry.Key, m.paper.DOI, m.score, m.paper.Title, m.paper.Year)
+		}
+	}
+}
+
+// doiChanges sets the doi field of the entries matched with a score of at
+// least minScore, by their position in the library
+func doiChanges(lib []Entry, matches []doiMatch, minScore float64) map[int]*Entry {
+	dois := map[string]string{}
+	for _, m := range matches {
+		if m.found && m.score >= minScore {
+			dois[m.entry.Key] = m.paper.DOI
+		}
+	}
+	changes := map[int]*Entry{}
+	for i, e := range lib {
+		// an entry given a DOI in the meantime keeps it
+		if doi, ok := dois[e.Key]; ok && e.Get("doi") == "" && !isLocked(&e, "doi") {
+			e.Fields = append([]Field(nil), e.Fields...)
+			e.Set("doi", doi)
+			changes[i] = &e
+		}
+	}
+	return changes
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// followConfig controls the citation alerts of bibgloss follow
+type followConfig struct {
+	// Webhook receives a JSON post for every new citing work. The text
+	// field makes Slack and Mattermost incoming webhooks show it.
+	Webhook string `toml:"webhook"`
+	// Interval is the time between checks of follow watch
+	Interval string `toml:"interval"`
+}
+
+// followState is what follow remembers between runs
+type followState struct {
+	Papers []followedPaper `json:"papers"`
+	// Inbox holds the alerts not dismissed in the TUI yet
+	Inbox []citingAlert `json:"inbox"`
+}
+
+// followedPaper is a work whose citations are watched
+type followedPaper struct {
+	DOI   string `json:"doi"`
+	Title string `json:"title,omitempty"`
+	// Seen holds the DOIs of the citing works already reported
+	Seen []string `json:"seen"`
+	// Checked is the last successful check, zero before the first one
+	Checked time.Time `json:"checked"`
+}
+
+// citingAlert is a new work citing a followed one
+type citingAlert struct {
+	resolve.Paper
+	Cites string    `json:"cites"`
+	Found time.Time `json:"found"`
+}
+
+// followPath returns the follow state file
+func followPath() string {
+	return statePath("follow.json")
+}
+
+// statePath returns $XDG_STATE_HOME/bibgloss/name, falling back to
+// ~/.local/state when the former is unset
+func statePath(name string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "bibgloss", name)
+}
+
+// loadFollow reads the follow state, a missing file is an empty one. The
+// save after it fails when another process, like a running follow watch,
+// saved the state in between.
+func loadFollow(path string) (followState, error) {
+	var s followState
+	// each save is checked against the load before it, not the first one
+	// of a long running watch
+	if _, ok := staged[path]; !ok {
+		forgetFile(path)
+	}
+	data, err := readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// saveFollow writes the follow state, or stages it in a dry run
+func saveFollow(path string, s followState) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if !dryRun {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+	}
+	return writeFile(path, append(data, '\n'))
+}
+
+// citingWorks returns the works citing a DOI, newest first, from OpenAlex
+// and from Semantic Scholar when OpenAlex fails. Works without a DOI are
+// left out.
+func citingWorks(doi string, opts resolve.Options) ([]resolve.Paper, error) {
+	return linkedWorks(doi, false, opts)
+}
+
+// referencedWorks returns the works a DOI cites, like citingWorks
+func referencedWorks(doi string, opts resolve.Options) ([]resolve.Paper, error) {
+	return linkedWorks(doi, true, opts)
+}
+
+// linkedWorks returns the works a DOI cites with references, otherwise
+// those citing it, from OpenAlex or Semantic Scholar, whichever is less
+// busy, and from the other when that fails
+func linkedWorks(doi string, references bool, opts resolve.Options) ([]resolve.Paper, error) {
+	sources := []struct {
+		name, api string
+		linked    func() ([]resolve.Paper, error)
+	}{
+		{resolve.SourceOpenAlex, resolve.OpenAlexAPI, func() ([]resolve.Paper, error) {
+			return openAlexLinked(doi, references, opts.OpenAlexKey)
+		}},
+		{resolve.SourceSemanticScholar, resolve.SemanticScholarAPI, func() ([]resolve.Paper, error) {
+			return semanticScholarLinked(doi, references, opts.SemanticScholarKey)
+		}},
+	}
+	var first error
+	for _, i := range resolve.Rank(sources[0].api, sources[1].api) {
+		papers, err := sources[i].linked()
+		if err == nil {
+			return papers, nil
+		}
+		slog.Info("linked works lookup failed", "resolver", sources[i].name, "doi", doi, "references", references, "err", err)
+		if first == nil {
+			first = err
+		}
+	}
+	return nil, first
+}
+
+func openAlexLinked(doi string, references bool, apiKey string) ([]resolve.Paper, error) {
+	key := ""
+	if apiKey != "" {
+		key = "&api_key=" + url.QueryEscape(apiKey)
+	}
+	var w resolve.OpenAlexWork
+	if err := sendJSON("openalex", http.MethodGet, resolve.OpenAlexAPI+"doi:"+resolve.EscapeDOI(doi)+"?select=id"+key, nil, nil, &w); err != nil {
+		return nil, err
+	}
+	id := strings.TrimPrefix(w.ID, "https://openalex.org/")
+	// cited_by:W lists the works W cites
+	filter := "cites:" + id
+	if references {
+		filter = "cited_by:" + id
+	}
+	q := url.Values{"filter": {filter}, "sort": {"publication_date:desc"}, "per-page": {"100"}}
+	var res struct {
+		Results []resolve.OpenAlexWork `json:"results"`
+	}
+	if err := sendJSON("openalex", http.MethodGet, strings.TrimSuffix(resolve.OpenAlexAPI, "/")+"?"+q.Encode()+key, nil, nil, &res); err != nil {
+		return nil, err
+	}
+	var out []resolve.Paper
+	for _, w := range res.Results {
+		if w.DOI != "" {
+			out = append(out, w.Paper())
+		}
+	}
+	return out, nil
+}
+
+// semanticScholarLink is a citing or cited paper of the Semantic Scholar
+// graph API
+type semanticScholarLink struct {
+	Title       string `json:"title"`
+	Year        int    `json:"year"`
+	Venue       string `json:"venue"`
+	ExternalIDs struct {
+		DOI string `json:"DOI"`
+	} `json:"externalIds"`
+	Authors []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	CitationCount int `json:"citationCount"`
+}
+
+func semanticScholarLinked(doi string, references bool, apiKey string) ([]resolve.Paper, error) {
+	var h http.Header
+	if apiKey != "" {
+		h = http.Header{"X-Api-Key": {apiKey}}
+	}
+	var res struct {
+		Data []struct {
+			CitingPaper semanticScholarLink `json:"citingPaper"`
+			CitedPaper  semanticScholarLink `json:"citedPaper"`
+		} `json:"data"`
+	}
+	path := "/citations"
+	if references {
+		path = "/references"
+	}
+	u := resolve.SemanticScholarAPI + "DOI:" + resolve.EscapeDOI(doi) + path + "?fields=title,year,venue,externalIds,authors,citationCount&limit=100"
+	if err := sendJSON("semantic scholar", http.MethodGet, u, h, nil, &res); err != nil {
+		return nil, err
+	}
+	var out []resolve.Paper
+	for _, d := range res.Data {
+		c := d.CitingPaper
+		if references {
+			c = d.CitedPaper
+		}
+		if c.ExternalIDs.DOI == "" {
+			continue
+		}
+		p := resolve.Paper{DOI: resolve.CleanDOI(c.ExternalIDs.DOI), Title: c.Title, Year: c.Year, Venue: c.Venue, Citations: c.CitationCount}
+		for _, a := range c.Authors {
+			p.Authors = append(p.Authors, a.Name)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// checkFollowed looks for new works citing the followed papers and adds
+// them to the inbox. The first check of a paper only records what already
+// cites it, so following a classic does not flood the inbox.
+func checkFollowed(s *followState, opts resolve.Options) ([]citingAlert, error) {
+	var alerts []citingAlert
+	var errs []error
+	for i := range s.Papers {
+		f := &s.Papers[i]
+		papers, err := citingWorks(f.DOI, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.DOI, err))
+			continue
+		}
+		first := f.Checked.IsZero()
+		f.Checked = time.Now()
+		for _, p := range papers {
+			if slices.Contains(f.Seen, strings.ToLower(p.DOI)) {
+				continue
+			}
+			f.Seen = append(f.Seen, strings.ToLower(p.DOI))
+			if !first {
+				alerts = append(alerts, citingAlert{Paper: p, Cites: f.DOI, Found: f.Checked})
+			}
+		}
+	}
+	s.Inbox = append(s.Inbox, alerts...)
+	return alerts, errors.Join(errs...)
+}
+
+// followWork adds DOIs to the follow list, recording what cites them now
+func followWork(path string, dois []string, opts resolve.Options) error {
+	s, err := loadFollow(path)
+	if err != nil {
+		return err
+	}
+	var added followState
+	for _, doi := range dois {
+		doi = resolve.CleanDOI(doi)
+		if err := resolve.ValidateDOI(doi); err != nil {
+			return withCode(exitInvalid, err)
+		}
+		if slices.ContainsFunc(append(s.Papers, added.Papers...), func(f followedPaper) bool { return strings.EqualFold(f.DOI, doi) }) {
+			continue
+		}
+		f := followedPaper{DOI: doi}
+		if e, _, err := resolve.FetchCrossref(doi); err == nil {
+			f.Title = e.Get("title")
+		}
+		added.Papers = append(added.Papers, f)
+	}
+	// a failing first check is repeated by the next one
+	if _, err := checkFollowed(&added, opts); err != nil {
+		slog.Warn("first check failed", "err", err)
+	}
+	s.Papers = append(s.Papers, added.Papers...)
+	return saveFollow(path, s)
+}
+
+// unfollowWork removes DOIs from the follow list along with their alerts
+func unfollowWork(path string, dois []string) error {
+	s, err := loadFollow(path)
+	if err != nil {
+		return err
+	}
+	for _, doi := range dois {
+		doi = resolve.CleanDOI(doi)
+		n := len(s.Papers)
+		s.Papers = slices.DeleteFunc(s.Papers, func(f followedPaper) bool { return strings.EqualFold(f.DOI, doi) })
+		if len(s.Papers) == n {
+			return withCode(exitNotFound, fmt.Errorf("%s is not followed", doi))
+		}
+		s.Inbox = slices.DeleteFunc(s.Inbox, func(a citingAlert) bool { return strings.EqualFold(a.Cites, doi) })
+	}
+	return saveFollow(path, s)
+}
+
+// runFollowCheck checks the followed papers once, saves what was found and
+// reports it to out and the webhook
+func runFollowCheck(path string, cfg config, out io.Writer) error {
+	s, err := loadFollow(path)
+	if err != nil {
+		return err
+	}
+	alerts, checkErr := checkFollowed(&s, cfg.resolveOptions())
+	if err := saveFollow(path, s); err != nil {
+		return err
+	}
+	for _, a := range alerts {
+		fmt.Fprintln(out, a.text())
+		if cfg.Follow.Webhook != "" {
+			// a failing webhook does not fail the check
+			post := func() error {
+				if err := postAlert(cfg.Follow.Webhook, a); err != nil {
+					slog.Warn("webhook failed", "err", err)
+				}
+				return nil
+			}
+			whenCommitted("post the alert about "+a.DOI+" to the webhook", post) // nolint:errcheck
+		}
+	}
+	return checkErr
+}
+
+// followDaemon checks the followed papers every interval until ctx is done.
+// Failed checks are logged, the next one tries again.
+func followDaemon(ctx context.Context, path string, cfg config, interval time.Duration, out io.Writer) error {
+	fmt.Fprintf(out, "checking followed papers every %s, ctrl+c to stop\n", interval)
+	for {
+		if err := runFollowCheck(path, cfg, out); err != nil {
+			slog.Warn("follow check failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// text describes the alert in one line
+func (a citingAlert) text() string {
+	s := fmt.Sprintf("%s cites %s: %s", a.DOI, a.Cites, a.Title)
+	if len(a.Authors) > 0 {
+		s += " (" + a.Authors[0]
+		if len(a.Authors) > 1 {
+			s += " et al."
+		}
+		s += ")"
+	}
+	return s
+}
+
+func postAlert(webhook string, a citingAlert) error {
+	payload := map[string]any{"text": a.text(), "cites": a.Cites, "paper": a.Paper}
+	return sendJSON("webhook", http.MethodPost, webhook, nil, payload, nil)
+}
+
+// dismissAlerts removes the alerts about a citing DOI from the inbox, those
+// for one followed paper or, with an empty cites, all of them
+func dismissAlerts(path, doi, cites string) ([]citingAlert, error) {
+	s, err := loadFollow(path)
+	if err != nil {
+		return nil, err
+	}
+	s.Inbox = slices.DeleteFunc(s.Inbox, func(a citingAlert) bool {
+		return strings.EqualFold(a.DOI, doi) && (cites == "" || strings.EqualFold(a.Cites, cites))
+	})
+	return s.Inbox, saveFollow(path, s)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "follow.json")
+	if err := saveFollow(path, followState{Papers: []followedPaper{{DOI: "10.1/a"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a save between the load and the save of another is not overwritten
+	s, err := loadFollow(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"papers": [{"doi": "10.1/b"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var changed *changedError
+	if err := saveFollow(path, s); !errors.As(err, &changed) {
+		t.Fatalf("got %v, want the file changed", err)
+	}
+	if s, err = loadFollow(path); err != nil || len(s.Papers) != 1 || s.Papers[0].DOI != "10.1/b" {
+		t.Fatalf("got %+v, %v, want the other save", s, err)
+	}
+	if err := saveFollow(path, s); err != nil {
+		t.Fatalf("save after a fresh load: %v", err)
+	}
+
+	// a dry run stages the state
+	dryRun = true
+	t.Cleanup(func() {
+		dryRun = false
+		clear(staged)
+	})
+	if err := saveFollow(path, followState{}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = loadFollow(path); err != nil || len(s.Papers) != 0 {
+		t.Errorf("dry run loads %+v, %v, want the staged state", s, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(staged[path]) == string(data) {
+		t.Errorf("dry run wrote the state")
+	}
+}
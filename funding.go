@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// fundedWork is a work whose funders are looked up, named by its citation
+// key or, for works from an ORCID record, by its DOI
+type fundedWork struct {
+	name, doi string
+}
+
+// funding is a funder with the awards and works it funded
+type funding struct {
+	Name string
+	DOI  string
+	// Awards are the distinct award numbers, Works the names of the works
+	Awards []string
+	Works  []string
+}
+
+// collectFunding looks up the funders CrossRef records for the works and
+// merges them by their registry DOI, or by name for funders without one.
+// The funders of most works come first. n works are looked up at a time.
+func collectFunding(n int, works []fundedWork) ([]funding, error) {
+	byID := map[string]*funding{}
+	var mu sync.Mutex
+	var errs []error
+	inParallel(n, works, func(_ int, w fundedWork) {
+		funders, err := resolve.FetchFunders(w.doi)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", w.name, err))
+			return
+		}
+		for _, f := range funders {
+			id := strings.ToLower(f.DOI)
+			if id == "" {
+				id = strings.ToLower(strings.TrimSpace(f.Name))
+			}
+			g := byID[id]
+			if g == nil {
+				g = &funding{Name: strings.TrimSpace(f.Name), DOI: f.DOI}
+				byID[id] = g
+			}
+			for _, a := range f.Awards {
+				if a = strings.TrimSpace(a); a != "" && !slices.Contains(g.Awards, a) {
+					g.Awards = append(g.Awards, a)
+				}
+			}
+			if !slices.Contains(g.Works, w.name) {
+				g.Works = append(g.Works, w.name)
+			}
+		}
+	})
+	out := make([]funding, 0, len(byID))
+	for _, g := range byID {
+		slices.Sort(g.Awards)
+		slices.Sort(g.Works)
+		out = append(out, *g)
+	}
+	slices.SortFunc(out, func(a, b funding) int {
+		return cmp.Or(cmp.Compare(len(b.Works), len(a.Works)), cmp.Compare(a.Name, b.Name))
+	})
+	slices.SortFunc(errs, func(a, b error) int { return cmp.Compare(a.Error(), b.Error()) })
+	return out, errors.Join(errs...)
+}
+
+// writeAcknowledgments writes a sentence thanking the funders, naming their
+// awards
+func writeAcknowledgments(w io.Writer, funders []funding) {
+	names := make([]string, len(funders))
+	for i, f := range funders {
+		names[i] = f.Name
+		switch len(f.Awards) {
+		case 0:
+		case 1:
+			names[i] += " (grant " + f.Awards[0] + ")"
+		default:
+			names[i] += " (grants " + joinList(f.Awards) + ")"
+		}
+	}
+	fmt.Fprintf(w, "This work was supported by %s.\n", joinList(names))
+}
+
+// writeFundingTable writes the funders as a Markdown table
+func writeFundingTable(w io.Writer, funders []funding) {
+	fmt.Fprintln(w, "| Funder | Registry DOI | Awards | Works |")
+	fmt.Fprintln(w, "|--------|--------------|--------|-------|")
+	cell := func(s string) string { return strings.ReplaceAll(s, "|", `\|`) }
+	for _, f := range funders {
+		fmt.Fprintf(w, "| %s | %s | %s | %s |\n", cell(f.Name), f.DOI, cell(strings.Join(f.Awards, ", ")), strings.Join(f.Works, ", "))
+	}
+}
+
+// joinList joins items as in a sentence: a, b and c
+func joinList(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/glossary"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// maxGlossaryDescription is the longest description taken from an abstract
+const maxGlossaryDescription = 120
+
+// loadGlossary parses a glossary file. A missing file is an empty glossary.
+func loadGlossary(path string) ([]glossary.Entry, error) {
+	data, err := readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return glossary.Parse(string(data))
+}
+
+// appendGlossary adds a definition to the glossary file, refusing keys that
+// are already defined
+func appendGlossary(path string, g glossary.Entry) error {
+	entries, err := loadGlossary(path)
+	if err != nil {
+		return err
+	}
+	for _, other := range entries {
+		if other.Key == g.Key {
+			return fmt.Errorf("%s: glossary key %s already exists", path, g.Key)
+		}
+	}
+	return appendFile(path, "\n"+g.LaTeX())
+}
+
+// glossaryKey returns the key for a definition added without one, made
+// with the template from its long form and unique across the glossaries. A
+// definition with the same long form is refused, its key is the one to use.
+func glossaryKey(paths []string, tmpl string, g glossary.Entry) (string, error) {
+	taken := map[string]bool{}
+	for _, path := range paths {
+		entries, err := loadGlossary(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		for _, other := range entries {
+			if other.Kind == g.Kind && strings.EqualFold(other.LongForm(), g.LongForm()) {
+				return "", fmt.Errorf("%s: %s is defined already as %s", path, g.LongForm(), other.Key)
+			}
+			taken[other.Key] = true
+		}
+	}
+	return glossary.UniqueKey(glossary.FormatKey(tmpl, g), taken), nil
+}
+
+// researchGlossaryEntry returns the glossary entry of a cited software or
+// dataset under the entry's key: the tool's name and a one-line
+// description that cites the entry, so the glossary and the bibliography
+// point at each other
+func researchGlossaryEntry(e Entry, abstract string) glossary.Entry {
+	name := e.Get("title")
+	// Zenodo names releases like owner/tool: v1.2.0
+	if before, _, ok := strings.Cut(name, ": "); ok {
+		name = before
+	}
+	if _, after, ok := strings.Cut(name, "/"); ok && !strings.Contains(after, " ") {
+		name = after
+	}
+	if name == "" {
+		name = e.Key
+	}
+	if a := e.Get("abstract"); a != "" {
+		abstract = a
+	}
+	desc := firstSentence(resolve.EscapeTeX(strings.Join(strings.Fields(abstract), " ")))
+	if desc == "" {
+		kind := "Software"
+		if e.Type == "dataset" || e.Get("howpublished") == "Dataset" {
+			kind = "Dataset"
+		}
+		desc = kind
+		if authors := bibtex.SplitAuthors(e.Get("author")); len(authors) > 0 {
+			family, _, _ := strings.Cut(authors[0], ",")
+			desc += " by " + family
+			if len(authors) > 1 {
+				desc += " et al."
+			}
+		}
+		if y := e.Get("year"); y != "" {
+			desc += ", " + y
+		}
+	}
+	return glossary.Entry{Kind: glossary.KindEntry, Key: e.Key, Name: name, Description: desc + " \\cite{" + e.Key + "}"}
+}
+
+// firstSentence returns the first sentence of text, cut at a word when it
+// is longer than maxGlossaryDescription
+func firstSentence(text string) string {
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSuffix(text, ".")
+	if len(text) > maxGlossaryDescription {
+		cut := strings.LastIndexByte(text[:maxGlossaryDescription], ' ')
+		if cut < 0 {
+			cut = maxGlossaryDescription
+		}
+		text = text[:cut] + "…"
+	}
+	return text
+}
+
+// linkGlossary adds the glossary entry of a software or dataset entry to
+// path, unless the glossary defines its key already. Other entries are
+// left out; added reports whether one was written.
+func linkGlossary(path string, e Entry, abstract string) (added bool, err error) {
+	if !isResearchOutput(&e) {
+		return false, nil
+	}
+	entries, err := loadGlossary(path)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range entries {
+		if g.Key == e.Key {
+			return false, nil
+		}
+	}
+	return true, appendGlossary(path, researchGlossaryEntry(e, abstract))
+}
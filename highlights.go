@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/pdf"
+)
+
+// errNoPDF is returned for entries whose file field names no PDF
+var errNoPDF = errors.New("no PDF in the file field")
+
+// entryPDFs returns the PDFs of an entry's file field, a plain path or a
+// description:path:mimetype list, relative paths resolved against the
+// library's directory
+func entryPDFs(library string, e *Entry) []string {
+	var paths []string
+	for _, item := range splitEscaped(e.Get("file"), ';') {
+		parts := splitEscaped(item, ':')
+		path := item
+		if len(parts) == 3 {
+			path = parts[1]
+		}
+		path = strings.TrimSpace(unescapeAttachment(path))
+		if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(library), filepath.FromSlash(path))
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// pdfHighlights reads the annotations of the PDFs of an entry. Highlights
+// and notes without text are left out, there is nothing to quote of them.
+func pdfHighlights(library string, e *Entry) ([]pdf.Annotation, error) {
+	paths := entryPDFs(library, e)
+	if len(paths) == 0 {
+		return nil, errNoPDF
+	}
+	var out []pdf.Annotation
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		anns, err := pdf.Annotations(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, a := range anns {
+			if a.Contents != "" {
+				out = append(out, a)
+			}
+		}
+	}
+	return out, nil
+}
+
+// renderHighlights renders annotations as the body of a note section:
+// highlights as quotes and notes as list items, each with its page. org
+// renders org quote blocks instead of Markdown quotes.
+func renderHighlights(anns []pdf.Annotation, org bool) string {
+	var b strings.Builder
+	for _, a := range anns {
+		page := fmt.Sprintf("(p. %d)", a.Page)
+		switch {
+		case a.Kind == "Text" || a.Kind == "FreeText":
+			fmt.Fprintf(&b, "- %s %s\n\n", strings.ReplaceAll(a.Contents, "\n", "\n  "), page)
+		case org:
+			fmt.Fprintf(&b, "#+begin_quote\n%s\n#+end_quote\n%s\n\n", a.Contents, page)
+		default:
+			fmt.Fprintf(&b, "> %s %s\n\n", strings.ReplaceAll(a.Contents, "\n", "\n> "), page)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// setSection replaces the text below a heading of a note, up to the next
+// heading of the same or a higher level, with body. A note without the
+// heading gets it appended at the level of its Notes heading. marker is
+// the character headings start with, like in notesSection.
+func setSection(note string, marker byte, heading, body string) string {
+	level := func(line string) int {
+		n := len(line) - len(strings.TrimLeft(line, string(marker)))
+		if n == 0 || n == len(line) || line[n] != ' ' {
+			return 0
+		}
+		return n
+	}
+	lines := strings.Split(note, "\n")
+	start, end, at, notes := -1, len(lines), 0, 0
+	for i, line := range lines {
+		l := level(line)
+		if l == 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[l:])
+		if notes == 0 && strings.EqualFold(name, "notes") {
+			notes = l
+		}
+		if start < 0 && strings.EqualFold(name, heading) {
+			start, at = i, l
+		} else if start >= 0 && l <= at {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		if notes == 0 {
+			// Markdown notes have the title as their only top heading
+			notes = map[byte]int{'#': 2, '*': 1}[marker]
+		}
+		return strings.TrimRight(note, "\n") + "\n\n" + strings.Repeat(string(marker), notes) + " " + heading + "\n\n" + body + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(strings.Join(lines[:start+1], "\n") + "\n\n" + body + "\n")
+	if end < len(lines) {
+		b.WriteString("\n" + strings.Join(lines[end:], "\n"))
+	}
+	return b.String()
+}
+
+// writeHighlights puts the annotations of an entry into the Highlights
+// section of its Obsidian and org-roam notes, creating the notes that do
+// not exist yet. The rest of a note is left as it is. It returns the notes
+// written.
+func writeHighlights(cfg config, e Entry, anns []pdf.Annotation) ([]string, error) {
+	type target struct {
+		path, note string
+		marker     byte
+	}
+	var targets []target
+	if cfg.Obsidian.enabled() {
+		targets = append(targets, target{notePath(cfg.Obsidian, e), literatureNote(cfg.Obsidian, e, ""), '#'})
+	}
+	if cfg.OrgRoam.enabled() {
+		targets = append(targets, target{orgNotePath(cfg.OrgRoam, e), orgNote(cfg.OrgRoam, e, ""), '*'})
+	}
+	var written []string
+	for _, t := range targets {
+		note := t.note
+		if data, err := readFile(t.path); err == nil {
+			note = string(data)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return written, err
+		} else if !dryRun {
+			if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+				return written, err
+			}
+		}
+		updated := setSection(note, t.marker, "Highlights", renderHighlights(anns, t.marker == '*'))
+		if updated == note {
+			continue
+		}
+		if err := writeFile(t.path, []byte(updated)); err != nil {
+			return written, err
+		}
+		written = append(written, t.path)
+	}
+	return written, nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// historyRecord is a change to one library entry, a line of the history
+// sidecar refs.bib -> refs.history.jsonl
+type historyRecord struct {
+	Time time.Time `json:"time"`
+	Key  string    `json:"key"`
+	// Action is added, changed, renamed or removed
+	Action string `json:"action"`
+	// From is the key a renamed entry had
+	From string `json:"from,omitempty"`
+	// Change is the label of the write, like "update doe21", Command the
+	// bibgloss command that made it
+	Change  string `json:"change"`
+	Command string `json:"command,omitempty"`
+	// Fields are the values that changed, the entry type as field type. An
+	// added entry has all of them new, a removed one all of them old.
+	Fields []fieldChange `json:"fields,omitempty"`
+}
+
+type fieldChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// historyCommand is the command the changes of this run are logged under
+var historyCommand = "bibgloss"
+
+// stagedLabels keep the labels of the writes staged for review by file, so
+// applying them logs what they were
+var stagedLabels = map[string][]string{}
+
+// historyPath returns the history sidecar of a library
+func historyPath(library string) string {
+	return strings.TrimSuffix(library, filepath.Ext(library)) + ".history.jsonl"
+}
+
+// logChanges appends the entry changes a write made to a library, from the
+// snapshot taken before it, to the history of the library. A history that
+// cannot be written does not fail the write.
+func logChanges(path, label string, s *snapshot) {
+	if filepath.Ext(path) != ".bib" {
+		return
+	}
+	if dryRun {
+		stagedLabels[path] = append(stagedLabels[path], label)
+		return
+	}
+	if err := appendHistory(path, label, s.data); err != nil {
+		slog.Warn("history not recorded", "library", path, "err", err)
+	}
+}
+
+func appendHistory(path, label string, before []byte) error {
+	after, err := readFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// entries appended to a library are all its changes, the rest need not
+	// be parsed again
+	var old []Entry
+	if bytes.HasPrefix(after, before) {
+		after = after[len(before):]
+	} else if old, err = bibtex.Parse(bytes.NewReader(before)); err != nil {
+		return err
+	}
+	entries, err := bibtex.Parse(bytes.NewReader(after))
+	if err != nil {
+		return err
+	}
+	records := entryChanges(old, entries)
+	if len(records) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(historyPath(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	now := time.Now().UTC()
+	for _, r := range records {
+		r.Time, r.Change, r.Command = now, label, historyCommand
+		if err := enc.Encode(r); err != nil {
+			f.Close() // nolint:errcheck
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// entryChanges compares two versions of a library by key. An entry that
+// lost its key to one listing it among its aliases was renamed.
+func entryChanges(before, after []Entry) []historyRecord {
+	old := map[string]Entry{}
+	for _, e := range before {
+		old[e.Key] = e
+	}
+	now := map[string]bool{}
+	for _, e := range after {
+		now[e.Key] = true
+	}
+	var records []historyRecord
+	gone := map[string]bool{}
+	for _, e := range after {
+		if o, ok := old[e.Key]; ok {
+			if fields := fieldChanges(&o, &e); len(fields) > 0 {
+				records = append(records, historyRecord{Key: e.Key, Action: "changed", Fields: fields})
+			}
+			continue
+		}
+		r := historyRecord{Key: e.Key, Action: "added", Fields: fieldChanges(&Entry{}, &e)}
+		for _, alias := range e.Aliases() {
+			if o, ok := old[alias]; ok && !now[alias] && !gone[alias] {
+				gone[alias] = true
+				r = historyRecord{Key: e.Key, Action: "renamed", From: alias, Fields: fieldChanges(&o, &e)}
+				break
+			}
+		}
+		records = append(records, r)
+	}
+	for _, o := range before {
+		if !now[o.Key] && !gone[o.Key] {
+			records = append(records, historyRecord{Key: o.Key, Action: "removed", Fields: fieldChanges(&o, &Entry{})})
+		}
+	}
+	return records
+}
+
+// fieldChanges lists the fields whose value differs between two versions
+// of an entry, in the order of the new one and then the removed ones
+func fieldChanges(old, e *Entry) []fieldChange {
+	var changes []fieldChange
+	if old.Type != e.Type {
+		changes = append(changes, fieldChange{"type", old.Type, e.Type})
+	}
+	for _, f := range e.Fields {
+		if v := old.Get(f.Name); v != f.Value {
+			changes = append(changes, fieldChange{f.Name, v, f.Value})
+		}
+	}
+	for _, f := range old.Fields {
+		if e.Get(f.Name) == "" {
+			changes = append(changes, fieldChange{f.Name, f.Value, ""})
+		}
+	}
+	return changes
+}
+
+// entryHistory reads the changes to an entry from the history of a
+// library, oldest first, following it through its renames. The history of
+// the entries merged into it comes along through aliases, its former keys.
+func entryHistory(library, key string, aliases []string) ([]historyRecord, error) {
+	f, err := os.Open(historyPath(library))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+	var all []historyRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		var r historyRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", historyPath(library), line, err)
+		}
+		all = append(all, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	// walking back from the newest, a rename hands over to the old key
+	keys := map[string]bool{key: true}
+	for _, a := range aliases {
+		keys[a] = true
+	}
+	var out []historyRecord
+	for i := len(all) - 1; i >= 0; i-- {
+		r := all[i]
+		if !keys[r.Key] {
+			continue
+		}
+		out = append(out, r)
+		if r.Action == "renamed" {
+			keys[r.From] = true
+		}
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+// writeHistory prints the changes of an entry, oldest first
+func writeHistory(w io.Writer, records []historyRecord) {
+	for i, r := range records {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		what := r.Action + " " + r.Key
+		if r.Action == "renamed" {
+			what = fmt.Sprintf("renamed %s to %s", r.From, r.Key)
+		}
+		fmt.Fprintf(w, "%s  %s by %s (%s)\n", r.Time.Local().Format("2006-01-02 15:04"), what, r.Command, r.Change)
+		for _, f := range r.Fields {
+			switch {
+			case f.Old == "":
+				fmt.Fprintf(w, "  + %s = {%s}\n", f.Name, f.New)
+			case f.New == "":
+				fmt.Fprintf(w, "  - %s = {%s}\n", f.Name, f.Old)
+			default:
+				fmt.Fprintf(w, "  ~ %s = {%s} -> {%s}\n", f.Name, f.Old, f.New)
+			}
+		}
+	}
+}
+
+// writeBlame prints for each field of an entry the change that last set
+// it, "unknown" for values older than the history
+func writeBlame(w io.Writer, e Entry, records []historyRecord) {
+	fields := append([]Field{{Name: "type", Value: e.Type}}, e.Fields...)
+	width := 0
+	for _, f := range fields {
+		width = max(width, len(f.Name))
+	}
+	for _, f := range fields {
+		by := "unknown"
+		for _, r := range slices.Backward(records) {
+			if i := slices.IndexFunc(r.Fields, func(c fieldChange) bool { return c.Name == f.Name }); i >= 0 {
+				if r.Fields[i].New == f.Value {
+					by = fmt.Sprintf("%s %s", r.Time.Local().Format("2006-01-02 15:04"), r.Change)
+				}
+				break
+			}
+		}
+		fmt.Fprintf(w, "%-*s  %-40s  %s\n", width, f.Name, by, f.Value)
+	}
+}
+
+// changesMsg carries the history of a library entry for the TUI
+type changesMsg struct {
+	key, text string
+	err       error
+}
+
+// loadChangesCmd reads the history of a library entry in the background
+func loadChangesCmd(library string, e *Entry) tea.Cmd {
+	key, aliases := e.Key, e.Aliases()
+	return func() tea.Msg {
+		records, err := entryHistory(library, key, aliases)
+		if err != nil {
+			return changesMsg{key: key, err: err}
+		}
+		if len(records) == 0 {
+			return changesMsg{key: key, text: tr("no changes to %s recorded yet", key)}
+		}
+		var b strings.Builder
+		writeHistory(&b, records)
+		return changesMsg{key: key, text: b.String()}
+	}
+}
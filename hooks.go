@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// hookTimeout bounds a single hook run
+const hookTimeout = 30 * time.Second
+
+// hooksConfig are shell commands run on every import with the entry as JSON
+// on stdin
+type hooksConfig struct {
+	// PreImport run in order before the entry is written. One may print a
+	// changed entry, which the next one and the library get; a failing one
+	// stops the import.
+	PreImport []string `toml:"pre_import"`
+	// PostImport run after the entry was written, their output is ignored
+	PostImport []string `toml:"post_import"`
+}
+
+// hookEntry is the JSON a hook reads, and the answer of a pre-import hook
+// that changes the entry
+type hookEntry struct {
+	Type     string            `json:"type"`
+	Key      string            `json:"key"`
+	Fields   map[string]string `json:"fields"`
+	Abstract string            `json:"abstract,omitempty"`
+	// Library is the file the entry is imported into
+	Library string `json:"library,omitempty"`
+}
+
+func newHookEntry(e *Entry, abstract, library string) hookEntry {
+	h := hookEntry{Type: e.Type, Key: e.Key, Fields: map[string]string{}, Abstract: abstract, Library: library}
+	for _, f := range e.Fields {
+		h.Fields[f.Name] = f.Value
+	}
+	return h
+}
+
+// apply updates e to the answer of a hook. Fields keep their order, new
+// ones are appended by name and those left out are dropped; an answer
+// without fields keeps them all.
+func (h hookEntry) apply(e *Entry) {
+	if h.Type != "" {
+		e.Type = strings.ToLower(h.Type)
+	}
+	if h.Key != "" {
+		e.Key = h.Key
+	}
+	if h.Fields == nil {
+		return
+	}
+	var fields []Field
+	for _, f := range e.Fields {
+		if v, ok := h.Fields[f.Name]; ok {
+			fields = append(fields, Field{Name: f.Name, Value: v})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(h.Fields)) {
+		if !slices.ContainsFunc(e.Fields, func(f Field) bool { return f.Name == name }) {
+			fields = append(fields, Field{Name: strings.ToLower(name), Value: h.Fields[name]})
+		}
+	}
+	e.Fields = fields
+}
+
+// runHook runs a hook command through the shell with in on stdin
+func runHook(command string, in []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	cmd.Stdin = bytes.NewReader(in)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("hook %q: %s", command, msg)
+		}
+		return nil, fmt.Errorf("hook %q: %w", command, err)
+	}
+	return out, nil
+}
+
+// preImport runs the pre-import hooks on an entry about to be written to
+// library. A key a hook assigns must be valid and not in taken.
+func preImport(cfg config, e *Entry, abstract, library string, taken map[string]bool) error {
+	for _, command := range cfg.Hooks.PreImport {
+		in, err := json.Marshal(newHookEntry(e, abstract, library))
+		if err != nil {
+			return err
+		}
+		out, err := runHook(command, in)
+		if err != nil {
+			return err
+		}
+		// a hook printing nothing leaves the entry as it is
+		if len(bytes.TrimSpace(out)) == 0 {
+			continue
+		}
+		var h hookEntry
+		if err := json.Unmarshal(out, &h); err != nil {
+			return fmt.Errorf("hook %q: invalid entry: %w", command, err)
+		}
+		key := e.Key
+		h.apply(e)
+		if e.Key == key {
+			continue
+		}
+		if err := bibtex.ValidKey(e.Key); err != nil {
+			return fmt.Errorf("hook %q: %w", command, err)
+		}
+		if taken[e.Key] {
+			return fmt.Errorf("hook %q: key %s already exists", command, e.Key)
+		}
+	}
+	return nil
+}
+
+// postImport runs the post-import hooks on an entry written to library,
+// all of them even when one fails
+func postImport(cfg config, e Entry, abstract, library string) error {
+	if len(cfg.Hooks.PostImport) == 0 {
+		return nil
+	}
+	in, err := json.Marshal(newHookEntry(&e, abstract, library))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, command := range cfg.Hooks.PostImport {
+		if _, err := runHook(command, in); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// languages of the TUI selectable with language. The messages are written
+// in English, the other languages translate them in their catalog.
+const (
+	langEnglish = "en"
+	langGerman  = "de"
+)
+
+// catalogs map the English text of each message to its translation.
+// Messages missing from a catalog are shown in English.
+var catalogs = map[string]map[string]string{
+	langEnglish: {},
+	langGerman:  catalogDE,
+}
+
+// catalog is the translation the TUI is shown in
+var catalog = catalogs[langEnglish]
+
+// setLanguage switches the TUI language. An empty name follows the
+// locale of the environment, falling back to English.
+func setLanguage(name string) {
+	if name == "" {
+		name = systemLanguage()
+	}
+	if c, ok := catalogs[name]; ok {
+		catalog = c
+		return
+	}
+	catalog = catalogs[langEnglish]
+}
+
+// systemLanguage returns the language of the locale set by LC_ALL,
+// LC_MESSAGES or LANG, like de for de_DE.UTF-8
+func systemLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			lang, _, _ := strings.Cut(v, "_")
+			lang, _, _ = strings.Cut(lang, ".")
+			return strings.ToLower(lang)
+		}
+	}
+	return langEnglish
+}
+
+// languageNames lists the languages with a catalog
+func languageNames() []string {
+	names := make([]string, 0, len(catalogs))
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// tr translates a message and formats it like fmt.Sprintf
+func tr(msg string, args ...any) string {
+	if t, ok := catalog[msg]; ok {
+		msg = t
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,154 @@
+package main
+
+// catalogDE is the German translation of the TUI
+var catalogDE = map[string]string{
+	// input screen
+	"Enter a DOI, patent number or thesis identifier": "DOI, Patentnummer oder Hochschulschrift-ID eingeben",
+	"ctrl+n inbox": "ctrl+n Eingang",
+	"ctrl+y queue": "ctrl+y Warteschlange",
+	"(enter to resolve • tab library • ctrl+o manual entry • %s • esc to quit)": "(enter auflösen • tab Bibliothek • ctrl+o manueller Eintrag • %s • esc beenden)",
+	"Resolving %s…":                    "Löse %s auf…",
+	"paste one BibTeX entry, found %d": "einen BibTeX-Eintrag einfügen, gefunden: %d",
+
+	// manual entry form
+	"New entry": "Neuer Eintrag",
+	"type":      "Typ",
+	"(tab/↑/↓ move • ←/→ type • enter next • ctrl+s done • esc back)": "(tab/↑/↓ wechseln • ←/→ Typ • enter weiter • ctrl+s fertig • esc zurück)",
+	"fill in %s":                      "bitte ausfüllen: %s",
+	"Family, Given and Family, Given": "Nachname, Vorname and Nachname, Vorname",
+	"Blog post, Talk, …":              "Blogartikel, Vortrag, …",
+	"Technical Report":                "Technischer Bericht",
+	"City":                            "Ort",
+	"Manuscript in preparation":       "Manuskript in Vorbereitung",
+	"inventors: Family, Given and …":  "Erfinder: Nachname, Vorname and …",
+	"assignee":                        "Anmelder",
+
+	// detail screen
+	"(i import • o open • d pdf • L references • M mentions • ↑/↓ scroll • esc back)": "(i importieren • o öffnen • d PDF • L Literaturliste • M Erwähnungen • ↑/↓ blättern • esc zurück)",
+	"(o open • d pdf • L references • M mentions • ↑/↓ scroll • esc back)":            "(o öffnen • d PDF • L Literaturliste • M Erwähnungen • ↑/↓ blättern • esc zurück)",
+	"Citations:":                           "Zitationen:",
+	"Open access:":                         "Open Access:",
+	"PDF:":                                 "PDF:",
+	"unknown":                              "unbekannt",
+	"closed":                               "geschlossen",
+	"Abstract":                             "Zusammenfassung",
+	"no abstract available":                "keine Zusammenfassung verfügbar",
+	"Fields":                               "Felder",
+	"key: ":                                "Schlüssel: ",
+	"downloading PDF…":                     "lade PDF herunter…",
+	"saved %s":                             "%s gespeichert",
+	"entry has no DOI":                     "Eintrag hat keine DOI",
+	"pattern not found: %s":                "Muster nicht gefunden: %s",
+	"Mentions":                             "Erwähnungen",
+	"no mentions found":                    "keine Erwähnungen gefunden",
+	"Blogs and news":                       "Blogs und Nachrichten",
+	"and %d more":                          "und %d weitere",
+	"from %d of %d events":                 "aus %d von %d Ereignissen",
+	"looking up mentions of %s…":           "suche Erwähnungen von %s…",
+	"%s has no DOI to look up mentions by": "%s hat keine DOI, nach der Erwähnungen gesucht werden können",
+
+	// library
+	"(enter details • o open • t tags • T tag filter • s sort • R related • r rename • x delete • ctrl+p switch • H history • / filter • esc back)":                                        "(enter Details • o öffnen • t Schlagwörter • T nach Schlagwort filtern • s sortieren • R Verwandte • r umbenennen • x löschen • ctrl+p wechseln • H Verlauf • / filtern • esc zurück)",
+	"(enter details • o open • t tags • T tag filter • s sort • R related • r rename • x delete • ctrl+p switch • H history • / filter • ctrl+w focus • </> resize • b bibtex • esc back)": "(enter Details • o öffnen • t Schlagwörter • T nach Schlagwort filtern • s sortieren • R Verwandte • r umbenennen • x löschen • ctrl+p wechseln • H Verlauf • / filtern • ctrl+w Fokus • </> Breite • b BibTeX • esc zurück)",
+	"Library":         "Bibliothek",
+	"Filter: ":        "Filter: ",
+	"by citations":    "nach Zitationen",
+	"by references":   "nach Referenzen",
+	"%d cites":        "%d Zitationen",
+	"%d refs":         "%d Referenzen",
+	"tags: ":          "Schlagwörter: ",
+	"filter by tag: ": "nach Schlagwort filtern: ",
+	"new key: ":       "neuer Schlüssel: ",
+
+	// inbox
+	"(enter details • o open • x dismiss • / filter • esc back)": "(enter Details • o öffnen • x verwerfen • / filtern • esc zurück)",
+	"Inbox":    "Eingang",
+	"cites %s": "zitiert %s",
+	"no new citing works, add papers with bibgloss follow add": "keine neuen zitierenden Arbeiten, Artikel mit bibgloss follow add hinzufügen",
+
+	// queue and clipboard
+	"(i import • enter details • o open • x drop • / filter • esc back)": "(i importieren • enter Details • o öffnen • x verwerfen • / filtern • esc zurück)",
+	"Queue": "Warteschlange",
+	"nothing queued, paste several identifiers at once":             "nichts vorgemerkt, mehrere Kennungen auf einmal einfügen",
+	"resolving %d more…":                                            "löse %d weitere auf…",
+	"resolving %d identifiers in the background, ctrl+y shows them": "löse %d Kennungen im Hintergrund auf, ctrl+y zeigt sie",
+	"clipboard watcher stopped":                                     "Überwachung der Zwischenablage beendet",
+	"resolving %s from the clipboard…":                              "löse %s aus der Zwischenablage auf…",
+	"%s is already in the library as %s":                            "%s ist bereits als %s in der Bibliothek",
+	"queued %s, ctrl+y to import":                                   "%s vorgemerkt, ctrl+y zum Importieren",
+
+	// related works
+	"(i import • space mark • a mark missing • I import marked • enter details • o open • / filter • esc back)": "(i importieren • space markieren • a fehlende markieren • I Markierte importieren • enter Details • o öffnen • / filtern • esc zurück)",
+	"Related":              "Verwandte Arbeiten",
+	"Related to %s":        "Verwandt mit %s",
+	"cited by it":          "von ihr zitiert",
+	"cites it":             "zitiert sie",
+	"importing %s…":        "importiere %s…",
+	"in the library as %s": "in der Bibliothek als %s",
+	"%s has no DOI to look up related works by":          "%s hat keine DOI, um verwandte Arbeiten zu suchen",
+	"looking up the works %s cites and those citing it…": "suche die von %s zitierten und zitierenden Arbeiten…",
+	"References of %s":                                          "Literaturliste von %s",
+	"looking up the works %s cites…":                            "suche die von %s zitierten Arbeiten…",
+	"importing %d works…":                                       "importiere %d Arbeiten…",
+	"nothing marked, space marks a work and a all missing ones": "nichts markiert, space markiert eine Arbeit und a alle fehlenden",
+
+	// library profiles
+	"(enter switch • / filter • esc back)": "(enter wechseln • / filtern • esc zurück)",
+	"Libraries":         "Bibliotheken",
+	"default":           "Standard",
+	"ctrl+p library %s": "ctrl+p Bibliothek %s",
+	"switched to %s":    "gewechselt zu %s",
+	"no profiles configured, see [profiles] in bibgloss config init": "keine Profile eingerichtet, siehe [profiles] in bibgloss config init",
+
+	// near duplicates
+	"the library has a similar title as %s, c compares": "die Bibliothek hat einen ähnlichen Titel als %s, c vergleicht",
+	"similar title as %s":                               "ähnlicher Titel wie %s",
+	"new":                                               "neu",
+
+	// session
+	"Restore the session of %s with %s? (y/n)": "Sitzung vom %s mit %s wiederherstellen? (y/n)",
+	"%d to resolve":              "%d aufzulösen",
+	"%d to import":               "%d zu importieren",
+	"restored the session of %s": "Sitzung vom %s wiederhergestellt",
+
+	// changes and their review
+	"(y apply • n discard • ↑/↓ scroll)": "(y übernehmen • n verwerfen • ↑/↓ blättern)",
+	"(↑/↓ scroll • esc back)":            "(↑/↓ blättern • esc zurück)",
+	"History of %s":                      "Verlauf von %s",
+	"no changes to %s recorded yet":      "noch keine Änderungen an %s aufgezeichnet",
+	"import %s":                          "%s importieren",
+	"imported %s into %s":                "%s in %s importiert",
+	"attach %s":                          "%s anhängen",
+	"delete %s":                          "%s löschen",
+	"rename %s to %s":                    "%s in %s umbenennen",
+	"edit tags of %s":                    "Schlagwörter von %s bearbeiten",
+	"key %s already exists":              "Schlüssel %s existiert bereits",
+	"discarded %s":                       "verworfen: %s",
+	"%s (ctrl+z to undo)":                "%s (ctrl+z macht es rückgängig)",
+	"undid %s":                           "rückgängig gemacht: %s",
+	"nothing to undo":                    "nichts rückgängig zu machen",
+	"%s changed on disk since bibgloss read it, y reloads it and applies the change again, n drops the change": "%s wurde geändert, seit bibgloss die Datei gelesen hat, y lädt sie neu und wendet die Änderung erneut an, n verwirft die Änderung",
+	"kept %s as it is on disk": "%s bleibt unverändert",
+
+	// accessible mode
+	"bibgloss in accessible mode. Enter a DOI to resolve it, help lists the commands.": "bibgloss im barrierefreien Modus. Eine DOI eingeben, um sie aufzulösen, help listet die Befehle.",
+	"DOI or command: ": "DOI oder Befehl: ",
+	"Commands: a DOI resolves it and offers to import it, list reads the library, show followed by a key reads an entry, new followed by misc, techreport, unpublished or patent asks for an entry without a DOI, quit ends.": "Befehle: eine DOI wird aufgelöst und kann importiert werden, list liest die Bibliothek vor, show gefolgt von einem Schlüssel liest einen Eintrag vor, new gefolgt von misc, techreport, unpublished oder patent fragt einen Eintrag ohne DOI ab, quit beendet.",
+	"Give the type of the entry: new misc, new techreport, new unpublished or new patent.": "Den Typ des Eintrags angeben: new misc, new techreport, new unpublished oder new patent.",
+	"%s, optional: ":                  "%s, optional: ",
+	"%s: ":                            "%s: ",
+	"%s is required.":                 "%s ist erforderlich.",
+	"Error: %v":                       "Fehler: %v",
+	"Import it as %s into %s? [y/N] ": "Als %s in %s importieren? [y/N] ",
+	"Not imported.":                   "Nicht importiert.",
+	"Imported %s into %s.":            "%s in %s importiert.",
+	"The library has a similar title as %s: %s.":                                    "Die Bibliothek hat einen ähnlichen Titel als %s: %s.",
+	"%s changed on disk since bibgloss read it. Reload it and import again? [y/N] ": "%s wurde geändert, seit bibgloss die Datei gelesen hat. Neu laden und erneut importieren? [y/N] ",
+	"Found %s.":             "Gefunden: %s.",
+	"By %s.":                "Von %s.",
+	"Published %s.":         "Erschienen %s.",
+	"Cited %d times.":       "%d-mal zitiert.",
+	"Open access.":          "Open Access.",
+	"%d entries in %s.":     "%d Einträge in %s.",
+	"No entry with key %s.": "Kein Eintrag mit dem Schlüssel %s.",
+}
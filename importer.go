@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+)
+
+// importers read the export formats of other reference managers
+var importers = map[string]func(r io.Reader) ([]Entry, error){
+	"bibtex":  bibtex.Parse,
+	"ris":     parseRIS,
+	"endnote": parseEndNote,
+}
+
+// importerFor picks the importer for a file by its extension
+func importerFor(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".bib", ".bibtex":
+		return "bibtex", nil
+	case ".ris":
+		return "ris", nil
+	case ".xml":
+		return "endnote", nil
+	}
+	return "", withCode(exitInvalid, fmt.Errorf("%s: unknown export format, use --from", path))
+}
+
+// importResult counts what importEntries did
+type importResult struct {
+	Added, Merged int
+	// Skipped explains every entry that was left out
+	Skipped []string
+}
+
+// importEntries merges entries exported by another tool into the library,
+// keeping their citation keys, so documents written against the other tool
+// keep compiling. An entry whose key is taken by the same work fills the
+// fields the library entry lacks; a key taken by a different work, or a
+// work already in the library under another key, is skipped. Entries
+// without a key, as RIS and EndNote exports have them, are keyed with the
+// key template and skipped if the library has a work of the same DOI, or
+// the same title and year.
+func importEntries(cfg config, entries []Entry, srcDir string) (importResult, error) {
+	var res importResult
+	bib := cfg.Library
+	existing, err := loadLibrary(bib)
+	if err != nil {
+		return res, err
+	}
+	byKey := map[string]int{}
+	byDOI := map[string]int{}
+	byTitle := map[string]int{}
+	for i, e := range existing {
+		byKey[e.Key] = i
+		if doi := strings.ToLower(e.Get("doi")); doi != "" {
+			byDOI[doi] = i
+		}
+		byTitle[library.NormalizeTitle(e.Get("title"))+e.Get("year")] = i
+	}
+	taken := library.Keys(existing)
+
+	changes := map[int]*Entry{}
+	var added []Entry
+	addedKeys := map[string]bool{}
+	for _, e := range entries {
+		if f := e.Get("file"); f != "" {
+			e.Set("file", relinkAttachments(f, srcDir, bib))
+		}
+		doi := strings.ToLower(e.Get("doi"))
+		if e.Key == "" {
+			if i, ok := byTitle[library.NormalizeTitle(e.Get("title"))+e.Get("year")]; ok && e.Get("title") != "" {
+				res.Skipped = append(res.Skipped, fmt.Sprintf("%q: already in the library as %s", e.Get("title"), existing[i].Key))
+				continue
+			}
+			e.Key = bibtex.UniqueKey(bibtex.FormatKey(cfg.KeyTemplate, &e), taken)
+		}
+		e = bibtex.Convert(e, cfg.Format)
+		if i, ok := byKey[e.Key]; ok {
+			old := existing[i]
+			if !sameWork(&old, &e) {
+				res.Skipped = append(res.Skipped, fmt.Sprintf("%s: key already used for a different work", e.Key))
+				continue
+			}
+			merged := old
+			if changed, ok := changes[i]; ok {
+				merged = *changed
+			}
+			merged.Fields = append([]Field(nil), merged.Fields...)
+			library.MergeInto(&merged, &e)
+			keepLocked(old, &merged)
+			changes[i] = &merged
+			res.Merged++
+			continue
+		}
+		if i, ok := byDOI[doi]; ok && doi != "" {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("%s: already in the library as %s", e.Key, existing[i].Key))
+			continue
+		}
+		if addedKeys[e.Key] {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("%s: duplicate key in the export", e.Key))
+			continue
+		}
+		if err := bibtex.ValidKey(e.Key); err != nil {
+			res.Skipped = append(res.Skipped, err.Error())
+			continue
+		}
+		addedKeys[e.Key] = true
+		taken[e.Key] = true
+		added = append(added, e)
+	}
+	if len(changes) == 0 && len(added) == 0 {
+		return res, nil
+	}
+	_, err = mutate(bib, fmt.Sprintf("import %d entries", len(added)+len(changes)), func() error {
+		if len(changes) > 0 {
+			if err := editLibrary(bib, changes); err != nil {
+				return err
+			}
+		}
+		for i := range added {
+			if err := appendEntry(bib, &added[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Added = len(added)
+	return res, nil
+}
+
+// sameWork reports whether two entries describe the same work, by DOI when
+// both have one and by title otherwise
+func sameWork(a, b *Entry) bool {
+	da, db := a.Get("doi"), b.Get("doi")
+	if da != "" && db != "" {
+		return strings.EqualFold(da, db)
+	}
+	return library.NormalizeTitle(a.Get("title")) == library.NormalizeTitle(b.Get("title"))
+}
+
+// relinkAttachments rewrites a file field relative to the library. It
+// understands plain paths and the description:path:mimetype lists Zotero
+// (Better BibTeX) and JabRef write, where \: and \; escape separators.
+func relinkAttachments(value, srcDir, library string) string {
+	var out []string
+	plain := true
+	for _, item := range splitEscaped(value, ';') {
+		parts := splitEscaped(item, ':')
+		var desc, path, typ string
+		switch len(parts) {
+		case 1:
+			path = parts[0]
+		case 3:
+			desc, path, typ = parts[0], parts[1], parts[2]
+			plain = false
+		default:
+			// a bare Windows path like C:\x.pdf
+			path = item
+		}
+		path = unescapeAttachment(path)
+		// paths that do not resolve are kept as they are
+		found := path
+		if !filepath.IsAbs(found) {
+			found = filepath.Join(srcDir, found)
+		}
+		if _, err := os.Stat(found); err == nil {
+			path = fileField(library, found)
+		}
+		if len(parts) == 3 {
+			path = strings.NewReplacer(`:`, `\:`, `;`, `\;`).Replace(path)
+			out = append(out, desc+":"+path+":"+typ)
+		} else {
+			out = append(out, path)
+		}
+	}
+	if plain && len(out) == 1 {
+		return out[0]
+	}
+	return strings.Join(out, ";")
+}
+
+// splitEscaped splits s at sep, except where it is escaped with a backslash
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeAttachment(s string) string {
+	return strings.NewReplacer(`\:`, `:`, `\;`, `;`, `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type inboxMsg struct{ alerts []citingAlert }
+
+// alertItem adapts a citation alert to the list component
+type alertItem struct{ alert citingAlert }
+
+func (i alertItem) Title() string {
+	if i.alert.Title != "" {
+		return i.alert.Title
+	}
+	return i.alert.DOI
+}
+
+func (i alertItem) Description() string {
+	parts := []string{tr("cites %s", i.alert.Cites)}
+	if len(i.alert.Authors) > 0 {
+		parts = append(parts, i.alert.Authors[0])
+	}
+	if i.alert.Year > 0 {
+		parts = append(parts, fmt.Sprint(i.alert.Year))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (i alertItem) FilterValue() string {
+	return i.alert.Title + " " + strings.Join(i.alert.Authors, " ") + " " + i.alert.Cites
+}
+
+func newInboxList() list.Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 80, 20)
+	l.Title = tr("Inbox")
+	l.FilterInput.Prompt = tr("Filter: ")
+	l.SetShowHelp(false)
+	l.DisableQuitKeybindings()
+	return l
+}
+
+// loadInboxCmd reads the alerts of bibgloss follow in the background
+func loadInboxCmd() tea.Cmd {
+	return func() tea.Msg {
+		s, err := loadFollow(followPath())
+		if err != nil {
+			return errMsg{err}
+		}
+		return inboxMsg{s.Inbox}
+	}
+}
+
+// dismissCmd removes the alerts about a citing DOI, for one followed
+// paper or for all of them
+func dismissCmd(doi, cites string) tea.Cmd {
+	return func() tea.Msg {
+		inbox, err := dismissAlerts(followPath(), doi, cites)
+		if err != nil {
+			return errMsg{err}
+		}
+		return inboxMsg{inbox}
+	}
+}
+
+func alertItems(alerts []citingAlert) []list.Item {
+	items := make([]list.Item, len(alerts))
+	// the newest alerts come first
+	for i, a := range alerts {
+		items[len(alerts)-1-i] = alertItem{a}
+	}
+	return items
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// jabrefMetaRe finds the @Comment{jabref-meta: ...} blocks JabRef keeps
+// its library settings and groups in
+var jabrefMetaRe = regexp.MustCompile(`(?i)@comment\s*\{\s*jabref-meta:\s*`)
+
+// jabrefMeta returns the settings of a JabRef library, keyed by name. It
+// is nil for files JabRef has not written.
+func jabrefMeta(data []byte) map[string]string {
+	var meta map[string]string
+	for _, loc := range jabrefMetaRe.FindAllIndex(data, -1) {
+		body := data[loc[1]:]
+		depth := 1
+		end := 0
+		for ; end < len(body); end++ {
+			if body[end] == '{' {
+				depth++
+			} else if body[end] == '}' {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		name, value, ok := strings.Cut(string(body[:end]), ":")
+		if !ok {
+			continue
+		}
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		meta[strings.TrimSpace(name)] = strings.TrimSuffix(strings.TrimSpace(value), ";")
+	}
+	return meta
+}
+
+// jabrefMetaStart returns the offset of the first jabref-meta block, or -1
+func jabrefMetaStart(data []byte) int {
+	if loc := jabrefMetaRe.FindIndex(data); loc != nil {
+		return loc[0]
+	}
+	return -1
+}
+
+// jabrefGroups lists the names of the groups defined in the grouping
+// setting, like "1 StaticGroup:Reading\;0\;1\;0x8a8a8aff\;\;\;;"
+func jabrefGroups(meta map[string]string) []string {
+	var names []string
+	for _, l := range strings.Split(meta["grouping"], "\n") {
+		_, def, ok := strings.Cut(strings.TrimSpace(l), " ")
+		if !ok {
+			continue
+		}
+		kind, rest, ok := strings.Cut(def, ":")
+		if !ok || kind == "AllEntriesGroup" {
+			continue
+		}
+		// the fields of a group are separated by \;
+		name, _, _ := strings.Cut(rest, `\;`)
+		names = append(names, strings.ReplaceAll(name, `\\`, `\`))
+	}
+	return names
+}
+
+// entryGroups returns the JabRef groups an entry belongs to
+func entryGroups(e *Entry) []string {
+	return parseTags(e.Get("groups"))
+}
+
+// linkFile returns the file field value attaching path to an entry of the
+// library. Plain BibGloss libraries get a path relative to the library;
+// JabRef libraries get JabRef's description:path:type form, relative to
+// the file directory JabRef is configured with.
+func linkFile(library, path string) string {
+	data, err := readFile(library)
+	if err != nil {
+		return fileField(library, path)
+	}
+	meta := jabrefMeta(data)
+	if meta == nil {
+		return fileField(library, path)
+	}
+	dir := filepath.Dir(library)
+	if d := meta["fileDirectory"]; d != "" {
+		if filepath.IsAbs(d) {
+			dir = d
+		} else {
+			dir = filepath.Join(dir, d)
+		}
+	}
+	rel := fileField(filepath.Join(dir, "x.bib"), path)
+	typ := strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), "."))
+	return ":" + strings.NewReplacer(`:`, `\:`, `;`, `\;`).Replace(rel) + ":" + typ
+}
+
+// insertEntry adds an entry to the content of a library file: at the end,
+// or in front of the jabref-meta blocks JabRef keeps last
+func insertEntry(data []byte, e *Entry) []byte {
+	i := jabrefMetaStart(data)
+	if i < 0 {
+		return append(data, "\n"+e.BibTeX()...)
+	}
+	var b bytes.Buffer
+	b.Write(data[:i])
+	b.WriteString(e.BibTeX() + "\n")
+	b.Write(data[i:])
+	return b.Bytes()
+}
+
+// lintGroups reports entries of a JabRef library filed under groups the
+// library does not define
+func lintGroups(data []byte, entries []Entry) []issue {
+	meta := jabrefMeta(data)
+	if meta == nil {
+		return nil
+	}
+	defined := map[string]bool{}
+	for _, g := range jabrefGroups(meta) {
+		defined[strings.ToLower(g)] = true
+	}
+	var issues []issue
+	for i := range entries {
+		for _, g := range entryGroups(&entries[i]) {
+			if !defined[strings.ToLower(g)] {
+				issues = append(issues, issue{entries[i].Key, "JabRef group " + g + " is not defined"})
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// record is one line of the JSON lines output of a batch run
+type record struct {
+	Identifier string            `json:"identifier,omitempty"`
+	Line       int               `json:"line,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Key        string            `json:"key,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	BibTeX     string            `json:"bibtex,omitempty"`
+	Abstract   string            `json:"abstract,omitempty"`
+	// Citations is omitted when the count is unknown
+	Citations  *int              `json:"citations,omitempty"`
+	OpenAccess bool              `json:"open_access,omitempty"`
+	OAStatus   string            `json:"oa_status,omitempty"`
+	OAURL      string            `json:"oa_url,omitempty"`
+	PDFURL     string            `json:"pdf_url,omitempty"`
+	Sources    map[string]string `json:"sources,omitempty"`
+}
+
+// newRecord describes a resolved work, its entry converted to format
+func newRecord(id string, line int, w *Work, format string) record {
+	r := entryRecord(w.Entry, format)
+	r.Identifier = id
+	r.Line = line
+	r.Abstract = w.Abstract
+	r.OpenAccess = w.OpenAccess
+	r.OAStatus = w.OAStatus
+	r.OAURL = w.OAURL
+	r.PDFURL = w.PDFURL
+	r.Sources = w.Sources
+	if w.Citations >= 0 {
+		r.Citations = &w.Citations
+	}
+	return r
+}
+
+// entryRecord describes a library entry converted to format
+func entryRecord(e Entry, format string) record {
+	e = bibtex.Convert(e, format)
+	r := record{Key: e.Key, Type: e.Type, Fields: map[string]string{}, BibTeX: e.BibTeX()}
+	for _, f := range e.Fields {
+		r.Fields[f.Name] = f.Value
+	}
+	return r
+}
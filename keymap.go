@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// keymap profiles selectable with -keymap
+const (
+	keymapDefault = "default"
+	keymapVim     = "vim"
+)
+
+// applyKeymap configures the list and viewport bindings for a profile
+func (m *model) applyKeymap(profile string) error {
+	switch profile {
+	case keymapDefault:
+		return nil
+	case keymapVim:
+	default:
+		return fmt.Errorf("unknown keymap %q", profile)
+	}
+	m.vim = true
+	// gg is handled by vimMotion, a single g must not jump
+	m.list.KeyMap.GoToStart = key.NewBinding(key.WithKeys("home"), key.WithHelp("gg/home", "go to start"))
+	m.list.KeyMap.NextPage = key.NewBinding(key.WithKeys("right", "pgdown", "ctrl+f", "ctrl+d"), key.WithHelp("ctrl+f/ctrl+d", "next page"))
+	m.list.KeyMap.PrevPage = key.NewBinding(key.WithKeys("left", "pgup", "ctrl+b", "ctrl+u"), key.WithHelp("ctrl+b/ctrl+u", "prev page"))
+	m.viewport.KeyMap.PageDown = key.NewBinding(key.WithKeys("pgdown", "ctrl+f"))
+	m.viewport.KeyMap.PageUp = key.NewBinding(key.WithKeys("pgup", "ctrl+b"))
+	m.viewport.KeyMap.HalfPageDown = key.NewBinding(key.WithKeys("ctrl+d"))
+	m.viewport.KeyMap.HalfPageUp = key.NewBinding(key.WithKeys("ctrl+u"))
+	m.preview.KeyMap = m.viewport.KeyMap
+	return nil
+}
+
+// vimMotion handles the modal movement keys of the vim profile that the
+// components do not know about. It reports whether the key was consumed.
+func (m *model) vimMotion(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if !m.vim || (m.state != stateLibrary && m.state != stateDetail) {
+		return false, nil
+	}
+	pending := m.pendingG
+	m.pendingG = false
+
+	switch msg.String() {
+	case "g":
+		if !pending {
+			m.pendingG = true
+			return true, nil
+		}
+		switch {
+		case m.state == stateLibrary && m.previewFocus:
+			m.preview.GotoTop()
+		case m.state == stateLibrary:
+			m.list.Select(0)
+		case m.state == stateDetail:
+			m.viewport.GotoTop()
+		}
+		return true, nil
+	}
+
+	if m.state == stateLibrary && m.previewFocus && msg.String() == "G" {
+		m.preview.GotoBottom()
+		return true, nil
+	}
+	if m.state != stateDetail {
+		return false, nil
+	}
+	switch msg.String() {
+	case "G":
+		m.viewport.GotoBottom()
+	case "/":
+		m.askFor(promptSearch, "/", "")
+		return true, textinput.Blink
+	case "n":
+		m.searchDetail(1)
+	case "N":
+		m.searchDetail(-1)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// searchDetail scrolls the detail viewport to the next line matching the
+// search query in direction dir
+func (m *model) searchDetail(dir int) {
+	if m.search == "" {
+		return
+	}
+	lines := strings.Split(ansi.Strip(m.detail), "\n")
+	query := strings.ToLower(m.search)
+	at := m.viewport.YOffset
+	for i := 1; i <= len(lines); i++ {
+		n := ((at+dir*i)%len(lines) + len(lines)) % len(lines)
+		if strings.Contains(strings.ToLower(lines[n]), query) {
+			m.viewport.SetYOffset(n)
+			m.message = ""
+			return
+		}
+	}
+	m.message = tr("pattern not found: %s", m.search)
+}
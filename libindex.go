@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// indexPath returns where the index of a library is stored, or "" when the
+// cache is disabled
+func indexPath(library string) string {
+	if resolve.CacheDir == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(library)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(filepath.Dir(resolve.CacheDir), "index", hex.EncodeToString(sum[:8])+".gob")
+}
+
+// openIndex returns the index of a library, rebuilding it when the file
+// changed since it was written. Changes staged by a dry run are indexed
+// without storing them.
+func openIndex(bib string) (*library.Index, error) {
+	if _, ok := staged[bib]; ok {
+		entries, err := loadLibrary(bib)
+		if err != nil {
+			return nil, err
+		}
+		return library.BuildIndex(entries, 0, time.Time{}), nil
+	}
+	info, err := os.Stat(bib)
+	if errors.Is(err, fs.ErrNotExist) {
+		return library.BuildIndex(nil, 0, time.Time{}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	path := indexPath(bib)
+	if x, ok := library.ReadIndex(path); ok && x.Size == info.Size() && x.ModTime.Equal(info.ModTime()) {
+		return x, nil
+	}
+	entries, err := loadLibrary(bib)
+	if err != nil {
+		return nil, err
+	}
+	x := library.BuildIndex(entries, info.Size(), info.ModTime())
+	if path != "" {
+		// a missing index only costs the next run a parse
+		if err := library.WriteIndex(path, x); err != nil {
+			slog.Debug("index not written", "path", path, "err", err)
+		}
+	}
+	return x, nil
+}
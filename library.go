@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+)
+
+// loadLibrary parses the library file. A missing file is an empty library.
+func loadLibrary(path string) ([]Entry, error) {
+	r, err := openLibrary(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		rememberFile(path, fileSum{})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() // nolint:errcheck
+	if _, ok := staged[path]; ok {
+		return bibtex.Parse(r)
+	}
+	// the checksum of the file is taken on the way, for checkUnchanged
+	h := sha256.New()
+	entries, err := bibtex.Parse(io.TeeReader(r, h))
+	if err == nil || errors.As(err, new(bibtex.ParseErrors)) {
+		var s fileSum
+		s.exists = true
+		h.Sum(s.sum[:0])
+		rememberFile(path, s)
+	}
+	return entries, err
+}
+
+// openLibrary opens the library file as the current run left it, for
+// reading it entry by entry with bibtex.Each
+func openLibrary(path string) (io.ReadCloser, error) {
+	if data, ok := staged[path]; ok {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return os.Open(path)
+}
+
+// appendEntry writes an entry to the end of the library file, creating it
+// if necessary. In JabRef libraries it goes before JabRef's settings.
+func appendEntry(path string, e *Entry) error {
+	data, err := readFile(path)
+	if err == nil && jabrefMetaStart(data) >= 0 {
+		return writeFile(path, insertEntry(data, e))
+	}
+	return appendFile(path, "\n"+e.BibTeX())
+}
+
+// rewriteEntry replaces the entry stored under key with e, leaving the rest
+// of the file untouched. A nil entry removes it.
+func rewriteEntry(path, key string, e *Entry) error {
+	entries, err := loadLibrary(path)
+	if err != nil {
+		return err
+	}
+	for i, old := range entries {
+		if old.Key == key {
+			return editLibrary(path, map[int]*Entry{i: e})
+		}
+	}
+	return fmt.Errorf("%s: no entry with key %s", path, key)
+}
+
+// editEntry applies edit to the entry stored under key, as it is in the
+// file now, and writes it back
+func editEntry(path, key string, edit func(e *Entry)) error {
+	entries, err := loadLibrary(path)
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		if e.Key == key {
+			edit(&e)
+			return editLibrary(path, map[int]*Entry{i: &e})
+		}
+	}
+	return fmt.Errorf("%s: no entry with key %s", path, key)
+}
+
+// editLibrary replaces entries of the library file by their position in
+// the file, leaving everything between them untouched. A nil entry removes
+// it.
+func editLibrary(path string, changes map[int]*Entry) error {
+	data, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	entries, err := bibtex.Parse(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return writeFile(path, library.Splice(data, entries, changes))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// noLicense groups the entries no license is known for
+const noLicense = "no license recorded"
+
+// licensedEntry is an entry of the licenses report. Looked up licenses are
+// not in the library.
+type licensedEntry struct {
+	entry    Entry
+	license  string
+	lookedUp bool
+}
+
+// isResearchOutput reports whether e cites software or a dataset: an entry
+// of that biblatex type or BibTeX misc kind, one with a license or one
+// archived on Zenodo or GitHub
+func isResearchOutput(e *Entry) bool {
+	switch {
+	case e.Type == "software", e.Type == "dataset":
+		return true
+	case e.Type == "misc" && (e.Get("howpublished") == "Software" || e.Get("howpublished") == "Dataset"):
+		return true
+	case e.Get("license") != "":
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(e.Get("doi")), "10.5281/zenodo.") || resolve.IsGitHubRepo(e.Get("url"))
+}
+
+// entryLicenses returns the licenses of the software and datasets among
+// entries. Those without a license field are looked up at DataCite by
+// their DOI, or at GitHub by their URL.
+func entryLicenses(entries []Entry) ([]licensedEntry, error) {
+	var out []licensedEntry
+	var errs []error
+	for _, e := range entries {
+		if !isResearchOutput(&e) {
+			continue
+		}
+		l := licensedEntry{entry: e, license: e.Get("license")}
+		if l.license == "" {
+			var err error
+			switch {
+			case e.Get("doi") != "":
+				l.license, err = resolve.FetchLicense(e.Get("doi"))
+			case resolve.IsGitHubRepo(e.Get("url")):
+				l.license, err = resolve.FetchGitHubLicense(e.Get("url"))
+			}
+			// CrossRef DOIs are unknown to DataCite
+			if err != nil && !errors.Is(err, resolve.ErrNotFound) {
+				errs = append(errs, fmt.Errorf("%s: %w", e.Key, err))
+			}
+			l.lookedUp = l.license != ""
+		}
+		out = append(out, l)
+	}
+	return out, errors.Join(errs...)
+}
+
+// writeLicenses lists the entries grouped by license, the most used
+// licenses first and entries without one last
+func writeLicenses(w io.Writer, entries []licensedEntry) {
+	groups := map[string][]licensedEntry{}
+	for _, l := range entries {
+		name := l.license
+		if name == "" {
+			name = noLicense
+		}
+		groups[name] = append(groups[name], l)
+	}
+	names := slices.Collect(maps.Keys(groups))
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(
+			cmp.Compare(boolInt(a == noLicense), boolInt(b == noLicense)),
+			cmp.Compare(len(groups[b]), len(groups[a])),
+			cmp.Compare(a, b),
+		)
+	})
+	lookedUp := false
+	for i, name := range names {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%d)\n", name, len(groups[name]))
+		for _, l := range groups[name] {
+			mark := ""
+			if l.lookedUp {
+				mark, lookedUp = " *", true
+			}
+			fmt.Fprintf(w, "  %s%s  %s\n", l.entry.Key, mark, l.entry.Get("title"))
+		}
+	}
+	if lookedUp {
+		fmt.Fprintln(w, "\n* looked up, not recorded in the library; bibgloss update <key> records licenses of DataCite DOIs")
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// linkCount returns 1, hard links are not looked for where the platform
+// does not report them
+func linkCount(info fs.FileInfo) int {
+	return 1
+}
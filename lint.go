@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// requiredFields lists the fields BibTeX styles need per entry type. An
+// entry satisfies a requirement of the form "a|b" with either field.
+var requiredFields = map[string][]string{
+	"article":       {"author", "title", "journal", "year"},
+	"book":          {"author|editor", "title", "publisher", "year"},
+	"inbook":        {"author|editor", "title", "publisher", "year"},
+	"incollection":  {"author", "title", "booktitle", "publisher", "year"},
+	"inproceedings": {"author", "title", "booktitle", "year"},
+	"mastersthesis": {"author", "title", "school", "year"},
+	"phdthesis":     {"author", "title", "school", "year"},
+	"techreport":    {"author", "title", "institution", "year"},
+	"misc":          {"title"},
+}
+
+// issue is a single lint finding
+type issue struct {
+	Key     string
+	Message string
+}
+
+func (i issue) String() string {
+	return i.Key + ": " + i.Message
+}
+
+// syntaxIssues turns the syntax errors of a library into issues located by
+// line and column
+func syntaxIssues(errs bibtex.ParseErrors) []issue {
+	issues := make([]issue, 0, len(errs))
+	for _, e := range errs {
+		msg := e.Msg
+		if e.Key != "" {
+			msg = e.Key + ": " + msg
+		}
+		issues = append(issues, issue{fmt.Sprintf("line %d, column %d", e.Line, e.Col), msg})
+	}
+	return issues
+}
+
+// lintEntries checks entries for duplicate keys, invalid keys, missing
+// required fields and aliases shared by entries
+func lintEntries(entries []Entry) []issue {
+	var issues []issue
+	seen := map[string]bool{}
+	for i := range entries {
+		e := &entries[i]
+		if err := bibtex.ValidKey(e.Key); err != nil {
+			issues = append(issues, issue{e.Key, err.Error()})
+		}
+		if seen[e.Key] {
+			issues = append(issues, issue{e.Key, "duplicate key"})
+		}
+		seen[e.Key] = true
+
+		for _, req := range requiredFields[e.Type] {
+			if !hasAny(e, strings.Split(req, "|")) {
+				issues = append(issues, issue{e.Key, fmt.Sprintf("@%s is missing %s", e.Type, strings.ReplaceAll(req, "|", " or "))})
+			}
+		}
+		if strings.Count(e.BibTeX(), "{") != strings.Count(e.BibTeX(), "}") {
+			issues = append(issues, issue{e.Key, "unbalanced braces"})
+		}
+	}
+	// an alias must lead to a single entry
+	aliased := map[string]string{}
+	for i := range entries {
+		e := &entries[i]
+		for _, k := range e.Aliases() {
+			switch {
+			case seen[k] && k != e.Key:
+				issues = append(issues, issue{e.Key, fmt.Sprintf("alias %s is the key of another entry", k)})
+			case aliased[k] != "" && aliased[k] != e.Key:
+				issues = append(issues, issue{e.Key, fmt.Sprintf("alias %s is also an alias of %s", k, aliased[k])})
+			}
+			aliased[k] = e.Key
+		}
+	}
+	return issues
+}
+
+func hasAny(e *Entry, names []string) bool {
+	for _, n := range names {
+		if strings.TrimSpace(e.Get(n)) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// errLocked is returned by tryLock while another process holds the lock
+var errLocked = errors.New("locked")
+
+// tryLock does not lock where flock is unavailable; writes are still
+// atomic, only concurrent bibgloss processes are not serialized
+func tryLock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// lockedField lists the fields of an entry that refreshing it must leave
+// alone, like locked = {title, journal}. Being a field of the entry, the
+// locks survive renames, exports and editing the library in other tools,
+// which ignore it like any field they do not know.
+const lockedField = "locked"
+
+// lockedFields returns the names of the locked fields of an entry
+func lockedFields(e *Entry) []string {
+	names := parseTags(e.Get(lockedField))
+	for i, n := range names {
+		names[i] = strings.ToLower(n)
+	}
+	return names
+}
+
+// isLocked reports whether a field of an entry is locked
+func isLocked(e *Entry, name string) bool {
+	return slices.Contains(lockedFields(e), strings.ToLower(name))
+}
+
+// setLocks replaces the locked fields of an entry
+func setLocks(e *Entry, names []string) {
+	slices.Sort(names)
+	e.Set(lockedField, strings.Join(slices.Compact(names), ", "))
+}
+
+// keepLocked undoes what a refresh changed in the locked fields of old,
+// the entry type included when it is locked, and returns the names of the
+// fields it kept
+func keepLocked(old Entry, e *Entry) []string {
+	var kept []string
+	for _, name := range lockedFields(&old) {
+		if name == "type" {
+			if e.Type != old.Type {
+				e.Type = old.Type
+				kept = append(kept, name)
+			}
+			continue
+		}
+		if was := old.Get(name); e.Get(name) != was {
+			e.Set(name, was)
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// setupLogging installs the default logger. Verbosity 0 logs warnings, 1
+// adds resolver activity and 2 every request. Logs go to file if set, and
+// are dropped otherwise while the TUI owns the terminal.
+func setupLogging(verbosity int, file string, tui bool) (io.Closer, error) {
+	level := slog.LevelWarn
+	switch {
+	case verbosity >= 2:
+		level = slog.LevelDebug
+	case verbosity == 1:
+		level = slog.LevelInfo
+	}
+
+	var w io.Writer = os.Stderr
+	var closer io.Closer = io.NopCloser(nil)
+	switch {
+	case file != "":
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		w, closer = f, f
+	case tui:
+		w = io.Discard
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr})))
+	return closer, nil
+}
+
+// redactAttr keeps API keys and addresses in the URLs of requests out of the
+// logs
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == "url" && a.Value.Kind() == slog.KindString {
+		a.Value = slog.StringValue(resolve.RedactURL(a.Value.String()))
+	}
+	return a
+}
@@ -1,110 +1,1059 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-const url = "https://charm.sh/"
+// version is set at build time with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
 
 type (
-	statusMsg int
 	// errMsg    error
 	errMsg struct{ error }
+	// similar are the library entries with a title close to the work's
+	workMsg struct {
+		work    *Work
+		similar []Entry
+	}
+	importedMsg struct {
+		// key is the key of the work in the library, which a pre-import
+		// hook may have changed
+		work *Work
+		key  string
+		undo *snapshot
+		// synced is the error mirroring the entry to Zotero or running
+		// the post-import hooks, if any
+		synced error
+		// similar are the keys of entries with a title close to it
+		similar []string
+	}
+	pdfMsg struct {
+		key, file string
+		undo      *snapshot
+	}
 )
 
+// state is the screen currently shown by the TUI
+type state int
+
+const (
+	stateInput state = iota
+	stateFetching
+	stateDetail
+	stateLibrary
+	// stateReview shows a library change before it is written
+	stateReview
+	// stateInbox lists the new works citing followed papers
+	stateInbox
+	// stateManual is the form for entries without an identifier
+	stateManual
+	// stateQueue lists the resolved works waiting for import
+	stateQueue
+	// stateRelated lists the references and citing works of a library entry,
+	// or the reference list of the work in the detail view
+	stateRelated
+	// stateProfiles switches between the libraries of the config
+	stateProfiles
+	// stateChanges shows the history of a library entry
+	stateChanges
+)
+
+// prompt is the single-line question shown below the library list
+type prompt int
+
+const (
+	promptNone prompt = iota
+	promptTags
+	promptRename
+	promptTagFilter
+	promptSearch
+	promptKey
+)
+
+// tuiWorkers is the size of the TUI's engine, which waits for one DOI at a
+// time
+const tuiWorkers = 1
+
 type model struct {
 	textInput textinput.Model
-	status    int
-	err       error
+	spinner   spinner.Model
+	viewport  viewport.Model
+	list      list.Model
+	// preview shows the selected library entry next to the list on wide
+	// terminals, previewKey is the key of the entry it shows. splitRatio is
+	// the share of the list in percent, previewFocus sends the keys to the
+	// preview, previewBibTeX shows the entry as BibTeX instead of details.
+	preview       viewport.Model
+	previewKey    string
+	splitRatio    int
+	previewFocus  bool
+	previewBibTeX bool
+	inbox         list.Model
+	// related lists the works relatedKey cites and those citing it
+	related       list.Model
+	relatedKey    string
+	relatedPapers []relatedPaper
+	// relatedMarked holds the DOIs marked for import, relatedFrom the
+	// screen the related works were opened from and, for the detail view,
+	// relatedWork and relatedPrev the work it showed and where it came from
+	relatedMarked map[string]bool
+	relatedFrom   state
+	relatedWork   *Work
+	relatedPrev   state
+	// profiles lists the libraries of the config, profilesFrom is the
+	// screen the switcher was opened from
+	profiles     list.Model
+	profilesFrom state
+	// queue holds the resolved works waiting for import, pending the
+	// identifiers still to resolve into it, one at a time while resolving
+	queue     list.Model
+	pending   []string
+	resolving bool
+	// restore is the saved session offered at start, until it is restored
+	// or declined
+	restore *session
+	// clipText is the last text read from the clipboard, clipRead is set
+	// once it was read
+	clipText string
+	clipRead bool
+	// ask reads tags for the library browser
+	ask       textinput.Model
+	prompt    prompt
+	entries   []Entry
+	tagFilter string
+	// metrics are the enriched counts by key, sortBy orders the library
+	metrics map[string]entryMetrics
+	sortBy  librarySort
+	// detail is the rendered content of the detail viewport
+	detail string
+	// mentions are the looked up mentions of works by DOI
+	mentions map[string]workMentions
+	// similar are the library entries with a title close to the work in
+	// the detail view, compare is the position of the one shown beside it
+	// counting from 1, 0 for none
+	similar []Entry
+	compare int
+	// search is the last query of the detail view's / search
+	search string
+	// vim enables the modal movement keys, pendingG marks a pending gg
+	vim      bool
+	pendingG bool
+	// altScreen tracks whether the program currently owns the full terminal
+	altScreen bool
+	// history holds the snapshots taken before each library change
+	history []*snapshot
+	// form is the manual entry form
+	form manualForm
+	// review is the change shown in diffView waiting for confirmation
+	review *pendingChange
+	// conflict is a change refused because the library changed on disk,
+	// waiting for the user to apply it again or drop it
+	conflict *conflictMsg
+	diffView viewport.Model
+	// changes shows the history of the entry changesKey
+	changes    viewport.Model
+	changesKey string
+	state      state
+	// prev is the screen the detail view returns to
+	prev state
+	// fetchFrom is the screen a running fetch was started from
+	fetchFrom state
+	// fetches resolves the DOIs entered in the TUI
+	fetches *resolve.Engine
+	work    *Work
+	cfg     config
+	message string
+	err     error
+	width   int
+	height  int
 }
 
 // Default values
-func initialModel() model {
+func initialModel(cfg config) (model, error) {
 	ti := textinput.New()
 	ti.Placeholder = "10.1016/j.icarus.2016.12.026"
 	ti.Focus()
 	ti.CharLimit = 156
 	ti.Width = 40
 
-	return model{
-		textInput: ti,
-		status:    0,
-		err:       nil,
+	m := model{
+		textInput:  ti,
+		spinner:    spinner.New(spinner.WithSpinner(spinner.Dot)),
+		viewport:   viewport.New(80, 20),
+		preview:    viewport.New(40, 20),
+		splitRatio: splitDefault,
+		diffView:   viewport.New(80, 20),
+		changes:    viewport.New(80, 20),
+		list:       newLibraryList(),
+		inbox:      newInboxList(),
+		queue:      newQueueList(),
+		related:    newRelatedList(),
+		profiles:   newProfileList(),
+		ask:        textinput.New(),
+		state:      stateInput,
+		cfg:        cfg,
+		altScreen:  !cfg.Inline,
+		fetches:    resolve.NewEngine(tuiWorkers, cfg.resolveOptions()),
+		err:        nil,
 	}
+	if err := m.applyKeymap(cfg.Keymap); err != nil {
+		return m, err
+	}
+	return m, nil
 }
 
 func (m model) Init() tea.Cmd {
-	return textinput.Blink
+	cmds := []tea.Cmd{textinput.Blink, loadInboxCmd()}
+	if m.cfg.WatchClipboard {
+		cmds = append(cmds, watchClipboard())
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.viewport.Width = msg.Width
+		m.viewport.Height = msg.Height - 4
+		m.diffView.Width = msg.Width
+		m.diffView.Height = msg.Height - 4
+		m.changes.Width = msg.Width
+		m.changes.Height = msg.Height - 4
+		m.layoutLibrary()
+		m.inbox.SetSize(msg.Width, msg.Height-2)
+		m.queue.SetSize(msg.Width, msg.Height-2)
+		m.related.SetSize(msg.Width, msg.Height-2)
+		m.profiles.SetSize(msg.Width, msg.Height-2)
+		if m.work != nil {
+			m.setDetail()
+		}
+
 	// catch key presses
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "ctrl+c", "esc":
+		case "ctrl+c":
 			return m, tea.Quit
-			// This will result in catching any keys
-			// default:
-			// 	return m, nil
+		case "ctrl+t":
+			m.altScreen = !m.altScreen
+			if m.altScreen {
+				return m, tea.EnterAltScreen
+			}
+			return m, tea.ExitAltScreen
+		case "ctrl+z":
+			if len(m.history) == 0 {
+				m.message = tr("nothing to undo")
+				return m, nil
+			}
+			s := m.history[len(m.history)-1]
+			m.history = m.history[:len(m.history)-1]
+			return m, undo(m.cfg.Library, s)
+		}
+		if c := m.conflict; c != nil {
+			switch msg.String() {
+			case "y":
+				m.conflict, m.err = nil, nil
+				forgetFile(c.err.path)
+				return m, onConflict(c.retry)
+			case "n", "esc":
+				m.conflict, m.err = nil, nil
+				m.message = tr("kept %s as it is on disk", c.err.path)
+				return m, nil
+			}
+			return m, nil
+		}
+		if s := m.restore; s != nil {
+			switch msg.String() {
+			case "y":
+				m.restore = nil
+				return m, m.restoreSession(s)
+			case "n", "esc":
+				m.restore = nil
+				return m, nil
+			}
+			return m, nil
+		}
+		if m.prompt == promptNone && m.list.FilterState() != list.Filtering {
+			if handled, cmd := m.vimMotion(msg); handled {
+				return m, cmd
+			}
 		}
+		switch m.state {
+		case stateInput:
+			if msg.Paste && isPastedBibTeX(string(msg.Runes)) {
+				text := string(msg.Runes)
+				// the spinner names the entry by its first line
+				first, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+				m.textInput.SetValue(first)
+				m.fetchFrom = stateInput
+				m.state = stateFetching
+				m.err = nil
+				m.message = ""
+				return m, tea.Batch(m.spinner.Tick, pastedWork(m.fetches, m.cfg, text))
+			}
+			if ids, ok := batchIdentifiers(string(msg.Runes)); msg.Paste && ok {
+				m.err = nil
+				m.message = tr("resolving %d identifiers in the background, ctrl+y shows them", len(ids))
+				return m, m.enqueue(ids...)
+			}
+			switch msg.String() {
+			case "esc":
+				return m, tea.Quit
+			case "tab":
+				m.state = stateLibrary
+				m.err = nil
+				return m, tea.Batch(loadLibraryCmd(m.cfg.Library), loadMetricsCmd(m.cfg.Library))
+			case "ctrl+n":
+				m.state = stateInbox
+				m.err = nil
+				return m, loadInboxCmd()
+			case "ctrl+y":
+				m.state = stateQueue
+				m.err = nil
+				return m, nil
+			case "ctrl+p":
+				return m, m.showProfiles()
+			case "ctrl+o":
+				m.state = stateManual
+				m.err = nil
+				m.message = ""
+				m.form = newManualForm(0, manualForm{})
+				return m, m.form.move(1)
+			case "enter":
+				if m.textInput.Value() == "" {
+					return m, nil
+				}
+				m.fetchFrom = stateInput
+				m.state = stateFetching
+				m.err = nil
+				m.message = ""
+				return m, tea.Batch(m.spinner.Tick, fetchWork(m.fetches, m.cfg, m.textInput.Value()))
+			}
+		case stateDetail:
+			if m.prompt != promptNone {
+				return m.updatePrompt(msg)
+			}
+			switch msg.String() {
+			case "esc", "q":
+				m.state = m.prev
+				return m, nil
+			case "o":
+				return m, openLink(&m.work.Entry)
+			case "d":
+				m.err = nil
+				m.message = tr("downloading PDF…")
+				return m, onConflict(fetchPDF(m.cfg, *m.work, m.prev == stateLibrary))
+			case "c":
+				if len(m.similar) > 0 {
+					m.compareNext()
+				}
+				return m, nil
+			case "L":
+				if m.work.Entry.Get("doi") != "" {
+					return m, m.showRelated(&m.work.Entry)
+				}
+				return m, nil
+			case "M":
+				doi := strings.ToLower(m.work.Entry.Get("doi"))
+				if doi == "" {
+					m.err = errors.New(tr("%s has no DOI to look up mentions by", m.work.Entry.Key))
+					return m, nil
+				}
+				m.err = nil
+				m.message = tr("looking up mentions of %s…", doi)
+				return m, loadMentionsCmd(doi, m.cfg.Email)
+			case "i", "enter":
+				if m.prev != stateLibrary {
+					m.askFor(promptKey, tr("key: "), m.work.Entry.Key)
+					return m, textinput.Blink
+				}
+			}
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		case stateLibrary:
+			if m.prompt != promptNone {
+				return m.updatePrompt(msg)
+			}
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			item, selected := m.list.SelectedItem().(entryItem)
+			switch msg.String() {
+			case "t":
+				if selected {
+					m.askFor(promptTags, tr("tags: "), strings.Join(entryTags(&item.entry), ", "))
+				}
+				return m, textinput.Blink
+			case "T":
+				m.askFor(promptTagFilter, tr("filter by tag: "), m.tagFilter)
+				return m, textinput.Blink
+			case "s":
+				m.sortBy = (m.sortBy + 1) % (sortReferences + 1)
+				return m, m.refreshList()
+			case "r":
+				if selected {
+					m.askFor(promptRename, tr("new key: "), item.entry.Key)
+				}
+				return m, textinput.Blink
+			case "x", "delete":
+				if selected {
+					key := item.entry.Key
+					return m, reviewEdit(m.cfg.Library, key, nil, tr("delete %s", key), removeEntry(m.cfg.Library, key))
+				}
+				return m, nil
+			case "esc", "tab":
+				m.state = stateInput
+				return m, nil
+			case "ctrl+w":
+				m.previewFocus = m.split() && !m.previewFocus
+				return m, nil
+			case "<", ">":
+				if m.split() {
+					step := splitStep
+					if msg.String() == "<" {
+						step = -splitStep
+					}
+					m.resizeSplit(step)
+				}
+				return m, nil
+			case "b":
+				m.previewBibTeX = !m.previewBibTeX
+				m.setPreview()
+				return m, nil
+			case "R":
+				if selected {
+					return m, m.showRelated(&item.entry)
+				}
+				return m, nil
+			case "ctrl+p":
+				return m, m.showProfiles()
+			case "H":
+				if selected {
+					return m, loadChangesCmd(m.cfg.Library, &item.entry)
+				}
+				return m, nil
+			case "o":
+				if selected {
+					return m, openLink(&item.entry)
+				}
+				return m, nil
+			case "enter":
+				if selected {
+					m.showDetail(item.work())
+				}
+				return m, nil
+			}
+		case stateChanges:
+			if msg.String() == "esc" || msg.String() == "H" {
+				m.state = stateLibrary
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.changes, cmd = m.changes.Update(msg)
+			return m, cmd
+		case stateInbox:
+			if m.inbox.FilterState() == list.Filtering {
+				break
+			}
+			item, selected := m.inbox.SelectedItem().(alertItem)
+			switch msg.String() {
+			case "enter":
+				if selected {
+					m.textInput.SetValue(item.alert.DOI)
+					m.fetchFrom = stateInbox
+					m.state = stateFetching
+					m.err = nil
+					m.message = ""
+					return m, tea.Batch(m.spinner.Tick, fetchWork(m.fetches, m.cfg, item.alert.DOI))
+				}
+				return m, nil
+			case "o":
+				if selected {
+					return m, openLink(&Entry{Fields: []Field{{Name: "doi", Value: item.alert.DOI}}})
+				}
+				return m, nil
+			case "x", "delete":
+				if selected {
+					return m, dismissCmd(item.alert.DOI, item.alert.Cites)
+				}
+				return m, nil
+			case "esc", "ctrl+n":
+				m.state = stateInput
+				return m, nil
+			}
+		case stateQueue:
+			if m.queue.FilterState() == list.Filtering {
+				break
+			}
+			item, selected := m.queue.SelectedItem().(queueItem)
+			switch msg.String() {
+			case "i":
+				if selected {
+					m.err = nil
+					return m, onConflict(importWork(m.cfg, item.work))
+				}
+				return m, nil
+			case "enter":
+				if selected {
+					m.showDetail(item.work)
+				}
+				return m, nil
+			case "o":
+				if selected {
+					return m, openLink(&item.work.Entry)
+				}
+				return m, nil
+			case "x", "delete":
+				if selected {
+					m.queue.RemoveItem(m.queue.Index())
+				}
+				return m, nil
+			case "esc", "ctrl+y":
+				m.state = stateInput
+				return m, nil
+			}
+		case stateRelated:
+			if m.related.FilterState() == list.Filtering {
+				break
+			}
+			item, selected := m.related.SelectedItem().(relatedItem)
+			switch msg.String() {
+			case "i":
+				if selected {
+					m.err = nil
+					m.message = tr("importing %s…", item.paper.DOI)
+					return m, onConflict(importDOI(m.cfg, item.paper.DOI))
+				}
+				return m, nil
+			case "enter":
+				if selected {
+					m.textInput.SetValue(item.paper.DOI)
+					m.fetchFrom = stateRelated
+					m.state = stateFetching
+					m.err = nil
+					m.message = ""
+					return m, tea.Batch(m.spinner.Tick, fetchWork(m.fetches, m.cfg, item.paper.DOI))
+				}
+				return m, nil
+			case "o":
+				if selected {
+					return m, openLink(&Entry{Fields: []Field{{Name: "doi", Value: item.paper.DOI}}})
+				}
+				return m, nil
+			case " ":
+				return m, m.markRelated(false)
+			case "a":
+				return m, m.markRelated(true)
+			case "I":
+				return m, m.importMarked()
+			case "esc":
+				m.closeRelated()
+				return m, nil
+			}
+		case stateProfiles:
+			if m.profiles.FilterState() == list.Filtering {
+				break
+			}
+			switch msg.String() {
+			case "enter":
+				if item, ok := m.profiles.SelectedItem().(profileItem); ok {
+					return m, m.switchProfile(item.name)
+				}
+				return m, nil
+			case "esc", "ctrl+p":
+				m.state = m.profilesFrom
+				return m, nil
+			}
+		case stateManual:
+			switch msg.String() {
+			case "esc":
+				m.state = stateInput
+				m.err = nil
+				return m, nil
+			case "enter", "ctrl+s":
+				if msg.String() == "enter" && m.form.focus < len(m.form.inputs) {
+					return m, m.form.move(1)
+				}
+				if missing := m.form.missing(); len(missing) > 0 {
+					m.err = missingFields(missing)
+					return m, nil
+				}
+				m.err = nil
+				m.fetchFrom = stateInput
+				return m, manualWork(m.cfg, m.form.entry())
+			}
+			var cmd tea.Cmd
+			m.form, cmd = m.form.update(msg)
+			return m, cmd
+		case stateReview:
+			switch msg.String() {
+			case "y", "enter":
+				apply := m.review.apply
+				m.review = nil
+				m.state = stateLibrary
+				return m, apply
+			case "n", "esc", "q":
+				m.message = tr("discarded %s", m.review.label)
+				m.review = nil
+				m.state = stateLibrary
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.diffView, cmd = m.diffView.Update(msg)
+			return m, cmd
+		}
+
+	// a library change is ready for review
+	case reviewMsg:
+		m.review = &msg.change
+		m.state = stateReview
+		m.message = ""
+		m.diffView.SetContent(colorDiff(msg.change.diff))
+		m.diffView.GotoTop()
+		return m, nil
+
+	// a DOI was resolved
+	case workMsg:
+		m.state = m.fetchFrom
+		m.showDetail(msg.work)
+		m.similar = msg.similar
+		if !m.altScreen {
+			// printed lines end up in the scrollback above the program
+			return m, tea.Println(bibtex.Render(&msg.work.Entry, m.cfg.Format))
+		}
+		return m, nil
+
+	// the library file was loaded
+	case libraryMsg:
+		m.entries = msg.entries
+		if msg.undo != nil {
+			m.history = append(m.history, msg.undo)
+			m.message = tr("%s (ctrl+z to undo)", msg.undo.label)
+		}
+		return m, tea.Batch(m.refreshList(), m.related.SetItems(relatedItems(m.relatedPapers, m.entries, m.relatedMarked)))
+
+	// the metrics sidecar was loaded
+	case metricsMsg:
+		m.metrics = msg.metrics
+		return m, m.refreshList()
 
-	// handle the status message of the request
-	case statusMsg:
-		m.status = int(msg)
-		return m, tea.Quit
+	// a snapshot was restored
+	case undoneMsg:
+		m.entries = msg.entries
+		m.message = tr("undid %s", msg.label)
+		m.err = nil
+		return m, m.refreshList()
+
+	// the shown work was written to the library
+	case importedMsg:
+		m.history = append(m.history, msg.undo)
+		m.message = tr("imported %s into %s", msg.key, m.cfg.Library)
+		if len(msg.similar) > 0 {
+			m.message += " · " + tr("similar title as %s", joinList(msg.similar))
+		}
+		m.err = msg.synced
+		if m.dequeue(msg.work) {
+			m.state = stateQueue
+			return m, nil
+		}
+		if m.state == stateRelated || m.state == stateDetail && m.prev == stateRelated {
+			// the library is read again to mark the work as imported
+			m.state = stateRelated
+			return m, loadLibraryCmd(m.cfg.Library)
+		}
+		m.textInput.SetValue("")
+		if m.prev == stateInbox {
+			// the alert is dealt with, for every followed paper it cites
+			m.state = stateInbox
+			return m, dismissCmd(m.work.Entry.Get("doi"), "")
+		}
+		m.state = stateInput
+		return m, nil
+
+	// the alerts of bibgloss follow were read
+	case inboxMsg:
+		return m, m.inbox.SetItems(alertItems(msg.alerts))
+
+	// the related works of a library entry were looked up
+	case relatedMsg:
+		if msg.key != m.relatedKey {
+			return m, nil
+		}
+		m.relatedPapers = msg.papers
+		m.message = ""
+		m.err = msg.err
+		return m, m.related.SetItems(relatedItems(m.relatedPapers, m.entries, m.relatedMarked))
+
+	// the clipboard was read
+	case clipboardMsg:
+		return m, m.onClipboard(msg)
+
+	// a pending identifier was resolved
+	case queueMsg:
+		return m, m.onQueued(msg)
+
+	// a PDF was stored for the shown work
+	case pdfMsg:
+		if m.work != nil && m.work.Entry.Key == msg.key {
+			m.work.Entry.Set("file", msg.file)
+			m.setDetail()
+		}
+		if msg.undo != nil {
+			m.history = append(m.history, msg.undo)
+		}
+		m.message = tr("saved %s", msg.file)
+		if m.prev == stateLibrary {
+			return m, loadLibraryCmd(m.cfg.Library)
+		}
+		return m, nil
+
+	// the mentions of a work were looked up
+	case mentionsMsg:
+		m.message = ""
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		if m.mentions == nil {
+			m.mentions = map[string]workMentions{}
+		}
+		m.mentions[msg.doi] = msg.workMentions
+		if m.state == stateDetail && m.compare == 0 && strings.EqualFold(m.work.Entry.Get("doi"), msg.doi) {
+			m.setDetail()
+		}
+		return m, nil
+
+	// the history of a library entry was read
+	case changesMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.changesKey = msg.key
+		m.changes.SetContent(msg.text)
+		m.changes.GotoBottom()
+		m.state = stateChanges
+		return m, nil
+
+	// a change was refused because the library changed on disk
+	case conflictMsg:
+		m.conflict = &msg
+		m.err = errors.New(tr("%s changed on disk since bibgloss read it, y reloads it and applies the change again, n drops the change", msg.err.path))
+		m.message = ""
+		return m, nil
 
 	// handle the error messages
 	case errMsg:
 		m.err = msg
+		if m.state == stateFetching {
+			m.state = m.fetchFrom
+		}
+		m.message = ""
 		return m, nil
 
-		// render the text input
-		// default:
-		// 	var cmd tea.Cmd
-		// 	m.textInput, cmd = m.textInput.Update(msg)
-		// 	return m, cmd
+	case spinner.TickMsg:
+		if m.state != stateFetching {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 	}
+
 	var cmd tea.Cmd
-	m.textInput, cmd = m.textInput.Update(msg)
+	switch m.state {
+	case stateInput:
+		m.textInput, cmd = m.textInput.Update(msg)
+	case stateLibrary:
+		_, key := msg.(tea.KeyMsg)
+		switch {
+		case m.prompt != promptNone:
+			m.ask, cmd = m.ask.Update(msg)
+		case key && m.previewFocus:
+			m.preview, cmd = m.preview.Update(msg)
+		default:
+			m.list, cmd = m.list.Update(msg)
+			m.setPreview()
+		}
+	case stateDetail:
+		if m.prompt != promptNone {
+			m.ask, cmd = m.ask.Update(msg)
+		}
+	case stateInbox:
+		m.inbox, cmd = m.inbox.Update(msg)
+	case stateQueue:
+		m.queue, cmd = m.queue.Update(msg)
+	case stateRelated:
+		m.related, cmd = m.related.Update(msg)
+	case stateProfiles:
+		m.profiles, cmd = m.profiles.Update(msg)
+	case stateManual:
+		m.form, cmd = m.form.update(msg)
+	}
 	return m, cmd
 }
 
+// setDetail renders the shown work into the detail viewport, next to the
+// similar entry it is compared with
+func (m *model) setDetail() {
+	if m.compare > len(m.similar) {
+		m.compare = 0
+	}
+	if m.compare > 0 {
+		m.detail = m.renderCompare()
+	} else {
+		m.detail = renderDetail(m.work, m.viewport.Width)
+		if wm, ok := m.mentions[strings.ToLower(m.work.Entry.Get("doi"))]; ok {
+			m.detail += "\n" + renderMentions(wm, m.viewport.Width)
+		}
+	}
+	m.viewport.SetContent(m.detail)
+}
+
+// showDetail switches to the detail screen for w, remembering where it was
+// opened from
+func (m *model) showDetail(w *Work) {
+	m.work = w
+	m.similar, m.compare = nil, 0
+	m.prev = m.state
+	m.state = stateDetail
+	m.setDetail()
+	m.viewport.GotoTop()
+}
+
 func (m model) View() string {
-	if m.textInput.View() != "" {
-		return fmt.Sprintf(
-			"What’s your favorite Pokémon?\n\n%s\n\n%s",
-			m.textInput.View(),
-			"(esc to quit)",
-		) + "\n"
-	}
-	return ""
+	switch m.state {
+	case stateFetching:
+		return m.spinner.View() + " " + tr("Resolving %s…", m.textInput.Value()) + "\n"
+	case stateDetail:
+		help := labelStyle.Render(tr("(i import • o open • d pdf • L references • M mentions • ↑/↓ scroll • esc back)"))
+		if m.prev == stateLibrary {
+			help = labelStyle.Render(tr("(o open • d pdf • L references • M mentions • ↑/↓ scroll • esc back)"))
+		}
+		if m.prompt != promptNone {
+			help = m.ask.View()
+		} else if m.err != nil {
+			help = errStyle.Render(m.err.Error()) + "  " + help
+		} else if m.message != "" {
+			help = okStyle.Render(m.message) + "  " + help
+		} else if len(m.similar) > 0 {
+			help = errStyle.Render(m.similarWarning()) + "  " + help
+		}
+		return m.viewport.View() + "\n\n" + help + "\n"
+	case stateLibrary:
+		help := labelStyle.Render(tr("(enter details • o open • t tags • T tag filter • s sort • R related • r rename • x delete • ctrl+p switch • H history • / filter • esc back)"))
+		if m.split() {
+			help = labelStyle.Render(tr("(enter details • o open • t tags • T tag filter • s sort • R related • r rename • x delete • ctrl+p switch • H history • / filter • ctrl+w focus • </> resize • b bibtex • esc back)"))
+		}
+		if m.prompt != promptNone {
+			help = m.ask.View()
+		} else if m.err != nil {
+			help = errStyle.Render(m.err.Error()) + "  " + help
+		} else if m.message != "" {
+			help = okStyle.Render(m.message) + "  " + help
+		}
+		if m.split() {
+			return m.splitView() + "\n" + help + "\n"
+		}
+		return m.list.View() + "\n" + help + "\n"
+	case stateInbox:
+		help := labelStyle.Render(tr("(enter details • o open • x dismiss • / filter • esc back)"))
+		if len(m.inbox.Items()) == 0 {
+			help = labelStyle.Render(tr("no new citing works, add papers with bibgloss follow add")+"  ") + help
+		}
+		if m.err != nil {
+			help = errStyle.Render(m.err.Error()) + "  " + help
+		} else if m.message != "" {
+			help = okStyle.Render(m.message) + "  " + help
+		}
+		return m.inbox.View() + "\n" + help + "\n"
+	case stateQueue:
+		help := labelStyle.Render(tr("(i import • enter details • o open • x drop • / filter • esc back)"))
+		if n := len(m.pending); n > 0 {
+			help = labelStyle.Render(tr("resolving %d more…", n)+"  ") + help
+		} else if len(m.queue.Items()) == 0 {
+			help = labelStyle.Render(tr("nothing queued, paste several identifiers at once")+"  ") + help
+		}
+		if m.err != nil {
+			help = errStyle.Render(m.err.Error()) + "  " + help
+		} else if m.message != "" {
+			help = okStyle.Render(m.message) + "  " + help
+		}
+		return m.queue.View() + "\n" + help + "\n"
+	case stateRelated:
+		help := labelStyle.Render(tr("(i import • space mark • a mark missing • I import marked • enter details • o open • / filter • esc back)"))
+		if m.err != nil {
+			help = errStyle.Render(m.err.Error()) + "  " + help
+		} else if m.message != "" {
+			help = okStyle.Render(m.message) + "  " + help
+		}
+		return m.related.View() + "\n" + help + "\n"
+	case stateProfiles:
+		help := labelStyle.Render(tr("(enter switch • / filter • esc back)"))
+		if m.err != nil {
+			help = errStyle.Render(m.err.Error()) + "  " + help
+		}
+		return m.profiles.View() + "\n" + help + "\n"
+	case stateManual:
+		help := labelStyle.Render(tr("(tab/↑/↓ move • ←/→ type • enter next • ctrl+s done • esc back)"))
+		if m.err != nil {
+			help = errStyle.Render(m.err.Error()) + "  " + help
+		}
+		return titleStyle.Render(tr("New entry")) + "\n\n" + m.form.view() + "\n" + help + "\n"
+	case stateChanges:
+		help := labelStyle.Render(tr("(↑/↓ scroll • esc back)"))
+		return titleStyle.Render(tr("History of %s", m.changesKey)) + "\n" + m.changes.View() + "\n\n" + help + "\n"
+	case stateReview:
+		help := labelStyle.Render(tr("(y apply • n discard • ↑/↓ scroll)"))
+		return titleStyle.Render(m.review.label) + "\n" + m.diffView.View() + "\n\n" + help + "\n"
+	}
+
+	var footer string
+	if m.restore != nil {
+		footer = okStyle.Render(tr("Restore the session of %s with %s? (y/n)", m.restore.Saved.Format(time.DateTime), m.restore.summary())) + "\n\n"
+	} else if m.err != nil {
+		footer = errStyle.Render(m.err.Error()) + "\n\n"
+	} else if m.message != "" {
+		footer = okStyle.Render(m.message) + "\n\n"
+	}
+	inbox := tr("ctrl+n inbox")
+	if n := len(m.inbox.Items()); n > 0 {
+		inbox += fmt.Sprintf(" (%d)", n)
+	}
+	if n := len(m.queue.Items()) + len(m.pending); n > 0 || m.cfg.WatchClipboard {
+		inbox += " • " + tr("ctrl+y queue")
+		if n > 0 {
+			inbox += fmt.Sprintf(" (%d)", n)
+		}
+	}
+	if len(m.cfg.Profiles) > 0 {
+		profile := m.cfg.Profile
+		if profile == "" {
+			profile = tr("default")
+		}
+		inbox += " • " + tr("ctrl+p library %s", profile)
+	}
+	return fmt.Sprintf(
+		"%s\n\n%s\n\n%s%s",
+		tr("Enter a DOI, patent number or thesis identifier"),
+		m.textInput.View(),
+		footer,
+		tr("(enter to resolve • tab library • ctrl+o manual entry • %s • esc to quit)", inbox),
+	) + "\n"
 }
 
-func checkServer() tea.Msg {
-	c := &http.Client{
-		Timeout: 10 * time.Second,
+// fetchWork queues a DOI on the fetch engine and keys the result with the
+// configured template, avoiding keys already in the library. The TUI waits
+// for one fetch at a time, so the next result is the one asked for.
+func fetchWork(e *resolve.Engine, cfg config, doi string) tea.Cmd {
+	return func() tea.Msg {
+		if !e.Submit(resolve.Job{ID: doi}) {
+			return nil
+		}
+		r := <-e.Results()
+		w, err := r.Work, r.Err
+		if err != nil {
+			return errMsg{err}
+		}
+		entries, err := loadLibrary(cfg.Library)
+		if err != nil {
+			return errMsg{err}
+		}
+		w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(cfg.KeyTemplate, &w.Entry), library.Keys(entries))
+		return workMsg{w, similarEntries(entries, &w.Entry)}
 	}
-	res, err := c.Get(url)
-	if err != nil {
-		return errMsg{err}
+}
+
+// importWork appends the work's entry to the library file
+func importWork(cfg config, w *Work) tea.Cmd {
+	bib := cfg.Library
+	return func() tea.Msg {
+		entries, err := loadLibrary(bib)
+		if err != nil {
+			return errMsg{err}
+		}
+		keys := library.Keys(entries)
+		if keys[w.Entry.Key] {
+			return errMsg{errors.New(tr("key %s already exists", w.Entry.Key))}
+		}
+		e := bibtex.Convert(w.Entry, cfg.Format)
+		if err := preImport(cfg, &e, w.Abstract, bib, keys); err != nil {
+			return errMsg{err}
+		}
+		s, err := mutate(bib, tr("import %s", e.Key), func() error {
+			return appendEntry(bib, &e)
+		})
+		if err != nil {
+			return errMsg{err}
+		}
+		var similar []string
+		for _, o := range similarEntries(entries, &e) {
+			similar = append(similar, o.Key)
+		}
+		return importedMsg{w, e.Key, s, syncImported(cfg, e, w.Abstract), similar}
 	}
-	defer res.Body.Close() // nolint:errcheck
+}
 
-	return statusMsg(res.StatusCode)
+// fetchPDF downloads the open-access PDF of w into the papers directory.
+// Entries that already live in the library get their file field rewritten
+// on disk, fresh results only carry it until they are imported.
+func fetchPDF(cfg config, w Work, persist bool) tea.Cmd {
+	return func() tea.Msg {
+		link := w.PDFURL
+		if link == "" {
+			doi := w.Entry.Get("doi")
+			if doi == "" {
+				return errMsg{errors.New(tr("entry has no DOI"))}
+			}
+			up, err := resolve.FetchUnpaywall(doi, cfg.Email)
+			if err != nil {
+				return errMsg{err}
+			}
+			link = up.PDFURL()
+		}
+		path, err := downloadPDF(link, cfg.Papers, w.Entry.Key)
+		if err != nil {
+			return errMsg{err}
+		}
+		file := linkFile(cfg.Library, path)
+		var s *snapshot
+		if persist {
+			s, err = mutate(cfg.Library, tr("attach %s", file), func() error {
+				return editEntry(cfg.Library, w.Entry.Key, func(e *Entry) { e.Set("file", file) })
+			})
+			if err != nil {
+				return errMsg{err}
+			}
+		}
+		return pdfMsg{w.Entry.Key, file, s}
+	}
 }
 
 func main() {
-	p := tea.NewProgram(initialModel())
-	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
+	root, finish := newRootCmd()
+	// pandoc runs filters with the output format as the only argument
+	if filepath.Base(os.Args[0]) == pandocFilterName {
+		root.SetArgs(append([]string{"pandoc"}, os.Args[1:]...))
+	}
+	if err := finish(root.Execute()); err != nil {
+		fmt.Fprintln(os.Stderr, "bibgloss:", err)
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sourceManual marks the fields of an entry typed into the manual entry form
+const sourceManual = "manual"
+
+// templateField is a field of a manual entry template. Hint is shown in
+// the empty input.
+type templateField struct {
+	Name     string
+	Hint     string
+	Required bool
+}
+
+// entryTemplate lists the fields asked for an entry type without a
+// resolvable identifier
+type entryTemplate struct {
+	Type   string
+	Fields []templateField
+}
+
+// entryTemplates are the types of the manual entry form, in the order they
+// are cycled through
+var entryTemplates = []entryTemplate{
+	{"misc", []templateField{
+		{"author", "Family, Given and Family, Given", true},
+		{"title", "", true},
+		{"howpublished", "Blog post, Talk, …", false},
+		{"year", "2024", false},
+		{"month", "1-12", false},
+		{"url", "https://", false},
+		{"note", "", false},
+	}},
+	{"techreport", []templateField{
+		{"author", "Family, Given and Family, Given", true},
+		{"title", "", true},
+		{"institution", "", true},
+		{"year", "2024", true},
+		{"number", "TR-2024-01", false},
+		{"type", "Technical Report", false},
+		{"address", "City", false},
+		{"url", "https://", false},
+	}},
+	{"unpublished", []templateField{
+		{"author", "Family, Given and Family, Given", true},
+		{"title", "", true},
+		{"note", "Manuscript in preparation", true},
+		{"year", "2024", false},
+		{"url", "https://", false},
+	}},
+	{"patent", []templateField{
+		{"author", "inventors: Family, Given and …", true},
+		{"title", "", true},
+		{"number", "US 10,000,000 B2", true},
+		{"holder", "assignee", false},
+		{"year", "2024", true},
+		{"month", "1-12", false},
+		{"url", "https://", false},
+	}},
+}
+
+// manualForm is the manual entry form. Focus 0 is the type row, the
+// fields of the template follow it.
+type manualForm struct {
+	template int
+	inputs   []textinput.Model
+	focus    int
+}
+
+// newManualForm returns the form of a template, keeping the values of the
+// fields it shares with the inputs of the form it replaces
+func newManualForm(template int, prev manualForm) manualForm {
+	kept := map[string]string{}
+	for i, in := range prev.inputs {
+		kept[entryTemplates[prev.template].Fields[i].Name] = in.Value()
+	}
+	f := manualForm{template: template}
+	for _, field := range entryTemplates[template].Fields {
+		in := textinput.New()
+		in.Prompt = fmt.Sprintf("%-12s ", field.Name)
+		if field.Required {
+			in.Prompt = fmt.Sprintf("%-12s ", field.Name+"*")
+		}
+		in.Placeholder = tr(field.Hint)
+		in.Width = 60
+		in.SetValue(kept[field.Name])
+		f.inputs = append(f.inputs, in)
+	}
+	return f
+}
+
+// move focuses the row by rows further down, wrapping around
+func (f *manualForm) move(by int) tea.Cmd {
+	if f.focus > 0 {
+		f.inputs[f.focus-1].Blur()
+	}
+	f.focus = (f.focus + by + len(f.inputs) + 1) % (len(f.inputs) + 1)
+	if f.focus > 0 {
+		return f.inputs[f.focus-1].Focus()
+	}
+	return nil
+}
+
+// update handles the messages of the form other than the keys submitting
+// and leaving it. Left and right on the type row switch the template.
+func (f manualForm) update(msg tea.Msg) (manualForm, tea.Cmd) {
+	key, _ := msg.(tea.KeyMsg)
+	switch key.String() {
+	case "tab", "down":
+		cmd := f.move(1)
+		return f, cmd
+	case "shift+tab", "up":
+		cmd := f.move(-1)
+		return f, cmd
+	case "left", "right":
+		if f.focus == 0 {
+			step := 1
+			if key.String() == "left" {
+				step = len(entryTemplates) - 1
+			}
+			return newManualForm((f.template+step)%len(entryTemplates), f), nil
+		}
+	}
+	if f.focus == 0 {
+		return f, nil
+	}
+	var cmd tea.Cmd
+	f.inputs[f.focus-1], cmd = f.inputs[f.focus-1].Update(msg)
+	return f, cmd
+}
+
+// entry returns the filled in fields as an entry of the template's type
+func (f manualForm) entry() bibtex.Entry {
+	t := entryTemplates[f.template]
+	e := bibtex.Entry{Type: t.Type}
+	for i, field := range t.Fields {
+		e.Set(field.Name, strings.TrimSpace(f.inputs[i].Value()))
+	}
+	return e
+}
+
+// missing returns the names of the required fields left empty
+func (f manualForm) missing() []string {
+	var names []string
+	for i, field := range entryTemplates[f.template].Fields {
+		if field.Required && strings.TrimSpace(f.inputs[i].Value()) == "" {
+			names = append(names, field.Name)
+		}
+	}
+	return names
+}
+
+// view draws the type row and the inputs
+func (f manualForm) view() string {
+	var b strings.Builder
+	var types []string
+	for i, t := range entryTemplates {
+		if i == f.template {
+			types = append(types, titleStyle.Render("@"+t.Type))
+		} else {
+			types = append(types, labelStyle.Render("@"+t.Type))
+		}
+	}
+	cursor := "  "
+	if f.focus == 0 {
+		cursor = "> "
+	}
+	fmt.Fprintf(&b, "%s%-12s ← %s →\n\n", cursor, tr("type"), strings.Join(types, "  "))
+	for i, in := range f.inputs {
+		cursor = "  "
+		if f.focus == i+1 {
+			cursor = "> "
+		}
+		b.WriteString(cursor + in.View() + "\n")
+	}
+	return b.String()
+}
+
+// manualWork keys an entry from the manual entry form with the configured
+// template like a fetched one
+func manualWork(cfg config, e bibtex.Entry) tea.Cmd {
+	return func() tea.Msg {
+		w := &Work{Entry: e, Citations: -1, Sources: map[string]string{}}
+		for _, f := range e.Fields {
+			w.Sources[f.Name] = sourceManual
+		}
+		if lang := bibtex.DetectLanguage(e.Get("title")); lang != "" {
+			w.Entry.Set("language", lang)
+			w.Sources["language"] = resolve.SourceDetected
+		}
+		entries, err := loadLibrary(cfg.Library)
+		if err != nil {
+			return errMsg{err}
+		}
+		w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(cfg.KeyTemplate, &w.Entry), library.Keys(entries))
+		return workMsg{w, similarEntries(entries, &w.Entry)}
+	}
+}
+
+// missingFields is the error of a form submitted without its required
+// fields
+func missingFields(names []string) error {
+	return errors.New(tr("fill in %s", strings.Join(names, ", ")))
+}
+
+// templateIndex returns the position of the template of an entry type
+func templateIndex(typ string) (int, bool) {
+	for i, t := range entryTemplates {
+		if t.Type == strings.TrimPrefix(typ, "@") {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// mdCiteRe matches pandoc citations, @key or @{key}. Keys start with a
+	// word character and may contain internal punctuation, so @10.1000/xyz
+	// is one key. The preceding character keeps emails and \@ out.
+	mdCiteRe = regexp.MustCompile(`(?:^|[^\w\\@.])@(?:\{([^}]+)\}|(\w(?:[\w:.#$%&\-+?<>~/]*\w)?))`)
+	// mdCodeRe matches inline code spans and HTML comments
+	mdCodeRe = regexp.MustCompile("(?s)`[^`]*`|<!--.*?-->")
+	// crossrefRe matches Quarto cross references like @fig-plot, which
+	// share the citation syntax
+	crossrefRe = regexp.MustCompile(`^(?:fig|tbl|lst|sec|eq|thm|lem|cor|prp|cnj|def|exm|exr|sol|rem|tip|nte|wrn|imp|cau)-`)
+)
+
+// markdownExts are the extensions of Quarto and R Markdown sources
+var markdownExts = map[string]bool{".qmd": true, ".Rmd": true, ".rmd": true}
+
+// splitFrontMatter returns the YAML front matter of a Quarto or R Markdown
+// source and the body after it
+func splitFrontMatter(src string) (front, body string) {
+	if !strings.HasPrefix(src, "---\n") && !strings.HasPrefix(src, "---\r\n") {
+		return "", src
+	}
+	lines := strings.SplitAfter(src, "\n")
+	for i := 1; i < len(lines); i++ {
+		if l := strings.TrimSpace(lines[i]); l == "---" || l == "..." {
+			return strings.Join(lines[1:i], ""), strings.Join(lines[i+1:], "")
+		}
+	}
+	return "", src
+}
+
+// stripMarkdownCode blanks fenced code blocks, like knitr chunks, inline
+// code and comments, none of which pandoc reads citations from
+func stripMarkdownCode(src string) string {
+	var b strings.Builder
+	fence := ""
+	for _, l := range strings.SplitAfter(src, "\n") {
+		t := strings.TrimSpace(l)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		case strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~"):
+			fence = t[:3]
+			continue
+		}
+		b.WriteString(l)
+	}
+	return mdCodeRe.ReplaceAllString(b.String(), "")
+}
+
+// markdownCitations returns the citation keys used in a Quarto or R
+// Markdown source, in order of first use. Cross references are left out.
+func markdownCitations(src string) []string {
+	_, body := splitFrontMatter(src)
+	var keys []string
+	seen := map[string]bool{}
+	for _, m := range mdCiteRe.FindAllStringSubmatch(stripMarkdownCode(body), -1) {
+		k := strings.TrimSpace(m[1] + m[2])
+		if k != "" && !seen[k] && !crossrefRe.MatchString(k) {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// yamlBibliographies returns the files named by the bibliography setting
+// of YAML front matter or a _quarto.yml, a single name or a list of them
+func yamlBibliographies(yaml string) []string {
+	var names []string
+	list := false
+	for _, l := range strings.Split(yaml, "\n") {
+		t := strings.TrimSpace(l)
+		if list {
+			// the block list of names follows the key, indented or not
+			if item, ok := strings.CutPrefix(t, "- "); ok {
+				names = append(names, yamlScalar(item))
+				continue
+			}
+			if t == "" || strings.HasPrefix(t, "#") {
+				continue
+			}
+			list = false
+		}
+		value, ok := strings.CutPrefix(t, "bibliography:")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			list = true
+		case strings.HasPrefix(value, "["):
+			value, _, _ = strings.Cut(value[1:], "]")
+			for _, name := range strings.Split(value, ",") {
+				names = append(names, yamlScalar(name))
+			}
+		default:
+			names = append(names, yamlScalar(value))
+		}
+	}
+	return names
+}
+
+// yamlScalar unquotes a plain YAML scalar and drops a trailing comment
+func yamlScalar(s string) string {
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// markdownBibliographies returns the bibliographies a Quarto or R Markdown
+// source at path names, resolved against its directory like Quarto does
+func markdownBibliographies(path, src string) []string {
+	front, _ := splitFrontMatter(src)
+	if filepath.Base(path) == "_quarto.yml" {
+		front = src
+	}
+	var out []string
+	for _, name := range yamlBibliographies(front) {
+		if name != "" {
+			out = append(out, filepath.Join(filepath.Dir(path), filepath.FromSlash(name)))
+		}
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// mcpProtocolVersion is the Model Context Protocol revision implemented
+const mcpProtocolVersion = "2025-06-18"
+
+// mcpTool describes a tool in the tools/list answer
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	call        func(args json.RawMessage) (string, error)
+}
+
+// mcpServer exposes resolving and the library to AI assistants over the
+// stdio transport of the Model Context Protocol
+type mcpServer struct {
+	*rpcServer
+	tools []mcpTool
+}
+
+func newMCPServer(cfg config, out io.Writer) *mcpServer {
+	s := &mcpServer{rpcServer: &rpcServer{cfg: cfg, out: out, lines: true}}
+	s.tools = []mcpTool{
+		{
+			Name:        "resolve_identifier",
+			Description: "Resolve a DOI into verified bibliographic metadata and a BibTeX entry. Use this instead of writing citations from memory.",
+			InputSchema: schema(map[string]string{"identifier": "DOI, e.g. 10.1016/j.icarus.2016.12.026"}, "identifier"),
+			call:        s.resolveIdentifier,
+		},
+		{
+			Name:        "search_papers",
+			Description: "Search the scholarly literature (OpenAlex) by free text and return matching works with their DOIs.",
+			InputSchema: schema(map[string]string{"query": "title words, authors or topic", "limit": "number of results, at most 25"}, "query"),
+			call:        s.searchPapers,
+		},
+		{
+			Name:        "search_library",
+			Description: "Search the user's bibliography by key, title, author, year or keyword and return the entries with their citation keys.",
+			InputSchema: schema(map[string]string{"query": "words that must all match"}, "query"),
+			call:        s.searchLibrary,
+		},
+		{
+			Name:        "append_to_library",
+			Description: "Resolve a DOI and add it to the user's bibliography. Returns the citation key to use; an entry already in the library is reused.",
+			InputSchema: schema(map[string]string{"identifier": "DOI of the work to cite"}, "identifier"),
+			call:        s.appendToLibrary,
+		},
+	}
+	s.methods = map[string]func(json.RawMessage) (any, error){
+		"initialize": s.initialize,
+		"ping":       func(json.RawMessage) (any, error) { return struct{}{}, nil },
+		"tools/list": s.listTools,
+		"tools/call": s.callTool,
+	}
+	return s
+}
+
+// schema builds a JSON schema of an object with string properties, except
+// for limit, which is an integer
+func schema(props map[string]string, required ...string) map[string]any {
+	p := map[string]any{}
+	for name, desc := range props {
+		typ := "string"
+		if name == "limit" {
+			typ = "integer"
+		}
+		p[name] = map[string]string{"type": typ, "description": desc}
+	}
+	return map[string]any{"type": "object", "properties": p, "required": required}
+}
+
+func (s *mcpServer) initialize(json.RawMessage) (any, error) {
+	return map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{"tools": map[string]any{}},
+		"serverInfo":      map[string]string{"name": "bibgloss", "version": version},
+	}, nil
+}
+
+func (s *mcpServer) listTools(json.RawMessage) (any, error) {
+	return map[string]any{"tools": s.tools}, nil
+}
+
+// callTool runs a tool. Failures of the tool are part of the result, so the
+// assistant sees them, rather than protocol errors.
+func (s *mcpServer) callTool(raw json.RawMessage) (any, error) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := params(raw, &p); err != nil {
+		return nil, err
+	}
+	for _, t := range s.tools {
+		if t.Name != p.Name {
+			continue
+		}
+		text, err := t.call(p.Arguments)
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(text, false), nil
+	}
+	return nil, &rpcError{rpcInvalidParams, "unknown tool " + p.Name}
+}
+
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func (s *mcpServer) resolveIdentifier(raw json.RawMessage) (string, error) {
+	res, err := s.resolve(raw)
+	if err != nil {
+		return "", err
+	}
+	return toJSON(res)
+}
+
+func (s *mcpServer) searchPapers(raw json.RawMessage) (string, error) {
+	var p struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := params(raw, &p); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(p.Query) == "" {
+		return "", fmt.Errorf("empty query")
+	}
+	if p.Limit <= 0 || p.Limit > 25 {
+		p.Limit = 10
+	}
+	papers, err := resolve.SearchOpenAlex(p.Query, s.cfg.APIKeys.OpenAlex, p.Limit)
+	if err != nil {
+		return "", err
+	}
+	if len(papers) == 0 {
+		return "no works found", nil
+	}
+	return toJSON(papers)
+}
+
+func (s *mcpServer) searchLibrary(raw json.RawMessage) (string, error) {
+	res, err := s.search(raw)
+	if err != nil {
+		return "", err
+	}
+	return toJSON(res)
+}
+
+func (s *mcpServer) appendToLibrary(raw json.RawMessage) (string, error) {
+	var p struct {
+		Identifier string `json:"identifier"`
+	}
+	if err := params(raw, &p); err != nil {
+		return "", err
+	}
+	e, imported, err := s.importIdentifier(p.Identifier)
+	if err != nil {
+		return "", err
+	}
+	if !imported {
+		return fmt.Sprintf("already in the library, cite it as %s\n\n%s", e.Key, e.BibTeX()), nil
+	}
+	return fmt.Sprintf("added to %s, cite it as %s\n\n%s", s.cfg.Library, e.Key, e.BibTeX()), nil
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// mentionsMsg carries the pages mentioning the work with a DOI
+type mentionsMsg struct {
+	doi string
+	workMentions
+	err error
+}
+
+// workMentions are the mentions of a work found by Crossref Event Data,
+// of total events
+type workMentions struct {
+	mentions []resolve.Mention
+	total    int
+}
+
+// perSource is how many mentions of each source the detail view lists
+const perSource = 5
+
+type mentionSource struct{ id, name string }
+
+// mentionSources name the Event Data sources, in the order they are shown
+var mentionSources = []mentionSource{
+	{"wikipedia", "Wikipedia"},
+	{"newsfeed", "Blogs and news"},
+	{"wordpressdotcom", "WordPress"},
+	{"reddit", "Reddit"},
+	{"reddit-links", "Reddit"},
+	{"stackexchange", "Stack Exchange"},
+	{"hypothesis", "Hypothesis"},
+	{"twitter", "Twitter"},
+	{"f1000", "F1000"},
+	{"web", "Web"},
+}
+
+func loadMentionsCmd(doi, email string) tea.Cmd {
+	return func() tea.Msg {
+		mentions, total, err := resolve.FetchMentions(doi, email)
+		return mentionsMsg{doi: doi, workMentions: workMentions{mentions, total}, err: err}
+	}
+}
+
+// renderMentions renders the Mentions section of the detail view: the
+// number of pages of each source and the latest of them
+func renderMentions(wm workMentions, width int) string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(tr("Mentions")) + labelStyle.Render("  [Crossref Event Data]") + "\n")
+	if len(wm.mentions) == 0 {
+		b.WriteString(labelStyle.Render(tr("no mentions found")) + "\n")
+		return b.String()
+	}
+	wrap := lipgloss.NewStyle().Width(max(width, 20))
+	// sources without a name are shown last, by their id
+	groups := map[string][]resolve.Mention{}
+	var names, unknown []string
+	for _, src := range mentionSources {
+		if !slices.Contains(names, src.name) {
+			names = append(names, src.name)
+		}
+	}
+	for _, m := range wm.mentions {
+		name := m.Source
+		if i := slices.IndexFunc(mentionSources, func(s mentionSource) bool { return s.id == m.Source }); i >= 0 {
+			name = mentionSources[i].name
+		} else if !slices.Contains(unknown, name) {
+			unknown = append(unknown, name)
+		}
+		groups[name] = append(groups[name], m)
+	}
+	for _, name := range append(names, unknown...) {
+		group := groups[name]
+		if len(group) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d\n", labelStyle.Render(fmt.Sprintf("%-16s", tr(name))), len(group))
+		for _, m := range group[:min(len(group), perSource)] {
+			line := "  " + m.Occurred.Format("2006-01-02") + "  " + cmp.Or(m.Title, m.URL)
+			if m.Title != "" {
+				line += labelStyle.Render("  " + m.URL)
+			}
+			b.WriteString(wrap.Render(line) + "\n")
+		}
+		if len(group) > perSource {
+			b.WriteString(labelStyle.Render("  "+tr("and %d more", len(group)-perSource)) + "\n")
+		}
+	}
+	if wm.total > resolve.MaxEvents {
+		b.WriteString(labelStyle.Render(tr("from %d of %d events", resolve.MaxEvents, wm.total)) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// entryMetrics are the counts enrich records for an entry. They change
+// over time, so they are kept next to the library instead of in it.
+type entryMetrics struct {
+	Citations  int `json:"citations"`
+	References int `json:"references"`
+	// Source is the API that counted them, the counts of OpenAlex and
+	// Semantic Scholar differ a little
+	Source  string    `json:"source,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// metricsPath returns the sidecar of a library, refs.bib -> refs.metrics.json
+func metricsPath(library string) string {
+	return strings.TrimSuffix(library, filepath.Ext(library)) + ".metrics.json"
+}
+
+// loadMetrics reads the sidecar of a library, keyed by citation key. A
+// library that was never enriched has none.
+func loadMetrics(library string) (map[string]entryMetrics, error) {
+	metrics := map[string]entryMetrics{}
+	data, err := readFile(metricsPath(library))
+	if errors.Is(err, fs.ErrNotExist) {
+		return metrics, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("%s: %w", metricsPath(library), err)
+	}
+	return metrics, nil
+}
+
+// fetchMetrics asks OpenAlex or Semantic Scholar, whichever is less busy,
+// for the current counts of a DOI, and the other when that fails. The
+// response cache would keep them for a week, so it is bypassed.
+func fetchMetrics(doi string, opts resolve.Options) (entryMetrics, error) {
+	var first error
+	for _, i := range resolve.Rank(resolve.OpenAlexAPI, resolve.SemanticScholarAPI) {
+		fetch, source := fetchOpenAlexMetrics, resolve.SourceOpenAlex
+		if i == 1 {
+			fetch, source = fetchSemanticScholarMetrics, resolve.SourceSemanticScholar
+		}
+		m, err := fetch(doi, opts)
+		if err == nil {
+			m.Source, m.Updated = source, time.Now().UTC()
+			return m, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return entryMetrics{}, first
+}
+
+func fetchOpenAlexMetrics(doi string, opts resolve.Options) (entryMetrics, error) {
+	u := resolve.OpenAlexAPI + "doi:" + resolve.EscapeDOI(doi) + "?select=cited_by_count,referenced_works_count"
+	if opts.OpenAlexKey != "" {
+		u += "&api_key=" + url.QueryEscape(opts.OpenAlexKey)
+	}
+	var w struct {
+		CitedByCount         int `json:"cited_by_count"`
+		ReferencedWorksCount int `json:"referenced_works_count"`
+	}
+	if err := sendJSON("openalex", http.MethodGet, u, nil, nil, &w); err != nil {
+		return entryMetrics{}, err
+	}
+	return entryMetrics{Citations: w.CitedByCount, References: w.ReferencedWorksCount}, nil
+}
+
+func fetchSemanticScholarMetrics(doi string, opts resolve.Options) (entryMetrics, error) {
+	var h http.Header
+	if opts.SemanticScholarKey != "" {
+		h = http.Header{"X-Api-Key": {opts.SemanticScholarKey}}
+	}
+	var p struct {
+		CitationCount  int `json:"citationCount"`
+		ReferenceCount int `json:"referenceCount"`
+	}
+	u := resolve.SemanticScholarAPI + "DOI:" + resolve.EscapeDOI(doi) + "?fields=citationCount,referenceCount"
+	if err := sendJSON("semantic scholar", http.MethodGet, u, h, nil, &p); err != nil {
+		return entryMetrics{}, err
+	}
+	return entryMetrics{Citations: p.CitationCount, References: p.ReferenceCount}, nil
+}
+
+// enrichMetrics updates the sidecar with the counts of the entries that
+// have a DOI. Entries whose lookup fails keep their old counts.
+func enrichMetrics(cfg config, entries []Entry) (updated int, err error) {
+	metrics, err := loadMetrics(cfg.Library)
+	if err != nil {
+		return 0, err
+	}
+	var mu sync.Mutex
+	var errs []error
+	var cited []Entry
+	for _, e := range entries {
+		if e.Get("doi") != "" {
+			cited = append(cited, e)
+		}
+	}
+	inParallel(cfg.Concurrency, cited, func(_ int, e Entry) {
+		m, err := fetchMetrics(e.Get("doi"), cfg.resolveOptions())
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Key, err))
+			return
+		}
+		metrics[e.Key] = m
+		updated++
+	})
+	slices.SortFunc(errs, func(a, b error) int { return cmp.Compare(a.Error(), b.Error()) })
+	if updated == 0 {
+		return 0, errors.Join(errs...)
+	}
+	// entries removed from the library take their counts with them
+	keys := library.Keys(entries)
+	if all, err := loadLibrary(cfg.Library); err == nil {
+		keys = library.Keys(all)
+	}
+	for key := range metrics {
+		if !keys[key] {
+			delete(metrics, key)
+		}
+	}
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return updated, err
+	}
+	if err := writeFile(metricsPath(cfg.Library), append(data, '\n')); err != nil {
+		return updated, err
+	}
+	return updated, errors.Join(errs...)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	notionAPI     = "https://api.notion.com/v1"
+	notionVersion = "2022-06-28"
+	// notionTextLimit is the most characters of one rich text object
+	notionTextLimit = 2000
+)
+
+// notionConfig points at the Notion database entries are exported to
+type notionConfig struct {
+	// Token is the secret of an internal integration the database is
+	// shared with
+	Token      string `toml:"token"`
+	DatabaseID string `toml:"database_id"`
+	// Columns maps database properties to entry fields
+	Columns map[string]string `toml:"columns"`
+}
+
+func (n notionConfig) enabled() bool {
+	return n.Token != "" && n.DatabaseID != ""
+}
+
+func (n notionConfig) columns() map[string]string {
+	if len(n.Columns) == 0 {
+		return defaultColumns
+	}
+	return n.Columns
+}
+
+// isoDateRe matches the dates Notion accepts, years are padded to them
+var isoDateRe = regexp.MustCompile(`^\d{4}(-\d{2}-\d{2})?$`)
+
+// notionProperty converts entry values to a property value of the given
+// type. ok is false when there is nothing to set.
+func notionProperty(typ string, values []string) (v any, ok bool, err error) {
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	joined := strings.Join(values, ", ")
+	text := func() []map[string]any {
+		r := []rune(joined)
+		if len(r) > notionTextLimit {
+			r = append(r[:notionTextLimit-1], '…')
+		}
+		return []map[string]any{{"text": map[string]string{"content": string(r)}}}
+	}
+	switch typ {
+	case "title":
+		return map[string]any{"title": text()}, true, nil
+	case "rich_text":
+		return map[string]any{"rich_text": text()}, true, nil
+	case "url":
+		return map[string]any{"url": joined}, true, nil
+	case "number":
+		f, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return nil, false, nil
+		}
+		return map[string]any{"number": f}, true, nil
+	case "date":
+		if !isoDateRe.MatchString(values[0]) {
+			return nil, false, nil
+		}
+		start := values[0]
+		if len(start) == 4 {
+			start += "-01-01"
+		}
+		return map[string]any{"date": map[string]string{"start": start}}, true, nil
+	case "select":
+		// commas are not allowed in option names
+		return map[string]any{"select": map[string]string{"name": strings.ReplaceAll(joined, ",", "")}}, true, nil
+	case "multi_select":
+		options := make([]map[string]string, len(values))
+		for i, v := range values {
+			options[i] = map[string]string{"name": strings.ReplaceAll(v, ",", "")}
+		}
+		return map[string]any{"multi_select": options}, true, nil
+	}
+	return nil, false, fmt.Errorf("notion: properties of type %s are not supported", typ)
+}
+
+// pushNotion adds a page for every entry to the database
+func pushNotion(n notionConfig, entries []Entry) error {
+	var db struct {
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+	}
+	if err := notionRequest(n, http.MethodGet, "/databases/"+n.DatabaseID, nil, &db); err != nil {
+		return err
+	}
+	columns := n.columns()
+	for _, name := range sortedColumns(columns) {
+		if _, ok := db.Properties[name]; !ok {
+			return fmt.Errorf("notion: the database has no property %q", name)
+		}
+	}
+	for _, e := range entries {
+		props := map[string]any{}
+		for _, name := range sortedColumns(columns) {
+			v, ok, err := notionProperty(db.Properties[name].Type, columnValues(e, columns[name]))
+			if err != nil {
+				return err
+			}
+			if ok {
+				props[name] = v
+			}
+		}
+		page := map[string]any{
+			"parent":     map[string]string{"database_id": n.DatabaseID},
+			"properties": props,
+		}
+		if err := notionRequest(n, http.MethodPost, "/pages", page, nil); err != nil {
+			return fmt.Errorf("%s: %w", e.Key, err)
+		}
+	}
+	return nil
+}
+
+func notionRequest(n notionConfig, method, path string, body, v any) error {
+	h := http.Header{
+		"Authorization":  {"Bearer " + n.Token},
+		"Notion-Version": {notionVersion},
+	}
+	return sendJSON("notion", method, notionAPI+path, h, body, v)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// obsidianConfig writes literature notes for imported entries into an
+// Obsidian vault. Templates use the variables of obsidian-citation-plugin.
+type obsidianConfig struct {
+	Vault string `toml:"vault"`
+	// Folder is the directory of the notes inside the vault
+	Folder string `toml:"folder"`
+	// FileName is the template of a note's name, without .md
+	FileName string `toml:"file_name"`
+	// Template is the note body below the frontmatter
+	Template string `toml:"template"`
+}
+
+func (o obsidianConfig) enabled() bool {
+	return o.Vault != ""
+}
+
+const defaultNoteTemplate = `# {{title}}
+
+{{authorString}} ({{year}}). {{containerTitle}}
+
+[{{DOI}}]({{URL}})
+
+## Abstract
+
+{{abstract}}
+
+## Notes
+
+`
+
+// unbrace drops the braces BibTeX protects capitals and accents with
+var unbrace = strings.NewReplacer("{", "", "}", "")
+
+// noteAuthors returns the authors of an entry as "Given Family"
+func noteAuthors(e Entry) []string {
+	return givenFirst(e.Get("author"))
+}
+
+// givenFirst splits a name list, turning "Family, Given" into "Given Family"
+func givenFirst(list string) []string {
+	var names []string
+	for _, a := range bibtex.SplitAuthors(list) {
+		if family, given, ok := strings.Cut(a, ","); ok {
+			a = strings.TrimSpace(given) + " " + strings.TrimSpace(family)
+		}
+		names = append(names, unbrace.Replace(a))
+	}
+	return names
+}
+
+// entryContainer returns the journal, book or publisher a work appeared in
+func entryContainer(e Entry) string {
+	for _, f := range []string{"journal", "journaltitle", "booktitle", "publisher"} {
+		if v := e.Get(f); v != "" {
+			return unbrace.Replace(v)
+		}
+	}
+	return ""
+}
+
+// noteVariables returns the template variables of an entry
+func noteVariables(e Entry, abstract string) map[string]string {
+	if abstract == "" {
+		abstract = e.Get("abstract")
+	}
+	return map[string]string{
+		"citekey":        e.Key,
+		"title":          unbrace.Replace(e.Get("title")),
+		"authorString":   strings.Join(noteAuthors(e), ", "),
+		"year":           e.Get("year"),
+		"containerTitle": entryContainer(e),
+		"DOI":            e.Get("doi"),
+		"URL":            entryLink(&e),
+		"abstract":       abstract,
+	}
+}
+
+// expandNote fills the {{variable}} placeholders of a template. Unknown
+// placeholders are left as they are.
+func expandNote(template string, vars map[string]string) string {
+	pairs := make([]string, 0, 2*len(vars))
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// literatureNote renders the note of an entry: YAML frontmatter with the
+// citation key, DOI and authors, then the body template
+func literatureNote(o obsidianConfig, e Entry, abstract string) string {
+	vars := noteVariables(e, abstract)
+	// JSON strings are valid YAML and need no further quoting
+	quote := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "citekey: %s\n", quote(e.Key))
+	if vars["title"] != "" {
+		fmt.Fprintf(&b, "title: %s\n", quote(vars["title"]))
+	}
+	if authors := noteAuthors(e); len(authors) > 0 {
+		b.WriteString("authors:\n")
+		for _, a := range authors {
+			fmt.Fprintf(&b, "  - %s\n", quote(a))
+		}
+	}
+	if vars["year"] != "" {
+		fmt.Fprintf(&b, "year: %s\n", quote(vars["year"]))
+	}
+	if vars["DOI"] != "" {
+		fmt.Fprintf(&b, "doi: %s\n", quote(vars["DOI"]))
+	}
+	if tags := entryTags(&e); len(tags) > 0 {
+		b.WriteString("tags:\n")
+		for _, t := range tags {
+			// Obsidian tags cannot contain spaces
+			fmt.Fprintf(&b, "  - %s\n", quote(strings.ReplaceAll(t, " ", "-")))
+		}
+	}
+	b.WriteString("---\n\n")
+	template := o.Template
+	if template == "" {
+		template = defaultNoteTemplate
+	}
+	b.WriteString(expandNote(template, vars))
+	return b.String()
+}
+
+// notePath returns where the note of an entry lives in the vault
+func notePath(o obsidianConfig, e Entry) string {
+	return noteFile(filepath.Join(o.Vault, o.Folder), o.FileName, "@{{citekey}}", e, ".md")
+}
+
+// noteFile names the note of an entry in dir after a file name template
+func noteFile(dir, name, fallback string, e Entry, ext string) string {
+	if name == "" {
+		name = fallback
+	}
+	name = expandNote(name, noteVariables(e, ""))
+	// keys like 10.1000/xyz must not create directories
+	name = strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name)
+	return filepath.Join(dir, name+ext)
+}
+
+// writeNote creates a note file. A note that exists already holds the
+// reader's own notes and is left alone; wrote reports whether it was created.
+func writeNote(path, note string) (wrote bool, err error) {
+	if _, err := readFile(path); err == nil {
+		return false, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if !dryRun {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return false, err
+		}
+	}
+	return true, writeFile(path, []byte(note))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// entryLink returns the address an entry should be opened at, preferring
+// the DOI resolver over the url field
+func entryLink(e *Entry) string {
+	if doi := e.Get("doi"); doi != "" {
+		return "https://doi.org/" + doi
+	}
+	return e.Get("url")
+}
+
+// openBrowser opens a URL with the platform's default handler
+func openBrowser(link string) error {
+	if link == "" {
+		return errors.New("entry has no DOI or URL")
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// orgRoamConfig writes org notes for imported entries where citar and
+// org-roam-bibtex find them: one file per citation key, linked to it by a
+// ROAM_REFS property
+type orgRoamConfig struct {
+	Directory string `toml:"directory"`
+	// FileName is the template of a note's name, without .org
+	FileName string `toml:"file_name"`
+	// Template is the note body below the property drawer and title
+	Template string `toml:"template"`
+}
+
+func (o orgRoamConfig) enabled() bool {
+	return o.Directory != ""
+}
+
+const defaultOrgTemplate = `{{authorString}} ({{year}}). {{containerTitle}}
+[[{{URL}}]]
+
+* Abstract
+{{abstract}}
+
+* Notes
+`
+
+// orgNotePath returns where the note of an entry lives, citar's default is
+// the bare key
+func orgNotePath(o orgRoamConfig, e Entry) string {
+	return noteFile(o.Directory, o.FileName, "{{citekey}}", e, ".org")
+}
+
+// orgNote renders the org-roam note of an entry. The ID makes it an
+// org-roam node, ROAM_REFS ties the node to the citation key.
+func orgNote(o orgRoamConfig, e Entry, abstract string) string {
+	vars := noteVariables(e, abstract)
+	var b strings.Builder
+	b.WriteString(":PROPERTIES:\n")
+	fmt.Fprintf(&b, ":ID:       %s\n", newUUID())
+	fmt.Fprintf(&b, ":ROAM_REFS: @%s\n", e.Key)
+	b.WriteString(":END:\n")
+	title := vars["title"]
+	if title == "" {
+		title = e.Key
+	}
+	fmt.Fprintf(&b, "#+title: %s\n", title)
+	if tags := entryTags(&e); len(tags) > 0 {
+		for i, t := range tags {
+			// org tags are words joined by colons
+			tags[i] = strings.ReplaceAll(t, " ", "_")
+		}
+		fmt.Fprintf(&b, "#+filetags: :%s:\n", strings.Join(tags, ":"))
+	}
+	b.WriteString("\n")
+	template := o.Template
+	if template == "" {
+		template = defaultOrgTemplate
+	}
+	b.WriteString(expandNote(template, vars))
+	return b.String()
+}
+
+// newUUID returns a random UUID, the form org-id uses for node IDs
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:]) // nolint:errcheck
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// printElisp writes the Emacs settings pointing citar and org-roam-bibtex
+// at the library, the papers and the notes
+func printElisp(w io.Writer, cfg config) error {
+	abs := func(p string) string {
+		if a, err := filepath.Abs(p); err == nil {
+			return a
+		}
+		return p
+	}
+	library := strconv.Quote(abs(cfg.Library))
+	papers := strconv.Quote(abs(cfg.Papers))
+	notes := strconv.Quote(abs(cfg.OrgRoam.Directory))
+	_, err := fmt.Fprintf(w, `;; citar
+(setq citar-bibliography '(%[1]s)
+      citar-library-paths '(%[2]s)
+      citar-notes-paths '(%[3]s))
+
+;; org-roam-bibtex, through bibtex-completion
+(setq bibtex-completion-bibliography '(%[1]s)
+      bibtex-completion-library-path '(%[2]s)
+      bibtex-completion-notes-path %[3]s)
+`, library, papers, notes)
+	return err
+}
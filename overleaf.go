@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/library"
+)
+
+const overleafGit = "https://git.overleaf.com/"
+
+// bibliographyRe matches \bibliography{a,b} and biblatex's \addbibresource{a.bib}
+var bibliographyRe = regexp.MustCompile(`\\(?:bibliography|addbibresource)\s*(?:\[[^\]]*\]\s*)?\{([^}]*)\}`)
+
+// overleafRemote turns an Overleaf project id or editor URL into its git
+// remote. Anything else that looks like a remote is used as it is.
+func overleafRemote(project string) string {
+	// the editor URL of a project is https://www.overleaf.com/project/<id>
+	if i := strings.Index(project, "/project/"); i >= 0 && strings.Contains(project, "overleaf.com") {
+		return overleafGit + strings.Trim(project[i+len("/project/"):], "/")
+	}
+	if strings.ContainsAny(project, "/:") {
+		return project
+	}
+	return overleafGit + project
+}
+
+// git runs a git command in dir, returning its trimmed output. Failures
+// carry the line of git's output saying what went wrong.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			lines := strings.Split(msg, "\n")
+			msg = lines[len(lines)-1]
+			for _, l := range lines {
+				if strings.HasPrefix(l, "fatal: ") || strings.HasPrefix(l, "error: ") {
+					msg = l
+					break
+				}
+			}
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// projectBibliography finds the .bib file a project cites from: the first
+// one named by \bibliography or \addbibresource, or by the bibliography
+// setting of a Quarto or R Markdown project, that exists, else the only
+// .bib file in the project
+func projectBibliography(dir string) (string, error) {
+	var named, found []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if markdownExts[filepath.Ext(path)] || d.Name() == "_quarto.yml" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			named = append(named, markdownBibliographies(path, string(data))...)
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".bib":
+			found = append(found, path)
+		case ".tex":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, m := range bibliographyRe.FindAllStringSubmatch(stripTeXComments(string(data)), -1) {
+				for _, name := range strings.Split(m[1], ",") {
+					name = strings.TrimSpace(name)
+					if name == "" {
+						continue
+					}
+					if filepath.Ext(name) != ".bib" {
+						name += ".bib"
+					}
+					// Overleaf resolves names against the project root
+					named = append(named, filepath.Join(dir, filepath.FromSlash(name)))
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, path := range named {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	switch {
+	case len(named) > 0:
+		return named[0], nil
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) == 0:
+		return "", fmt.Errorf("%s: no bibliography found", dir)
+	}
+	return "", fmt.Errorf("%s: %d .bib files and none named by \\bibliography, use --file", dir, len(found))
+}
+
+// overleafOptions control a sync of an Overleaf project
+type overleafOptions struct {
+	// Dir is the local clone, created when missing
+	Dir string
+	// Bib is the project bibliography, found from the sources when empty
+	Bib string
+	// NoPush leaves the commit for the resolved entries unpushed
+	NoPush bool
+}
+
+// syncOverleaf clones or pulls an Overleaf project, adds the entries its
+// sources cite but its bibliography lacks, and pushes the result back.
+// Missing keys are copied from the library when it has them and resolved
+// when they are DOIs; the rest are reported.
+func syncOverleaf(cfg config, project string, opts overleafOptions, out io.Writer) error {
+	remote := overleafRemote(project)
+	if opts.Dir == "" {
+		opts.Dir = strings.TrimSuffix(filepath.Base(remote), ".git")
+	}
+	if _, err := os.Stat(filepath.Join(opts.Dir, ".git")); err == nil {
+		fmt.Fprintf(out, "pulling %s\n", opts.Dir)
+		if _, err := git(opts.Dir, "pull", "--ff-only"); err != nil {
+			return err
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(out, "cloning %s into %s\n", remote, opts.Dir)
+		if _, err := git(".", "clone", "--", remote, opts.Dir); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	bib := opts.Bib
+	if bib == "" {
+		var err error
+		if bib, err = projectBibliography(opts.Dir); err != nil {
+			return err
+		}
+	} else if !filepath.IsAbs(bib) {
+		bib = filepath.Join(opts.Dir, bib)
+	}
+	cited, err := projectCitations(opts.Dir)
+	if err != nil {
+		return err
+	}
+	entries, err := loadLibrary(bib)
+	if err != nil {
+		return err
+	}
+	keys := library.Keys(entries)
+	// the personal library is optional, a project may be all DOIs
+	personal := map[string]Entry{}
+	if lib, err := loadLibrary(cfg.Library); err == nil {
+		for _, e := range lib {
+			personal[e.Key] = e
+		}
+	}
+
+	missing := 0
+	for _, c := range cited {
+		if keys[c.key] {
+			continue
+		}
+		keys[c.key] = true
+		if e, ok := personal[c.key]; ok {
+			if _, err := mutate(bib, "overleaf "+c.key, func() error {
+				return appendEntry(bib, &e)
+			}); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "copied %s from %s\n", c.key, cfg.Library)
+			continue
+		}
+		if looksLikeDOI(c.key) {
+			if _, err := fetchCitation(cfg, bib, c.key); err != nil {
+				fmt.Fprintf(out, "%s: fetching %s: %v\n", c.file, c.key, err)
+				missing++
+				continue
+			}
+			fmt.Fprintf(out, "fetched %s\n", c.key)
+			continue
+		}
+		fmt.Fprintf(out, "%s: %s is not in %s\n", c.file, c.key, bib)
+		missing++
+	}
+
+	if dryRun {
+		return partial(missing)
+	}
+	rel, err := filepath.Rel(opts.Dir, bib)
+	if err != nil {
+		return err
+	}
+	// entries a run failed to commit are still in the clone, they go out now
+	status, err := git(opts.Dir, "status", "--porcelain", "--", rel)
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return partial(missing)
+	}
+	if _, err := git(opts.Dir, "add", "--", rel); err != nil {
+		return err
+	}
+	if _, err := git(opts.Dir, "commit", "-m", "Add missing citations to "+filepath.ToSlash(rel)); err != nil {
+		return err
+	}
+	if opts.NoPush {
+		fmt.Fprintln(out, "committed", rel)
+		return partial(missing)
+	}
+	if _, err := git(opts.Dir, "push"); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "pushed", rel, "to", remote)
+	return partial(missing)
+}
+
+// partial fails a sync that left cited keys without an entry
+func partial(missing int) error {
+	if missing > 0 {
+		return withCode(exitPartial, fmt.Errorf("%d cited keys could not be added", missing))
+	}
+	return nil
+}
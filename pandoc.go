@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// pandocFilterName is the name under which bibgloss runs as a pandoc filter,
+// for pandoc --filter pandoc-bibgloss with a symlink to the binary
+const pandocFilterName = "pandoc-bibgloss"
+
+// doiCitePrefix marks the citations the filter resolves, like @doi:10.1000/xyz
+const doiCitePrefix = "doi:"
+
+// pandocFilter reads a pandoc JSON document from in, resolves its
+// @doi:... citations into the bibliography and writes the document with
+// the citations rewritten to their keys to out. Citations that cannot be
+// resolved are left alone and reported on errOut; pandoc shows the report
+// and citeproc warns about the key.
+func pandocFilter(cfg config, in io.Reader, out, errOut io.Writer) error {
+	dec := json.NewDecoder(in)
+	// numbers pass through the filter unchanged
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("reading pandoc document: %w", err)
+	}
+	meta, _ := doc["meta"].(map[string]any)
+	if meta == nil {
+		meta = map[string]any{}
+		doc["meta"] = meta
+	}
+	library := metaString(meta["bibliography"])
+	if library == "" {
+		library = cfg.Library
+		// citeproc needs to find the entries the filter adds
+		meta["bibliography"] = map[string]any{"t": "MetaString", "c": library}
+	}
+
+	var dois []string
+	walkCites(doc["blocks"], func(c map[string]any) {
+		if id, _ := c["citationId"].(string); strings.HasPrefix(id, doiCitePrefix) {
+			dois = append(dois, strings.TrimPrefix(id, doiCitePrefix))
+		}
+	})
+	keys, err := citeKeys(cfg, library, dois, errOut)
+	if err != nil {
+		return err
+	}
+	walkCites(doc["blocks"], func(c map[string]any) {
+		id, _ := c["citationId"].(string)
+		if key, ok := keys[strings.TrimPrefix(id, doiCitePrefix)]; ok && strings.HasPrefix(id, doiCitePrefix) {
+			c["citationId"] = key
+		}
+	})
+	// the rendering pandoc falls back to without citeproc shows the keys
+	// too. Longer DOIs go first, 10.1/ab must not be read as 10.1/a.
+	pairs := make([]string, 0, 2*len(keys))
+	for _, doi := range slices.SortedFunc(maps.Keys(keys), func(a, b string) int { return len(b) - len(a) }) {
+		pairs = append(pairs, "@"+doiCitePrefix+doi, "@"+keys[doi])
+	}
+	rewrite := strings.NewReplacer(pairs...)
+	walkStrings(doc["blocks"], rewrite.Replace)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+// citeKeys returns the library key of every DOI, importing the ones the
+// library does not have yet
+func citeKeys(cfg config, bib string, dois []string, errOut io.Writer) (map[string]string, error) {
+	keys := map[string]string{}
+	if len(dois) == 0 {
+		return keys, nil
+	}
+	entries, err := loadLibrary(bib)
+	if err != nil {
+		return nil, err
+	}
+	byDOI := map[string]string{}
+	for _, e := range entries {
+		if doi := e.Get("doi"); doi != "" {
+			byDOI[strings.ToLower(doi)] = e.Key
+		}
+	}
+	taken := library.Keys(entries)
+	for _, doi := range dois {
+		if _, ok := keys[doi]; ok {
+			continue
+		}
+		if key, ok := byDOI[strings.ToLower(resolve.CleanDOI(doi))]; ok {
+			keys[doi] = key
+			continue
+		}
+		w, err := resolve.Resolve(doi, cfg.resolveOptions())
+		if err != nil {
+			fmt.Fprintf(errOut, "%s: %s: %v\n", pandocFilterName, doi, err)
+			continue
+		}
+		w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(cfg.KeyTemplate, &w.Entry), taken)
+		e := bibtex.Convert(w.Entry, cfg.Format)
+		if err := preImport(cfg, &e, w.Abstract, bib, taken); err != nil {
+			fmt.Fprintf(errOut, "%s: %s: %v\n", pandocFilterName, doi, err)
+			continue
+		}
+		taken[e.Key] = true
+		if _, err := mutate(bib, "pandoc "+doi, func() error {
+			return appendEntry(bib, &e)
+		}); err != nil {
+			return nil, err
+		}
+		if err := syncImported(cfg, e, w.Abstract); err != nil {
+			fmt.Fprintf(errOut, "%s: %s: %v\n", pandocFilterName, e.Key, err)
+		}
+		keys[doi] = e.Key
+		byDOI[strings.ToLower(e.Get("doi"))] = e.Key
+	}
+	return keys, nil
+}
+
+// walkCites calls fn for the citations of every Cite element below node
+func walkCites(node any, fn func(map[string]any)) {
+	switch n := node.(type) {
+	case []any:
+		for _, child := range n {
+			walkCites(child, fn)
+		}
+	case map[string]any:
+		if n["t"] == "Cite" {
+			if c, ok := n["c"].([]any); ok && len(c) == 2 {
+				citations, _ := c[0].([]any)
+				for _, citation := range citations {
+					if m, ok := citation.(map[string]any); ok {
+						fn(m)
+					}
+				}
+			}
+		}
+		for _, child := range n {
+			walkCites(child, fn)
+		}
+	}
+}
+
+// walkStrings replaces the text of every Str element below node
+func walkStrings(node any, fn func(string) string) {
+	switch n := node.(type) {
+	case []any:
+		for _, child := range n {
+			walkStrings(child, fn)
+		}
+	case map[string]any:
+		if s, ok := n["c"].(string); ok && n["t"] == "Str" {
+			n["c"] = fn(s)
+			return
+		}
+		for _, child := range n {
+			walkStrings(child, fn)
+		}
+	}
+}
+
+// metaString returns the text of a metadata value, the first item of a list
+func metaString(v any) string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return ""
+	}
+	switch m["t"] {
+	case "MetaString":
+		s, _ := m["c"].(string)
+		return s
+	case "MetaInlines":
+		var b strings.Builder
+		inlines, _ := m["c"].([]any)
+		for _, i := range inlines {
+			switch in, _ := i.(map[string]any); in["t"] {
+			case "Str":
+				s, _ := in["c"].(string)
+				b.WriteString(s)
+			case "Space":
+				b.WriteString(" ")
+			}
+		}
+		return b.String()
+	case "MetaList":
+		if list, _ := m["c"].([]any); len(list) > 0 {
+			return metaString(list[0])
+		}
+	}
+	return ""
+}
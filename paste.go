@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sourcePasted marks the fields of an entry pasted into the TUI
+const sourcePasted = "pasted"
+
+// isPastedBibTeX reports whether text pasted into the DOI input is a BibTeX
+// entry rather than an identifier
+func isPastedBibTeX(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "@")
+}
+
+// pageRange matches a page range written with one hyphen or an en dash
+var pageRange = regexp.MustCompile(`^(\w+)\s*(?:-|–|—|--)\s*(\w+)$`)
+
+// monthNumbers are the BibTeX month macros
+var monthNumbers = map[string]string{
+	"jan": "1", "feb": "2", "mar": "3", "apr": "4", "may": "5", "jun": "6",
+	"jul": "7", "aug": "8", "sep": "9", "oct": "10", "nov": "11", "dec": "12",
+}
+
+// normalizePasted brings an entry copied from a publisher page into the
+// form resolved entries have: BibTeX rather than biblatex names, values on
+// one line, a bare DOI, numeric months, -- in page ranges and a language
+func normalizePasted(e Entry) Entry {
+	e = bibtex.FromBibLaTeX(e)
+	for i, f := range e.Fields {
+		e.Fields[i].Value = strings.Join(strings.Fields(f.Value), " ")
+	}
+	if doi := e.Get("doi"); doi != "" {
+		e.Set("doi", resolve.CleanDOI(doi))
+		// the url of many exports only points at the DOI
+		if u := e.Get("url"); resolve.CleanDOI(u) == e.Get("doi") {
+			e.Set("url", "")
+		}
+	}
+	month := strings.ToLower(strings.Trim(e.Get("month"), "{}. "))
+	if n, ok := monthNumbers[month[:min(3, len(month))]]; ok {
+		e.Set("month", n)
+	} else if n, err := strconv.Atoi(month); err == nil {
+		e.Set("month", strconv.Itoa(n))
+	}
+	if m := pageRange.FindStringSubmatch(e.Get("pages")); m != nil {
+		e.Set("pages", m[1]+"--"+m[2])
+	}
+	if e.Get("language") == "" {
+		e.Set("language", bibtex.DetectLanguage(e.Get("title")))
+	}
+	return e
+}
+
+// pastedWork reads a pasted BibTeX entry and keys it with the configured
+// template like a fetched one. An entry with a DOI is completed with what
+// the resolvers know, the pasted fields take precedence.
+func pastedWork(e *resolve.Engine, cfg config, text string) tea.Cmd {
+	return func() tea.Msg {
+		parsed, err := bibtex.Parse(strings.NewReader(text))
+		if err != nil {
+			return errMsg{err}
+		}
+		if len(parsed) != 1 {
+			return errMsg{errors.New(tr("paste one BibTeX entry, found %d", len(parsed)))}
+		}
+		w := &Work{Entry: normalizePasted(parsed[0]), Citations: -1, Sources: map[string]string{}}
+		for _, f := range w.Entry.Fields {
+			w.Sources[f.Name] = sourcePasted
+		}
+		if doi := w.Entry.Get("doi"); doi != "" && e.Submit(resolve.Job{ID: doi}) {
+			// a pasted entry is imported the same, resolved or not
+			if r := <-e.Results(); r.Err != nil {
+				slog.Info("pasted entry not resolved", "doi", doi, "err", r.Err)
+			} else {
+				resolved := r.Work
+				pasted := w.Entry
+				library.MergeInto(&pasted, &resolved.Entry)
+				for name, src := range w.Sources {
+					resolved.Sources[name] = src
+				}
+				resolved.Entry = pasted
+				w = resolved
+			}
+		}
+		// the pasted booktitle won over the normalized one
+		bibtex.NormalizeVenue(&w.Entry, cfg.venues())
+		entries, err := loadLibrary(cfg.Library)
+		if err != nil {
+			return errMsg{err}
+		}
+		w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(cfg.KeyTemplate, &w.Entry), library.Keys(entries))
+		return workMsg{w, similarEntries(entries, &w.Entry)}
+	}
+}
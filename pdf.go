@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// downloadPDF stores the PDF at link as <dir>/<key>.pdf and returns the path
+func downloadPDF(link, dir, key string) (string, error) {
+	if link == "" {
+		return "", errors.New("no open-access PDF available")
+	}
+	res, err := resolve.Client.Get(link)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close() // nolint:errcheck
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", link, res.Status)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	// publishers like to answer with a landing page instead of the file
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", fmt.Errorf("%s did not return a PDF", link)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, key+".pdf")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// fileField returns path relative to the library's directory when possible,
+// which keeps the file field portable between machines
+func fileField(library, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	base, err := filepath.Abs(filepath.Dir(library))
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(base, abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
@@ -0,0 +1,156 @@
+// Package bibtex reads and writes the entries of BibTeX files and builds
+// their citation keys.
+package bibtex
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Field is a single BibTeX field, kept in insertion order
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Entry is a single bibliography record as it is written to the .bib file
+type Entry struct {
+	Type   string
+	Key    string
+	Fields []Field
+
+	// byte span of the entry in the file it was parsed from
+	start, end int
+}
+
+// Span returns the byte offsets of the entry in the input it was parsed
+// from, both zero for entries that were not parsed
+func (e *Entry) Span() (start, end int) {
+	return e.start, e.end
+}
+
+// Get returns the value of a field or an empty string
+func (e *Entry) Get(name string) string {
+	name = strings.ToLower(name)
+	for _, f := range e.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Set replaces the value of a field, appending it if it is missing.
+// Setting an empty value removes the field.
+func (e *Entry) Set(name, value string) {
+	name = strings.ToLower(name)
+	for i, f := range e.Fields {
+		if f.Name == name {
+			if value == "" {
+				e.Fields = append(e.Fields[:i], e.Fields[i+1:]...)
+			} else {
+				e.Fields[i].Value = value
+			}
+			return
+		}
+	}
+	if value != "" {
+		e.Fields = append(e.Fields, Field{Name: name, Value: value})
+	}
+}
+
+// BibTeX renders the entry in the format used by the library file
+func (e *Entry) BibTeX() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@%s{%s,\n", e.Type, e.Key)
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "  %s = {%s},\n", f.Name, f.Value)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Aliases returns the former keys of the entry, kept in its ids field as
+// biblatex and JabRef do, so citations by an old key still find it
+func (e *Entry) Aliases() []string {
+	var keys []string
+	for _, k := range strings.Split(e.Get("ids"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// AddAlias records a former key of the entry
+func (e *Entry) AddAlias(key string) {
+	keys := e.Aliases()
+	if key == "" || key == e.Key || slices.Contains(keys, key) {
+		return
+	}
+	e.Set("ids", strings.Join(append(keys, key), ", "))
+}
+
+// Rename changes the key of the entry and keeps the old one as an alias.
+// Renaming it back to an alias drops that alias.
+func (e *Entry) Rename(key string) {
+	old := e.Key
+	e.Key = key
+	keys := slices.DeleteFunc(e.Aliases(), func(k string) bool { return k == key })
+	e.Set("ids", strings.Join(keys, ", "))
+	e.AddAlias(old)
+}
+
+// SplitAuthors splits a BibTeX author list on the "and" separator
+func SplitAuthors(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	parts := strings.Split(s, " and ")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// NameIDs returns the ORCID iDs of the names in a name list field like
+// author, in the order of SplitAuthors. They are kept in a +ids field by
+// the position of the name, like author+ids = {1=0000-0002-1825-0097;
+// 3=...}. Names without an iD are empty.
+func (e *Entry) NameIDs(field string) []string {
+	return e.nameItems(field, "ids")
+}
+
+// SetNameIDs records the ORCID iDs of the names in field, removing its +ids
+// field when none is known
+func (e *Entry) SetNameIDs(field string, ids []string) {
+	e.setNameItems(field, "ids", ids)
+}
+
+// nameItems reads the field+kind field annotating the names of field by
+// position
+func (e *Entry) nameItems(field, kind string) []string {
+	values := make([]string, len(SplitAuthors(e.Get(field))))
+	for _, item := range strings.Split(e.Get(field+"+"+kind), ";") {
+		pos, v, ok := strings.Cut(item, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(pos))
+		if ok && err == nil && n >= 1 && n <= len(values) {
+			values[n-1] = strings.TrimSpace(v)
+		}
+	}
+	return values
+}
+
+// setNameItems writes the field+kind field, removing it when every value
+// is empty
+func (e *Entry) setNameItems(field, kind string, values []string) {
+	var items []string
+	for i, v := range values {
+		if v != "" {
+			items = append(items, fmt.Sprintf("%d=%s", i+1, v))
+		}
+	}
+	e.Set(field+"+"+kind, strings.Join(items, "; "))
+}
@@ -0,0 +1,138 @@
+package bibtex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// entry dialects selectable with Convert
+const (
+	FormatBibTeX   = "bibtex"
+	FormatBibLaTeX = "biblatex"
+)
+
+// Formats converts entries, which are kept in BibTeX form internally, to
+// the configured dialect
+var Formats = map[string]func(e Entry) Entry{
+	FormatBibTeX:   func(e Entry) Entry { return e },
+	FormatBibLaTeX: ToBibLaTeX,
+}
+
+// Convert returns e in the given dialect
+func Convert(e Entry, format string) Entry {
+	if convert, ok := Formats[format]; ok {
+		return convert(e)
+	}
+	return e
+}
+
+// Render renders e in the given dialect
+func Render(e *Entry, format string) string {
+	out := Convert(*e, format)
+	return out.BibTeX()
+}
+
+// biblatexFields are the biblatex names of renamed BibTeX fields
+var biblatexFields = map[string]string{
+	"journal":  "journaltitle",
+	"address":  "location",
+	"school":   "institution",
+	"language": "langid",
+}
+
+// biblatexTypes maps BibTeX types to a biblatex type and its type field
+var biblatexTypes = map[string][2]string{
+	"phdthesis":     {"thesis", "phdthesis"},
+	"mastersthesis": {"thesis", "mathesis"},
+	"techreport":    {"report", "techreport"},
+}
+
+// biblatexMisc maps the howpublished of BibTeX misc entries to the biblatex
+// types of software and datasets
+var biblatexMisc = map[string]string{
+	"Software": "software",
+	"Dataset":  "dataset",
+}
+
+// ToBibLaTeX renames fields and types and merges year and month into date
+func ToBibLaTeX(e Entry) Entry {
+	out := Entry{Type: e.Type, Key: e.Key}
+	if t, ok := biblatexTypes[e.Type]; ok {
+		out.Type = t[0]
+		out.Set("type", t[1])
+	}
+	kind, software := biblatexMisc[e.Get("howpublished")]
+	software = software && e.Type == "misc"
+	if software {
+		out.Type = kind
+	}
+	for _, f := range e.Fields {
+		switch f.Name {
+		case "howpublished":
+			if !software {
+				out.Set(f.Name, f.Value)
+			}
+		case "year":
+			date := f.Value
+			if m, err := strconv.Atoi(e.Get("month")); err == nil && m >= 1 && m <= 12 {
+				date = fmt.Sprintf("%s-%02d", date, m)
+			}
+			out.Set("date", date)
+		case "month":
+		default:
+			name := f.Name
+			if n, ok := biblatexFields[name]; ok {
+				name = n
+			}
+			out.Set(name, f.Value)
+		}
+	}
+	return out
+}
+
+// FromBibLaTeX undoes ToBibLaTeX for entries written for biblatex: fields
+// and types get their BibTeX names again and date is split into year and
+// month. BibTeX entries are returned unchanged.
+func FromBibLaTeX(e Entry) Entry {
+	out := Entry{Type: e.Type, Key: e.Key}
+	kind := e.Get("type")
+	typed := false
+	for t, b := range biblatexTypes {
+		// an untyped thesis is taken for a PhD thesis
+		if e.Type == b[0] && (kind == b[1] || kind == "" && t != "mastersthesis") {
+			out.Type, typed = t, true
+		}
+	}
+	for how, t := range biblatexMisc {
+		if e.Type == t {
+			out.Type = "misc"
+			out.Set("howpublished", how)
+		}
+	}
+	for _, f := range e.Fields {
+		name := f.Name
+		for b, l := range biblatexFields {
+			// reports have an institution in BibTeX too
+			if name == l && (b != "school" || strings.HasSuffix(out.Type, "thesis")) {
+				name = b
+			}
+		}
+		switch {
+		case name == "type" && typed:
+		case name == "date":
+			year, rest, _ := strings.Cut(f.Value, "-")
+			out.Set("year", year)
+			if m, _, _ := strings.Cut(rest, "-"); m != "" {
+				if n, err := strconv.Atoi(m); err == nil {
+					out.Set("month", strconv.Itoa(n))
+				}
+			}
+		default:
+			if out.Get(name) == "" {
+				out.Set(name, f.Value)
+			}
+		}
+	}
+	return out
+}
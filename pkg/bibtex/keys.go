@@ -0,0 +1,135 @@
+package bibtex
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultKeyTemplate produces keys like smith2020great
+const DefaultKeyTemplate = "{auth}{year}{title}"
+
+var placeholderRe = regexp.MustCompile(`\{(\w+)\}`)
+
+// MakeKey builds a citation key with the default template
+func MakeKey(e *Entry) string {
+	return FormatKey(DefaultKeyTemplate, e)
+}
+
+// FormatKey expands a key template. Supported placeholders are {auth}
+// (first author's family name), {authors} (up to three family names),
+// {year}, {title} (first word longer than three letters) and {shorttitle}
+// (first three such words).
+func FormatKey(tmpl string, e *Entry) string {
+	key := placeholderRe.ReplaceAllStringFunc(tmpl, func(p string) string {
+		switch p[1 : len(p)-1] {
+		case "auth":
+			return keySafe(firstFamily(e))
+		case "authors":
+			var names []string
+			for i, a := range SplitAuthors(e.Get("author")) {
+				if i == 3 {
+					break
+				}
+				names = append(names, keySafe(FamilyName(a)))
+			}
+			return strings.Join(names, "")
+		case "year":
+			return keySafe(e.Get("year"))
+		case "title":
+			return strings.Join(titleWords(e, 1), "")
+		case "shorttitle":
+			return strings.Join(titleWords(e, 3), "")
+		}
+		return p
+	})
+	if key == "" {
+		return "unknown"
+	}
+	return key
+}
+
+// UniqueKey appends a, b, c… to key until it does not collide with taken
+func UniqueKey(key string, taken map[string]bool) string {
+	if !taken[key] {
+		return key
+	}
+	for i := 0; ; i++ {
+		suffix := ""
+		for n := i; ; n = n/26 - 1 {
+			suffix = string(rune('a'+n%26)) + suffix
+			if n < 26 {
+				break
+			}
+		}
+		if k := key + suffix; !taken[k] {
+			return k
+		}
+	}
+}
+
+// ValidKey reports whether key can be used as a BibTeX citation key
+func ValidKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+	if i := strings.IndexAny(key, " \t\n,{}()=\"#%'\\"); i >= 0 {
+		return fmt.Errorf("key %q contains %q", key, key[i])
+	}
+	return nil
+}
+
+func firstFamily(e *Entry) string {
+	authors := SplitAuthors(e.Get("author"))
+	if len(authors) == 0 {
+		authors = SplitAuthors(e.Get("editor"))
+	}
+	if len(authors) == 0 {
+		return ""
+	}
+	return FamilyName(authors[0])
+}
+
+// FamilyName extracts the family name from "Family, Given" or "Given Family"
+func FamilyName(name string) string {
+	name = strings.Trim(name, "{}")
+	if i := strings.Index(name, ","); i >= 0 {
+		return name[:i]
+	}
+	if i := strings.LastIndex(name, " "); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// latexMarkup matches the math and command names in titles, which are not
+// words of a key
+var latexMarkup = regexp.MustCompile(`\$[^$]*\$|\\[A-Za-z]+`)
+
+// titleWords returns up to n key-safe title words longer than three letters
+func titleWords(e *Entry, n int) []string {
+	var words []string
+	for _, w := range strings.Fields(latexMarkup.ReplaceAllString(e.Get("title"), " ")) {
+		w = keySafe(w)
+		if len(w) > 3 {
+			words = append(words, w)
+			if len(words) == n {
+				break
+			}
+		}
+	}
+	return words
+}
+
+// keySafe lowercases s and drops everything that is not an ASCII letter or
+// digit. Cyrillic, Greek and Korean names are romanized first.
+func keySafe(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(Romanize(s)) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,242 @@
+package bibtex
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageNames maps ISO 639 codes, as metadata gives them, to the babel
+// names the language field takes
+var languageNames = map[string]string{
+	"en": "english", "eng": "english",
+	"de": "ngerman", "deu": "ngerman", "ger": "ngerman",
+	"fr": "french", "fra": "french", "fre": "french",
+	"es": "spanish", "spa": "spanish",
+	"pt": "portuguese", "por": "portuguese",
+	"it": "italian", "ita": "italian",
+	"nl": "dutch", "nld": "dutch", "dut": "dutch",
+	"pl": "polish", "pol": "polish",
+	"cs": "czech", "ces": "czech", "cze": "czech",
+	"sv": "swedish", "swe": "swedish",
+	"da": "danish", "dan": "danish",
+	"no": "norsk", "nb": "norsk", "nor": "norsk",
+	"fi": "finnish", "fin": "finnish",
+	"tr": "turkish", "tur": "turkish",
+	"ru": "russian", "rus": "russian",
+	"uk": "ukrainian", "ukr": "ukrainian",
+	"bg": "bulgarian", "bul": "bulgarian",
+	"el": "greek", "ell": "greek", "gre": "greek",
+	"ja": "japanese", "jpn": "japanese",
+	"zh": "chinese", "zho": "chinese", "chi": "chinese",
+	"ko": "korean", "kor": "korean",
+	"ar": "arabic", "ara": "arabic",
+	"he": "hebrew", "heb": "hebrew",
+}
+
+// LanguageName returns the babel name of a language code like en, en-US or
+// rus, or an empty string for codes it does not know. Babel names are
+// returned as they are.
+func LanguageName(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	for _, name := range languageNames {
+		if code == name {
+			return name
+		}
+	}
+	return ""
+}
+
+// LanguageCode returns the two-letter ISO 639-1 code of a babel name or of
+// a code LanguageName knows, or an empty string
+func LanguageCode(name string) string {
+	name = LanguageName(name)
+	for code, n := range languageNames {
+		if n == name && len(code) == 2 {
+			return code
+		}
+	}
+	return ""
+}
+
+// stopWords are short words that only one of the languages written in Latin
+// script uses, which tell the language of a title
+var stopWords = map[string]string{
+	"the": "english", "and": "english", "of": "english", "for": "english", "with": "english", "from": "english", "towards": "english",
+	"der": "ngerman", "die": "ngerman", "das": "ngerman", "und": "ngerman", "für": "ngerman", "mit": "ngerman", "von": "ngerman", "zur": "ngerman", "zum": "ngerman", "über": "ngerman", "eine": "ngerman", "einer": "ngerman",
+	"le": "french", "les": "french", "et": "french", "pour": "french", "une": "french", "sur": "french", "du": "french", "dans": "french", "aux": "french",
+	"el": "spanish", "los": "spanish", "las": "spanish", "del": "spanish", "sobre": "spanish", "por": "spanish",
+	"os": "portuguese", "da": "portuguese", "do": "portuguese", "dos": "portuguese", "uma": "portuguese", "com": "portuguese", "em": "portuguese",
+	"il": "italian", "della": "italian", "dei": "italian", "delle": "italian", "degli": "italian", "nel": "italian", "gli": "italian",
+	"het": "dutch", "een": "dutch", "voor": "dutch", "naar": "dutch",
+}
+
+// DetectLanguage guesses the babel name of the language text is written in.
+// Scripts other than Latin mostly tell the language; Latin text needs two
+// stop words of one language and more than of any other. An empty string
+// means the language is unknown.
+func DetectLanguage(text string) string {
+	counts := map[*unicode.RangeTable]int{}
+	scripts := []*unicode.RangeTable{unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Han, unicode.Cyrillic, unicode.Greek, unicode.Arabic, unicode.Hebrew}
+	latin := 0
+	for _, r := range text {
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+		}
+		for _, s := range scripts {
+			if unicode.Is(s, r) {
+				counts[s]++
+			}
+		}
+	}
+	switch {
+	case counts[unicode.Hiragana]+counts[unicode.Katakana] > 0:
+		// Japanese mixes kana into kanji, Chinese has none
+		return "japanese"
+	case counts[unicode.Hangul] > 0:
+		return "korean"
+	case counts[unicode.Han] > latin:
+		return "chinese"
+	case counts[unicode.Cyrillic] > latin:
+		if strings.ContainsAny(strings.ToLower(text), "іїєґ") {
+			return "ukrainian"
+		}
+		return "russian"
+	case counts[unicode.Greek] > latin:
+		return "greek"
+	case counts[unicode.Arabic] > latin:
+		return "arabic"
+	case counts[unicode.Hebrew] > latin:
+		return "hebrew"
+	}
+	votes := map[string]int{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if lang, ok := stopWords[w]; ok {
+			votes[lang]++
+		}
+	}
+	best, top, second := "", 0, 0
+	for lang, n := range votes {
+		if n > top {
+			best, top, second = lang, n, top
+		} else if n > second {
+			second = n
+		}
+	}
+	if top < 2 || top == second {
+		return ""
+	}
+	return best
+}
+
+// IsCJK reports whether s contains Chinese, Japanese or Korean characters,
+// whose names put the family name first
+func IsCJK(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+// CJKName turns a Chinese, Japanese or Korean name given in one piece,
+// family name first, into a BibTeX "Family, Given". It reports false for
+// other names and ones without a space to split at.
+func CJKName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	family, given, ok := strings.Cut(name, " ")
+	if !ok || !IsCJK(name) {
+		return "", false
+	}
+	return family + ", " + strings.TrimSpace(given), true
+}
+
+// romanLetters transliterates Cyrillic and Greek letters, following the
+// common English romanization of names
+var romanLetters = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+	'α': "a", 'ά': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'έ': "e", 'ζ': "z",
+	'η': "i", 'ή': "i", 'θ': "th", 'ι': "i", 'ί': "i", 'ϊ': "i", 'ΐ': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'ό': "o", 'π': "p", 'ρ': "r",
+	'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'ύ': "y", 'ϋ': "y", 'ΰ': "y", 'φ': "f",
+	'χ': "ch", 'ψ': "ps", 'ω': "o", 'ώ': "o",
+}
+
+// greekDigraphs are the letter pairs of Greek romanized as one sound
+var greekDigraphs = strings.NewReplacer("ου", "ou", "ού", "ou", "Ου", "Ou", "Ού", "Ou", "ΟΥ", "OU")
+
+// hangul jamo in the Revised Romanization of Korean, by their position in
+// a precomposed syllable
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulVowels   = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+)
+
+// Romanize transliterates the Cyrillic, Greek and Korean letters of s into
+// Latin ones, keeping capitals. Chinese and Japanese characters cannot be
+// romanized without a dictionary and stay as they are.
+func Romanize(s string) string {
+	var b strings.Builder
+	inWord := false
+	for _, r := range greekDigraphs.Replace(s) {
+		latin, upper := "", false
+		switch l := unicode.ToLower(r); {
+		case romanLetters[l] != "" || l == 'ъ' || l == 'ь':
+			latin, upper = romanLetters[l], unicode.IsUpper(r)
+		case r >= 0xac00 && r <= 0xd7a3:
+			n := int(r - 0xac00)
+			latin = hangulInitials[n/588] + hangulVowels[n%588/28] + hangulFinals[n%28]
+			// Korean has no capitals, a syllable starting a word gets one
+			upper = !inWord
+		default:
+			b.WriteRune(r)
+			inWord = unicode.IsLetter(r)
+			continue
+		}
+		if upper && latin != "" {
+			latin = strings.ToUpper(latin[:1]) + latin[1:]
+		}
+		b.WriteString(latin)
+		inWord = true
+	}
+	return b.String()
+}
+
+// NameRomanized returns the romanized forms of the names in field, kept in
+// a +roman field by position like NameIDs. Names without one are empty.
+func (e *Entry) NameRomanized(field string) []string {
+	return e.nameItems(field, "roman")
+}
+
+// SetNameRomanized records the romanized forms of the names in field
+func (e *Entry) SetNameRomanized(field string, names []string) {
+	e.setNameItems(field, "roman", names)
+}
+
+// RomanizeNames records the romanized form of every name in field that is
+// written in Cyrillic, Greek or Hangul, reporting whether it found any
+func (e *Entry) RomanizeNames(field string) bool {
+	names := SplitAuthors(e.Get(field))
+	roman := make([]string, len(names))
+	found := false
+	for i, n := range names {
+		if r := Romanize(n); r != n {
+			roman[i], found = r, true
+		}
+	}
+	if found {
+		e.SetNameRomanized(field, roman)
+	}
+	return found
+}
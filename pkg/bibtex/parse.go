@@ -0,0 +1,375 @@
+package bibtex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parse reads all entries from a BibTeX file. @comment, @string and
+// @preamble blocks are skipped. Entries that do not parse are left out and
+// reported as ParseErrors with their positions once the rest is read.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	err := Each(r, func(e *Entry) error {
+		entries = append(entries, *e)
+		return nil
+	})
+	return entries, err
+}
+
+// Each calls fn for every entry of r as soon as it is read, so files
+// of any size are parsed with the memory of a single entry. Syntax errors
+// skip to the next entry and are returned as ParseErrors at the end; an
+// error from fn stops the scan.
+func Each(r io.Reader, fn func(*Entry) error) error {
+	s := newBibScanner(r)
+	var errs ParseErrors
+	for {
+		e, err := s.next()
+		var perr *ParseError
+		switch {
+		case errors.Is(err, io.EOF):
+			if len(errs) > 0 {
+				return errs
+			}
+			return nil
+		case errors.As(err, &perr):
+			errs = append(errs, perr)
+			continue
+		case err != nil:
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// ParseError is a syntax error at a line and column of the input, both
+// counted from 1
+type ParseError struct {
+	Line, Col int
+	// Key is the entry the error is in, if it got that far
+	Key string
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Col, e.Key, e.Msg)
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// ParseErrors are the syntax errors of one input, in order
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more syntax errors)", e[0], len(e)-1)
+}
+
+// position is a place in the input
+type position struct{ off, line, col int }
+
+// bibScanner reads entries from a stream one byte at a time, keeping the
+// byte offsets rewrites need and the lines and columns errors report
+type bibScanner struct {
+	r   *bufio.Reader
+	pos position
+	// err is the first read error other than the end of input
+	err error
+}
+
+func newBibScanner(r io.Reader) *bibScanner {
+	return &bibScanner{r: bufio.NewReaderSize(r, 64*1024), pos: position{line: 1, col: 1}}
+}
+
+// peek returns the next byte without consuming it, false at the end
+func (s *bibScanner) peek() (byte, bool) {
+	b, err := s.r.Peek(1)
+	if err != nil {
+		if !errors.Is(err, io.EOF) && s.err == nil {
+			s.err = err
+		}
+		return 0, false
+	}
+	return b[0], true
+}
+
+// advance consumes the next byte. Columns count characters, so the
+// continuation bytes of UTF-8 sequences do not move them.
+func (s *bibScanner) advance() byte {
+	c, err := s.r.ReadByte()
+	if err != nil {
+		return 0
+	}
+	s.pos.off++
+	switch {
+	case c == '\n':
+		s.pos.line++
+		s.pos.col = 1
+	case c&0xC0 != 0x80:
+		s.pos.col++
+	}
+	return c
+}
+
+// entryAhead reports whether the input continues, after blanks, with the
+// start of an entry like @article{. Checked at line starts, it ends an
+// entry whose closing brace is missing instead of swallowing the next one.
+func (s *bibScanner) entryAhead() bool {
+	b, _ := s.r.Peek(64)
+	i := 0
+	for i < len(b) && (b[i] == ' ' || b[i] == '\t') {
+		i++
+	}
+	if i >= len(b) || b[i] != '@' {
+		return false
+	}
+	i++
+	n := i
+	for i < len(b) && isTypeByte(b[i]) {
+		i++
+	}
+	for i < len(b) && (b[i] == ' ' || b[i] == '\t') {
+		i++
+	}
+	return i > n && i < len(b) && (b[i] == '{' || b[i] == '(')
+}
+
+func isTypeByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// errTruncated marks text that would run into the next entry
+var errTruncated = errors.New("truncated")
+
+func (s *bibScanner) errorAt(at position, key, format string, args ...any) error {
+	if s.err != nil {
+		return s.err
+	}
+	return &ParseError{Line: at.line, Col: at.col, Key: key, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns the next entry, skipping the other blocks. It returns
+// io.EOF at the end of input and a *ParseError for an entry that does not
+// parse, after which the scan can go on.
+func (s *bibScanner) next() (*Entry, error) {
+	// lineStart is set while only blanks were read since the last newline
+	lineStart := s.pos.col == 1
+	for {
+		c, ok := s.peek()
+		if !ok {
+			if s.err != nil {
+				return nil, s.err
+			}
+			return nil, io.EOF
+		}
+		if c != '@' {
+			s.advance()
+			lineStart = c == '\n' || lineStart && (c == ' ' || c == '\t')
+			continue
+		}
+		e, err := s.block(lineStart)
+		if err != nil || e != nil {
+			return e, err
+		}
+		lineStart = false
+	}
+}
+
+// block reads the block starting at the @ under the scanner. A nil entry
+// without error is a skipped block. Text between entries is a comment to
+// BibTeX, so an @ in it, like in an email address, is only an error at the
+// start of a line.
+func (s *bibScanner) block(lineStart bool) (*Entry, error) {
+	start := s.pos
+	s.advance()
+	var typ strings.Builder
+	for c, ok := s.peek(); ok && isTypeByte(c); c, ok = s.peek() {
+		typ.WriteByte(s.advance())
+	}
+	s.skipSpace(false)
+	open, ok := s.peek()
+	if !ok || open != '{' && open != '(' {
+		if typ.Len() == 0 || !lineStart {
+			return nil, nil
+		}
+		return nil, s.errorAt(start, "", "@%s is not followed by { or (", typ.String())
+	}
+	s.advance()
+	closer := byte('}')
+	if open == '(' {
+		closer = ')'
+	}
+
+	switch t := strings.ToLower(typ.String()); t {
+	case "comment", "string", "preamble":
+		if _, err := s.balanced(open, closer, false); err != nil {
+			return nil, s.errorAt(start, "", "unterminated @%s", t)
+		}
+		return nil, nil
+	}
+
+	key, err := s.until(",}"+string(closer), true)
+	e := &Entry{Type: strings.ToLower(typ.String()), Key: strings.TrimSpace(key), start: start.off}
+	if err != nil {
+		return nil, s.errorAt(start, e.Key, "unterminated entry")
+	}
+	for {
+		s.skipSpace(true)
+		c, ok := s.peek()
+		if !ok || s.pos.col == 1 && s.entryAhead() {
+			return nil, s.errorAt(start, e.Key, "unterminated entry")
+		}
+		switch c {
+		case ',':
+			s.advance()
+			continue
+		case closer:
+			s.advance()
+			e.end = s.pos.off
+			return e, nil
+		}
+		at := s.pos
+		name, err := s.until("=,}"+string(closer), true)
+		if err != nil {
+			return nil, s.errorAt(start, e.Key, "unterminated entry")
+		}
+		if c, ok := s.peek(); !ok || c != '=' {
+			// text without a value is ignored, a stray brace skipped
+			if c != ',' && c != closer {
+				s.advance()
+			}
+			continue
+		}
+		s.advance()
+		value, err := s.value(closer)
+		if err != nil {
+			return nil, s.errorAt(at, e.Key, "%s: %v", strings.TrimSpace(name), err)
+		}
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			e.Fields = append(e.Fields, Field{Name: name, Value: value})
+		}
+	}
+}
+
+// value parses a field value including # concatenations
+func (s *bibScanner) value(closer byte) (string, error) {
+	var parts []string
+	for {
+		s.skipSpace(true)
+		c, ok := s.peek()
+		if !ok {
+			return "", errors.New("unexpected end of input")
+		}
+		switch c {
+		case '{':
+			s.advance()
+			v, err := s.balanced('{', '}', true)
+			if err != nil {
+				return "", errors.New("unbalanced {")
+			}
+			parts = append(parts, v)
+		case '"':
+			s.advance()
+			v, err := s.quoted()
+			if err != nil {
+				return "", errors.New("unterminated string")
+			}
+			parts = append(parts, v)
+		default:
+			v, err := s.until("#,}"+string(closer), true)
+			if err != nil {
+				return "", errors.New("unexpected end of input")
+			}
+			parts = append(parts, strings.TrimSpace(v))
+		}
+		s.skipSpace(true)
+		if c, ok := s.peek(); ok && c == '#' {
+			s.advance()
+			continue
+		}
+		return strings.Join(parts, ""), nil
+	}
+}
+
+// quoted returns a "..." value after its opening quote and consumes the
+// closing one. Quotes inside braces belong to the value.
+func (s *bibScanner) quoted() (string, error) {
+	var b strings.Builder
+	depth := 0
+	for {
+		c, ok := s.peek()
+		if !ok || s.pos.col == 1 && s.entryAhead() {
+			return "", errTruncated
+		}
+		s.advance()
+		switch {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == '"' && depth == 0:
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+	}
+}
+
+// balanced returns the text up to the matching closer and consumes it.
+// Within entries the text may not run into the next entry.
+func (s *bibScanner) balanced(open, closer byte, inEntry bool) (string, error) {
+	var b strings.Builder
+	depth := 1
+	for {
+		c, ok := s.peek()
+		if !ok || inEntry && s.pos.col == 1 && s.entryAhead() {
+			return "", errTruncated
+		}
+		s.advance()
+		switch c {
+		case open:
+			depth++
+		case closer:
+			depth--
+			if depth == 0 {
+				return b.String(), nil
+			}
+		}
+		b.WriteByte(c)
+	}
+}
+
+// until advances to the next byte contained in stop and returns the text
+// before it
+func (s *bibScanner) until(stop string, inEntry bool) (string, error) {
+	var b strings.Builder
+	for {
+		c, ok := s.peek()
+		if !ok || inEntry && s.pos.col == 1 && s.entryAhead() {
+			return b.String(), errTruncated
+		}
+		if strings.IndexByte(stop, c) >= 0 {
+			return b.String(), nil
+		}
+		b.WriteByte(s.advance())
+	}
+}
+
+// skipSpace consumes blanks. Within entries it stops before the start of
+// the next entry.
+func (s *bibScanner) skipSpace(inEntry bool) {
+	for c, ok := s.peek(); ok && strings.IndexByte(" \t\r\n", c) >= 0; c, ok = s.peek() {
+		if inEntry && s.pos.col == 1 && s.entryAhead() {
+			return
+		}
+		s.advance()
+	}
+}
@@ -0,0 +1,196 @@
+package bibtex
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Entry
+		// errs are the syntax errors, in order
+		errs []string
+	}{
+		{
+			name: "braces",
+			in:   "@ARTICLE{Doe21, TITLE = {A {B} c}, year = 2021,}",
+			want: []Entry{{Type: "article", Key: "Doe21", Fields: []Field{{"title", "A {B} c"}, {"year", "2021"}}}},
+		},
+		{
+			name: "parentheses",
+			in:   "@article(a, title = {T (x)}, year = 2020)",
+			want: []Entry{{Type: "article", Key: "a", Fields: []Field{{"title", "T (x)"}, {"year", "2020"}}}},
+		},
+		{
+			name: "quotes and concatenation",
+			in:   `@article{a, title = "a {"} b", note = "x" # {y} # z}`,
+			want: []Entry{{Type: "article", Key: "a", Fields: []Field{{"title", `a {"} b`}, {"note", "xyz"}}}},
+		},
+		{
+			name: "comment block",
+			in:   "@comment{ @article{x, title={X}} }\n@article{a, title={T}}",
+			want: []Entry{{Type: "article", Key: "a", Fields: []Field{{"title", "T"}}}},
+		},
+		{
+			name: "comment block in parentheses",
+			in:   "@Comment(@article{x})\n@article{a, title={T}}",
+			want: []Entry{{Type: "article", Key: "a", Fields: []Field{{"title", "T"}}}},
+		},
+		{
+			name: "string and preamble",
+			in:   "@string{jan = \"January\"}\n@preamble{\"\\newcommand\"}\n@article{a, month = jan}",
+			want: []Entry{{Type: "article", Key: "a", Fields: []Field{{"month", "jan"}}}},
+		},
+		{
+			name: "at sign in text between entries",
+			in:   "mail me@example.org today\n@article{a, title={T}}",
+			want: []Entry{{Type: "article", Key: "a", Fields: []Field{{"title", "T"}}}},
+		},
+		{
+			name: "stray brace in parentheses",
+			in:   "@article(a, }x = {1})",
+			want: []Entry{{Type: "article", Key: "a", Fields: []Field{{"x", "1"}}}},
+		},
+		{
+			name: "missing closing brace",
+			in:   "@article{a,\n  title = {T}\n@book{b, title={U}}",
+			want: []Entry{{Type: "book", Key: "b", Fields: []Field{{"title", "U"}}}},
+			errs: []string{"line 1, column 1: a: unterminated entry"},
+		},
+		{
+			name: "unbalanced value",
+			in:   "@article{a,\n  title = {T\n@book{b, title={U}}",
+			want: []Entry{{Type: "book", Key: "b", Fields: []Field{{"title", "U"}}}},
+			errs: []string{"line 2, column 3: a: title: unbalanced {"},
+		},
+		{
+			name: "unterminated string",
+			in:   "@article{a,\n  title = \"T\n@book{b, title={U}}",
+			want: []Entry{{Type: "book", Key: "b", Fields: []Field{{"title", "U"}}}},
+			errs: []string{"line 2, column 3: a: title: unterminated string"},
+		},
+		{
+			name: "columns count characters",
+			in:   "@article{a,\n  tïtle = {Ü}, ẅ = {x\n@book{b,}",
+			want: []Entry{{Type: "book", Key: "b"}},
+			errs: []string{"line 2, column 16: a: ẅ: unbalanced {"},
+		},
+		{
+			name: "type without brace",
+			in:   "@oops\n@article{a,}",
+			want: []Entry{{Type: "article", Key: "a"}},
+			errs: []string{"line 1, column 1: @oops is not followed by { or ("},
+		},
+		{
+			name: "unterminated comment",
+			in:   "@article{a,}\n@comment{never closed",
+			want: []Entry{{Type: "article", Key: "a"}},
+			errs: []string{"line 2, column 1: unterminated @comment"},
+		},
+		{
+			name: "every error is reported",
+			in:   "@article{a\n@article{b, title = {T}}\n@article{c,\n@article{d,}",
+			want: []Entry{{Type: "article", Key: "b", Fields: []Field{{"title", "T"}}}, {Type: "article", Key: "d"}},
+			errs: []string{"line 1, column 1: a: unterminated entry", "line 3, column 1: c: unterminated entry"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.in))
+			var errs []string
+			var perrs ParseErrors
+			if errors.As(err, &perrs) {
+				for _, e := range perrs {
+					errs = append(errs, e.Error())
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(errs, tt.errs) {
+				t.Errorf("errors %q, want %q", errs, tt.errs)
+			}
+			if !slices.EqualFunc(got, tt.want, sameEntry) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameEntry compares entries without their spans
+func sameEntry(a, b Entry) bool {
+	return a.Type == b.Type && a.Key == b.Key && slices.Equal(a.Fields, b.Fields)
+}
+
+func TestParseSpans(t *testing.T) {
+	in := "% Ünïcode before\r\n@article{a,\r\n  title = {Über}\r\n}\r\n" +
+		"@comment{@book{x,}}\n@book(b, title = \"T\")  trailing text\n" +
+		"@article{broken,\n@misc{c, note = {ẅ}}"
+	entries, err := Parse(strings.NewReader(in))
+	var perrs ParseErrors
+	if !errors.As(err, &perrs) || len(perrs) != 1 {
+		t.Fatalf("got error %v, want the one of broken", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	spans := []string{
+		"@article{a,\r\n  title = {Über}\r\n}",
+		`@book(b, title = "T")`,
+		"@misc{c, note = {ẅ}}",
+	}
+	for i, e := range entries {
+		start, end := e.Span()
+		if got := in[start:end]; got != spans[i] {
+			t.Errorf("%s: span %q, want %q", e.Key, got, spans[i])
+		}
+		// an entry cut out of the file parses to itself
+		again, err := Parse(strings.NewReader(in[start:end]))
+		if err != nil || len(again) != 1 || !sameEntry(again[0], e) {
+			t.Errorf("%s: reparsed to %+v, %v", e.Key, again, err)
+		}
+	}
+
+	// replacing every entry by its rendering keeps the text between them
+	// and the entries
+	var b strings.Builder
+	last := 0
+	for _, e := range entries {
+		start, end := e.Span()
+		b.WriteString(in[last:start])
+		b.WriteString(strings.TrimSuffix(e.BibTeX(), "\n"))
+		last = end
+	}
+	b.WriteString(in[last:])
+	out := b.String()
+	again, err := Parse(strings.NewReader(out))
+	if !errors.As(err, &perrs) || len(perrs) != 1 {
+		t.Fatalf("got error %v after rewriting, want the one of broken", err)
+	}
+	if !slices.EqualFunc(again, entries, sameEntry) {
+		t.Errorf("rewritten file parses to %+v, want %+v", again, entries)
+	}
+	for _, text := range []string{"% Ünïcode before\r\n", "@comment{@book{x,}}\n", "  trailing text\n@article{broken,\n"} {
+		if !strings.Contains(out, text) {
+			t.Errorf("rewritten file lost %q:\n%s", text, out)
+		}
+	}
+}
+
+func TestEachStops(t *testing.T) {
+	stop := errors.New("stop")
+	var keys []string
+	err := Each(strings.NewReader("@misc{a,}\n@misc{b,}\n@misc{c,}"), func(e *Entry) error {
+		keys = append(keys, e.Key)
+		if e.Key == "b" {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("got %q, %v, want a and b, then the error of fn", keys, err)
+	}
+}
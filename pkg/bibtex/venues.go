@@ -0,0 +1,126 @@
+package bibtex
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Venue is the canonical form of a conference series. Match lists the
+// names and acronyms its proceedings come under, compared as whole words
+// after VenueWords has reduced both sides.
+type Venue struct {
+	Name   string
+	Series string
+	Match  []string
+}
+
+// DefaultVenues are the conference series recognized without configuration
+var DefaultVenues = []Venue{
+	{"Proceedings of the IEEE/CVF Conference on Computer Vision and Pattern Recognition", "CVPR", []string{"cvpr", "conference on computer vision and pattern recognition"}},
+	{"Proceedings of the IEEE/CVF International Conference on Computer Vision", "ICCV", []string{"iccv", "international conference on computer vision"}},
+	{"Proceedings of the European Conference on Computer Vision", "ECCV", []string{"eccv", "european conference on computer vision"}},
+	{"Advances in Neural Information Processing Systems", "NeurIPS", []string{"neurips", "nips", "neural information processing systems"}},
+	{"Proceedings of the International Conference on Machine Learning", "ICML", []string{"icml", "international conference on machine learning"}},
+	{"Proceedings of the International Conference on Learning Representations", "ICLR", []string{"iclr", "international conference on learning representations"}},
+	{"Proceedings of the AAAI Conference on Artificial Intelligence", "AAAI", []string{"aaai conference on artificial intelligence"}},
+	{"Proceedings of the International Joint Conference on Artificial Intelligence", "IJCAI", []string{"ijcai", "international joint conference on artificial intelligence"}},
+	{"Proceedings of the Annual Meeting of the Association for Computational Linguistics", "ACL", []string{"annual meeting of the association for computational linguistics"}},
+	{"Proceedings of the Conference on Empirical Methods in Natural Language Processing", "EMNLP", []string{"emnlp", "empirical methods in natural language processing"}},
+	{"Proceedings of the International ACM SIGIR Conference on Research and Development in Information Retrieval", "SIGIR", []string{"sigir conference on research and development in information retrieval"}},
+	{"Proceedings of the ACM SIGKDD International Conference on Knowledge Discovery and Data Mining", "KDD", []string{"sigkdd", "conference on knowledge discovery and data mining"}},
+	{"Proceedings of the ACM Web Conference", "WWW", []string{"the web conference", "international world wide web conference"}},
+	{"Proceedings of the CHI Conference on Human Factors in Computing Systems", "CHI", []string{"conference on human factors in computing systems"}},
+	{"Proceedings of the IEEE/ACM International Conference on Software Engineering", "ICSE", []string{"icse", "international conference on software engineering"}},
+	{"Proceedings of the ACM SIGPLAN Conference on Programming Language Design and Implementation", "PLDI", []string{"pldi", "conference on programming language design and implementation"}},
+	{"Proceedings of the ACM SIGPLAN Symposium on Principles of Programming Languages", "POPL", []string{"popl", "symposium on principles of programming languages"}},
+	{"Proceedings of the USENIX Symposium on Operating Systems Design and Implementation", "OSDI", []string{"osdi", "symposium on operating systems design and implementation"}},
+	{"Proceedings of the ACM Symposium on Operating Systems Principles", "SOSP", []string{"sosp", "symposium on operating systems principles"}},
+	{"Proceedings of the ACM SIGMOD International Conference on Management of Data", "SIGMOD", []string{"sigmod", "conference on management of data"}},
+	{"Proceedings of the VLDB Endowment", "VLDB", []string{"vldb", "very large data bases"}},
+	{"Proceedings of the ACM SIGCOMM Conference", "SIGCOMM", []string{"sigcomm"}},
+	{"Proceedings of the ACM SIGSAC Conference on Computer and Communications Security", "CCS", []string{"conference on computer and communications security"}},
+	{"Proceedings of the IEEE Symposium on Security and Privacy", `S\&P`, []string{"symposium on security and privacy"}},
+	{"Proceedings of the IEEE International Conference on Robotics and Automation", "ICRA", []string{"icra", "international conference on robotics and automation"}},
+	{"Proceedings of the IEEE/RSJ International Conference on Intelligent Robots and Systems", "IROS", []string{"iros", "international conference on intelligent robots and systems"}},
+	{"Proceedings of the IEEE International Conference on Acoustics, Speech and Signal Processing", "ICASSP", []string{"icassp", "international conference on acoustics speech and signal processing"}},
+	{"Proceedings of Interspeech", "Interspeech", []string{"interspeech"}},
+	{"Proceedings of the ACM Symposium on Theory of Computing", "STOC", []string{"stoc", "symposium on theory of computing"}},
+	{"Proceedings of the IEEE Symposium on Foundations of Computer Science", "FOCS", []string{"focs", "symposium on foundations of computer science"}},
+	{"Proceedings of the ACM-SIAM Symposium on Discrete Algorithms", "SODA", []string{"soda", "symposium on discrete algorithms"}},
+}
+
+// venueAbbreviations expands the abbreviations of proceedings titles
+var venueAbbreviations = strings.NewReplacer(
+	"proc.", "proceedings ", "intl.", "international ", "int.", "international ",
+	"conf.", "conference ", "symp.", "symposium ", "annu.", "annual ",
+	"assoc.", "association ", "&", " and ",
+)
+
+// venueNoise matches what varies between the years of a series, numbers,
+// ordinals and years, and the filler words abbreviated names leave out
+var venueNoise = regexp.MustCompile(`\b(\d+(st|nd|rd|th)?|first|second|third|fourth|fifth|sixth|seventh|eighth|ninth|tenth|of|the|on|in|for)\b`)
+
+// VenueWords reduces a venue name to lowercase words without punctuation,
+// abbreviations, years, ordinals and filler words, so that the variants of one series
+// compare equal
+func VenueWords(s string) string {
+	s = venueAbbreviations.Replace(strings.ToLower(s))
+	s = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127 {
+			return r
+		}
+		return ' '
+	}, s)
+	return strings.Join(strings.Fields(venueNoise.ReplaceAllString(s, " ")), " ")
+}
+
+// MatchVenue returns the first venue one of whose Match names occurs in
+// name. The workshops held with a conference only match names that say
+// workshop themselves.
+func MatchVenue(name string, venues []Venue) (Venue, bool) {
+	words := " " + VenueWords(name) + " "
+	workshop := strings.Contains(words, " workshop")
+	for _, v := range venues {
+		for _, m := range v.Match {
+			m = VenueWords(m)
+			if workshop && !strings.Contains(m, "workshop") {
+				continue
+			}
+			if m != "" && strings.Contains(words, " "+m+" ") {
+				return v, true
+			}
+		}
+	}
+	return Venue{}, false
+}
+
+// NormalizeVenue replaces the booktitle of e by the canonical name of its
+// venue and fills in the series. It reports whether e changed.
+func NormalizeVenue(e *Entry, venues []Venue) bool {
+	v, ok := MatchVenue(e.Get("booktitle"), venues)
+	if !ok {
+		return false
+	}
+	name, series := escapeVenue(v.Name), escapeVenue(v.Series)
+	changed := e.Get("booktitle") != name || e.Get("series") == "" && series != ""
+	e.Set("booktitle", name)
+	if e.Get("series") == "" {
+		e.Set("series", series)
+	}
+	return changed
+}
+
+// escapeVenue escapes the characters of a venue LaTeX would read as markup,
+// like the & of S&P in a configured venue, leaving those already escaped
+func escapeVenue(s string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range s {
+		if strings.ContainsRune("&%#", r) && prev != '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package bibtex
+
+import "testing"
+
+func TestNormalizeVenueEscapes(t *testing.T) {
+	configured := append([]Venue{
+		{"Proceedings of Theory & Practice", "T&P", []string{"theory and practice"}},
+		{"Proceedings of Already \\& Escaped", `A\&E`, []string{"already and escaped"}},
+	}, DefaultVenues...)
+	tests := []struct {
+		booktitle, name, series string
+	}{
+		{"2021 IEEE Symposium on Security and Privacy (SP)", "Proceedings of the IEEE Symposium on Security and Privacy", `S\&P`},
+		{"Proc. 3rd Conf. on Theory & Practice", `Proceedings of Theory \& Practice`, `T\&P`},
+		{"Already & Escaped 2020", `Proceedings of Already \& Escaped`, `A\&E`},
+	}
+	for _, tt := range tests {
+		e := Entry{Type: "inproceedings", Key: "k", Fields: []Field{{"booktitle", tt.booktitle}}}
+		if !NormalizeVenue(&e, configured) {
+			t.Errorf("%s: not normalized", tt.booktitle)
+			continue
+		}
+		if e.Get("booktitle") != tt.name || e.Get("series") != tt.series {
+			t.Errorf("%s: got %q, %q, want %q, %q", tt.booktitle, e.Get("booktitle"), e.Get("series"), tt.name, tt.series)
+		}
+		// normalizing again changes nothing
+		if NormalizeVenue(&e, configured) {
+			t.Errorf("%s: normalized twice", tt.booktitle)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package glossary reads and writes the term and acronym definitions of
+// the LaTeX glossaries package.
+package glossary
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// glossary entry kinds
+const (
+	KindEntry   = "entry"
+	KindAcronym = "acronym"
+)
+
+// Entry is a \newglossaryentry or \newacronym definition of the
+// LaTeX glossaries package
+type Entry struct {
+	Kind string
+	Key  string
+	// Name is the term, or the short form of an acronym
+	Name string
+	// Description is the explanation, or the long form of an acronym
+	Description string
+}
+
+// LaTeX renders the definition as it is written to the glossary file
+func (g Entry) LaTeX() string {
+	if g.Kind == KindAcronym {
+		return fmt.Sprintf("\\newacronym{%s}{%s}{%s}\n", g.Key, g.Name, g.Description)
+	}
+	return fmt.Sprintf("\\newglossaryentry{%s}{\n  name={%s},\n  description={%s}\n}\n", g.Key, g.Name, g.Description)
+}
+
+// Parse extracts all glossary definitions from a LaTeX source
+func Parse(src string) ([]Entry, error) {
+	var out []Entry
+	for pos := 0; ; {
+		i := strings.Index(src[pos:], `\new`)
+		if i < 0 {
+			return out, nil
+		}
+		pos += i
+		rest := src[pos:]
+		switch {
+		case strings.HasPrefix(rest, `\newglossaryentry`):
+			args, n, err := braceArgs(rest[len(`\newglossaryentry`):], 2)
+			if err != nil {
+				return out, fmt.Errorf("\\newglossaryentry: %w", err)
+			}
+			opts := keyValues(args[1])
+			out = append(out, Entry{Kind: KindEntry, Key: args[0], Name: opts["name"], Description: opts["description"]})
+			pos += len(`\newglossaryentry`) + n
+		case strings.HasPrefix(rest, `\newacronym`):
+			args, n, err := braceArgs(rest[len(`\newacronym`):], 3)
+			if err != nil {
+				return out, fmt.Errorf("\\newacronym: %w", err)
+			}
+			out = append(out, Entry{Kind: KindAcronym, Key: args[0], Name: args[1], Description: args[2]})
+			pos += len(`\newacronym`) + n
+		default:
+			pos += len(`\new`)
+		}
+	}
+}
+
+// braceArgs reads count brace-delimited arguments, skipping an optional
+// [..] argument in front. It returns the arguments and the bytes consumed.
+func braceArgs(s string, count int) ([]string, int, error) {
+	pos := 0
+	skip := func() {
+		for pos < len(s) && strings.ContainsRune(" \t\r\n", rune(s[pos])) {
+			pos++
+		}
+	}
+	skip()
+	if pos < len(s) && s[pos] == '[' {
+		end := strings.IndexByte(s[pos:], ']')
+		if end < 0 {
+			return nil, 0, errors.New("unterminated optional argument")
+		}
+		pos += end + 1
+	}
+	var args []string
+	for len(args) < count {
+		skip()
+		if pos >= len(s) || s[pos] != '{' {
+			return nil, 0, fmt.Errorf("expected %d arguments", count)
+		}
+		depth, start := 0, pos+1
+		for ; pos < len(s); pos++ {
+			if s[pos] == '{' {
+				depth++
+			} else if s[pos] == '}' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if pos >= len(s) {
+			return nil, 0, errors.New("unbalanced braces")
+		}
+		args = append(args, strings.TrimSpace(s[start:pos]))
+		pos++
+	}
+	return args, pos, nil
+}
+
+// keyValues parses a name={value}, other=value list, splitting only on
+// commas outside of braces
+func keyValues(s string) map[string]string {
+	out := map[string]string{}
+	depth, start := 0, 0
+	flush := func(part string) {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return
+		}
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "{") && strings.HasSuffix(v, "}") {
+			v = v[1 : len(v)-1]
+		}
+		out[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				flush(s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	flush(s[start:])
+	return out
+}
@@ -0,0 +1,128 @@
+package glossary
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultKeyTemplate produces keys like portable-document-format
+const DefaultKeyTemplate = "{long}"
+
+// maxKeyWords is how many words of the long form {long} keeps
+const maxKeyWords = 4
+
+var placeholderRe = regexp.MustCompile(`\{(\w+)\}`)
+
+// latexMarkup matches math and commands, left out of keys
+var latexMarkup = regexp.MustCompile(`\$[^$]*\$|\\[A-Za-z]+`)
+
+// latinLetters spells the Latin letters that do not decompose into a base
+// letter and an accent
+var latinLetters = strings.NewReplacer("ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE", "ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "đ", "d", "Đ", "D", "þ", "th", "Þ", "TH")
+
+// smallWords are dropped from keys, unless nothing else is left
+var smallWords = []string{"a", "an", "and", "as", "at", "by", "for", "in", "of", "on", "or", "the", "to", "with"}
+
+// placeholders are the placeholders of key templates
+var placeholders = []string{"long", "short", "initials"}
+
+// LongForm returns what a key is made from: the term of an entry, the long
+// form of an acronym
+func (g Entry) LongForm() string {
+	if g.Kind == KindAcronym {
+		return g.Description
+	}
+	return g.Name
+}
+
+// FormatKey expands a key template for a definition, the way
+// bibtex.FormatKey does for citation keys. Supported placeholders are
+// {long} (the first words of the long form joined by hyphens), {short}
+// (the short form of an acronym, the initials of a term) and {initials}
+// (the first letters of the words of the long form). Small words like
+// "of" are left out, so the same long form always gives the same key.
+func FormatKey(tmpl string, g Entry) string {
+	words := keyWords(g.LongForm())
+	key := placeholderRe.ReplaceAllStringFunc(tmpl, func(p string) string {
+		switch p[1 : len(p)-1] {
+		case "long":
+			return strings.Join(words[:min(len(words), maxKeyWords)], "-")
+		case "short":
+			if g.Kind == KindAcronym {
+				return strings.Join(keyWords(g.Name), "")
+			}
+			return initials(words)
+		case "initials":
+			return initials(words)
+		}
+		return p
+	})
+	if strings.Trim(key, "-:") == "" {
+		return "term"
+	}
+	return key
+}
+
+// UniqueKey appends -2, -3… to key until it does not collide with taken
+func UniqueKey(key string, taken map[string]bool) string {
+	if !taken[key] {
+		return key
+	}
+	for i := 2; ; i++ {
+		if k := fmt.Sprintf("%s-%d", key, i); !taken[k] {
+			return k
+		}
+	}
+}
+
+// ValidateKeyTemplate reports placeholders FormatKey does not know
+func ValidateKeyTemplate(tmpl string) error {
+	for _, m := range placeholderRe.FindAllStringSubmatch(tmpl, -1) {
+		if !slices.Contains(placeholders, m[1]) {
+			return fmt.Errorf("unknown placeholder {%s}, use %s", m[1], "{"+strings.Join(placeholders, "}, {")+"}")
+		}
+	}
+	return nil
+}
+
+// keyWords splits a long form into lowercase ASCII words, romanized and
+// without accents, LaTeX markup and small words
+func keyWords(s string) []string {
+	s = norm.NFD.String(latinLetters.Replace(bibtex.Romanize(s)))
+	var all, words []string
+	for _, w := range strings.FieldsFunc(latexMarkup.ReplaceAllString(s, " "), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '/'
+	}) {
+		var b strings.Builder
+		for _, r := range strings.ToLower(w) {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				b.WriteRune(r)
+			}
+		}
+		if b.Len() == 0 {
+			continue
+		}
+		all = append(all, b.String())
+		if !slices.Contains(smallWords, b.String()) {
+			words = append(words, b.String())
+		}
+	}
+	if len(words) == 0 {
+		return all
+	}
+	return words
+}
+
+func initials(words []string) string {
+	var b strings.Builder
+	for _, w := range words {
+		b.WriteByte(w[0])
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package library
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// NormalizeTitle reduces a title to lowercase letters and digits so that
+// case, braces and punctuation do not hide duplicates
+func NormalizeTitle(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// TitleDistance is the Levenshtein distance between the normalized forms
+// of two titles, in letters
+func TitleDistance(a, b string) int {
+	return levenshtein([]rune(NormalizeTitle(a)), []rune(NormalizeTitle(b)))
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range a {
+		cur[0] = i + 1
+		for j := range b {
+			cost := 1
+			if a[i] == b[j] {
+				cost = 0
+			}
+			cur[j+1] = min(prev[j+1]+1, cur[j]+1, prev[j]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// SimilarTitles returns the positions of entries whose title is the same as
+// title up to case and punctuation, or a few letters apart, the closest
+// first. A preprint and its published version are often that close. Short
+// titles only match exactly, lest every "Introduction" is a duplicate.
+func SimilarTitles(entries []bibtex.Entry, title string) []int {
+	t := []rune(NormalizeTitle(title))
+	if len(t) == 0 {
+		return nil
+	}
+	limit := 0
+	if len(t) >= 12 {
+		limit = max(2, len(t)/10)
+	}
+	type match struct{ i, d int }
+	var matches []match
+	for i := range entries {
+		other := []rune(NormalizeTitle(entries[i].Get("title")))
+		if len(other) == 0 || abs(len(other)-len(t)) > limit {
+			continue
+		}
+		if d := levenshtein(t, other); d <= limit {
+			matches = append(matches, match{i, d})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return a.d - b.d })
+	out := make([]int, len(matches))
+	for k, m := range matches {
+		out[k] = m.i
+	}
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// FindDuplicates groups the positions of entries that describe the same
+// work, matched by DOI or by normalized title and year. Each group is in
+// file order and has at least two members.
+func FindDuplicates(entries []bibtex.Entry) [][]int {
+	parent := make([]int, len(entries))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(index map[string]int, k string, i int) {
+		if k == "" {
+			return
+		}
+		if j, ok := index[k]; ok {
+			parent[find(i)] = find(j)
+			return
+		}
+		index[k] = i
+	}
+
+	byDOI, byTitle := map[string]int{}, map[string]int{}
+	for i := range entries {
+		e := &entries[i]
+		union(byDOI, strings.ToLower(resolve.CleanDOI(e.Get("doi"))), i)
+		if t := NormalizeTitle(e.Get("title")); t != "" {
+			union(byTitle, t+"|"+e.Get("year"), i)
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range entries {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], i)
+	}
+	var out [][]int
+	for _, r := range roots {
+		if len(groups[r]) > 1 {
+			out = append(out, groups[r])
+		}
+	}
+	return out
+}
+
+// MergeInto copies the fields of dup that keep does not have yet
+func MergeInto(keep, dup *bibtex.Entry) {
+	for _, f := range dup.Fields {
+		if keep.Get(f.Name) == "" {
+			keep.Set(f.Name, f.Value)
+		}
+	}
+}
+
+// DedupeChanges merges every duplicate group into its first entry, which
+// keeps the keys of the others as aliases, and returns the resulting edits
+// for Splice
+func DedupeChanges(entries []bibtex.Entry, groups [][]int) map[int]*bibtex.Entry {
+	changes := map[int]*bibtex.Entry{}
+	for _, g := range groups {
+		keep := entries[g[0]]
+		keep.Fields = append([]bibtex.Field(nil), keep.Fields...)
+		for _, i := range g[1:] {
+			MergeInto(&keep, &entries[i])
+			// citations of a duplicate keep working through the merged entry
+			for _, k := range append(entries[i].Aliases(), entries[i].Key) {
+				keep.AddAlias(k)
+			}
+			changes[i] = nil
+		}
+		changes[g[0]] = &keep
+	}
+	return changes
+}
@@ -0,0 +1,158 @@
+package library
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// IndexVersion is bumped whenever Index changes shape, older files
+// are then rebuilt
+const IndexVersion = 1
+
+// Index is a parsed library that is stored between runs, so searches,
+// completions and statistics of a large library do not parse the .bib file
+// again until it changes. Its entries carry no file positions
+// and must not be used to rewrite the library.
+type Index struct {
+	Version int
+	// Size and ModTime identify the file the index was built from
+	Size    int64
+	ModTime time.Time
+	Entries []bibtex.Entry
+	// Words maps the lowercase words of key, title, authors, year and
+	// keywords to the positions of the entries containing them
+	Words map[string][]int
+	// Duplicates are the groups of FindDuplicates
+	Duplicates [][]int
+}
+
+// ReadIndex reads an index written by WriteIndex, false when it is missing
+// or from another version
+func ReadIndex(path string) (*Index, bool) {
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var x Index
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&x); err != nil || x.Version != IndexVersion {
+		return nil, false
+	}
+	return &x, true
+}
+
+// WriteIndex replaces the index file through a rename, so a concurrent
+// reader never sees half of it
+func WriteIndex(path string, x *Index) error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(x); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func BuildIndex(entries []bibtex.Entry, size int64, modTime time.Time) *Index {
+	x := &Index{Version: IndexVersion, Size: size, ModTime: modTime, Entries: entries, Words: map[string][]int{}}
+	for i := range entries {
+		for _, w := range strings.Fields(searchText(&entries[i])) {
+			if p := x.Words[w]; len(p) == 0 || p[len(p)-1] != i {
+				x.Words[w] = append(p, i)
+			}
+		}
+	}
+	x.Duplicates = FindDuplicates(entries)
+	return x
+}
+
+// searchText is what a library search looks at
+func searchText(e *bibtex.Entry) string {
+	return strings.ToLower(strings.Join([]string{e.Key, e.Get("title"), e.Get("author"), e.Get("year"), e.Get("keywords")}, " "))
+}
+
+// Search returns the entries containing every word of query in their key,
+// title, authors, year or keywords, ignoring case. Query words match
+// inside indexed words, "merc" finds "mercury".
+func (x *Index) Search(query string) []bibtex.Entry {
+	var hits map[int]bool
+	for _, q := range strings.Fields(strings.ToLower(query)) {
+		found := map[int]bool{}
+		for w, positions := range x.Words {
+			if !strings.Contains(w, q) {
+				continue
+			}
+			for _, i := range positions {
+				if hits == nil || hits[i] {
+					found[i] = true
+				}
+			}
+		}
+		hits = found
+		if len(hits) == 0 {
+			return nil
+		}
+	}
+	if hits == nil {
+		return x.Entries
+	}
+	out := make([]bibtex.Entry, 0, len(hits))
+	for _, i := range slices.Sorted(maps.Keys(hits)) {
+		out = append(out, x.Entries[i])
+	}
+	return out
+}
+
+// Stats summarizes a library
+type Stats struct {
+	Entries   int
+	Types     map[string]int
+	Years     map[string]int
+	DOIs      int
+	Files     int
+	Duplicate int
+}
+
+func (x *Index) Stats() Stats {
+	s := Stats{Entries: len(x.Entries), Types: map[string]int{}, Years: map[string]int{}}
+	for i := range x.Entries {
+		e := &x.Entries[i]
+		s.Types[strings.ToLower(e.Type)]++
+		if y := e.Get("year"); y != "" {
+			s.Years[y]++
+		}
+		if e.Get("doi") != "" {
+			s.DOIs++
+		}
+		if e.Get("file") != "" {
+			s.Files++
+		}
+	}
+	for _, g := range x.Duplicates {
+		s.Duplicate += len(g) - 1
+	}
+	return s
+}
+
+// ByCount returns the keys of counts, most frequent first
+func ByCount(counts map[string]int) []string {
+	keys := slices.Sorted(maps.Keys(counts))
+	slices.SortStableFunc(keys, func(a, b string) int { return cmp.Compare(counts[b], counts[a]) })
+	return keys
+}
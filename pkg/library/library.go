@@ -0,0 +1,56 @@
+// Package library edits BibTeX library files in place and indexes them for
+// searching and deduplication.
+package library
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// Keys returns the set of citation keys in use
+func Keys(entries []bibtex.Entry) map[string]bool {
+	keys := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		keys[e.Key] = true
+	}
+	return keys
+}
+
+// Aliases maps the former keys of entries, see bibtex.Entry.Aliases, to
+// their current keys
+func Aliases(entries []bibtex.Entry) map[string]string {
+	aliases := map[string]string{}
+	for _, e := range entries {
+		for _, k := range e.Aliases() {
+			aliases[k] = e.Key
+		}
+	}
+	return aliases
+}
+
+// Splice replaces entries of a library file by their position in it,
+// leaving everything between them untouched. entries must be parsed from
+// data. A nil entry removes it.
+func Splice(data []byte, entries []bibtex.Entry, changes map[int]*bibtex.Entry) []byte {
+	var b bytes.Buffer
+	last := 0
+	for i, old := range entries {
+		e, ok := changes[i]
+		if !ok {
+			continue
+		}
+		start, end := old.Span()
+		if e != nil {
+			b.Write(data[last:start])
+			b.WriteString(strings.TrimSuffix(e.BibTeX(), "\n"))
+		} else {
+			// take the blank line an append put in front with it
+			b.Write(bytes.TrimRight(data[last:start], "\n"))
+		}
+		last = end
+	}
+	b.Write(data[last:])
+	return b.Bytes()
+}
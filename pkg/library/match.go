@@ -0,0 +1,112 @@
+package library
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// weights of the parts of MatchScore
+const (
+	titleWeight  = 0.7
+	authorWeight = 0.2
+	yearWeight   = 0.1
+)
+
+// MatchScore is the confidence from 0 to 1 that a search hit is the work an
+// entry describes. It weighs the overlap of their title words most, then
+// how many of the entry's first authors the hit names and how close the
+// years are. Parts missing from either side are left out.
+func MatchScore(e *bibtex.Entry, p resolve.Paper) float64 {
+	score, weight := 0.0, 0.0
+	add := func(w, s float64) {
+		score += w * s
+		weight += w
+	}
+	add(titleWeight, dice(words(e.Get("title")), words(p.Title)))
+	if families := firstFamilies(e, 3); len(families) > 0 && len(p.Authors) > 0 {
+		names := words(strings.Join(p.Authors, " "))
+		found := 0
+		for _, f := range families {
+			if names[f] {
+				found++
+			}
+		}
+		add(authorWeight, float64(found)/float64(len(families)))
+	}
+	if y, err := strconv.Atoi(e.Get("year")); err == nil && p.Year > 0 {
+		switch d := y - p.Year; {
+		case d == 0:
+			add(yearWeight, 1)
+		case d == 1 || d == -1:
+			// a preprint a year before the article
+			add(yearWeight, 0.5)
+		default:
+			add(yearWeight, 0)
+		}
+	}
+	return score / weight
+}
+
+// firstFamilies returns the lowercase family names of the first n authors
+func firstFamilies(e *bibtex.Entry, n int) []string {
+	var out []string
+	for _, a := range bibtex.SplitAuthors(e.Get("author")) {
+		// particles like van der only precede it
+		if ws := wordList(bibtex.FamilyName(a)); len(ws) > 0 {
+			out = append(out, ws[len(ws)-1])
+		}
+		if len(out) == n {
+			break
+		}
+	}
+	return out
+}
+
+// latexCommand matches the commands and accents of a BibTeX value
+var latexCommand = regexp.MustCompile(`\\[A-Za-z]+|\\.`)
+
+// foldAccents drops the accents of letters, which BibTeX values write as
+// commands like {\"u} that latexCommand removes
+var foldAccents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "å", "a", "ã", "a", "ç", "c",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ñ", "n", "ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ý", "y", "š", "s", "ž", "z", "č", "c",
+)
+
+// wordList returns the lowercase words of s, without LaTeX markup and
+// accents
+func wordList(s string) []string {
+	s = strings.NewReplacer("{", "", "}", "").Replace(latexCommand.ReplaceAllString(s, ""))
+	return strings.FieldsFunc(foldAccents.Replace(strings.ToLower(s)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// words returns the set of words of s
+func words(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range wordList(s) {
+		set[w] = true
+	}
+	return set
+}
+
+// dice is the Sørensen–Dice coefficient of two word sets
+func dice(a, b map[string]bool) float64 {
+	if len(a)+len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
@@ -0,0 +1,213 @@
+package library
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// Merge is the outcome of merging the local edits of a shared library into
+// the version upstream
+type Merge struct {
+	// Changes edit the upstream entries by position, for Splice
+	Changes map[int]*bibtex.Entry
+	// Added are the local entries upstream does not have, to append
+	Added []bibtex.Entry
+	// Notes say what was merged or deleted and how conflicts were resolved,
+	// one line each
+	Notes []string
+}
+
+// Merge3 merges two versions of a library, local and upstream, that both
+// started from base, entry by entry instead of line by line. Edits on one
+// side only are taken; when both sides changed an entry, fields changed on
+// one side are taken from it and fields changed on both keep the local
+// value. Entries added locally that duplicate one upstream, by DOI or by
+// title and year, are merged into it and leave their key as an alias.
+// Entries deleted on one side and changed on the other are kept.
+func Merge3(base, local, upstream []bibtex.Entry) Merge {
+	m := Merge{Changes: map[int]*bibtex.Entry{}}
+	baseBy, localBy, upstreamBy := byKey(base), byKey(local), byKey(upstream)
+
+	// upstream entries in their order, with the local edits applied
+	merged := slices.Clone(upstream)
+	for i, u := range upstream {
+		b, inBase := baseBy[u.Key]
+		l, inLocal := localBy[u.Key]
+		switch {
+		case !inLocal && !inBase:
+			// added upstream
+		case !inLocal:
+			if sameEntry(u, b) {
+				m.Changes[i] = nil
+				m.Notes = append(m.Notes, fmt.Sprintf("deleted %s", u.Key))
+			} else {
+				m.Notes = append(m.Notes, fmt.Sprintf("kept %s, deleted here but changed upstream", u.Key))
+			}
+		case !inBase:
+			// added on both sides under the same key
+			if sameEntry(l, u) {
+				continue
+			}
+			if sameWork(l, u) {
+				e := u
+				e.Fields = slices.Clone(u.Fields)
+				MergeInto(&e, &l)
+				if !sameEntry(e, u) {
+					merged[i], m.Changes[i] = e, &merged[i]
+				}
+				m.Notes = append(m.Notes, fmt.Sprintf("merged %s into the entry added upstream", u.Key))
+				continue
+			}
+			// two works under one key: the local one moves aside
+			l.Key = bibtex.UniqueKey(l.Key, keySet(upstream, local))
+			m.Notes = append(m.Notes, fmt.Sprintf("renamed %s to %s, upstream added another work as %s", u.Key, l.Key, u.Key))
+			m.Added = append(m.Added, l)
+		default:
+			e, conflicts := merge3Fields(b, l, u)
+			for _, f := range conflicts {
+				m.Notes = append(m.Notes, fmt.Sprintf("%s: %s changed here and upstream, kept yours", u.Key, f))
+			}
+			if !sameEntry(e, u) {
+				merged[i], m.Changes[i] = e, &merged[i]
+			}
+		}
+	}
+
+	// local entries upstream does not have
+	var added []bibtex.Entry
+	for _, l := range local {
+		if _, ok := upstreamBy[l.Key]; ok {
+			continue
+		}
+		if b, inBase := baseBy[l.Key]; inBase {
+			if sameEntry(l, b) {
+				m.Notes = append(m.Notes, fmt.Sprintf("deleted %s, deleted upstream", l.Key))
+				continue
+			}
+			m.Notes = append(m.Notes, fmt.Sprintf("kept %s, deleted upstream but changed here", l.Key))
+		}
+		added = append(added, l)
+	}
+	added = append(m.Added, added...)
+	m.Added = nil
+
+	// additions that duplicate an upstream entry are merged into it
+	all := append(slices.Clone(merged), added...)
+	dup := map[int]bool{}
+	for _, g := range FindDuplicates(all) {
+		if g[0] >= len(merged) {
+			// only local entries, the library had them before
+			continue
+		}
+		if e, ok := m.Changes[g[0]]; ok && e == nil {
+			continue
+		}
+		keep := merged[g[0]]
+		keep.Fields = slices.Clone(keep.Fields)
+		for _, i := range g[1:] {
+			if i < len(merged) {
+				continue
+			}
+			a := all[i]
+			MergeInto(&keep, &a)
+			for _, k := range append(a.Aliases(), a.Key) {
+				keep.AddAlias(k)
+			}
+			dup[i-len(merged)] = true
+			m.Notes = append(m.Notes, fmt.Sprintf("merged %s into %s, the same work upstream", a.Key, keep.Key))
+		}
+		if !sameEntry(keep, merged[g[0]]) {
+			merged[g[0]] = keep
+			m.Changes[g[0]] = &merged[g[0]]
+		}
+	}
+	for i, a := range added {
+		if !dup[i] {
+			m.Added = append(m.Added, a)
+		}
+	}
+	return m
+}
+
+// merge3Fields merges the edits of local and upstream to an entry of base.
+// It returns the merged entry and the fields both sides set differently.
+func merge3Fields(base, local, upstream bibtex.Entry) (bibtex.Entry, []string) {
+	e := upstream
+	e.Fields = slices.Clone(upstream.Fields)
+	if local.Type != base.Type {
+		e.Type = local.Type
+	}
+	var conflicts []string
+	names := fieldNames(upstream)
+	for _, n := range fieldNames(local) {
+		if !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	for _, n := range fieldNames(base) {
+		if !slices.Contains(names, n) {
+			names = append(names, n)
+		}
+	}
+	for _, n := range names {
+		b, l, u := base.Get(n), local.Get(n), upstream.Get(n)
+		if l == b || l == u {
+			continue
+		}
+		if u != b {
+			conflicts = append(conflicts, n)
+		}
+		e.Set(n, l)
+	}
+	if local.Type != base.Type && upstream.Type != base.Type && local.Type != upstream.Type {
+		conflicts = append(conflicts, "type")
+	}
+	return e, conflicts
+}
+
+// sameEntry reports whether two entries have the same type, key and
+// fields, in whatever order
+func sameEntry(a, b bibtex.Entry) bool {
+	return a.Type == b.Type && a.Key == b.Key && maps.Equal(fieldMap(a), fieldMap(b))
+}
+
+// sameWork reports whether two entries describe the same work, the way
+// FindDuplicates tells
+func sameWork(a, b bibtex.Entry) bool {
+	return len(FindDuplicates([]bibtex.Entry{a, b})) > 0
+}
+
+func byKey(entries []bibtex.Entry) map[string]bibtex.Entry {
+	by := make(map[string]bibtex.Entry, len(entries))
+	for _, e := range entries {
+		by[e.Key] = e
+	}
+	return by
+}
+
+func keySet(lists ...[]bibtex.Entry) map[string]bool {
+	keys := map[string]bool{}
+	for _, l := range lists {
+		maps.Copy(keys, Keys(l))
+	}
+	return keys
+}
+
+func fieldMap(e bibtex.Entry) map[string]string {
+	m := make(map[string]string, len(e.Fields))
+	for _, f := range e.Fields {
+		m[f.Name] = f.Value
+	}
+	return m
+}
+
+func fieldNames(e bibtex.Entry) []string {
+	names := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		names[i] = f.Name
+	}
+	return names
+}
@@ -0,0 +1,204 @@
+package library
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+func parse(t *testing.T, s string) []bibtex.Entry {
+	t.Helper()
+	entries, err := bibtex.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+// apply writes a merge to upstream the way sync does
+func apply(upstream string, entries []bibtex.Entry, m Merge) string {
+	out := Splice([]byte(upstream), entries, m.Changes)
+	for _, e := range m.Added {
+		out = append(out, "\n"+e.BibTeX()...)
+	}
+	return string(out)
+}
+
+func TestMerge3(t *testing.T) {
+	const (
+		a = "@article{a,\n  title = {Alpha},\n  year = {2020},\n  doi = {10.1/a}\n}\n"
+		b = "@article{b,\n  title = {Beta},\n  year = {2021}\n}\n"
+	)
+	tests := []struct {
+		name                  string
+		base, local, upstream string
+		// want is the merged library, notes say what happened
+		want  string
+		notes []string
+	}{
+		{
+			name: "unchanged",
+			base: a + b, local: a + b, upstream: a + b,
+			want: a + b,
+		},
+		{
+			name: "changed here",
+			base: a + b, local: strings.Replace(a, "Alpha", "Alpha revised", 1) + b, upstream: a + b,
+			want: strings.Replace(a, "Alpha", "Alpha revised", 1) + b,
+		},
+		{
+			name: "changed upstream",
+			base: a + b, local: a + b, upstream: a + strings.Replace(b, "2021", "2022", 1),
+			want: a + strings.Replace(b, "2021", "2022", 1),
+		},
+		{
+			name:     "different fields changed on both sides",
+			base:     a,
+			local:    strings.Replace(a, "2020", "2019", 1),
+			upstream: strings.Replace(a, "Alpha", "Alpha revised", 1),
+			want:     "@article{a, title = {Alpha revised}, year = {2019}, doi = {10.1/a}}",
+		},
+		{
+			name:     "same field changed on both sides keeps yours",
+			base:     a,
+			local:    strings.Replace(a, "Alpha", "Alpha mine", 1),
+			upstream: strings.Replace(a, "Alpha", "Alpha theirs", 1),
+			want:     strings.Replace(a, "Alpha", "Alpha mine", 1),
+			notes:    []string{"a: title changed here and upstream, kept yours"},
+		},
+		{
+			name:     "type changed here",
+			base:     a,
+			local:    strings.Replace(a, "@article", "@inproceedings", 1),
+			upstream: a,
+			want:     strings.Replace(a, "@article", "@inproceedings", 1),
+		},
+		{
+			name:     "type changed on both sides",
+			base:     a,
+			local:    strings.Replace(a, "@article", "@inproceedings", 1),
+			upstream: strings.Replace(a, "@article", "@misc", 1),
+			want:     strings.Replace(a, "@article", "@inproceedings", 1),
+			notes:    []string{"a: type changed here and upstream, kept yours"},
+		},
+		{
+			name: "deleted here",
+			base: a + b, local: b, upstream: a + b,
+			want:  b,
+			notes: []string{"deleted a"},
+		},
+		{
+			name: "deleted here, changed upstream",
+			base: a + b, local: b, upstream: strings.Replace(a, "Alpha", "Alpha revised", 1) + b,
+			want:  strings.Replace(a, "Alpha", "Alpha revised", 1) + b,
+			notes: []string{"kept a, deleted here but changed upstream"},
+		},
+		{
+			name: "deleted upstream",
+			base: a + b, local: a + b, upstream: b,
+			want:  b,
+			notes: []string{"deleted a, deleted upstream"},
+		},
+		{
+			name: "deleted upstream, changed here",
+			base: a + b, local: strings.Replace(a, "Alpha", "Alpha revised", 1) + b, upstream: b,
+			want:  b + strings.Replace(a, "Alpha", "Alpha revised", 1),
+			notes: []string{"kept a, deleted upstream but changed here"},
+		},
+		{
+			name: "added here",
+			base: a, local: a + b, upstream: a,
+			want: a + b,
+		},
+		{
+			name: "added upstream",
+			base: a, local: a, upstream: a + b,
+			want: a + b,
+		},
+		{
+			name: "same entry added on both sides",
+			base: "", local: a, upstream: a,
+			want: a,
+		},
+		{
+			name:     "same work added on both sides under one key",
+			base:     "",
+			local:    strings.Replace(a, "doi =", "note = {mine},\n  doi =", 1),
+			upstream: a,
+			want:     "@article{a, title = {Alpha}, year = {2020}, doi = {10.1/a}, note = {mine}}",
+			notes:    []string{"merged a into the entry added upstream"},
+		},
+		{
+			name:     "different works added on both sides under one key",
+			base:     "",
+			local:    strings.Replace(b, "{b,", "{a,", 1),
+			upstream: a,
+			want:     a + strings.Replace(b, "{b,", "{aa,", 1),
+			notes:    []string{"renamed a to aa, upstream added another work as a"},
+		},
+		{
+			name:     "work added here under another key",
+			base:     b,
+			local:    b + strings.Replace(a, "{a,", "{mine,", 1),
+			upstream: b + a,
+			want:     b + "@article{a, title = {Alpha}, year = {2020}, doi = {10.1/a}, ids = {mine}}",
+			notes:    []string{"merged mine into a, the same work upstream"},
+		},
+		{
+			name:     "work added here matching by title and year",
+			base:     "",
+			local:    "@misc{mine, title = {ALPHA}, year = {2020}, url = {https://example.org}}",
+			upstream: a,
+			want:     "@article{a, title = {Alpha}, year = {2020}, doi = {10.1/a}, url = {https://example.org}, ids = {mine}}",
+			notes:    []string{"merged mine into a, the same work upstream"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := parse(t, tt.upstream)
+			m := Merge3(parse(t, tt.base), parse(t, tt.local), upstream)
+			if !slices.Equal(m.Notes, tt.notes) {
+				t.Errorf("notes %q, want %q", m.Notes, tt.notes)
+			}
+			out := apply(tt.upstream, upstream, m)
+			got, want := parse(t, out), parse(t, tt.want)
+			if !slices.EqualFunc(got, want, func(a, b bibtex.Entry) bool {
+				return a.Type == b.Type && a.Key == b.Key && maps.Equal(fieldMap(a), fieldMap(b))
+			}) {
+				t.Errorf("merged to\n%s\nwant\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplice(t *testing.T) {
+	const in = "% shared library\n@article{a,\n  title = {A}\n}\n\n@book(b, title = {B})\n" +
+		"@comment{not an entry}\n\n@misc{c, note = {Über}}\ntrailing text\n"
+	entries := parse(t, in)
+	if got := string(Splice([]byte(in), entries, nil)); got != in {
+		t.Errorf("no changes rewrote the file:\n%s", got)
+	}
+
+	edited := entries[1]
+	edited.Fields = slices.Clone(edited.Fields)
+	edited.Set("year", "2020")
+	out := string(Splice([]byte(in), entries, map[int]*bibtex.Entry{0: nil, 1: &edited}))
+	// the text around the entries stays as it was
+	for _, text := range []string{"% shared library", "@comment{not an entry}\n\n@misc{c, note = {Über}}\ntrailing text\n"} {
+		if !strings.Contains(out, text) {
+			t.Errorf("lost %q:\n%s", text, out)
+		}
+	}
+	got := parse(t, out)
+	if len(got) != 2 || got[0].Key != "b" || got[0].Get("year") != "2020" || got[1].Key != "c" {
+		t.Errorf("got %+v from\n%s", got, out)
+	}
+	// the spans of the result splice it again
+	again := string(Splice([]byte(out), got, map[int]*bibtex.Entry{1: &got[1]}))
+	if again != strings.Replace(out, "@misc{c, note = {Über}}", strings.TrimSuffix(got[1].BibTeX(), "\n"), 1) {
+		t.Errorf("second splice gave\n%s", again)
+	}
+}
@@ -0,0 +1,602 @@
+// Package pdf reads the annotations readers leave in PDF files: highlights,
+// underlines and notes with their comments. It understands the object
+// syntax of PDF 1.0 to 2.0, incremental updates and compressed object
+// streams, which is all it takes to find annotations; page content is not
+// interpreted.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Annotation is a highlight, note or other markup on a page
+type Annotation struct {
+	// Page counts from 1
+	Page int
+	// Kind is the subtype, like Highlight, Underline, Text or FreeText
+	Kind string
+	// Contents is the text of a note, or what a reader recorded with a
+	// highlight: the highlighted text or the comment on it, depending on
+	// the reader
+	Contents string
+	// Author and Modified are as the reader recorded them, Modified in the
+	// form D:20240131120000
+	Author   string
+	Modified string
+}
+
+// markupKinds are the subtypes of annotations made while reading; links,
+// widgets of forms and popups are left out
+var markupKinds = []string{"Text", "FreeText", "Highlight", "Underline", "Squiggly", "StrikeOut", "Caret", "Ink", "Square", "Circle"}
+
+// ErrEncrypted is returned for encrypted files, whose strings cannot be read
+var ErrEncrypted = errors.New("pdf: the file is encrypted")
+
+// maxStreamSize caps what a stream inflates to, a few kilobytes of zeros
+// can inflate to gigabytes
+const maxStreamSize = 64 << 20
+
+// maxDepth caps the nesting of arrays and dictionaries, every level takes
+// a call of parser.value
+const maxDepth = 100
+
+// Annotations returns the annotations of a PDF file in page order
+func Annotations(data []byte) ([]Annotation, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\r "), []byte("%PDF-")) {
+		return nil, errors.New("pdf: not a PDF file")
+	}
+	d := &document{objects: map[int]object{}}
+	if err := d.scan(data); err != nil {
+		return nil, err
+	}
+	if d.encrypted {
+		return nil, ErrEncrypted
+	}
+	root, ok := d.root.(dict)
+	if !ok {
+		return nil, errors.New("pdf: no document catalog")
+	}
+	var out []Annotation
+	page := 0
+	seen := map[ref]bool{}
+	var walk func(node any)
+	walk = func(node any) {
+		if r, ok := node.(ref); ok {
+			if seen[r] {
+				return
+			}
+			seen[r] = true
+		}
+		n, ok := d.resolve(node).(dict)
+		if !ok {
+			return
+		}
+		if kids, ok := d.resolve(n["Kids"]).([]any); ok {
+			for _, k := range kids {
+				walk(k)
+			}
+			return
+		}
+		page++
+		annots, _ := d.resolve(n["Annots"]).([]any)
+		for _, a := range annots {
+			a, ok := d.resolve(a).(dict)
+			if !ok {
+				continue
+			}
+			kind, _ := a["Subtype"].(name)
+			if !slices.Contains(markupKinds, string(kind)) {
+				continue
+			}
+			out = append(out, Annotation{
+				Page:     page,
+				Kind:     string(kind),
+				Contents: d.text(a["Contents"]),
+				Author:   d.text(a["T"]),
+				Modified: d.text(a["M"]),
+			})
+		}
+	}
+	walk(root["Pages"])
+	return out, nil
+}
+
+// the values of the object syntax: nil, bool, float64, string (byte
+// strings), name, []any, dict, ref and stream
+type (
+	name string
+	dict map[string]any
+	ref  struct{ num, gen int }
+	// stream is a dictionary with data, still encoded
+	stream struct {
+		dict dict
+		data []byte
+	}
+)
+
+// object is an indirect object and where it was found, later ones are
+// updates of earlier ones
+type object struct {
+	value any
+	pos   int
+}
+
+type document struct {
+	objects   map[int]object
+	root      any
+	encrypted bool
+}
+
+var (
+	objRe     = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	trailerRe = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// scan reads every indirect object of the file in order, without the
+// cross-reference table: a file whose offsets are off, as edited files
+// often are, reads all the same. Streams are skipped over, so binary data
+// is not mistaken for objects.
+func (d *document) scan(data []byte) error {
+	var streams []object
+	for pos := 0; pos < len(data); {
+		loc := objRe.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		start := pos + loc[0]
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		p := &parser{data: data, pos: pos + loc[1]}
+		v, err := p.value()
+		if err != nil {
+			pos = start + 1
+			continue
+		}
+		if s, ok := v.(stream); ok {
+			if t, _ := s.dict["Type"].(name); t == "ObjStm" {
+				streams = append(streams, object{v, start})
+			}
+			if t, _ := s.dict["Type"].(name); t == "XRef" {
+				d.trailer(s.dict)
+			}
+		}
+		d.set(num, object{v, start})
+		pos = p.pos
+	}
+	// trailers of the classic cross-reference tables
+	for _, m := range trailerRe.FindAllIndex(data, -1) {
+		p := &parser{data: data, pos: m[1] - 2}
+		if v, err := p.value(); err == nil {
+			if t, ok := v.(dict); ok {
+				d.trailer(t)
+			}
+		}
+	}
+	for _, s := range streams {
+		if err := d.objectStream(s); err != nil {
+			return err
+		}
+	}
+	if d.root == nil {
+		// a file without a readable trailer still has its catalog
+		for _, o := range d.objects {
+			if t, ok := o.value.(dict); ok && t["Type"] == name("Catalog") {
+				d.root = t
+			}
+		}
+	}
+	if r, ok := d.root.(ref); ok {
+		d.root = d.resolve(r)
+	}
+	return nil
+}
+
+// trailer takes the catalog and encryption of a trailer, the last one wins
+func (d *document) trailer(t dict) {
+	if r, ok := t["Root"]; ok {
+		d.root = r
+	}
+	if _, ok := t["Encrypt"]; ok {
+		d.encrypted = true
+	}
+}
+
+func (d *document) set(num int, o object) {
+	if old, ok := d.objects[num]; !ok || old.pos <= o.pos {
+		d.objects[num] = o
+	}
+}
+
+// objectStream reads the objects compressed into a stream, which count as
+// found where the stream is
+func (d *document) objectStream(o object) error {
+	s := o.value.(stream)
+	data, err := d.decode(s)
+	if err != nil {
+		return err
+	}
+	broken := fmt.Errorf("pdf: broken object stream at %d", o.pos)
+	n, ok1 := d.resolve(s.dict["N"]).(float64)
+	first, ok2 := d.resolve(s.dict["First"]).(float64)
+	if !ok1 || !ok2 || n < 0 || n > float64(len(data)) || first < 0 || first > float64(len(data)) {
+		return broken
+	}
+	// the header holds a number and an offset for each object
+	header := &parser{data: data[:int(first)]}
+	for range int(n) {
+		v1, err1 := header.value()
+		v2, err2 := header.value()
+		num, ok1 := v1.(float64)
+		off, ok2 := v2.(float64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 || num < 0 || off < 0 {
+			return broken
+		}
+		if off >= float64(len(data))-first {
+			continue
+		}
+		p := &parser{data: data, pos: int(first) + int(off)}
+		v, err := p.value()
+		if err != nil {
+			continue
+		}
+		d.set(int(num), object{v, o.pos})
+	}
+	return nil
+}
+
+// decode returns the data of a stream, inflated when it is compressed
+func (d *document) decode(s stream) ([]byte, error) {
+	filters := []any{d.resolve(s.dict["Filter"])}
+	if list, ok := filters[0].([]any); ok {
+		filters = list
+	}
+	data := s.data
+	for _, f := range filters {
+		switch d.resolve(f) {
+		case nil:
+		case name("FlateDecode"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("pdf: %w", err)
+			}
+			// a damaged end still leaves what was inflated
+			out, err := io.ReadAll(io.LimitReader(r, maxStreamSize+1))
+			if err != nil && len(out) == 0 {
+				return nil, fmt.Errorf("pdf: %w", err)
+			}
+			if len(out) > maxStreamSize {
+				return nil, fmt.Errorf("pdf: stream inflates to more than %d bytes", maxStreamSize)
+			}
+			data = out
+		default:
+			return nil, fmt.Errorf("pdf: unsupported stream filter %v", f)
+		}
+	}
+	return data, nil
+}
+
+// resolve follows references to the value they point at
+func (d *document) resolve(v any) any {
+	for range 32 {
+		r, ok := v.(ref)
+		if !ok {
+			return v
+		}
+		v = d.objects[r.num].value
+	}
+	return nil
+}
+
+// text decodes a text string: UTF-16 with its byte order mark, UTF-8 with
+// one, or else PDFDocEncoding, which matches Latin-1 for the letters
+func (d *document) text(v any) string {
+	s, ok := d.resolve(v).(string)
+	if !ok {
+		return ""
+	}
+	b := []byte(s)
+	var out string
+	switch {
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		out = string(utf16.Decode(u))
+	case bytes.HasPrefix(b, []byte{0xef, 0xbb, 0xbf}):
+		out = string(b[3:])
+	default:
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		out = string(r)
+	}
+	return strings.TrimSpace(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(out))
+}
+
+// parser reads values of the object syntax from data at pos
+type parser struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+var errSyntax = errors.New("pdf: syntax error")
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skip passes white space and comments
+func (p *parser) skip() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case isSpace(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads a regular token, like a number or keyword
+func (p *parser) token() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !isDelim(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// value reads one value, a reference n g R, or a dictionary followed by
+// its stream
+func (p *parser) value() (any, error) {
+	p.skip()
+	if p.pos >= len(p.data) || p.depth >= maxDepth {
+		return nil, errSyntax
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return name(unescapeName(p.token())), nil
+	case c == '(':
+		return p.literal()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		dict, err := p.dict()
+		if err != nil {
+			return nil, err
+		}
+		return p.stream(dict)
+	case c == '<':
+		return p.hex()
+	case c == '[':
+		p.pos++
+		var list []any
+		for {
+			p.skip()
+			if p.pos >= len(p.data) {
+				return nil, errSyntax
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	}
+	t := p.token()
+	switch t {
+	case "":
+		return nil, errSyntax
+	case "true", "false":
+		return t == "true", nil
+	case "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil {
+		return nil, errSyntax
+	}
+	// n g R is a reference
+	save := p.pos
+	p.skip()
+	if g := p.token(); g != "" {
+		if gen, err := strconv.Atoi(g); err == nil {
+			p.skip()
+			if p.token() == "R" {
+				return ref{int(n), gen}, nil
+			}
+		}
+	}
+	p.pos = save
+	return n, nil
+}
+
+func (p *parser) dict() (dict, error) {
+	d := dict{}
+	for {
+		p.skip()
+		if p.pos+1 < len(p.data) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return d, nil
+		}
+		k, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(name)
+		if !ok {
+			return nil, errSyntax
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		d[string(key)] = v
+	}
+}
+
+// stream reads the data following a dictionary, when there is any. Its
+// length is taken from /Length when that is a number, or else by looking
+// for endstream.
+func (p *parser) stream(d dict) (any, error) {
+	save := p.pos
+	p.skip()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		p.pos = save
+		return d, nil
+	}
+	p.pos += len("stream")
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+	// a /Length past the end of the file is wrong, the data ends at endstream
+	if n, ok := d["Length"].(float64); ok && n >= 0 && n <= float64(len(p.data)-start) {
+		end := start + int(n)
+		rest := bytes.TrimLeft(p.data[end:min(end+16, len(p.data))], "\r\n ")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			p.pos = end
+			p.skip()
+			p.pos += len("endstream")
+			return stream{d, p.data[start:end]}, nil
+		}
+	}
+	i := bytes.Index(p.data[start:], []byte("endstream"))
+	if i < 0 {
+		return nil, errSyntax
+	}
+	end := start + i
+	p.pos = end + len("endstream")
+	data := bytes.TrimSuffix(bytes.TrimSuffix(p.data[start:end], []byte("\n")), []byte("\r"))
+	return stream{d, data}, nil
+}
+
+// literal reads a (string), with its escapes and balanced parentheses
+func (p *parser) literal() (any, error) {
+	p.pos++
+	var b []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return string(b), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return nil, errSyntax
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// a line continuation
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						n = n*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(n)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return nil, errSyntax
+}
+
+// hex reads a <hex string>, an odd last digit followed by 0
+func (p *parser) hex() (any, error) {
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 0 {
+		return nil, errSyntax
+	}
+	var digits []byte
+	for _, c := range p.data[p.pos+1 : p.pos+end] {
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	p.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, errSyntax
+		}
+		b[i] = byte(v)
+	}
+	return string(b), nil
+}
+
+// unescapeName decodes the #xx escapes of a name
+func unescapeName(s string) string {
+	if !strings.Contains(s, "#") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
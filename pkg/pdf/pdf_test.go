@@ -0,0 +1,250 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// file builds a PDF of numbered objects, 1 0 obj first, with a classic
+// cross-reference table. The catalog is object 1.
+type file struct {
+	bytes.Buffer
+	offsets map[int]int
+}
+
+func newFile(version string, objects ...string) *file {
+	f := &file{offsets: map[int]int{}}
+	f.WriteString("%PDF-" + version + "\n%\xe2\xe3\xcf\xd3\n")
+	f.update(nil, objects...)
+	return f
+}
+
+// update appends an incremental update replacing or adding the objects
+// numbered, or numbered from 1 when num is nil
+func (f *file) update(num []int, objects ...string) *file {
+	start := f.Len()
+	for i, o := range objects {
+		n := i + 1
+		if num != nil {
+			n = num[i]
+		}
+		f.offsets[n] = f.Len()
+		fmt.Fprintf(f, "%d 0 obj\n%s\nendobj\n", n, o)
+	}
+	xref := f.Len()
+	fmt.Fprintf(f, "xref\n0 %d\n0000000000 65535 f \n", len(f.offsets)+1)
+	for n := 1; n <= len(f.offsets); n++ {
+		fmt.Fprintf(f, "%010d 00000 n \n", f.offsets[n])
+	}
+	prev := ""
+	if start > 0 {
+		prev = fmt.Sprintf(" /Prev %d", start)
+	}
+	fmt.Fprintf(f, "trailer\n<< /Size %d /Root 1 0 R%s >>\nstartxref\n%d\n%%%%EOF\n", len(f.offsets)+1, prev, xref)
+	return f
+}
+
+// utf16Text is a text string of UTF-16 with its byte order mark, as a hex
+// string the way Acrobat and Zotero write them
+func utf16Text(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	return b.String() + ">"
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data) // nolint:errcheck
+	w.Close()     // nolint:errcheck
+	return b.Bytes()
+}
+
+// flateStream is a FlateDecode stream of data with the entries of dict
+func flateStream(dict string, data []byte) string {
+	z := deflate(data)
+	return fmt.Sprintf("<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", dict, len(z), z)
+}
+
+// objectStream holds the objects numbered from num, as Acrobat compresses
+// them
+func objectStream(num int, objects ...string) string {
+	var header, body bytes.Buffer
+	for i, o := range objects {
+		fmt.Fprintf(&header, "%d %d ", num+i, body.Len())
+		body.WriteString(o + "\n")
+	}
+	return flateStream(fmt.Sprintf("/Type /ObjStm /N %d /First %d", len(objects), header.Len()), append(header.Bytes(), body.Bytes()...))
+}
+
+// zoteroFile is laid out the way Zotero saves annotations into a file: an
+// incremental update adding them to the pages, UTF-16 text strings and an
+// author in /T
+func zoteroFile() []byte {
+	f := newFile("1.4",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	)
+	f.update([]int{4, 5, 6},
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Annots [5 0 R 6 0 R] >>",
+		"<< /Type /Annot /Subtype /Highlight /Rect [72 700 300 712] /QuadPoints [72 712 300 712 72 700 300 700] "+
+			"/NM (3f1c2a9e) /T "+utf16Text("Jane Doe")+" /Contents "+utf16Text("Ünïcödé — the main claim")+
+			" /M (D:20240131120000Z) /C [1 0.82 0.4] /F 4 >>",
+		"<< /Type /Annot /Subtype /Link /Rect [72 600 300 612] /A << /S /URI /URI (https://example.org) >> >>",
+	)
+	return f.Bytes()
+}
+
+// acrobatFile is laid out the way Acrobat saves a file: the objects
+// compressed into an object stream and a cross-reference stream, popups
+// belonging to the notes
+func acrobatFile() []byte {
+	var f bytes.Buffer
+	f.WriteString("%PDF-1.6\n%\xe2\xe3\xcf\xd3\n")
+	f.WriteString("1 0 obj\n" + objectStream(2,
+		"<< /Type /Catalog /Pages 3 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 3 0 R /MediaBox [0 0 612 792] /Annots [5 0 R 6 0 R 7 0 R] >>",
+		"<< /Type /Annot /Subtype /Underline /Rect [72 700 300 712] /T "+utf16Text("Reviewer 2")+
+			" /Contents "+utf16Text("needs a citation")+" /RC (<body><p>needs a citation</p></body>) /Popup 6 0 R /M (D:20240201093000+01'00') >>",
+		"<< /Type /Annot /Subtype /Popup /Parent 5 0 R /Rect [400 600 600 700] /Open false >>",
+		"<< /Type /Annot /Subtype /FreeText /Rect [72 500 300 520] /Contents "+utf16Text("typewriter text")+" >>",
+	) + "\nendobj\n")
+	xref := f.Len()
+	f.WriteString("8 0 obj\n" + flateStream("/Type /XRef /Size 9 /W [1 2 1] /Root 2 0 R", []byte{1, 0, 15, 0}) + "\nendobj\n")
+	fmt.Fprintf(&f, "startxref\n%d\n%%%%EOF\n", xref)
+	return f.Bytes()
+}
+
+// okularFile is laid out the way Okular saves annotations through Poppler:
+// an incremental update, literal strings with escapes in PDFDocEncoding
+// and popups of the notes
+func okularFile() []byte {
+	f := newFile("1.5",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] >>",
+	)
+	f.update([]int{3, 4, 5, 6},
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Annots [4 0 R 5 0 R 6 0 R] >>",
+		"<< /Type /Annot /Subtype /Text /Rect [50 780 70 800] /Contents (see \\(Smith, 2019\\) for the caf\\351 example) /T (okular) /Popup 5 0 R /Name /Comment >>",
+		"<< /Type /Annot /Subtype /Popup /Parent 4 0 R /Rect [70 700 250 800] >>",
+		"<< /Type /Annot /Subtype /StrikeOut /Rect [50 600 200 612] /Contents (line one\\r\\nline two) >>",
+	)
+	return f.Bytes()
+}
+
+func TestAnnotations(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []Annotation
+	}{
+		{"zotero", zoteroFile(), []Annotation{
+			{Page: 2, Kind: "Highlight", Contents: "Ünïcödé — the main claim", Author: "Jane Doe", Modified: "D:20240131120000Z"},
+		}},
+		{"acrobat", acrobatFile(), []Annotation{
+			{Page: 1, Kind: "Underline", Contents: "needs a citation", Author: "Reviewer 2", Modified: "D:20240201093000+01'00'"},
+			{Page: 1, Kind: "FreeText", Contents: "typewriter text"},
+		}},
+		{"okular", okularFile(), []Annotation{
+			{Page: 1, Kind: "Text", Contents: "see (Smith, 2019) for the café example", Author: "okular"},
+			{Page: 1, Kind: "StrikeOut", Contents: "line one\nline two"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Annotations(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// malformed builds a file with an object stream holding data, the catalog
+// and page tree outside it
+func malformed(objStm string) []byte {
+	return newFile("1.5",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Annots [5 0 R] >>",
+		objStm,
+	).Bytes()
+}
+
+func TestAnnotationsMalformed(t *testing.T) {
+	note := "<< /Type /Annot /Subtype /Text /Contents (kept) >>"
+	header := func(h string) string {
+		return flateStream(fmt.Sprintf("/Type /ObjStm /N 1 /First %d", len(h)), []byte(h+note))
+	}
+	tests := []struct {
+		name string
+		data []byte
+		// wantErr is whether the file is refused, else the note is read or
+		// left out but nothing panics
+		wantErr bool
+	}{
+		{"not a pdf", []byte("<html></html>"), true},
+		{"empty", nil, true},
+		{"only a header", []byte("%PDF-1.7\n"), true},
+		{"truncated", zoteroFile()[:300], false},
+		{"encrypted", append(newFile("1.4", "<< /Type /Catalog /Pages 2 0 R >>", "<< /Filter /Standard /V 2 >>").Bytes(),
+			"trailer\n<< /Size 3 /Root 1 0 R /Encrypt 2 0 R >>\n"...), true},
+		// the stream at the start of the file, where start-100 is before it
+		{"negative length", append([]byte("%PDF-1.4\n9 0 obj\n<< /Length -100 >> stream\nBT ET\nendstream\nendobj\n"), okularFile()...), false},
+		{"length past the end", newFile("1.4",
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Length 99999999999999999999 >> stream\nBT ET\nendstream",
+		).Bytes(), false},
+		{"stream without end", []byte("%PDF-1.4\n1 0 obj\n<< /Length 10 >> stream\nabc"), true},
+		{"object number not a number", malformed(header("5 /Foo ")), true},
+		{"offset not a number", malformed(header("/Foo 0 ")), true},
+		{"negative offset", malformed(header("5 -100 ")), true},
+		{"offset past the end", malformed(header("5 100000 ")), false},
+		{"negative first", malformed(flateStream("/Type /ObjStm /N 1 /First -10", []byte("5 0 "+note))), true},
+		{"first past the end", malformed(flateStream("/Type /ObjStm /N 1 /First 100000", []byte("5 0 "+note))), true},
+		{"first not a number", malformed(flateStream("/Type /ObjStm /N 1 /First /Foo", []byte("5 0 "+note))), true},
+		{"huge count", malformed(flateStream("/Type /ObjStm /N 1e300 /First 4", []byte("5 0 "+note))), true},
+		{"negative count", malformed(flateStream("/Type /ObjStm /N -1 /First 4", []byte("5 0 "+note))), true},
+		{"broken deflate", malformed("<< /Type /ObjStm /N 1 /First 4 /Filter /FlateDecode /Length 8 >>\nstream\nxxxxxxxx\nendstream"), true},
+		{"zip bomb", malformed(flateStream("/Type /ObjStm /N 1 /First 4", append([]byte("5 0 "+note), make([]byte, maxStreamSize)...))), true},
+		{"unknown filter", malformed("<< /Type /ObjStm /N 1 /First 4 /Filter /JBIG2Decode /Length 4 >>\nstream\nxxxx\nendstream"), true},
+		{"deep nesting", newFile("1.4",
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Annots [4 0 R] /Deep "+strings.Repeat("[", 1_000_000)+" >>",
+			note,
+		).Bytes(), false},
+		{"reference loop", newFile("1.4",
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [2 0 R 3 0 R] /Count 1 >>",
+			"3 0 R",
+		).Bytes(), false},
+		{"bad hex string", malformed("<< /Type /ObjStm /Contents <FEFFzz> >>"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Annotations(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, %v; want an error: %v", got, err, tt.wantErr)
+			}
+			if errors.Is(err, ErrEncrypted) != (tt.name == "encrypted") {
+				t.Errorf("got %v", err)
+			}
+		})
+	}
+}
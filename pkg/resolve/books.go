@@ -0,0 +1,179 @@
+package resolve
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// OpenLibraryAPI is the Internet Archive's catalog of books, which
+	// groups the editions of a work
+	OpenLibraryAPI = "https://openlibrary.org/"
+	// GoogleBooksAPI is searched for books OpenLibrary does not know
+	GoogleBooksAPI = "https://www.googleapis.com/books/v1/volumes"
+)
+
+// sources of book editions
+const (
+	SourceOpenLibrary = "OpenLibrary"
+	SourceGoogleBooks = "Google Books"
+)
+
+// Edition is an edition of a book as a catalog lists it
+type Edition struct {
+	Title    string
+	Subtitle string
+	// Edition is how the catalog names it, like "3rd ed."; empty for most
+	// first editions and reprints
+	Edition   string
+	Year      int
+	ISBN      string
+	Publisher string
+	Source    string
+}
+
+var yearRe = regexp.MustCompile(`\b(1[5-9]|20)\d\d\b`)
+
+// FetchEditions returns the editions of a book, found by its ISBN or else
+// by its title and the family name of its first author, from OpenLibrary
+// or, when it does not know the book, Google Books
+func FetchEditions(isbn, title, author string) ([]Edition, error) {
+	editions, err := openLibraryEditions(isbn, title, author)
+	if err == nil && len(editions) > 0 {
+		return editions, nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return googleBooksEditions(title, author)
+}
+
+func openLibraryEditions(isbn, title, author string) ([]Edition, error) {
+	var work string
+	if isbn = strings.ReplaceAll(isbn, "-", ""); isbn != "" {
+		var ed struct {
+			Works []struct {
+				Key string `json:"key"`
+			} `json:"works"`
+		}
+		if err := GetJSON(OpenLibraryAPI+"isbn/"+url.PathEscape(isbn)+".json", &ed); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("openlibrary: %w", err)
+		}
+		if len(ed.Works) > 0 {
+			work = ed.Works[0].Key
+		}
+	}
+	if work == "" {
+		if title == "" {
+			return nil, ErrNotFound
+		}
+		q := url.Values{"title": {title}, "fields": {"key"}, "limit": {"1"}}
+		if author != "" {
+			q.Set("author", author)
+		}
+		var res struct {
+			Docs []struct {
+				Key string `json:"key"`
+			} `json:"docs"`
+		}
+		if err := GetJSON(OpenLibraryAPI+"search.json?"+q.Encode(), &res); err != nil {
+			return nil, fmt.Errorf("openlibrary: %w", err)
+		}
+		if len(res.Docs) == 0 {
+			return nil, ErrNotFound
+		}
+		work = res.Docs[0].Key
+	}
+	var res struct {
+		Entries []struct {
+			Title       string   `json:"title"`
+			Subtitle    string   `json:"subtitle"`
+			EditionName string   `json:"edition_name"`
+			PublishDate string   `json:"publish_date"`
+			ISBN13      []string `json:"isbn_13"`
+			ISBN10      []string `json:"isbn_10"`
+			Publishers  []string `json:"publishers"`
+		} `json:"entries"`
+	}
+	if err := GetJSON(OpenLibraryAPI+strings.TrimPrefix(work, "/")+"/editions.json?limit=100", &res); err != nil {
+		return nil, fmt.Errorf("openlibrary: %w", err)
+	}
+	var out []Edition
+	for _, e := range res.Entries {
+		out = append(out, Edition{
+			Title:     e.Title,
+			Subtitle:  e.Subtitle,
+			Edition:   e.EditionName,
+			Year:      parseYear(e.PublishDate),
+			ISBN:      first(append(e.ISBN13, e.ISBN10...)),
+			Publisher: first(e.Publishers),
+			Source:    SourceOpenLibrary,
+		})
+	}
+	return out, nil
+}
+
+func googleBooksEditions(title, author string) ([]Edition, error) {
+	if title == "" {
+		return nil, ErrNotFound
+	}
+	q := `intitle:"` + title + `"`
+	if author != "" {
+		q += ` inauthor:"` + author + `"`
+	}
+	var res struct {
+		Items []struct {
+			VolumeInfo struct {
+				Title         string `json:"title"`
+				Subtitle      string `json:"subtitle"`
+				Publisher     string `json:"publisher"`
+				PublishedDate string `json:"publishedDate"`
+				Identifiers   []struct {
+					Type       string `json:"type"`
+					Identifier string `json:"identifier"`
+				} `json:"industryIdentifiers"`
+			} `json:"volumeInfo"`
+		} `json:"items"`
+	}
+	p := url.Values{"q": {q}, "printType": {"books"}, "maxResults": {"40"}}
+	if err := GetJSON(GoogleBooksAPI+"?"+p.Encode(), &res); err != nil {
+		return nil, fmt.Errorf("google books: %w", err)
+	}
+	var out []Edition
+	for _, it := range res.Items {
+		v := it.VolumeInfo
+		var isbn10, isbn13 string
+		for _, id := range v.Identifiers {
+			switch id.Type {
+			case "ISBN_13":
+				isbn13 = id.Identifier
+			case "ISBN_10":
+				isbn10 = id.Identifier
+			}
+		}
+		out = append(out, Edition{
+			Title:     v.Title,
+			Subtitle:  v.Subtitle,
+			Year:      parseYear(v.PublishedDate),
+			ISBN:      cmp.Or(isbn13, isbn10),
+			Publisher: v.Publisher,
+			Source:    SourceGoogleBooks,
+		})
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+// parseYear finds the year in a free-form date like "March 2009", 0 when
+// there is none
+func parseYear(s string) int {
+	y, _ := strconv.Atoi(yearRe.FindString(s))
+	return y
+}
@@ -0,0 +1,68 @@
+package resolve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheTTL is how long API responses are reused
+const CacheTTL = 7 * 24 * time.Hour
+
+// CacheDir holds cached API responses, an empty string disables the cache
+var CacheDir = defaultCacheDir()
+
+// Offline restricts GetJSON to cached responses
+var Offline bool
+
+// ErrOffline is returned for cache misses in offline mode
+var ErrOffline = errors.New("offline and not cached")
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bibgloss", "http")
+}
+
+func cachePath(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return filepath.Join(CacheDir, hex.EncodeToString(sum[:]))
+}
+
+// cacheGet returns a stored response for u that has not expired yet
+func cacheGet(u string) ([]byte, bool) {
+	if CacheDir == "" {
+		return nil, false
+	}
+	p := cachePath(u)
+	info, err := os.Stat(p)
+	if err != nil || time.Since(info.ModTime()) > CacheTTL {
+		return nil, false
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	slog.Debug("cache hit", "url", u)
+	return data, true
+}
+
+// cachePut stores a response. Failing to cache is not an error.
+func cachePut(u string, data []byte) {
+	if CacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(CacheDir, 0o755); err != nil {
+		slog.Warn("cache unavailable", "dir", CacheDir, "err", err)
+		return
+	}
+	if err := os.WriteFile(cachePath(u), data, 0o644); err != nil {
+		slog.Warn("cache write failed", "url", u, "err", err)
+	}
+}
@@ -0,0 +1,230 @@
+package resolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNotRecorded is returned when replaying a request the cassette has no
+// response for
+var ErrNotRecorded = errors.New("not recorded")
+
+// Cassette is a file of API responses. While one is in use, requests of
+// Client are either sent and their responses recorded, or answered from
+// the recording without touching the network, so checks of the resolvers
+// run the same offline as they did live.
+type Cassette struct {
+	path   string
+	record bool
+	prev   http.RoundTripper
+	// cache and offline are restored on Close, the cache is bypassed so
+	// every request is recorded and replays leave it alone
+	cache   string
+	offline bool
+
+	mu           sync.Mutex
+	Interactions []Interaction `json:"interactions"`
+	// played counts the replays of each request, repeated requests get
+	// their responses in the order they were recorded
+	played map[string]int
+}
+
+// Interaction is a request and the response to it. Credentials in the URL
+// are replaced by secretValue, cassettes can be shared.
+type Interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// Binary is a body that is not UTF-8, like a PDF
+	Binary []byte `json:"binary,omitempty"`
+}
+
+// secretParams are query parameters holding credentials or addresses
+var secretParams = []string{"api_key", "apikey", "key", "token", "mailto", "email"}
+
+// secretValue replaces them and the tokens of secretBody
+const secretValue = "REDACTED"
+
+// secretBody matches the tokens of OAuth responses, like those of EPO OPS
+var secretBody = regexp.MustCompile(`("access_token"\s*:\s*")[^"]*`)
+
+// recordedHeaders are the response headers kept, what the resolvers and
+// the scheduling read
+var recordedHeaders = []string{"Content-Type", "Location", "Retry-After", "X-Rate-Limit-Limit", "X-Rate-Limit-Interval"}
+
+// RecordCassette sends the requests of Client and records the responses,
+// written to path on Close
+func RecordCassette(path string) *Cassette {
+	c := &Cassette{path: path, record: true}
+	c.install()
+	return c
+}
+
+// ReplayCassette answers the requests of Client from the recording at path
+func ReplayCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{path: path, played: map[string]int{}}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.install()
+	return c, nil
+}
+
+func (c *Cassette) install() {
+	c.prev, c.cache, c.offline = Client.Transport, CacheDir, Offline
+	Client.Transport = c
+	CacheDir = ""
+	// replays need no network
+	Offline = Offline && c.record
+}
+
+// Close stops using the cassette and writes a recording
+func (c *Cassette) Close() error {
+	Client.Transport, CacheDir, Offline = c.prev, c.cache, c.offline
+	if !c.record {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, append(data, '\n'), 0o644)
+}
+
+// Len returns the number of recorded responses
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Interactions)
+}
+
+// RoundTrip records the response to req, or replays the recorded one
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := redactURL(req.URL)
+	if c.record {
+		return c.recordTrip(req, u)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matches []Interaction
+	for _, in := range c.Interactions {
+		if in.Method == req.Method && in.URL == u {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s %s: %w in %s", req.Method, u, ErrNotRecorded, c.path)
+	}
+	// asked more often than recorded, the last response stands
+	id := req.Method + " " + u
+	in := matches[min(c.played[id], len(matches)-1)]
+	c.played[id]++
+	body := in.Binary
+	if body == nil {
+		body = []byte(in.Body)
+	}
+	header := in.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (c *Cassette) recordTrip(req *http.Request, u string) (*http.Response, error) {
+	res, err := c.prev.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(res.Body)
+	res.Body.Close() // nolint:errcheck
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(data))
+	in := Interaction{Method: req.Method, URL: u, Status: res.StatusCode, Header: http.Header{}}
+	for _, h := range recordedHeaders {
+		if v := res.Header.Values(h); len(v) > 0 {
+			in.Header[h] = v
+		}
+	}
+	if utf8.Valid(data) {
+		in.Body = secretBody.ReplaceAllString(string(data), "${1}"+secretValue)
+	} else {
+		in.Binary = data
+	}
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, in)
+	c.mu.Unlock()
+	return res, nil
+}
+
+// redactURL returns u with the values of secretParams replaced. The query
+// is otherwise kept as it is, so a replay matches the request exactly.
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	parts := strings.Split(u.RawQuery, "&")
+	for i, p := range parts {
+		name, _, _ := strings.Cut(p, "=")
+		for _, s := range secretParams {
+			if strings.EqualFold(name, s) {
+				parts[i] = name + "=" + secretValue
+			}
+		}
+	}
+	r := *u
+	r.RawQuery = strings.Join(parts, "&")
+	return r.String()
+}
+
+// RedactURL is u with the values of credentials and addresses in its query
+// replaced, the way cassettes record it, for logs and error messages
+func RedactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return redactURL(parsed)
+}
+
+// RedactError redacts the URL of a failed request in err, which http.Client
+// reports as it was sent
+func RedactError(err error) error {
+	if ue := (*url.Error)(nil); errors.As(err, &ue) {
+		ue.URL = RedactURL(ue.URL)
+	}
+	return err
+}
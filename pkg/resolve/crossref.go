@@ -0,0 +1,263 @@
+package resolve
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+const CrossrefAPI = "https://api.crossref.org/works/"
+
+type crossrefAuthor struct {
+	Given  string `json:"given"`
+	Family string `json:"family"`
+	Name   string `json:"name"`
+	// ORCID is the iD as a URL, https://orcid.org/0000-...
+	ORCID string `json:"ORCID"`
+}
+
+type crossrefDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+type crossrefWork struct {
+	Type           string           `json:"type"`
+	DOI            string           `json:"DOI"`
+	URL            string           `json:"URL"`
+	Title          []string         `json:"title"`
+	ContainerTitle []string         `json:"container-title"`
+	Author         []crossrefAuthor `json:"author"`
+	Editor         []crossrefAuthor `json:"editor"`
+	Issued         crossrefDate     `json:"issued"`
+	Volume         string           `json:"volume"`
+	Issue          string           `json:"issue"`
+	Page           string           `json:"page"`
+	Publisher      string           `json:"publisher"`
+	ISBN           []string         `json:"ISBN"`
+	ISSN           []string         `json:"ISSN"`
+	Abstract       string           `json:"abstract"`
+	ReferencedBy   int              `json:"is-referenced-by-count"`
+	// UpdatedBy lists notices amending the work, like retractions
+	UpdatedBy []crossrefUpdate `json:"updated-by"`
+	Funder    []Funder         `json:"funder"`
+	// Language is an ISO 639-1 code, like en
+	Language string `json:"language"`
+	// Reference is the reference list deposited by the publisher
+	Reference []crossrefReference `json:"reference"`
+}
+
+// crossrefReference is an item of a reference list. Only DOI is reliable,
+// the other fields are whatever the publisher deposited.
+type crossrefReference struct {
+	DOI          string `json:"DOI"`
+	ArticleTitle string `json:"article-title"`
+	VolumeTitle  string `json:"volume-title"`
+	JournalTitle string `json:"journal-title"`
+	Author       string `json:"author"`
+	Year         string `json:"year"`
+	Unstructured string `json:"unstructured"`
+}
+
+// Funder is an organisation that funded a work, with the awards it was
+// funded under. DOI is the funder's id in the Open Funder Registry, where
+// it is registered.
+type Funder struct {
+	Name   string   `json:"name"`
+	DOI    string   `json:"DOI"`
+	Awards []string `json:"award"`
+}
+
+type crossrefUpdate struct {
+	Type string `json:"type"`
+	DOI  string `json:"DOI"`
+}
+
+// crossrefTypes maps CrossRef work types to BibTeX entry types
+var crossrefTypes = map[string]string{
+	"journal-article":     "article",
+	"proceedings-article": "inproceedings",
+	"book":                "book",
+	"monograph":           "book",
+	"edited-book":         "book",
+	"book-chapter":        "incollection",
+	"dissertation":        "phdthesis",
+	"report":              "techreport",
+	"posted-content":      "misc",
+	"dataset":             "misc",
+}
+
+// FetchRetraction returns the DOI of the notice retracting a work, or an
+// empty string if it has not been retracted
+func FetchRetraction(doi string) (string, error) {
+	var res struct {
+		Message crossrefWork `json:"message"`
+	}
+	if err := GetJSON(CrossrefAPI+EscapeDOI(doi), &res); err != nil {
+		return "", fmt.Errorf("crossref: %w", err)
+	}
+	for _, u := range res.Message.UpdatedBy {
+		if u.Type == "retraction" {
+			return u.DOI, nil
+		}
+	}
+	return "", nil
+}
+
+// FetchFunders returns the funders CrossRef records for a work
+func FetchFunders(doi string) ([]Funder, error) {
+	var res struct {
+		Message crossrefWork `json:"message"`
+	}
+	if err := GetJSON(CrossrefAPI+EscapeDOI(doi), &res); err != nil {
+		return nil, fmt.Errorf("crossref: %w", err)
+	}
+	return res.Message.Funder, nil
+}
+
+// FetchReferences returns the works a DOI cites in the order of its
+// reference list, as deposited with CrossRef. References without a DOI are
+// left out, they cannot be resolved.
+func FetchReferences(doi string) ([]Paper, error) {
+	var res struct {
+		Message crossrefWork `json:"message"`
+	}
+	if err := GetJSON(CrossrefAPI+EscapeDOI(doi), &res); err != nil {
+		return nil, fmt.Errorf("crossref: %w", err)
+	}
+	var out []Paper
+	for _, r := range res.Message.Reference {
+		if r.DOI == "" {
+			continue
+		}
+		p := Paper{DOI: r.DOI, Title: r.ArticleTitle, Venue: r.JournalTitle}
+		if p.Title == "" {
+			p.Title = r.VolumeTitle
+		}
+		if p.Title == "" {
+			p.Title = strings.Join(strings.Fields(r.Unstructured), " ")
+		}
+		if r.Author != "" {
+			p.Authors = []string{r.Author}
+		}
+		p.Year, _ = strconv.Atoi(r.Year)
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// FetchCrossref resolves a DOI against the CrossRef REST API
+func FetchCrossref(doi string) (bibtex.Entry, string, error) {
+	var res struct {
+		Message crossrefWork `json:"message"`
+	}
+	if err := GetJSON(CrossrefAPI+EscapeDOI(doi), &res); err != nil {
+		return bibtex.Entry{}, "", fmt.Errorf("crossref: %w", err)
+	}
+	w := res.Message
+
+	e := bibtex.Entry{Type: crossrefTypes[w.Type]}
+	if e.Type == "" {
+		e.Type = "misc"
+	}
+	names, field := w.Author, "author"
+	if len(w.Author) == 0 {
+		names, field = w.Editor, "editor"
+	}
+	list, ids := joinNames(names)
+	e.Set(field, list)
+	e.SetNameIDs(field, ids)
+	e.Set("title", titleLaTeX(first(w.Title)))
+	switch e.Type {
+	case "article":
+		e.Set("journal", first(w.ContainerTitle))
+	case "inproceedings", "incollection":
+		e.Set("booktitle", first(w.ContainerTitle))
+	}
+	if len(w.Issued.DateParts) > 0 && len(w.Issued.DateParts[0]) > 0 {
+		e.Set("year", fmt.Sprint(w.Issued.DateParts[0][0]))
+		if len(w.Issued.DateParts[0]) > 1 {
+			e.Set("month", fmt.Sprint(w.Issued.DateParts[0][1]))
+		}
+	}
+	e.Set("volume", w.Volume)
+	e.Set("number", w.Issue)
+	e.Set("pages", strings.ReplaceAll(w.Page, "-", "--"))
+	e.Set("publisher", w.Publisher)
+	e.Set("isbn", first(w.ISBN))
+	e.Set("issn", first(w.ISSN))
+	e.Set("language", bibtex.LanguageName(w.Language))
+	e.Set("doi", w.DOI)
+	e.Set("url", w.URL)
+	e.Key = bibtex.MakeKey(&e)
+
+	return e, stripTags(w.Abstract), nil
+}
+
+// SearchCrossref finds works by their bibliographic data, like a title and
+// year, the most relevant first. A non-empty author narrows the search to
+// works by that person.
+func SearchCrossref(bibliographic, author string, rows int) ([]Paper, error) {
+	q := url.Values{
+		"query.bibliographic": {bibliographic},
+		"rows":                {fmt.Sprint(rows)},
+		"select":              {"DOI,title,author,issued,container-title,is-referenced-by-count"},
+	}
+	if author != "" {
+		q.Set("query.author", author)
+	}
+	var res struct {
+		Message struct {
+			Items []crossrefWork `json:"items"`
+		} `json:"message"`
+	}
+	if err := GetJSON(strings.TrimSuffix(CrossrefAPI, "/")+"?"+q.Encode(), &res); err != nil {
+		return nil, fmt.Errorf("crossref: %w", err)
+	}
+	out := make([]Paper, 0, len(res.Message.Items))
+	for _, w := range res.Message.Items {
+		p := Paper{DOI: w.DOI, Title: stripTags(first(w.Title)), Venue: first(w.ContainerTitle), Citations: w.ReferencedBy}
+		for _, a := range w.Author {
+			p.Authors = append(p.Authors, strings.TrimSpace(a.Given+" "+a.Family+a.Name))
+		}
+		if len(w.Issued.DateParts) > 0 && len(w.Issued.DateParts[0]) > 0 {
+			p.Year = w.Issued.DateParts[0][0]
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// joinNames formats CrossRef contributors as a BibTeX name list and
+// returns the ORCID iDs of the names
+func joinNames(people []crossrefAuthor) (string, []string) {
+	names := make([]string, 0, len(people))
+	var ids []string
+	for _, p := range people {
+		cjk, isCJK := bibtex.CJKName(p.Name)
+		switch {
+		case p.Family != "" && p.Given != "":
+			names = append(names, p.Family+", "+p.Given)
+		case p.Family != "":
+			names = append(names, p.Family)
+		case isCJK:
+			names = append(names, cjk)
+		case p.Name != "":
+			names = append(names, "{"+p.Name+"}")
+		default:
+			continue
+		}
+		id, _ := CleanORCID(p.ORCID)
+		ids = append(ids, id)
+	}
+	return strings.Join(names, " and "), ids
+}
+
+func first(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
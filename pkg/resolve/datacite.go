@@ -0,0 +1,218 @@
+package resolve
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// DataCiteAPI serves DOIs registered with DataCite, the registry of most
+// datasets and software, like those archived on Zenodo
+const DataCiteAPI = "https://api.datacite.org/dois/"
+
+// GitHubAPI answers questions about GitHub repositories
+const GitHubAPI = "https://api.github.com/repos/"
+
+type dataciteCreator struct {
+	Name       string `json:"name"`
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+	NameType   string `json:"nameType"`
+	// NameIdentifiers include the ORCID iD of a person
+	NameIdentifiers []struct {
+		NameIdentifier string `json:"nameIdentifier"`
+		Scheme         string `json:"nameIdentifierScheme"`
+	} `json:"nameIdentifiers"`
+}
+
+type dataciteRights struct {
+	Rights           string `json:"rights"`
+	RightsURI        string `json:"rightsUri"`
+	RightsIdentifier string `json:"rightsIdentifier"`
+}
+
+type dataciteRecord struct {
+	Data struct {
+		Attributes struct {
+			DOI      string            `json:"doi"`
+			URL      string            `json:"url"`
+			Creators []dataciteCreator `json:"creators"`
+			Titles   []struct {
+				Title string `json:"title"`
+			} `json:"titles"`
+			Publisher       string `json:"publisher"`
+			PublicationYear int    `json:"publicationYear"`
+			Types           struct {
+				ResourceTypeGeneral string `json:"resourceTypeGeneral"`
+			} `json:"types"`
+			Version      string           `json:"version"`
+			Language     string           `json:"language"`
+			RightsList   []dataciteRights `json:"rightsList"`
+			Descriptions []struct {
+				Description     string `json:"description"`
+				DescriptionType string `json:"descriptionType"`
+			} `json:"descriptions"`
+			RelatedIdentifiers []struct {
+				RelatedIdentifier string `json:"relatedIdentifier"`
+			} `json:"relatedIdentifiers"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// dataciteKinds are the resource types kept in howpublished, which
+// bibtex.ToBibLaTeX turns into entry types
+var dataciteKinds = map[string]string{
+	"Software": "Software",
+	"Dataset":  "Dataset",
+}
+
+// githubRepoRe matches the URL of a GitHub repository, or a page in one
+var githubRepoRe = regexp.MustCompile(`^https?://(?:www\.)?github\.com/([\w.-]+)/([\w.-]+?)(?:\.git)?(?:[/#?].*)?$`)
+
+// FetchDataCite resolves a DOI against the DataCite REST API. The license
+// comes from the rights of the record, or from the GitHub repository a
+// software release was archived from.
+func FetchDataCite(doi string) (bibtex.Entry, string, error) {
+	var res dataciteRecord
+	if err := GetJSON(DataCiteAPI+EscapeDOI(doi), &res); err != nil {
+		return bibtex.Entry{}, "", fmt.Errorf("datacite: %w", err)
+	}
+	a := res.Data.Attributes
+
+	e := bibtex.Entry{Type: "misc"}
+	names := make([]string, 0, len(a.Creators))
+	ids := make([]string, len(a.Creators))
+	for i, c := range a.Creators {
+		for _, n := range c.NameIdentifiers {
+			if strings.EqualFold(n.Scheme, "ORCID") {
+				ids[i], _ = CleanORCID(n.NameIdentifier)
+			}
+		}
+		cjk, isCJK := bibtex.CJKName(c.Name)
+		switch {
+		case c.FamilyName != "" && c.GivenName != "":
+			names = append(names, c.FamilyName+", "+c.GivenName)
+		case c.NameType != "Organizational" && isCJK:
+			names = append(names, cjk)
+		case c.NameType == "Organizational" || !strings.Contains(c.Name, ","):
+			names = append(names, "{"+c.Name+"}")
+		default:
+			names = append(names, c.Name)
+		}
+	}
+	e.Set("author", strings.Join(names, " and "))
+	e.SetNameIDs("author", ids)
+	if len(a.Titles) > 0 {
+		e.Set("title", a.Titles[0].Title)
+	}
+	e.Set("howpublished", dataciteKinds[a.Types.ResourceTypeGeneral])
+	e.Set("publisher", a.Publisher)
+	if a.PublicationYear > 0 {
+		e.Set("year", fmt.Sprint(a.PublicationYear))
+	}
+	e.Set("version", a.Version)
+	e.Set("language", bibtex.LanguageName(a.Language))
+	e.Set("doi", a.DOI)
+	e.Set("url", a.URL)
+	license := rightsLicense(a.RightsList)
+	if license == "" {
+		for _, r := range a.RelatedIdentifiers {
+			if githubRepoRe.MatchString(r.RelatedIdentifier) {
+				// a repository without a license is no reason to fail
+				license, _ = FetchGitHubLicense(r.RelatedIdentifier)
+				break
+			}
+		}
+	}
+	e.Set("license", license)
+	e.Key = bibtex.MakeKey(&e)
+
+	var abstract string
+	for _, d := range a.Descriptions {
+		if d.DescriptionType == "Abstract" {
+			abstract = stripTags(d.Description)
+			break
+		}
+	}
+	return e, abstract, nil
+}
+
+// FetchLicense returns the license DataCite records for a DOI, or an empty
+// string when it records none
+func FetchLicense(doi string) (string, error) {
+	var res dataciteRecord
+	if err := GetJSON(DataCiteAPI+EscapeDOI(doi), &res); err != nil {
+		return "", fmt.Errorf("datacite: %w", err)
+	}
+	return rightsLicense(res.Data.Attributes.RightsList), nil
+}
+
+// FetchGitHubLicense returns the SPDX id of the license GitHub detected in
+// a repository, or its name for licenses without one
+func FetchGitHubLicense(repo string) (string, error) {
+	m := githubRepoRe.FindStringSubmatch(repo)
+	if m == nil {
+		return "", fmt.Errorf("%q is not a GitHub repository", repo)
+	}
+	var res struct {
+		License *struct {
+			SPDXID string `json:"spdx_id"`
+			Name   string `json:"name"`
+		} `json:"license"`
+	}
+	if err := GetJSON(GitHubAPI+url.PathEscape(m[1])+"/"+url.PathEscape(m[2]), &res); err != nil {
+		return "", fmt.Errorf("github: %w", err)
+	}
+	switch {
+	case res.License == nil:
+		return "", nil
+	case res.License.SPDXID != "" && res.License.SPDXID != "NOASSERTION":
+		return res.License.SPDXID, nil
+	}
+	return res.License.Name, nil
+}
+
+// IsGitHubRepo reports whether u points into a GitHub repository
+func IsGitHubRepo(u string) bool {
+	return githubRepoRe.MatchString(u)
+}
+
+// spdxIDs spells common SPDX license ids the canonical way, DataCite
+// lower-cases them
+var spdxIDs = map[string]string{}
+
+func init() {
+	for _, id := range []string{
+		"CC0-1.0", "CC-BY-4.0", "CC-BY-SA-4.0", "CC-BY-NC-4.0", "CC-BY-NC-SA-4.0", "CC-BY-ND-4.0", "CC-BY-NC-ND-4.0",
+		"CC-BY-3.0", "MIT", "Apache-2.0", "BSD-2-Clause", "BSD-3-Clause", "ISC", "MPL-2.0",
+		"GPL-2.0-only", "GPL-2.0-or-later", "GPL-3.0-only", "GPL-3.0-or-later",
+		"LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0-only", "LGPL-3.0-or-later",
+		"AGPL-3.0-only", "AGPL-3.0-or-later", "EUPL-1.2", "Unlicense", "ODbL-1.0", "PDDL-1.0",
+	} {
+		spdxIDs[strings.ToLower(id)] = id
+	}
+}
+
+// rightsLicense picks the license from DataCite rights, preferring an SPDX
+// id over a name. Access rights like info:eu-repo/semantics/openAccess
+// are not licenses.
+func rightsLicense(rights []dataciteRights) string {
+	for _, r := range rights {
+		if strings.HasPrefix(r.RightsURI, "info:") {
+			continue
+		}
+		if id := strings.ToLower(r.RightsIdentifier); id != "" {
+			if canonical, ok := spdxIDs[id]; ok {
+				return canonical
+			}
+			return r.RightsIdentifier
+		}
+		if r.Rights != "" {
+			return r.Rights
+		}
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// HandleAPI looks up DOIs at the handle system behind doi.org, whichever
+// agency registered them
+var HandleAPI = "https://doi.org/api/handles/"
+
+// doiPattern is the syntax of a DOI: a registrant code of 10. and at least
+// four digits, optionally extended with further dot separated digits, a
+// slash and a non-empty suffix
+var doiPattern = regexp.MustCompile(`^10\.\d{4,9}(\.\d+)*/\S+$`)
+
+// registrantPattern matches the registrant code of a DOI missing its slash
+var registrantPattern = regexp.MustCompile(`^10\.\d{4,5}`)
+
+// arXivPrefix is the DOI prefix of arXiv, which registers every paper at
+// DataCite
+const arXivPrefix = "10.48550/arXiv."
+
+// arXivID matches an arXiv identifier, new style like 2301.01234 or old
+// style like hep-th/9901001, labelled arXiv: or as the link of its abstract
+// or PDF
+var arXivID = regexp.MustCompile(`(?i)^(?:arxiv:\s*|https?://(?:www\.|export\.)?arxiv\.org/(?:abs|pdf)/)(\d{4}\.\d{4,5}|[a-z-]+(?:\.[a-z]{2})?/\d{7})(?:v\d+)?(?:\.pdf)?/?$`)
+
+// ArXivDOI returns the DOI arXiv registered for an arXiv identifier. The
+// version is dropped, the DOI names the work.
+func ArXivDOI(s string) (string, bool) {
+	m := arXivID.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", false
+	}
+	return arXivPrefix + m[1], true
+}
+
+// doiPrefixes are the resolver and label prefixes stripped by CleanDOI,
+// lower case
+var doiPrefixes = []string{
+	"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/",
+	"doi.org/", "dx.doi.org/", "doi:", "doi ",
+}
+
+// doiQuotes are the quotes and brackets a DOI is pasted in
+const doiQuotes = `"'<>“”‘’«»`
+
+// CleanDOI strips resolver prefixes, surrounding whitespace and the
+// artifacts of copying a DOI from a PDF or web page: invisible
+// characters, Unicode dashes, quotes and trailing punctuation
+func CleanDOI(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\u200b', r == '\u200c', r == '\u200d', r == '\u2060', r == '\ufeff', r == '\u00ad':
+			return -1
+		case r >= '\u2010' && r <= '\u2015', r == '\u2212':
+			return '-'
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, s)
+	s = strings.Trim(strings.TrimSpace(s), doiQuotes)
+	for _, p := range doiPrefixes {
+		if strings.HasPrefix(strings.ToLower(s), p) {
+			s = strings.TrimSpace(s[len(p):])
+			break
+		}
+	}
+	// a DOI copied from a link is percent-encoded
+	if strings.Contains(s, "%") {
+		if u, err := url.PathUnescape(s); err == nil {
+			s = u
+		}
+	}
+	for {
+		trimmed := strings.Trim(strings.TrimRight(s, ".,;:"), doiQuotes)
+		// brackets are part of some suffixes, only unbalanced ones are stripped
+		for _, b := range [][2]string{{"(", ")"}, {"[", "]"}} {
+			if strings.HasSuffix(trimmed, b[1]) && strings.Count(trimmed, b[1]) > strings.Count(trimmed, b[0]) {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+		}
+		if trimmed == s {
+			return s
+		}
+		s = trimmed
+	}
+}
+
+// EscapeDOI escapes a DOI for use in a URL path, keeping the prefix slash
+func EscapeDOI(doi string) string {
+	return strings.ReplaceAll(url.PathEscape(doi), "%2F", "/")
+}
+
+// ValidateDOI checks the syntax of a cleaned DOI. The error wraps
+// ErrInvalid, says what is wrong and suggests a fix for common typos.
+func ValidateDOI(doi string) error {
+	if doiPattern.MatchString(doi) {
+		return nil
+	}
+	invalid := func(reason string) error {
+		return fmt.Errorf("%q: %s: %w", doi, reason, ErrInvalid)
+	}
+	switch {
+	case doi == "":
+		return invalid("empty")
+	case strings.ContainsAny(doi, " \t"):
+		return invalid("contains spaces")
+	case !strings.HasPrefix(doi, "10."):
+		if fixed := fixRegistrant(doi); doiPattern.MatchString(fixed) {
+			return invalid(fmt.Sprintf("does not start with 10., did you mean %s?", fixed))
+		}
+		return invalid("does not start with 10.")
+	}
+	prefix, suffix, ok := strings.Cut(doi, "/")
+	switch {
+	case !ok:
+		// the slash is often lost after the registrant digits
+		if m := registrantPattern.FindString(doi); m != "" && len(m) < len(doi) {
+			return invalid(fmt.Sprintf("has no slash, did you mean %s/%s?", m, doi[len(m):]))
+		}
+		return invalid("has no slash between registrant and suffix")
+	case suffix == "":
+		return invalid("has an empty suffix")
+	}
+	if fixed := fixRegistrant(prefix) + "/" + suffix; doiPattern.MatchString(fixed) {
+		return invalid(fmt.Sprintf("registrant code %s is not numeric, did you mean %s?", prefix, fixed))
+	}
+	return invalid(fmt.Sprintf("registrant code %s is not 10. followed by digits", prefix))
+}
+
+// fixRegistrant replaces the letters mistyped for digits in the registrant
+// code of a DOI
+func fixRegistrant(doi string) string {
+	prefix, suffix, ok := strings.Cut(doi, "/")
+	prefix = strings.NewReplacer("O", "0", "o", "0", "l", "1", "I", "1", "S", "5", ",", ".").Replace(prefix)
+	if ok {
+		return prefix + "/" + suffix
+	}
+	return prefix
+}
+
+// maxSuggestions bounds the candidates SuggestDOIs looks up
+const maxSuggestions = 12
+
+// SuggestDOIs returns up to three registered DOIs close to one that was not
+// found: with pasted trailing characters dropped or a letter mistaken for
+// a digit swapped. Lookups that fail, e.g. offline, yield no suggestion.
+func SuggestDOIs(doi string) []string {
+	var out []string
+	for _, c := range nearDOIs(doi) {
+		var res struct {
+			ResponseCode int `json:"responseCode"`
+		}
+		if err := GetJSON(HandleAPI+EscapeDOI(c), &res); err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				break
+			}
+			continue
+		}
+		if res.ResponseCode == 1 {
+			out = append(out, c)
+			if len(out) == 3 {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// nearDOIs lists the valid DOIs one typo away from doi, the likeliest first
+func nearDOIs(doi string) []string {
+	var out []string
+	add := func(c string) {
+		if c != doi && len(out) < maxSuggestions && doiPattern.MatchString(c) && !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	for n := 1; n <= 3 && n < len(doi); n++ {
+		add(doi[:len(doi)-n])
+	}
+	prefix, suffix, _ := strings.Cut(doi, "/")
+	confusable := map[byte]string{'0': "O", 'O': "0", 'o': "0", '1': "l", 'l': "1", 'I': "1"}
+	for i := 0; i < len(suffix); i++ {
+		for _, r := range confusable[suffix[i]] {
+			add(prefix + "/" + suffix[:i] + string(r) + suffix[i+1:])
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package resolve
+
+import "sync"
+
+// Job is an identifier queued for resolving. Seq and Line are left to the
+// consumer to tell where it came from.
+type Job struct {
+	Seq, Line int
+	ID        string
+}
+
+// Result is a resolved job
+type Result struct {
+	Job
+	Work *Work
+	Err  error
+}
+
+// Engine resolves queued identifiers with a fixed number of workers.
+// Results arrive in the order they finish, consumers that need the input
+// order restore it from Job.Seq.
+type Engine struct {
+	opts    Options
+	jobs    chan Job
+	results chan Result
+	// done stops the workers once their results are not wanted
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewEngine starts the workers of an engine
+func NewEngine(workers int, opts Options) *Engine {
+	e := &Engine{
+		opts:    opts,
+		jobs:    make(chan Job),
+		results: make(chan Result),
+		done:    make(chan struct{}),
+	}
+	for range max(workers, 1) {
+		e.wg.Add(1)
+		go e.work()
+	}
+	go func() {
+		e.wg.Wait()
+		close(e.results)
+	}()
+	return e
+}
+
+func (e *Engine) work() {
+	defer e.wg.Done()
+	for j := range e.jobs {
+		w, err := Resolve(j.ID, e.opts)
+		select {
+		case e.results <- Result{j, w, err}:
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// Submit queues a job, blocking while every worker is busy. It reports
+// false once the engine was stopped.
+func (e *Engine) Submit(j Job) bool {
+	select {
+	case e.jobs <- j:
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+// Results returns the channel results arrive on
+func (e *Engine) Results() <-chan Result {
+	return e.results
+}
+
+// Close tells the workers that no more jobs come. Results is closed once
+// the queued ones are resolved.
+func (e *Engine) Close() {
+	close(e.jobs)
+}
+
+// Stop abandons the queued jobs and unblocks Submit
+func (e *Engine) Stop() {
+	e.stopOnce.Do(func() { close(e.done) })
+}
@@ -0,0 +1,88 @@
+package resolve
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// EventDataAPI is Crossref Event Data, which collects where DOIs are
+// mentioned outside the literature: Wikipedia, blogs, Reddit and others
+const EventDataAPI = "https://api.eventdata.crossref.org/v1/events"
+
+// MaxEvents is how many events of a DOI are read, the most discussed
+// papers have tens of thousands
+const MaxEvents = 1000
+
+// scholarlySources are the Event Data sources that link DOIs to each
+// other, citations counted elsewhere already
+var scholarlySources = []string{"crossref", "datacite"}
+
+// Mention is a page that mentions a work
+type Mention struct {
+	// Source is the Event Data source, like wikipedia, newsfeed (blogs and
+	// news sites), reddit or hypothesis
+	Source string
+	// Relation is how the page relates to the work, like references or
+	// discusses
+	Relation string
+	Title    string
+	URL      string
+	Occurred time.Time
+}
+
+// FetchMentions returns the pages mentioning a DOI, the latest first, each
+// once however often it was edited. email is sent as Crossref asks for.
+// Responses are cached like the other lookups.
+func FetchMentions(doi, email string) (mentions []Mention, total int, err error) {
+	q := url.Values{"obj-id": {doi}, "rows": {fmt.Sprint(MaxEvents)}}
+	if email != "" {
+		q.Set("mailto", email)
+	}
+	var res struct {
+		Message struct {
+			TotalResults int `json:"total-results"`
+			Events       []struct {
+				SubjID     string    `json:"subj_id"`
+				SourceID   string    `json:"source_id"`
+				RelationID string    `json:"relation_type_id"`
+				OccurredAt time.Time `json:"occurred_at"`
+				Subj       struct {
+					PID   string `json:"pid"`
+					URL   string `json:"url"`
+					Title string `json:"title"`
+				} `json:"subj"`
+			} `json:"events"`
+		} `json:"message"`
+	}
+	if err := GetJSON(EventDataAPI+"?"+q.Encode(), &res); err != nil {
+		return nil, 0, fmt.Errorf("event data: %w", err)
+	}
+	seen := map[string]int{}
+	for _, ev := range res.Message.Events {
+		if slices.Contains(scholarlySources, ev.SourceID) {
+			continue
+		}
+		m := Mention{
+			Source:   ev.SourceID,
+			Relation: ev.RelationID,
+			Title:    ev.Subj.Title,
+			URL:      cmp.Or(ev.Subj.PID, ev.Subj.URL, ev.SubjID),
+			Occurred: ev.OccurredAt,
+		}
+		// every revision of a Wikipedia article is an event of its own,
+		// under the article's URL
+		if i, ok := seen[m.URL]; ok {
+			if m.Occurred.After(mentions[i].Occurred) {
+				mentions[i] = m
+			}
+			continue
+		}
+		seen[m.URL] = len(mentions)
+		mentions = append(mentions, m)
+	}
+	slices.SortStableFunc(mentions, func(a, b Mention) int { return b.Occurred.Compare(a.Occurred) })
+	return mentions, res.Message.TotalResults, nil
+}
@@ -0,0 +1,386 @@
+package resolve
+
+import (
+	"encoding/xml"
+	"errors"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// markupNode is an element of JATS, HTML or MathML markup, or the text
+// between elements when its name is empty
+type markupNode struct {
+	name     string
+	attrs    []xml.Attr
+	text     string
+	children []*markupNode
+}
+
+// attr returns the value of the attribute name, or def without one
+func (n *markupNode) attr(name, def string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return def
+}
+
+// elements returns the child elements of n, skipping text
+func (n *markupNode) elements() []*markupNode {
+	var out []*markupNode
+	for _, c := range n.children {
+		if c.name != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// textContent returns the text of n and its descendants
+func (n *markupNode) textContent() string {
+	if n.name == "" {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.textContent())
+	}
+	return b.String()
+}
+
+// find returns the first descendant element called name
+func (n *markupNode) find(name string) *markupNode {
+	for _, c := range n.elements() {
+		if c.name == name {
+			return c
+		}
+		if f := c.find(name); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// bareLess matches a < that opens no tag, as in p < 0.05
+var bareLess = regexp.MustCompile(`<([^A-Za-z/!?]|$)`)
+
+// parseMarkup reads markup leniently, as publishers mix JATS with HTML
+// entities and unclosed tags. Namespace prefixes like mml: are dropped.
+func parseMarkup(s string) (*markupNode, error) {
+	s = bareLess.ReplaceAllString(s, "&lt;$1")
+	d := xml.NewDecoder(strings.NewReader("<root>" + s + "</root>"))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	root := &markupNode{name: "root"}
+	stack := []*markupNode{root}
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &markupNode{name: strings.ToLower(t.Name.Local), attrs: t.Attr}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			top.children = append(top.children, &markupNode{text: string(t)})
+		}
+	}
+}
+
+// titleLaTeX converts the markup of a title to LaTeX: JATS formulas and
+// MathML become math, sub- and superscripts math scripts and face markup
+// text commands. Math written as $...$ is kept and other text escaped.
+func titleLaTeX(s string) string {
+	root, err := parseMarkup(s)
+	if err != nil {
+		root = &markupNode{children: []*markupNode{{text: html.UnescapeString(stripTags(s))}}}
+	}
+	w := &latexWriter{}
+	for _, c := range root.children {
+		w.node(c)
+	}
+	return strings.Join(strings.Fields(w.out), " ")
+}
+
+// latexWriter writes LaTeX in text mode, merging adjacent math spans so
+// that H<sub>2</sub><sup>+</sup> does not turn into display math
+type latexWriter struct {
+	out string
+	// inMath is set while out ends with a math span
+	inMath bool
+}
+
+func (w *latexWriter) raw(s string) {
+	w.out += s
+	w.inMath = false
+}
+
+func (w *latexWriter) math(s string) {
+	if s == "" {
+		return
+	}
+	if w.inMath {
+		w.out = joinTeX(strings.TrimSuffix(w.out, "$"), s) + "$"
+	} else {
+		w.out += "$" + s + "$"
+	}
+	w.inMath = true
+}
+
+// inlineMath finds the $...$ spans in text
+var inlineMath = regexp.MustCompile(`\$[^$]+\$`)
+
+// text writes s escaped for LaTeX, except for the math spans in it
+func (w *latexWriter) text(s string) {
+	last := 0
+	for _, m := range inlineMath.FindAllStringIndex(s, -1) {
+		if m[0] > last {
+			w.raw(EscapeTeX(s[last:m[0]]))
+		}
+		w.math(s[m[0]+1 : m[1]-1])
+		last = m[1]
+	}
+	if last < len(s) {
+		w.raw(EscapeTeX(s[last:]))
+	}
+}
+
+func (w *latexWriter) node(n *markupNode) {
+	switch n.name {
+	case "":
+		w.text(n.text)
+	case "i", "italic", "em":
+		w.command("textit", n)
+	case "b", "bold", "strong":
+		w.command("textbf", n)
+	case "sc", "scp":
+		w.command("textsc", n)
+	case "sub":
+		w.math("_{" + mathChildren(n) + "}")
+	case "sup":
+		w.math("^{" + mathChildren(n) + "}")
+	case "tex-math":
+		w.tex(n.textContent())
+	case "math":
+		w.math(mathChildren(n))
+	case "inline-formula", "disp-formula":
+		// a formula may come both as TeX and MathML
+		if t := n.find("tex-math"); t != nil {
+			w.tex(t.textContent())
+			return
+		}
+		fallthrough
+	default:
+		for _, c := range n.children {
+			w.node(c)
+		}
+	}
+}
+
+// tex writes the content of a tex-math element, a formula or text with
+// math in it
+func (w *latexWriter) tex(s string) {
+	if m := texMath(s); strings.Contains(m, "$") {
+		w.text(m)
+	} else {
+		w.math(m)
+	}
+}
+
+func (w *latexWriter) command(name string, n *markupNode) {
+	inner := &latexWriter{}
+	for _, c := range n.children {
+		inner.node(c)
+	}
+	if strings.TrimSpace(inner.out) != "" {
+		w.raw(`\` + name + "{" + inner.out + "}")
+	}
+}
+
+// EscapeTeX escapes the characters special to LaTeX in text, leaving
+// those already escaped
+func EscapeTeX(s string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range s {
+		if strings.ContainsRune(`&%#_$`, r) && prev != '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// texMath returns the math of a JATS tex-math element without its
+// document wrapper and delimiters
+func texMath(s string) string {
+	if _, body, ok := strings.Cut(s, `\begin{document}`); ok {
+		s, _, _ = strings.Cut(body, `\end{document}`)
+	}
+	s = strings.TrimSpace(s)
+	for _, d := range [][2]string{{"$$", "$$"}, {"$", "$"}, {`\(`, `\)`}, {`\[`, `\]`}} {
+		if len(s) >= len(d[0])+len(d[1]) && strings.HasPrefix(s, d[0]) && strings.HasSuffix(s, d[1]) {
+			s = s[len(d[0]) : len(s)-len(d[1])]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// mathChildren converts the children of n to math
+func mathChildren(n *markupNode) string {
+	out := ""
+	for _, c := range n.children {
+		out = joinTeX(out, mathNode(c))
+	}
+	return out
+}
+
+// mathNode converts a MathML element, or text inside math, to LaTeX math
+func mathNode(n *markupNode) string {
+	kids := n.elements()
+	arg := func(i int) string {
+		if i < len(kids) {
+			return mathNode(kids[i])
+		}
+		return ""
+	}
+	switch n.name {
+	case "":
+		return mathText(strings.TrimSpace(n.text))
+	case "mi":
+		t := strings.TrimSpace(n.textContent())
+		// multi-letter identifiers are names like sin, a single letter
+		// is upright only when asked for
+		word := utf8.RuneCountInString(t) > 1 && !strings.ContainsFunc(t, func(r rune) bool { return r >= utf8.RuneSelf || !isASCIILetter(byte(r)) })
+		if word || n.attr("mathvariant", "") == "normal" && len(t) == 1 && isASCIILetter(t[0]) {
+			return `\mathrm{` + t + "}"
+		}
+		return mathText(t)
+	case "mn", "mo":
+		return mathText(strings.TrimSpace(n.textContent()))
+	case "mtext":
+		if t := strings.Join(strings.Fields(n.textContent()), " "); t != "" {
+			return `\mbox{` + EscapeTeX(t) + "}"
+		}
+		return ""
+	case "mspace":
+		return `\,`
+	case "msub":
+		return group(arg(0)) + "_{" + arg(1) + "}"
+	case "msup":
+		return group(arg(0)) + "^{" + arg(1) + "}"
+	case "msubsup", "munderover":
+		return group(arg(0)) + "_{" + arg(1) + "}^{" + arg(2) + "}"
+	case "mfrac":
+		return `\frac{` + arg(0) + "}{" + arg(1) + "}"
+	case "msqrt":
+		return `\sqrt{` + mathChildren(n) + "}"
+	case "mroot":
+		return `\sqrt[` + arg(1) + "]{" + arg(0) + "}"
+	case "mover":
+		if len(kids) > 1 {
+			if acc, ok := mathAccents[strings.TrimSpace(kids[1].textContent())]; ok {
+				return acc + "{" + arg(0) + "}"
+			}
+		}
+		return `\overset{` + arg(1) + "}{" + arg(0) + "}"
+	case "munder":
+		return `\underset{` + arg(1) + "}{" + arg(0) + "}"
+	case "mfenced":
+		parts := make([]string, len(kids))
+		for i := range kids {
+			parts[i] = arg(i)
+		}
+		return mathText(n.attr("open", "(")) + strings.Join(parts, mathText(n.attr("separators", ","))) + mathText(n.attr("close", ")"))
+	case "semantics":
+		for _, k := range kids {
+			if k.name == "annotation" && k.attr("encoding", "") == "application/x-tex" {
+				return texMath(k.textContent())
+			}
+		}
+		return arg(0)
+	case "annotation", "annotation-xml", "mphantom", "none":
+		return ""
+	}
+	return mathChildren(n)
+}
+
+// mathSymbols are the LaTeX math commands for characters publishers put in
+// MathML as Unicode
+var mathSymbols = map[rune]string{
+	'α': `\alpha`, 'β': `\beta`, 'γ': `\gamma`, 'δ': `\delta`, 'ε': `\varepsilon`,
+	'ϵ': `\epsilon`, 'ζ': `\zeta`, 'η': `\eta`, 'θ': `\theta`, 'ι': `\iota`,
+	'κ': `\kappa`, 'λ': `\lambda`, 'μ': `\mu`, 'ν': `\nu`, 'ξ': `\xi`, 'π': `\pi`,
+	'ρ': `\rho`, 'σ': `\sigma`, 'τ': `\tau`, 'υ': `\upsilon`, 'φ': `\varphi`,
+	'ϕ': `\phi`, 'χ': `\chi`, 'ψ': `\psi`, 'ω': `\omega`,
+	'Γ': `\Gamma`, 'Δ': `\Delta`, 'Θ': `\Theta`, 'Λ': `\Lambda`, 'Ξ': `\Xi`,
+	'Π': `\Pi`, 'Σ': `\Sigma`, 'Υ': `\Upsilon`, 'Φ': `\Phi`, 'Ψ': `\Psi`, 'Ω': `\Omega`,
+	'±': `\pm`, '∓': `\mp`, '×': `\times`, '÷': `\div`, '·': `\cdot`, '⋅': `\cdot`,
+	'−': "-", '∗': "*", '≤': `\leq`, '≥': `\geq`, '≠': `\neq`, '≈': `\approx`,
+	'∼': `\sim`, '≃': `\simeq`, '≡': `\equiv`, '∝': `\propto`, '→': `\rightarrow`,
+	'←': `\leftarrow`, '↔': `\leftrightarrow`, '⇒': `\Rightarrow`, '∞': `\infty`,
+	'∂': `\partial`, '∇': `\nabla`, '∑': `\sum`, '∏': `\prod`, '∫': `\int`,
+	'√': `\surd`, '∈': `\in`, '∉': `\notin`, '⊂': `\subset`, '⊆': `\subseteq`,
+	'∪': `\cup`, '∩': `\cap`, '∘': `\circ`, '⊗': `\otimes`, '⊕': `\oplus`,
+	'°': `^{\circ}`, '′': "'", 'ℏ': `\hbar`, '…': `\ldots`, '⋯': `\cdots`,
+	'{': `\{`, '}': `\}`, '&': `\&`, '%': `\%`, '#': `\#`, '\u00a0': "~", '\u2009': `\,`,
+}
+
+// mathAccents are the accent commands for the marks of mover elements
+var mathAccents = map[string]string{
+	"¯": `\bar`, "‾": `\overline`, "^": `\hat`, "ˆ": `\hat`, "~": `\tilde`, "˜": `\tilde`,
+	"→": `\vec`, "⃗": `\vec`, "˙": `\dot`, ".": `\dot`, "¨": `\ddot`,
+}
+
+// mathText converts text inside math, replacing Unicode symbols
+func mathText(s string) string {
+	out := ""
+	for _, r := range s {
+		if cmd, ok := mathSymbols[r]; ok {
+			out = joinTeX(out, cmd)
+		} else {
+			out = joinTeX(out, string(r))
+		}
+	}
+	return out
+}
+
+// texCommandEnd matches a LaTeX command at the end of math
+var texCommandEnd = regexp.MustCompile(`\\[A-Za-z]+$`)
+
+// joinTeX appends b to a, separating a command from the letters after it
+func joinTeX(a, b string) string {
+	if b != "" && isASCIILetter(b[0]) && texCommandEnd.MatchString(a) {
+		return a + " " + b
+	}
+	return a + b
+}
+
+// group braces s for a script base unless it is a single symbol
+func group(s string) string {
+	if utf8.RuneCountInString(s) == 1 || texCommandEnd.FindString(s) == s && s != "" {
+		return s
+	}
+	return "{" + s + "}"
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
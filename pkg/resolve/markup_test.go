@@ -0,0 +1,66 @@
+package resolve
+
+import "testing"
+
+func TestTitleLaTeX(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "A study of things", "A study of things"},
+		{"subscript", "H<sub>2</sub>O", "H$_{2}$O"},
+		{"adjacent scripts merge", "H<sub>2</sub><sup>+</sup> ions", "H$_{2}^{+}$ ions"},
+		{"separate scripts stay apart", "CO<sub>2</sub> and CH<sub>4</sub>", "CO$_{2}$ and CH$_{4}$"},
+		{"superscript first", "<sup>3</sup>He", "$^{3}$He"},
+		{"charge", "Fe<sup>3+</sup>", "Fe$^{3+}$"},
+		{"math kept", "The $H_2O$ molecule", "The $H_2O$ molecule"},
+		{
+			"formula prefers tex-math",
+			"Effect of <inline-formula><tex-math>$\\alpha$</tex-math><mml:math><mml:mi>α</mml:mi></mml:math></inline-formula>-decay",
+			"Effect of $\\alpha$-decay",
+		},
+		{
+			"formula as MathML",
+			"<inline-formula><mml:math><mml:msub><mml:mi>T</mml:mi><mml:mi>c</mml:mi></mml:msub></mml:math></inline-formula> of cuprates",
+			"$T_{c}$ of cuprates",
+		},
+		{
+			"formula in a tex document",
+			"<inline-formula><tex-math><![CDATA[\\documentclass{article}\\begin{document}$\\sqrt{s}=13$ TeV\\end{document}]]></tex-math></inline-formula> collisions",
+			"$\\sqrt{s}=13$ TeV collisions",
+		},
+		{"display formula", "<disp-formula><tex-math>\\(E=mc^2\\)</tex-math></disp-formula>", "$E=mc^2$"},
+		{"inline formula next to script", "<inline-formula><tex-math>x</tex-math></inline-formula><sup>2</sup>", "$x^{2}$"},
+		{"fraction", "<mml:math><mml:mfrac><mml:mn>1</mml:mn><mml:mn>2</mml:mn></mml:mfrac></mml:math>-spin", "$\\frac{1}{2}$-spin"},
+		{"symbol before letter", "<mml:math><mml:mi>α</mml:mi><mml:mi>x</mml:mi></mml:math>", "$\\alpha x$"},
+		{"accent", "<mml:math><mml:mover><mml:mi>x</mml:mi><mml:mo>¯</mml:mo></mml:mover></mml:math>", "$\\bar{x}$"},
+		{
+			"semantics annotation",
+			`<mml:math><mml:semantics><mml:mi>y</mml:mi><mml:annotation encoding="application/x-tex">\beta</mml:annotation></mml:semantics></mml:math>`,
+			"$\\beta$",
+		},
+		{"faces", "<i>In vivo</i> repair of <b>DNA</b> by <sc>Rec</sc>A", "\\textit{In vivo} repair of \\textbf{DNA} by \\textsc{Rec}A"},
+		{"empty face dropped", "<i> </i>repair", "repair"},
+		{"escapes", "50% of #1_a & b", "50\\% of \\#1\\_a \\& b"},
+		{"entities", "T<sub>c</sub> &gt; 90&nbsp;K", "T$_{c}$ > 90 K"},
+		{"unclosed tag", "<unclosed>broken & stuff", "broken \\& stuff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titleLaTeX(tt.in); got != tt.want {
+				t.Errorf("titleLaTeX(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeTeX(t *testing.T) {
+	for in, want := range map[string]string{
+		"a & b":       `a \& b`,
+		`already \&`:  `already \&`,
+		"100% $5 #_1": `100\% \$5 \#\_1`,
+	} {
+		if got := EscapeTeX(in); got != want {
+			t.Errorf("EscapeTeX(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
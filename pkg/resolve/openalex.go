@@ -0,0 +1,163 @@
+package resolve
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+const (
+	OpenAlexAPI        = "https://api.openalex.org/works/"
+	SemanticScholarAPI = "https://api.semanticscholar.org/graph/v1/paper/"
+)
+
+type OpenAlexWork struct {
+	// ID is the OpenAlex URL of the work, https://openalex.org/W...
+	ID          string `json:"id"`
+	DOI         string `json:"doi"`
+	DisplayName string `json:"display_name"`
+	Authorships []struct {
+		Author struct {
+			DisplayName string `json:"display_name"`
+		} `json:"author"`
+	} `json:"authorships"`
+	CitedByCount int `json:"cited_by_count"`
+	OpenAccess   struct {
+		IsOA     bool   `json:"is_oa"`
+		OAStatus string `json:"oa_status"`
+		OAURL    string `json:"oa_url"`
+	} `json:"open_access"`
+	AbstractInvertedIndex map[string][]int `json:"abstract_inverted_index"`
+	PublicationYear       int              `json:"publication_year"`
+	// Language is an ISO 639-1 code, like en
+	Language string `json:"language"`
+	Biblio   struct {
+		Volume    string `json:"volume"`
+		Issue     string `json:"issue"`
+		FirstPage string `json:"first_page"`
+		LastPage  string `json:"last_page"`
+	} `json:"biblio"`
+	PrimaryLocation struct {
+		Source *struct {
+			DisplayName string `json:"display_name"`
+		} `json:"source"`
+	} `json:"primary_location"`
+}
+
+// fill completes bibliographic fields CrossRef left empty
+func (o OpenAlexWork) fill(w *Work) {
+	if o.PublicationYear > 0 {
+		w.fill("year", fmt.Sprint(o.PublicationYear), SourceOpenAlex)
+	}
+	w.fill("volume", o.Biblio.Volume, SourceOpenAlex)
+	w.fill("number", o.Biblio.Issue, SourceOpenAlex)
+	pages := o.Biblio.FirstPage
+	if o.Biblio.LastPage != "" && o.Biblio.LastPage != pages {
+		pages += "--" + o.Biblio.LastPage
+	}
+	w.fill("pages", pages, SourceOpenAlex)
+	w.fill("language", bibtex.LanguageName(o.Language), SourceOpenAlex)
+	if src := o.PrimaryLocation.Source; src != nil && w.Entry.Type == "article" {
+		w.fill("journal", src.DisplayName, SourceOpenAlex)
+	}
+}
+
+// abstract rebuilds the plain text abstract from OpenAlex's inverted index
+func (w OpenAlexWork) abstract() string {
+	type pos struct {
+		at   int
+		word string
+	}
+	var words []pos
+	for word, positions := range w.AbstractInvertedIndex {
+		for _, p := range positions {
+			words = append(words, pos{p, word})
+		}
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i].at < words[j].at })
+	parts := make([]string, len(words))
+	for i, p := range words {
+		parts[i] = p.word
+	}
+	return strings.Join(parts, " ")
+}
+
+// fetchOpenAlex looks up citation and open-access data for a DOI
+func fetchOpenAlex(doi, apiKey string) (OpenAlexWork, error) {
+	var w OpenAlexWork
+	u := OpenAlexAPI + "doi:" + EscapeDOI(doi)
+	if apiKey != "" {
+		u += "?api_key=" + url.QueryEscape(apiKey)
+	}
+	if err := GetJSON(u, &w); err != nil {
+		return w, fmt.Errorf("openalex: %w", err)
+	}
+	return w, nil
+}
+
+// Paper is a search hit
+type Paper struct {
+	DOI       string   `json:"doi"`
+	Title     string   `json:"title"`
+	Authors   []string `json:"authors,omitempty"`
+	Year      int      `json:"year,omitempty"`
+	Venue     string   `json:"venue,omitempty"`
+	Citations int      `json:"citations"`
+}
+
+// SearchOpenAlex finds works matching a free text query. Works without a
+// DOI are left out, they cannot be resolved.
+func SearchOpenAlex(query, apiKey string, limit int) ([]Paper, error) {
+	q := url.Values{"search": {query}, "per-page": {fmt.Sprint(limit)}}
+	if apiKey != "" {
+		q.Set("api_key", apiKey)
+	}
+	var res struct {
+		Results []OpenAlexWork `json:"results"`
+	}
+	if err := GetJSON(strings.TrimSuffix(OpenAlexAPI, "/")+"?"+q.Encode(), &res); err != nil {
+		return nil, fmt.Errorf("openalex: %w", err)
+	}
+	var out []Paper
+	for _, w := range res.Results {
+		if w.DOI != "" {
+			out = append(out, w.Paper())
+		}
+	}
+	return out, nil
+}
+
+// Paper returns the work as a search hit
+func (w OpenAlexWork) Paper() Paper {
+	p := Paper{DOI: CleanDOI(w.DOI), Title: w.DisplayName, Year: w.PublicationYear, Citations: w.CitedByCount}
+	for _, a := range w.Authorships {
+		p.Authors = append(p.Authors, a.Author.DisplayName)
+	}
+	if src := w.PrimaryLocation.Source; src != nil {
+		p.Venue = src.DisplayName
+	}
+	return p
+}
+
+type semanticScholarPaper struct {
+	CitationCount int    `json:"citationCount"`
+	Abstract      string `json:"abstract"`
+}
+
+// fetchSemanticScholar is the fallback source for citation counts and abstracts
+func fetchSemanticScholar(doi, apiKey string) (semanticScholarPaper, error) {
+	var p semanticScholarPaper
+	u := SemanticScholarAPI + "DOI:" + EscapeDOI(doi) + "?fields=citationCount,abstract"
+	var h http.Header
+	if apiKey != "" {
+		h = http.Header{"X-Api-Key": {apiKey}}
+	}
+	if err := GetJSONHeader(u, h, &p); err != nil {
+		return p, fmt.Errorf("semantic scholar: %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,58 @@
+package resolve
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ORCIDAPI is the public ORCID API
+const ORCIDAPI = "https://pub.orcid.org/v3.0/"
+
+// orcidRe matches an ORCID iD, the last digit may be a checksum X
+var orcidRe = regexp.MustCompile(`^\d{4}-\d{4}-\d{4}-\d{3}[\dX]$`)
+
+// CleanORCID strips the https://orcid.org/ prefix from an ORCID iD and
+// checks its form
+func CleanORCID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	for _, prefix := range []string{"https://orcid.org/", "http://orcid.org/", "orcid.org/"} {
+		id = strings.TrimPrefix(id, prefix)
+	}
+	id = strings.ToUpper(id)
+	if !orcidRe.MatchString(id) {
+		return "", fmt.Errorf("%q: %w", id, ErrInvalid)
+	}
+	return id, nil
+}
+
+// FetchORCIDWorks returns the DOIs of the works on an ORCID record
+func FetchORCIDWorks(id string) ([]string, error) {
+	id, err := CleanORCID(id)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Group []struct {
+			ExternalIDs struct {
+				ExternalID []struct {
+					Type  string `json:"external-id-type"`
+					Value string `json:"external-id-value"`
+				} `json:"external-id"`
+			} `json:"external-ids"`
+		} `json:"group"`
+	}
+	if err := GetJSON(ORCIDAPI+id+"/works", &res); err != nil {
+		return nil, fmt.Errorf("orcid: %w", err)
+	}
+	var dois []string
+	for _, g := range res.Group {
+		for _, x := range g.ExternalIDs.ExternalID {
+			if strings.EqualFold(x.Type, "doi") {
+				dois = append(dois, CleanDOI(x.Value))
+				break
+			}
+		}
+	}
+	return dois, nil
+}
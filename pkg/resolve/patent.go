@@ -0,0 +1,403 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// patent services
+const (
+	// OPSAPI is the Open Patent Services of the European Patent Office,
+	// which needs a consumer key from https://developers.epo.org
+	OPSAPI = "https://ops.epo.org/3.2/"
+	// GooglePatentsAPI is the search behind patents.google.com, asked
+	// without OPS credentials
+	GooglePatentsAPI = "https://patents.google.com/xhr/query"
+)
+
+// metadata sources of patents recorded in Work.Sources
+const (
+	SourceOPS           = "EPO OPS"
+	SourceGooglePatents = "Google Patents"
+)
+
+// Patent is a patent publication number like US 10,000,000 B2, EP 1234567
+// A1 or WO 2020/123456 A1. Kind is empty when the number was given
+// without one.
+type Patent struct {
+	Country, Number, Kind string
+}
+
+// patentPattern matches a publication number once spaces, also the
+// non-breaking ones of copied numbers, commas, periods, slashes and dashes
+// are removed
+var patentPattern = regexp.MustCompile(`^(US|EP|WO)(\d{5,11})([A-Z]\d?)?$`)
+
+// ParsePatent reads a US, EP or WO publication number
+func ParsePatent(s string) (Patent, bool) {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" ,./-\u00a0", r) {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(s)))
+	m := patentPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Patent{}, false
+	}
+	return Patent{m[1], m[2], m[3]}, true
+}
+
+// ID is the number without separators, like US10000000B2
+func (p Patent) ID() string {
+	return p.Country + p.Number + p.Kind
+}
+
+// String is the number as printed on the patent: the year of applications
+// published by WIPO and the USPTO split off, US grants in groups of three
+// digits
+func (p Patent) String() string {
+	n := p.Number
+	switch {
+	case p.Country != "EP" && len(n) >= 10 && (n[:2] == "19" || n[:2] == "20"):
+		n = n[:4] + "/" + n[4:]
+	case p.Country == "US":
+		for i := len(n) - 3; i > 0; i -= 3 {
+			n = n[:i] + "," + n[i:]
+		}
+	}
+	return strings.TrimSpace(p.Country + " " + n + " " + p.Kind)
+}
+
+// patentTypes are the biblatex type keys of patents by country
+var patentTypes = map[string]string{
+	"US": "patentus",
+	"EP": "patenteu",
+}
+
+// patentData is what the patent services tell about a publication. Dates
+// are ISO dates.
+type patentData struct {
+	Title     string
+	Inventors []string
+	Assignees []string
+	Filed     string
+	Published string
+	Kind      string
+	Language  string
+}
+
+// ResolvePatent looks up a patent at EPO OPS when credentials are given,
+// at Google Patents otherwise, and returns it as a patent entry. The
+// assignees are the holder, the publication date gives year and month and
+// the filing date is kept in filingdate.
+func ResolvePatent(p Patent, opts Options) (*Work, error) {
+	var d patentData
+	var err error
+	source := SourceGooglePatents
+	start := time.Now()
+	if opts.EPOKey != "" && opts.EPOSecret != "" {
+		source = SourceOPS
+		d, err = fetchOPS(p, opts.EPOKey, opts.EPOSecret)
+		recordLookup("ops", start, err)
+	} else {
+		d, err = fetchGooglePatents(p)
+		recordLookup("googlepatents", start, err)
+	}
+	logResolver(source, p.ID(), err)
+	if err != nil {
+		return nil, err
+	}
+	if p.Kind == "" {
+		p.Kind = d.Kind
+	}
+
+	e := bibtex.Entry{Type: "patent"}
+	e.Set("author", strings.Join(d.Inventors, " and "))
+	e.Set("title", d.Title)
+	e.Set("number", p.String())
+	e.Set("holder", strings.Join(d.Assignees, " and "))
+	e.Set("type", patentTypes[p.Country])
+	if year, rest, ok := strings.Cut(d.Published, "-"); ok {
+		e.Set("year", year)
+		if m, err := strconv.Atoi(rest[:min(2, len(rest))]); err == nil {
+			e.Set("month", strconv.Itoa(m))
+		}
+	}
+	e.Set("filingdate", d.Filed)
+	e.Set("language", bibtex.LanguageName(d.Language))
+	e.Set("url", "https://patents.google.com/patent/"+p.ID())
+	e.Key = bibtex.MakeKey(&e)
+
+	w := &Work{Entry: e, Citations: -1, Sources: map[string]string{}}
+	for _, f := range e.Fields {
+		w.Sources[f.Name] = source
+	}
+	return w, nil
+}
+
+// fetchGooglePatents finds a publication with the search of Google
+// Patents, which lists the first inventor and assignee
+func fetchGooglePatents(p Patent) (patentData, error) {
+	var res struct {
+		Results struct {
+			Cluster []struct {
+				Result []struct {
+					Patent struct {
+						PublicationNumber string `json:"publication_number"`
+						Title             string `json:"title"`
+						Inventor          string `json:"inventor"`
+						Assignee          string `json:"assignee"`
+						FilingDate        string `json:"filing_date"`
+						PublicationDate   string `json:"publication_date"`
+						Language          string `json:"language"`
+					} `json:"patent"`
+				} `json:"result"`
+			} `json:"cluster"`
+		} `json:"results"`
+	}
+	u := GooglePatentsAPI + "?" + url.Values{"url": {"q=" + p.ID()}, "exp": {""}}.Encode()
+	if err := GetJSON(u, &res); err != nil {
+		return patentData{}, fmt.Errorf("google patents: %w", err)
+	}
+	for _, c := range res.Results.Cluster {
+		for _, r := range c.Result {
+			g := r.Patent
+			// the search also finds the patents citing the number
+			if !strings.HasPrefix(g.PublicationNumber, p.Country+p.Number) {
+				continue
+			}
+			d := patentData{
+				Title:     stripTags(html.UnescapeString(g.Title)),
+				Filed:     g.FilingDate,
+				Published: g.PublicationDate,
+				Kind:      strings.TrimPrefix(g.PublicationNumber, p.Country+p.Number),
+				Language:  g.Language,
+			}
+			if g.Inventor != "" {
+				d.Inventors = []string{invertName(html.UnescapeString(g.Inventor))}
+			}
+			if g.Assignee != "" {
+				d.Assignees = []string{"{" + html.UnescapeString(g.Assignee) + "}"}
+			}
+			return d, nil
+		}
+	}
+	return patentData{}, fmt.Errorf("google patents: %s: %w", p, ErrNotFound)
+}
+
+// invertName turns "Given Family" into "Family, Given"
+func invertName(name string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, " "); i > 0 && !strings.Contains(name, ",") {
+		return name[i+1:] + ", " + name[:i]
+	}
+	return name
+}
+
+// opsList decodes the JSON OPS converts its XML to, which holds a single
+// element as an object and several as an array
+type opsList[T any] []T
+
+func (l *opsList[T]) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, (*[]T)(l))
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*l = opsList[T]{v}
+	return nil
+}
+
+// opsText is an XML text node
+type opsText struct {
+	Value string `json:"$"`
+}
+
+type opsDocumentID struct {
+	Type    string  `json:"@document-id-type"`
+	Country opsText `json:"country"`
+	Number  opsText `json:"doc-number"`
+	Kind    opsText `json:"kind"`
+	Date    opsText `json:"date"`
+}
+
+// opsParty is an applicant or an inventor
+type opsParty struct {
+	Format string
+	Name   string
+}
+
+// UnmarshalJSON reads the name of a party, whose element is named after
+// its role
+func (p *opsParty) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Format    string `json:"@data-format"`
+		Applicant struct {
+			Name opsText `json:"name"`
+		} `json:"applicant-name"`
+		Inventor struct {
+			Name opsText `json:"name"`
+		} `json:"inventor-name"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	p.Format, p.Name = v.Format, v.Applicant.Name.Value+v.Inventor.Name.Value
+	return nil
+}
+
+type opsBiblio struct {
+	World struct {
+		Documents struct {
+			Document opsList[struct {
+				Kind   string `json:"@kind"`
+				Biblio struct {
+					Publication struct {
+						IDs opsList[opsDocumentID] `json:"document-id"`
+					} `json:"publication-reference"`
+					Application struct {
+						IDs opsList[opsDocumentID] `json:"document-id"`
+					} `json:"application-reference"`
+					Titles opsList[struct {
+						Lang  string `json:"@lang"`
+						Value string `json:"$"`
+					}] `json:"invention-title"`
+					Parties struct {
+						Applicants struct {
+							Applicant opsList[opsParty] `json:"applicant"`
+						} `json:"applicants"`
+						Inventors struct {
+							Inventor opsList[opsParty] `json:"inventor"`
+						} `json:"inventors"`
+					} `json:"parties"`
+				} `json:"bibliographic-data"`
+			}] `json:"exchange-document"`
+		} `json:"exchange-documents"`
+	} `json:"ops:world-patent-data"`
+}
+
+// opsCountry matches the country code epodoc names end with
+var opsCountry = regexp.MustCompile(`\s*\[[A-Z]{2}\]$`)
+
+// fetchOPS reads the bibliographic data of a publication from EPO OPS
+func fetchOPS(p Patent, key, secret string) (patentData, error) {
+	// offline, answers come from the cache without a token
+	token := ""
+	if !Offline {
+		var err error
+		if token, err = opsToken(key, secret); err != nil {
+			return patentData{}, fmt.Errorf("ops: %w", err)
+		}
+	}
+	ref := "docdb/" + p.Country + "." + p.Number + "." + p.Kind
+	if p.Kind == "" {
+		ref = "epodoc/" + p.Country + p.Number
+	}
+	var res opsBiblio
+	u := OPSAPI + "rest-services/published-data/publication/" + ref + "/biblio"
+	if err := GetJSONHeader(u, http.Header{"Authorization": {"Bearer " + token}}, &res); err != nil {
+		return patentData{}, fmt.Errorf("ops: %w", err)
+	}
+	docs := res.World.Documents.Document
+	if len(docs) == 0 {
+		return patentData{}, fmt.Errorf("ops: %s: %w", p, ErrNotFound)
+	}
+	b := docs[0].Biblio
+	d := patentData{Kind: docs[0].Kind}
+	for _, t := range b.Titles {
+		if d.Title == "" || t.Lang == "en" {
+			d.Title, d.Language = t.Value, t.Lang
+		}
+	}
+	d.Published = opsDate(b.Publication.IDs)
+	d.Filed = opsDate(b.Application.IDs)
+	// the original spelling is preferred over the upper-cased epodoc one
+	names := func(parties opsList[opsParty], corporate bool) []string {
+		var original, epodoc []string
+		for _, party := range parties {
+			n := strings.TrimSuffix(opsCountry.ReplaceAllString(party.Name, ""), ",")
+			if corporate {
+				n = "{" + n + "}"
+			}
+			if party.Format == "original" {
+				original = append(original, n)
+			} else {
+				epodoc = append(epodoc, n)
+			}
+		}
+		if len(original) > 0 {
+			return original
+		}
+		return epodoc
+	}
+	d.Inventors = names(b.Parties.Inventors.Inventor, false)
+	d.Assignees = names(b.Parties.Applicants.Applicant, true)
+	return d, nil
+}
+
+// opsDate returns the date of the docdb document id as an ISO date
+func opsDate(ids opsList[opsDocumentID]) string {
+	for _, id := range ids {
+		if d := id.Date.Value; len(d) == 8 && (id.Type == "docdb" || id.Type == "epodoc") {
+			return d[:4] + "-" + d[4:6] + "-" + d[6:]
+		}
+	}
+	return ""
+}
+
+var (
+	opsMu sync.Mutex
+	// opsAccess is the current access token of OPS and when it expires
+	opsAccess struct {
+		token   string
+		expires time.Time
+	}
+)
+
+// opsToken returns an access token for OPS, asking for a new one with the
+// consumer key and secret when the last has expired
+func opsToken(key, secret string) (string, error) {
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	if opsAccess.token != "" && time.Now().Before(opsAccess.expires) {
+		return opsAccess.token, nil
+	}
+	req, err := http.NewRequest(http.MethodPost, OPSAPI+"auth/accesstoken", strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(key, secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close() // nolint:errcheck
+	if res.StatusCode != http.StatusOK {
+		return "", &HTTPError{OPSAPI + "auth/accesstoken", res.Status}
+	}
+	var t struct {
+		Token     string `json:"access_token"`
+		ExpiresIn string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	secs, _ := strconv.Atoi(t.ExpiresIn)
+	// a token is renewed a minute before OPS lets it expire
+	opsAccess.token, opsAccess.expires = t.Token, time.Now().Add(time.Duration(secs)*time.Second-time.Minute)
+	return t.Token, nil
+}
@@ -0,0 +1,149 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pluginTimeout bounds a single plugin run
+const pluginTimeout = 30 * time.Second
+
+// Plugin is an executable resolver, for services like an institutional
+// repository. It reads a PluginRequest as JSON on stdin and writes a
+// PluginResponse as JSON to stdout. A failing plugin exits with a non-zero
+// status and explains why on stderr; one that knows nothing about the DOI
+// answers {}.
+type Plugin struct {
+	// Name is the file name without extension, used in the resolvers
+	// setting and as the source of the fields it fills
+	Name string
+	Path string
+}
+
+// PluginRequest is what a plugin is asked about
+type PluginRequest struct {
+	DOI   string `json:"doi"`
+	Entry struct {
+		Type string `json:"type"`
+		Key  string `json:"key"`
+		// Fields are those the resolvers before the plugin found
+		Fields map[string]string `json:"fields"`
+	} `json:"entry"`
+}
+
+// PluginResponse is a plugin's answer. Like every enricher, it only fills
+// in what earlier resolvers left empty.
+type PluginResponse struct {
+	Fields    map[string]string `json:"fields"`
+	Abstract  string            `json:"abstract"`
+	Citations *int              `json:"citations"`
+	OAStatus  string            `json:"oa_status"`
+	OAURL     string            `json:"oa_url"`
+	PDFURL    string            `json:"pdf_url"`
+}
+
+// FindPlugins lists the executables in dir. A missing directory has none.
+func FindPlugins(dir string) ([]Plugin, error) {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Plugin
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())))
+		out = append(out, Plugin{Name: name, Path: filepath.Join(dir, f.Name())})
+	}
+	return out, nil
+}
+
+// RegisterPlugin adds a plugin to Enrichers under its name. Names of
+// resolvers already registered are refused.
+func RegisterPlugin(p Plugin) error {
+	if _, ok := Enrichers[p.Name]; ok {
+		return fmt.Errorf("plugin %s: resolver %s already exists", p.Path, p.Name)
+	}
+	Enrichers[p.Name] = p.enrich
+	return nil
+}
+
+func (p Plugin) enrich(w *Work, doi string, opts Options) error {
+	if Offline {
+		return fmt.Errorf("%s: %w", p.Name, ErrOffline)
+	}
+	var req PluginRequest
+	req.DOI = doi
+	req.Entry.Type, req.Entry.Key = w.Entry.Type, w.Entry.Key
+	req.Entry.Fields = map[string]string{}
+	for _, f := range w.Entry.Fields {
+		req.Entry.Fields[f.Name] = f.Value
+	}
+	res, err := p.run(req)
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(res.Fields)) {
+		w.fill(strings.ToLower(name), res.Fields[name], p.Name)
+	}
+	if w.Abstract == "" && res.Abstract != "" {
+		w.Abstract = res.Abstract
+		w.Sources["abstract"] = p.Name
+	}
+	if w.Citations < 0 && res.Citations != nil {
+		w.Citations = *res.Citations
+		w.Sources["citations"] = p.Name
+	}
+	if w.OAStatus == "" && res.OAStatus != "" {
+		w.OpenAccess = res.OAStatus != "closed"
+		w.OAStatus, w.OAURL = res.OAStatus, res.OAURL
+		w.Sources["oa"] = p.Name
+	}
+	if w.PDFURL == "" && res.PDFURL != "" {
+		w.PDFURL = res.PDFURL
+		w.Sources["pdf"] = p.Name
+	}
+	return nil
+}
+
+// run executes the plugin once
+func (p Plugin) run(req PluginRequest) (PluginResponse, error) {
+	var res PluginResponse
+	in, err := json.Marshal(req)
+	if err != nil {
+		return res, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(in)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return res, fmt.Errorf("%s: %s", p.Name, msg)
+		}
+		return res, fmt.Errorf("%s: %w", p.Name, err)
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return res, fmt.Errorf("%s: invalid response: %w", p.Name, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,59 @@
+package resolve
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// rateLimits is the minimum spacing of requests to each API host, staying
+// below the documented limits when fetching concurrently
+var rateLimits = map[string]time.Duration{
+	"api.crossref.org":           25 * time.Millisecond,
+	"api.openalex.org":           100 * time.Millisecond,
+	"api.unpaywall.org":          100 * time.Millisecond,
+	"api.semanticscholar.org":    time.Second,
+	"pub.orcid.org":              50 * time.Millisecond,
+	"api.datacite.org":           100 * time.Millisecond,
+	"api.github.com":             time.Second,
+	"doi.org":                    100 * time.Millisecond,
+	"ops.epo.org":                200 * time.Millisecond,
+	"patents.google.com":         time.Second,
+	"api.archives-ouvertes.fr":   100 * time.Millisecond,
+	"www.proquest.com":           time.Second,
+	"www.dart-europe.org":        time.Second,
+	"api.notion.com":             350 * time.Millisecond,
+	"api.airtable.com":           200 * time.Millisecond,
+	"openlibrary.org":            350 * time.Millisecond,
+	"www.googleapis.com":         100 * time.Millisecond,
+	"api.eventdata.crossref.org": 100 * time.Millisecond,
+}
+
+var (
+	limitMu sync.Mutex
+	// nextSlot is the earliest time the next request to a host may start
+	nextSlot = map[string]time.Time{}
+)
+
+// waitTurn blocks until a request to host is allowed, by its configured
+// rate limit and the limits its responses told
+func waitTurn(host string) {
+	limitMu.Lock()
+	now := time.Now()
+	gap := max(rateLimits[host], quotaGap(host, now))
+	if gap == 0 {
+		limitMu.Unlock()
+		return
+	}
+	slot := nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	nextSlot[host] = slot.Add(gap)
+	limitMu.Unlock()
+	if d := time.Until(slot); d > 0 {
+		slog.Debug("rate limited", "host", host, "wait", d)
+		record(func(t *Timings) { t.throttled += d })
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,376 @@
+// Package resolve looks up DOIs at CrossRef, or DataCite for datasets and
+// software, and completes the records with open-access, citation and
+// abstract data of further services.
+package resolve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+var (
+	// ErrNotFound is returned when a resolver has no record for an identifier
+	ErrNotFound = errors.New("identifier not found")
+	// ErrInvalid is returned for input that cannot be an identifier
+	ErrInvalid = errors.New("invalid identifier")
+)
+
+// HTTPError is an unexpected response status from an API
+type HTTPError struct {
+	URL    string
+	Status string
+}
+
+func (e *HTTPError) Error() string {
+	return RedactURL(e.URL) + ": " + e.Status
+}
+
+// metadata sources recorded in Work.Sources
+const (
+	SourceCrossref        = "CrossRef"
+	SourceOpenAlex        = "OpenAlex"
+	SourceUnpaywall       = "Unpaywall"
+	SourceSemanticScholar = "Semantic Scholar"
+	SourceDataCite        = "DataCite"
+	// SourceDetected marks a language guessed from the title
+	SourceDetected = "detected"
+)
+
+// Work is a resolved entry together with metadata that is shown to the
+// user but not written to the library
+type Work struct {
+	Entry      bibtex.Entry
+	Abstract   string
+	Citations  int
+	OpenAccess bool
+	OAStatus   string
+	OAURL      string
+	// PDFURL is a direct link to an open-access PDF reported by Unpaywall
+	PDFURL string
+	// Sources maps field names, and the pseudo fields abstract, citations,
+	// oa and pdf, to the service that supplied them
+	Sources map[string]string
+}
+
+// fill sets a field that is still missing and records where it came from
+func (w *Work) fill(name, value, source string) {
+	if value == "" || w.Entry.Get(name) != "" {
+		return
+	}
+	w.Entry.Set(name, value)
+	w.Sources[name] = source
+}
+
+// Options select the enrichment resolvers and their credentials
+type Options struct {
+	// Email is sent to Unpaywall, which refuses anonymous requests
+	Email string
+	// Resolvers lists the enrichment services in the order they are asked
+	Resolvers          []string
+	OpenAlexKey        string
+	SemanticScholarKey string
+	// EPOKey and EPOSecret are the OPS consumer credentials patents are
+	// looked up with, Google Patents is asked without them
+	EPOKey, EPOSecret string
+	// Venues normalize the booktitle of proceedings, nil means
+	// bibtex.DefaultVenues
+	Venues []bibtex.Venue
+	// Romanize records romanized forms of Cyrillic, Greek and Korean names
+	Romanize bool
+}
+
+// DefaultResolvers is the enrichment chain used without configuration
+var DefaultResolvers = []string{"openalex", "unpaywall", "semanticscholar"}
+
+// Enrichers maps resolver names of the configuration to their implementation.
+// An enricher only fills in what earlier resolvers left empty.
+var Enrichers = map[string]func(w *Work, doi string, opts Options) error{
+	"openalex":        enrichOpenAlex,
+	"unpaywall":       enrichUnpaywall,
+	"semanticscholar": enrichSemanticScholar,
+}
+
+// Resolve fetches the bibliographic record for a DOI from CrossRef and
+// enriches it with citation and open-access data. Enrichment is best effort.
+// Patent numbers are resolved by ResolvePatent, thesis identifiers by
+// ResolveThesis and arXiv identifiers by the DOI arXiv registered.
+func Resolve(doi string, opts Options) (*Work, error) {
+	if p, ok := ParsePatent(doi); ok {
+		return detectLanguage(ResolvePatent(p, opts))
+	}
+	if t, ok := ParseThesis(doi); ok {
+		return detectLanguage(ResolveThesis(t))
+	}
+	if d, ok := ArXivDOI(doi); ok {
+		doi = d
+	}
+	if clean := CleanDOI(doi); clean != strings.TrimSpace(doi) {
+		slog.Info("cleaned DOI", "from", doi, "to", clean)
+		doi = clean
+	}
+	if err := ValidateDOI(doi); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	entry, abstract, err := FetchCrossref(doi)
+	recordLookup("crossref", start, err)
+	logResolver(SourceCrossref, doi, err)
+	source := SourceCrossref
+	if errors.Is(err, ErrNotFound) {
+		// datasets and software are mostly registered with DataCite
+		start := time.Now()
+		entry, abstract, err = FetchDataCite(doi)
+		recordLookup("datacite", start, err)
+		logResolver(SourceDataCite, doi, err)
+		source = SourceDataCite
+	}
+	if errors.Is(err, ErrNotFound) {
+		if near := SuggestDOIs(doi); len(near) > 0 {
+			err = fmt.Errorf("%w, did you mean %s?", err, strings.Join(near, " or "))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	w := &Work{Entry: entry, Citations: -1, Sources: map[string]string{}}
+	for _, f := range entry.Fields {
+		w.Sources[f.Name] = source
+	}
+	if abstract != "" {
+		w.Abstract = abstract
+		w.Sources["abstract"] = source
+	}
+
+	chain := opts.Resolvers
+	if chain == nil {
+		chain = DefaultResolvers
+	}
+	for _, name := range chain {
+		enrich, ok := Enrichers[name]
+		if !ok {
+			slog.Warn("unknown resolver", "resolver", name)
+			continue
+		}
+		start := time.Now()
+		if err := enrich(w, doi, opts); err != ErrSkipped {
+			recordLookup(name, start, err)
+			logResolver(name, doi, err)
+		}
+	}
+	venues := opts.Venues
+	if venues == nil {
+		venues = bibtex.DefaultVenues
+	}
+	bibtex.NormalizeVenue(&w.Entry, venues)
+	w.fill("language", bibtex.DetectLanguage(w.Entry.Get("title")), SourceDetected)
+	if opts.Romanize {
+		for _, field := range []string{"author", "editor"} {
+			w.Entry.RomanizeNames(field)
+		}
+	}
+	return w, nil
+}
+
+// ErrSkipped is returned by enrichers that had nothing to do
+var ErrSkipped = errors.New("skipped")
+
+func enrichOpenAlex(w *Work, doi string, opts Options) error {
+	oa, err := fetchOpenAlex(doi, opts.OpenAlexKey)
+	if err != nil {
+		return err
+	}
+	if w.Citations < 0 {
+		w.Citations = oa.CitedByCount
+		w.Sources["citations"] = SourceOpenAlex
+	}
+	if w.OAStatus == "" {
+		w.OpenAccess = oa.OpenAccess.IsOA
+		w.OAStatus = oa.OpenAccess.OAStatus
+		w.OAURL = oa.OpenAccess.OAURL
+		w.Sources["oa"] = SourceOpenAlex
+	}
+	if w.Abstract == "" {
+		if w.Abstract = oa.abstract(); w.Abstract != "" {
+			w.Sources["abstract"] = SourceOpenAlex
+		}
+	}
+	oa.fill(w)
+	return nil
+}
+
+func enrichUnpaywall(w *Work, doi string, opts Options) error {
+	if opts.Email == "" {
+		return ErrSkipped
+	}
+	up, err := FetchUnpaywall(doi, opts.Email)
+	if err != nil {
+		return err
+	}
+	if w.PDFURL = up.PDFURL(); w.PDFURL != "" {
+		w.Sources["pdf"] = SourceUnpaywall
+	}
+	if w.OAStatus == "" {
+		w.OpenAccess = up.IsOA
+		w.OAStatus = up.OAStatus
+		w.Sources["oa"] = SourceUnpaywall
+	}
+	return nil
+}
+
+func enrichSemanticScholar(w *Work, doi string, opts Options) error {
+	if w.Citations >= 0 && w.Abstract != "" {
+		return ErrSkipped
+	}
+	s2, err := fetchSemanticScholar(doi, opts.SemanticScholarKey)
+	if err != nil {
+		return err
+	}
+	if w.Citations < 0 {
+		w.Citations = s2.CitationCount
+		w.Sources["citations"] = SourceSemanticScholar
+	}
+	if w.Abstract == "" && s2.Abstract != "" {
+		w.Abstract = s2.Abstract
+		w.Sources["abstract"] = SourceSemanticScholar
+	}
+	return nil
+}
+
+// logResolver records the outcome of asking one resolver about a DOI
+func logResolver(source, doi string, err error) {
+	if err != nil {
+		slog.Info("resolver failed", "resolver", source, "doi", doi, "err", err)
+		return
+	}
+	slog.Info("resolved", "resolver", source, "doi", doi)
+}
+
+// GetJSON performs a GET request and decodes the JSON response into v.
+// Successful responses are cached.
+func GetJSON(u string, v any) error {
+	return GetJSONHeader(u, nil, v)
+}
+
+// GetJSONHeader is GetJSON with extra request headers, e.g. API keys
+func GetJSONHeader(u string, h http.Header, v any) error {
+	return getCached(u, h, "application/json", func(data []byte) error {
+		return json.Unmarshal(data, v)
+	})
+}
+
+// GetPage fetches a web page, like the record of a catalog, through the
+// cache
+func GetPage(u string) ([]byte, error) {
+	var page []byte
+	err := getCached(u, nil, "text/html", func(data []byte) error {
+		page = data
+		return nil
+	})
+	return page, err
+}
+
+// getCached performs a GET request and hands the body to decode. Bodies
+// decode accepts are cached.
+func getCached(u string, h http.Header, accept string, decode func([]byte) error) error {
+	if data, ok := cacheGet(u); ok {
+		record(func(t *Timings) { t.cacheHits++ })
+		return decode(data)
+	}
+	record(func(t *Timings) { t.cacheMisses++ })
+	if Offline {
+		return fmt.Errorf("%s: %w", RedactURL(u), ErrOffline)
+	}
+	var res *http.Response
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		for k, vs := range h {
+			req.Header[k] = vs
+		}
+		req.Header.Set("Accept", accept)
+		start := time.Now()
+		res, err = Client.Do(req)
+		if err != nil {
+			err = RedactError(err)
+			slog.Info("request failed", "url", u, "err", err)
+			return err
+		}
+		slog.Debug("response", "url", u, "status", res.StatusCode, "duration", time.Since(start))
+		wait, ok := retryAfter(res, attempt)
+		if !ok {
+			break
+		}
+		res.Body.Close() // nolint:errcheck
+		record(func(t *Timings) { t.retries++ })
+		slog.Info("retrying", "url", u, "status", res.Status, "wait", wait)
+		time.Sleep(wait)
+	}
+	defer res.Body.Close() // nolint:errcheck
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return &HTTPError{u, res.Status}
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := decode(data); err != nil {
+		return err
+	}
+	cachePut(u, data)
+	return nil
+}
+
+// maxRetries is how often a request is repeated after a rate limit or a
+// server error
+const maxRetries = 2
+
+// retryAfter reports whether a response asks for the request to be repeated
+// and how long to wait first, as told by Retry-After or doubling from one
+// second
+func retryAfter(res *http.Response, attempt int) (time.Duration, bool) {
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	default:
+		return 0, false
+	}
+	if attempt >= maxRetries {
+		return 0, false
+	}
+	wait := time.Second << attempt
+	if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s >= 0 {
+		wait = min(time.Duration(s)*time.Second, 10*time.Second)
+	}
+	return wait, true
+}
+
+var tagRe = regexp.MustCompile(`<[^>]+>`)
+
+// stripTags removes JATS/HTML markup and collapses whitespace
+func stripTags(s string) string {
+	return strings.Join(strings.Fields(tagRe.ReplaceAllString(s, " ")), " ")
+}
+
+// detectLanguage fills the language of a resolved work from its title
+func detectLanguage(w *Work, err error) (*Work, error) {
+	if err != nil {
+		return nil, err
+	}
+	w.fill("language", bibtex.DetectLanguage(w.Entry.Get("title")), SourceDetected)
+	return w, nil
+}
@@ -0,0 +1,154 @@
+package resolve
+
+import (
+	"cmp"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hostLoad is what the responses of an API host told about it
+type hostLoad struct {
+	// latency is the moving average of the time to the response headers
+	latency time.Duration
+	// remaining is the quota left until reset, -1 when the host does not
+	// say; interval is the spacing its advertised rate limit asks for
+	remaining int
+	reset     time.Time
+	interval  time.Duration
+}
+
+// loads are the observed hosts, guarded by limitMu
+var loads = map[string]*hostLoad{}
+
+// A quota running low, below quotaReserve percent of it or quotaFloor
+// requests when the host does not tell its size, has the requests left
+// spread over the time until it resets
+const (
+	quotaReserve = 10
+	quotaFloor   = 50
+)
+
+// maxQuotaWait is how long a request waits for an exhausted quota to reset
+// before it is sent anyway, to be refused or retried after Retry-After
+const maxQuotaWait = time.Minute
+
+// observe records the latency and rate limit headers of a response of host,
+// d after the request was sent. A failed request counts its d as latency.
+func observe(host string, res *http.Response, d time.Duration) {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	l := loads[host]
+	if l == nil {
+		l = &hostLoad{latency: d, remaining: -1}
+		loads[host] = l
+	}
+	l.latency = (3*l.latency + d) / 4
+	if res == nil {
+		return
+	}
+	now := time.Now()
+	limit := headerInt(res.Header, "X-RateLimit-Limit", "RateLimit-Limit", "X-Rate-Limit-Limit")
+	if s := res.Header.Get("X-Rate-Limit-Interval"); s != "" && limit > 0 {
+		// CrossRef: so many requests per interval
+		if iv, err := time.ParseDuration(s); err == nil {
+			l.interval = iv / time.Duration(limit)
+		}
+	}
+	if r := headerInt(res.Header, "X-RateLimit-Remaining", "RateLimit-Remaining", "X-Rate-Limit-Remaining"); r >= 0 {
+		l.remaining = r
+		if limit > 0 && r*100 >= limit*quotaReserve || limit <= 0 && r >= quotaFloor {
+			// plenty left, nothing to spread
+			l.remaining = -1
+		}
+		l.reset = time.Time{}
+		if s := headerInt(res.Header, "X-RateLimit-Reset", "RateLimit-Reset"); s >= 0 {
+			// seconds until the reset, or GitHub's Unix time of it
+			if s > 1e9 {
+				l.reset = time.Unix(int64(s), 0)
+			} else {
+				l.reset = now.Add(time.Duration(s) * time.Second)
+			}
+		}
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		l.remaining = 0
+		l.reset = now.Add(time.Second)
+		if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s >= 0 {
+			l.reset = now.Add(time.Duration(s) * time.Second)
+		}
+	}
+}
+
+// headerInt returns the first of the headers that is set, read up to the
+// first non-digit since some list several windows like "100, 100;w=60",
+// -1 when none is
+func headerInt(h http.Header, names ...string) int {
+	for _, name := range names {
+		s := strings.TrimSpace(h.Get(name))
+		if end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+			s = s[:end]
+		}
+		if n, err := strconv.Atoi(s); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+// quotaGap returns the spacing the observed limits of a host ask for on top
+// of its configured one: the advertised rate, and the time until the reset
+// shared by the requests left of a quota running low. Called under limitMu.
+func quotaGap(host string, now time.Time) time.Duration {
+	l := loads[host]
+	if l == nil {
+		return 0
+	}
+	gap := l.interval
+	if l.remaining >= 0 && l.reset.After(now) {
+		until := l.reset.Sub(now)
+		if l.remaining == 0 {
+			return max(gap, min(until, maxQuotaWait))
+		}
+		gap = max(gap, until/time.Duration(l.remaining))
+	}
+	return gap
+}
+
+// Rank orders APIs that can answer the same request, given by their base
+// URLs like OpenAlexAPI, by how soon each is expected to: the wait for its
+// rate limit and quota plus its observed latency, so the requests of a
+// batch spread over them and none runs out of quota. APIs with nothing to
+// tell them apart keep their order, the first is the preferred one. It
+// returns the positions.
+func Rank(apis ...string) []int {
+	now := time.Now()
+	cost := make([]time.Duration, len(apis))
+	limitMu.Lock()
+	for i, api := range apis {
+		h := api
+		if u, err := url.Parse(api); err == nil && u.Host != "" {
+			h = u.Host
+		}
+		if slot := nextSlot[h]; slot.After(now) {
+			cost[i] = slot.Sub(now)
+		}
+		cost[i] += quotaGap(h, now)
+		if l := loads[h]; l != nil {
+			cost[i] += l.latency
+		}
+	}
+	limitMu.Unlock()
+	order := make([]int, len(apis))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(cost[a], cost[b]) })
+	if len(order) > 0 && order[0] != 0 {
+		record(func(t *Timings) { t.rescheduled++ })
+	}
+	return order
+}
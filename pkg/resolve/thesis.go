@@ -0,0 +1,253 @@
+package resolve
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// thesis catalogs
+const (
+	// HALAPI searches HAL, the French open archive whose TEL and DUMAS
+	// portals hold doctoral and master theses
+	HALAPI = "https://api.archives-ouvertes.fr/search/"
+	// ProQuestURL serves the records of ProQuest Dissertations & Theses
+	ProQuestURL = "https://www.proquest.com/docview/"
+	// DARTEuropeURL serves the records of DART-Europe, the portal of
+	// European research theses
+	DARTEuropeURL = "https://www.dart-europe.org/full.php?id="
+)
+
+// metadata sources of theses recorded in Work.Sources
+const (
+	SourceHAL        = "HAL"
+	SourceProQuest   = "ProQuest"
+	SourceDARTEurope = "DART-Europe"
+)
+
+// Thesis is the identifier of a thesis in one of the catalogs
+type Thesis struct {
+	// Catalog is SourceHAL, SourceProQuest or SourceDARTEurope
+	Catalog string
+	ID      string
+}
+
+// thesis identifiers, bare or as a catalog prefix or URL
+var (
+	halID      = regexp.MustCompile(`^(?:https?://(?:[a-z]+\.)?(?:archives-ouvertes|hal\.science)[^/]*/)?((?:tel|dumas|hal)-\d{8})(?:v\d+)?/?$`)
+	proquestID = regexp.MustCompile(`^(?:proquest:\s*|https?://(?:www\.)?proquest\.com/(?:[a-z-]+/)*docview/)(\d+)`)
+	dartID     = regexp.MustCompile(`^(?:dart:\s*|https?://(?:www\.)?dart-europe\.(?:org|eu)/full\.php\?id=)(\d+)`)
+)
+
+// ParseThesis reads a HAL identifier like tel-01234567, proquest:12345678
+// or dart:123456, each also as the URL of its record
+func ParseThesis(s string) (Thesis, bool) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	if m := halID.FindStringSubmatch(lower); m != nil {
+		return Thesis{SourceHAL, m[1]}, true
+	}
+	if m := proquestID.FindStringSubmatch(lower); m != nil {
+		return Thesis{SourceProQuest, m[1]}, true
+	}
+	if m := dartID.FindStringSubmatch(lower); m != nil {
+		return Thesis{SourceDARTEurope, m[1]}, true
+	}
+	return Thesis{}, false
+}
+
+// thesisData is what a catalog tells about a thesis
+type thesisData struct {
+	Masters     bool
+	Degree      string
+	Authors     []string
+	Title       string
+	Institution string
+	Date        string
+	Language    string
+	DOI, URL    string
+	Abstract    string
+}
+
+// ResolveThesis looks up a thesis in its catalog and returns it as a
+// phdthesis or mastersthesis entry with the degree in type, the granting
+// institution in school and the date of the defense
+func ResolveThesis(t Thesis) (*Work, error) {
+	var d thesisData
+	var err error
+	start := time.Now()
+	switch t.Catalog {
+	case SourceHAL:
+		d, err = fetchHAL(t.ID)
+	case SourceProQuest:
+		d, err = fetchThesisPage(ProQuestURL + t.ID)
+	default:
+		d, err = fetchThesisPage(DARTEuropeURL + t.ID)
+	}
+	recordLookup(strings.ToLower(t.Catalog), start, err)
+	logResolver(t.Catalog, t.ID, err)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.ToLower(t.Catalog), err)
+	}
+
+	e := bibtex.Entry{Type: "phdthesis"}
+	if d.Masters {
+		e.Type = "mastersthesis"
+	}
+	e.Set("author", strings.Join(d.Authors, " and "))
+	e.Set("title", d.Title)
+	e.Set("school", d.Institution)
+	e.Set("type", d.Degree)
+	if year, rest, _ := strings.Cut(d.Date, "-"); len(year) == 4 {
+		e.Set("year", year)
+		if m, _, _ := strings.Cut(rest, "-"); m != "" {
+			e.Set("month", strings.TrimPrefix(m, "0"))
+		}
+	}
+	e.Set("language", bibtex.LanguageName(d.Language))
+	e.Set("doi", CleanDOI(d.DOI))
+	e.Set("url", d.URL)
+	e.Key = bibtex.MakeKey(&e)
+
+	w := &Work{Entry: e, Abstract: d.Abstract, Citations: -1, Sources: map[string]string{}}
+	for _, f := range e.Fields {
+		w.Sources[f.Name] = t.Catalog
+	}
+	if d.Abstract != "" {
+		w.Sources["abstract"] = t.Catalog
+	}
+	return w, nil
+}
+
+// halDegrees are the HAL document types of theses and their degree
+var halDegrees = map[string]string{
+	"THESE": "PhD thesis",
+	"HDR":   "Habilitation thesis",
+	"MEM":   "Master's thesis",
+}
+
+// fetchHAL looks up a thesis in HAL by its identifier
+func fetchHAL(id string) (thesisData, error) {
+	q := url.Values{
+		"q":  {`halId_s:"` + id + `"`},
+		"fl": {"docType_s,title_s,authFirstName_s,authLastName_s,producedDate_s,authorityInstitution_s,instStructName_s,language_s,doiId_s,uri_s,abstract_s"},
+	}
+	var res struct {
+		Response struct {
+			Docs []struct {
+				DocType     string   `json:"docType_s"`
+				Title       []string `json:"title_s"`
+				FirstNames  []string `json:"authFirstName_s"`
+				LastNames   []string `json:"authLastName_s"`
+				Produced    string   `json:"producedDate_s"`
+				Authority   []string `json:"authorityInstitution_s"`
+				Institution []string `json:"instStructName_s"`
+				Language    []string `json:"language_s"`
+				DOI         string   `json:"doiId_s"`
+				URI         string   `json:"uri_s"`
+				Abstract    []string `json:"abstract_s"`
+			} `json:"docs"`
+		} `json:"response"`
+	}
+	if err := GetJSON(HALAPI+"?"+q.Encode(), &res); err != nil {
+		return thesisData{}, err
+	}
+	if len(res.Response.Docs) == 0 {
+		return thesisData{}, fmt.Errorf("%s: %w", id, ErrNotFound)
+	}
+	doc := res.Response.Docs[0]
+	d := thesisData{
+		// DUMAS only holds master theses, typed as memos or not at all
+		Masters:     doc.DocType == "MEM" || strings.HasPrefix(id, "dumas-"),
+		Degree:      halDegrees[doc.DocType],
+		Title:       first(doc.Title),
+		Institution: first(doc.Authority),
+		Date:        doc.Produced,
+		Language:    first(doc.Language),
+		DOI:         doc.DOI,
+		URL:         doc.URI,
+		Abstract:    stripTags(first(doc.Abstract)),
+	}
+	if d.Masters {
+		d.Degree = halDegrees["MEM"]
+	}
+	if d.Institution == "" {
+		d.Institution = first(doc.Institution)
+	}
+	for i, last := range doc.LastNames {
+		if i < len(doc.FirstNames) && doc.FirstNames[i] != "" {
+			last += ", " + doc.FirstNames[i]
+		}
+		d.Authors = append(d.Authors, last)
+	}
+	return d, nil
+}
+
+// metaTag matches the meta tags of a page, with the name before or after
+// the content
+var (
+	metaTag     = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaName    = regexp.MustCompile(`(?is)\b(?:name|property)\s*=\s*["']([^"']+)["']`)
+	metaContent = regexp.MustCompile(`(?is)\bcontent\s*=\s*["']([^"']*)["']`)
+)
+
+// metaTags returns the contents of the meta tags of a page by lowercase
+// name, in the order they appear
+func metaTags(page []byte) map[string][]string {
+	tags := map[string][]string{}
+	for _, tag := range metaTag.FindAll(page, -1) {
+		name, content := metaName.FindSubmatch(tag), metaContent.FindSubmatch(tag)
+		if name == nil || content == nil {
+			continue
+		}
+		if v := strings.TrimSpace(html.UnescapeString(string(content[1]))); v != "" {
+			n := strings.ToLower(string(name[1]))
+			tags[n] = append(tags[n], v)
+		}
+	}
+	return tags
+}
+
+// mastersDegree matches the names of master degrees
+var mastersDegree = regexp.MustCompile(`(?i)^(m\.?\s?[a-z]{1,3}\.?|master|magister|diplom)`)
+
+// fetchThesisPage reads a thesis from the Google Scholar citation_* tags of
+// its record page, or its Dublin Core DC.* tags
+func fetchThesisPage(u string) (thesisData, error) {
+	page, err := GetPage(u)
+	if err != nil {
+		return thesisData{}, err
+	}
+	tags := metaTags(page)
+	get := func(names ...string) []string {
+		for _, n := range names {
+			if v := tags[n]; len(v) > 0 {
+				return v
+			}
+		}
+		return nil
+	}
+	d := thesisData{
+		Title:       first(get("citation_title", "dc.title")),
+		Institution: first(get("citation_dissertation_institution", "citation_publisher", "dc.publisher")),
+		Degree:      first(get("citation_dissertation_name", "dc.type.degree")),
+		Date:        strings.ReplaceAll(first(get("citation_publication_date", "citation_date", "dc.date.issued", "dc.date")), "/", "-"),
+		Language:    first(get("citation_language", "dc.language")),
+		DOI:         first(get("citation_doi", "dc.identifier.doi")),
+		URL:         u,
+		Abstract:    stripTags(first(get("citation_abstract", "dc.description.abstract", "dc.description"))),
+	}
+	if d.Title == "" {
+		return thesisData{}, fmt.Errorf("%s: no thesis metadata: %w", u, ErrNotFound)
+	}
+	d.Masters = mastersDegree.MatchString(d.Degree)
+	for _, a := range get("citation_author", "dc.creator") {
+		d.Authors = append(d.Authors, invertName(a))
+	}
+	return d, nil
+}
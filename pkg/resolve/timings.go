@@ -0,0 +1,124 @@
+package resolve
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ResolverTiming sums up the lookups of one resolver
+type ResolverTiming struct {
+	Name     string
+	Lookups  int
+	Failures int
+	Total    time.Duration
+	Max      time.Duration
+}
+
+// Mean is the average duration of a lookup
+func (r ResolverTiming) Mean() time.Duration {
+	if r.Lookups == 0 {
+		return 0
+	}
+	return r.Total / time.Duration(r.Lookups)
+}
+
+// Timings records how the resolvers of a run performed
+type Timings struct {
+	mu        sync.Mutex
+	resolvers map[string]*ResolverTiming
+	// cacheHits and cacheMisses count the responses GetJSON answered from
+	// the cache and those it had to request
+	cacheHits, cacheMisses int
+	// retries counts requests repeated after a rate limit or server error
+	retries int
+	// throttled is the time requests waited for the rate limits
+	throttled time.Duration
+	// connsOpened and connsReused count the connections requests got, new
+	// ones and those kept open by earlier requests; http2 counts the
+	// responses that came over HTTP/2
+	connsOpened, connsReused, http2 int
+	// rescheduled counts the requests Rank sent to another API than the
+	// preferred one, because that was busier
+	rescheduled int
+}
+
+// recorder collects the timings of the run, nil unless RecordTimings was
+// called
+var recorder *Timings
+
+// RecordTimings starts recording and returns the timings of the lookups
+// that follow
+func RecordTimings() *Timings {
+	recorder = &Timings{resolvers: map[string]*ResolverTiming{}}
+	return recorder
+}
+
+// Resolvers returns the timing of each resolver asked, slowest in total
+// first
+func (t *Timings) Resolvers() []ResolverTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]ResolverTiming, 0, len(t.resolvers))
+	for _, r := range t.resolvers {
+		out = append(out, *r)
+	}
+	slices.SortFunc(out, func(a, b ResolverTiming) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Name, b.Name))
+	})
+	return out
+}
+
+// Counts returns the cache hits and misses, the retried requests and the
+// time spent waiting for rate limits
+func (t *Timings) Counts() (hits, misses, retries int, throttled time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cacheHits, t.cacheMisses, t.retries, t.throttled
+}
+
+// Connections returns how many requests opened a connection, how many
+// reused one and how many were answered over HTTP/2
+func (t *Timings) Connections() (opened, reused, http2 int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connsOpened, t.connsReused, t.http2
+}
+
+// Rescheduled returns how many requests went to another API than the
+// preferred one
+func (t *Timings) Rescheduled() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rescheduled
+}
+
+// record runs fn under the lock when timings are recorded
+func record(fn func(t *Timings)) {
+	t := recorder
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(t)
+}
+
+// recordLookup adds a lookup of a resolver that started at start
+func recordLookup(name string, start time.Time, err error) {
+	d := time.Since(start)
+	record(func(t *Timings) {
+		r := t.resolvers[name]
+		if r == nil {
+			r = &ResolverTiming{Name: name}
+			t.resolvers[name] = r
+		}
+		r.Lookups++
+		if err != nil {
+			r.Failures++
+		}
+		r.Total += d
+		r.Max = max(r.Max, d)
+	})
+}
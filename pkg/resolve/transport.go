@@ -0,0 +1,100 @@
+package resolve
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// Timeouts of the phases of a request. Each phase gets its own, so a slow
+// PDF download is not cut short like a lookup would be, while a host that
+// does not answer at all is given up on quickly.
+const (
+	dialTimeout   = 5 * time.Second
+	tlsTimeout    = 5 * time.Second
+	headerTimeout = 20 * time.Second
+	// bodyTimeout is how long reading a response may stall, however long
+	// the whole body takes
+	bodyTimeout = 30 * time.Second
+)
+
+// Client is the HTTP client all of bibgloss uses. Sharing it keeps the
+// connections to an API open across lookups, several of them multiplexed
+// over one HTTP/2 connection, and the rate limits of its hosts no matter
+// which part of bibgloss asks.
+var Client = &http.Client{Transport: limitedTransport{base: newBaseTransport()}}
+
+func newBaseTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.ForceAttemptHTTP2 = true
+	t.TLSHandshakeTimeout = tlsTimeout
+	t.ResponseHeaderTimeout = headerTimeout
+	t.ExpectContinueTimeout = time.Second
+	// the workers of a fetch run talk to the same few hosts
+	t.MaxIdleConns = 64
+	t.MaxIdleConnsPerHost = 16
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
+
+// limitedTransport waits for the rate limit of the request's host,
+// identifies bibgloss to the APIs, bounds the phases of the request and
+// observes the host's latency and quota for Rank
+type limitedTransport struct{ base http.RoundTripper }
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	if recorder != nil {
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				record(func(t *Timings) {
+					if info.Reused {
+						t.connsReused++
+					} else {
+						t.connsOpened++
+					}
+				})
+			},
+		})
+	}
+	req = req.Clone(ctx)
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "BibGloss (https://github.com/arunoruto/BibGloss)")
+	}
+	waitTurn(req.URL.Host)
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	observe(req.URL.Host, res, time.Since(start))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if res.ProtoMajor == 2 {
+		record(func(t *Timings) { t.http2++ })
+	}
+	res.Body = &stallBody{ReadCloser: res.Body, stall: time.AfterFunc(bodyTimeout, cancel), cancel: cancel}
+	return res, nil
+}
+
+// stallBody cancels the request when reading its body stalls for longer
+// than bodyTimeout
+type stallBody struct {
+	io.ReadCloser
+	stall  *time.Timer
+	cancel context.CancelFunc
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	b.stall.Reset(bodyTimeout)
+	return b.ReadCloser.Read(p)
+}
+
+func (b *stallBody) Close() error {
+	b.stall.Stop()
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,49 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const unpaywallAPI = "https://api.unpaywall.org/v2/"
+
+type unpaywallLocation struct {
+	URL       string `json:"url"`
+	URLForPDF string `json:"url_for_pdf"`
+	HostType  string `json:"host_type"`
+	Version   string `json:"version"`
+}
+
+type UnpaywallRecord struct {
+	IsOA           bool                `json:"is_oa"`
+	OAStatus       string              `json:"oa_status"`
+	BestOALocation *unpaywallLocation  `json:"best_oa_location"`
+	OALocations    []unpaywallLocation `json:"oa_locations"`
+}
+
+// PDFURL returns the first direct PDF link among the OA locations
+func (r UnpaywallRecord) PDFURL() string {
+	if r.BestOALocation != nil && r.BestOALocation.URLForPDF != "" {
+		return r.BestOALocation.URLForPDF
+	}
+	for _, l := range r.OALocations {
+		if l.URLForPDF != "" {
+			return l.URLForPDF
+		}
+	}
+	return ""
+}
+
+// FetchUnpaywall looks up open-access locations for a DOI. Unpaywall
+// requires a contact email on every request.
+func FetchUnpaywall(doi, email string) (UnpaywallRecord, error) {
+	var r UnpaywallRecord
+	if email == "" {
+		return r, errors.New("unpaywall: no contact email configured (-email)")
+	}
+	if err := GetJSON(unpaywallAPI+EscapeDOI(doi)+"?email="+url.QueryEscape(email), &r); err != nil {
+		return r, fmt.Errorf("unpaywall: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/mattn/go-isatty"
+)
+
+// interactive reports whether both stdin and stdout are terminals. The TUI
+// is only started when they are.
+func interactive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// fetchOptions control where a plain fetch run reads and writes
+type fetchOptions struct {
+	// Input is a file with one identifier per line, read after the arguments
+	Input string
+	// Output is a file successes are written to instead of stdout. An
+	// existing file is only touched with Append or Force.
+	Output string
+	// Append adds to an existing Output, Force replaces it
+	Append bool
+	Force  bool
+	// Report is a JSON lines file failures are recorded in
+	Report string
+	// JSON prints one record per identifier instead of BibTeX
+	JSON bool
+}
+
+// failure is one line of the failure report
+type failure struct {
+	Line       int    `json:"line,omitempty"`
+	Identifier string `json:"identifier"`
+	Reason     string `json:"reason"`
+}
+
+// runPlain resolves identifiers given as arguments, or one per line on
+// stdin, and prints their BibTeX to out as soon as each one is resolved
+func runPlain(cfg config, opts fetchOptions, args []string, in io.Reader, out, errOut io.Writer) error {
+	if opts.Input != "" {
+		f, err := os.Open(opts.Input)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint:errcheck
+		in = f
+		args = append(args, "-")
+	}
+	if len(args) == 0 {
+		if in == os.Stdin && isTerminal(os.Stdin) {
+			return withCode(exitInvalid, errors.New("no identifier given"))
+		}
+		args = []string{"-"}
+	}
+
+	if opts.Output == "-" {
+		opts.Output = ""
+	}
+	bib := cfg.Library
+	if opts.JSON && opts.Output != "" {
+		return withCode(exitInvalid, errors.New("--json writes to stdout and cannot be combined with --output"))
+	}
+	if opts.Output != "" {
+		if err := prepareOutput(opts); err != nil {
+			return err
+		}
+		bib = opts.Output
+	}
+	entries, err := loadLibrary(bib)
+	if err != nil {
+		return err
+	}
+	taken := library.Keys(entries)
+
+	var report *json.Encoder
+	if opts.Report != "" {
+		f, err := os.Create(opts.Report)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint:errcheck
+		report = json.NewEncoder(f)
+	}
+	// records are encoded straight to out, one line per identifier
+	var records *json.Encoder
+	if opts.JSON {
+		records = json.NewEncoder(out)
+	}
+
+	total, failed := 0, 0
+	// codes collects the exit code of every failure
+	codes := map[int]bool{}
+	err = resolveConcurrently(cfg.Concurrency, args, in, cfg.resolveOptions(), func(id string, line int, w *Work, err error) error {
+		total++
+		if err != nil {
+			fmt.Fprintf(errOut, "%s: %v\n", id, err)
+			failed++
+			codes[exitCode(err)] = true
+			if records != nil {
+				if err := records.Encode(record{Identifier: id, Line: line, Error: err.Error()}); err != nil {
+					return err
+				}
+			}
+			if report != nil {
+				return report.Encode(failure{line, id, err.Error()})
+			}
+			return nil
+		}
+		w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(cfg.KeyTemplate, &w.Entry), taken)
+		taken[w.Entry.Key] = true
+		if records != nil {
+			return records.Encode(newRecord(id, line, w, cfg.Format))
+		}
+		if opts.Output != "" {
+			e := bibtex.Convert(w.Entry, cfg.Format)
+			return appendEntry(opts.Output, &e)
+		}
+		_, err = fmt.Fprint(out, bibtex.Render(&w.Entry, cfg.Format))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if failed == 0 {
+		return nil
+	}
+	err = fmt.Errorf("%d of %d identifiers failed", failed, total)
+	// a run where everything failed for the same reason reports that reason
+	if failed == total && len(codes) == 1 {
+		for code := range codes {
+			return withCode(code, err)
+		}
+	}
+	return withCode(exitPartial, err)
+}
+
+// prepareOutput checks the output mode against an existing output file and
+// empties it when it is to be replaced
+func prepareOutput(opts fetchOptions) error {
+	if opts.Append && opts.Force {
+		return errors.New("--append and --force are mutually exclusive")
+	}
+	_, err := os.Stat(opts.Output)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if exists && !opts.Append && !opts.Force {
+		return fmt.Errorf("%s already exists, use --append or --force", opts.Output)
+	}
+	// a single snapshot covers the whole run
+	if _, err := takeSnapshot(opts.Output, "fetch"); err != nil {
+		return err
+	}
+	if exists && opts.Force {
+		return writeFile(opts.Output, nil)
+	}
+	return nil
+}
+
+// errStopped ends reading identifiers once their results are not wanted
+var errStopped = errors.New("stopped")
+
+// resolveConcurrently resolves the identifiers of args and in with n workers
+// of a fetch engine and hands the results to fn in input order, each as
+// soon as it and all before it are resolved
+func resolveConcurrently(n int, args []string, in io.Reader, opts resolve.Options, fn func(id string, line int, w *Work, err error) error) error {
+	e := resolve.NewEngine(n, opts)
+	defer e.Stop()
+
+	var readErr error
+	go func() {
+		defer e.Close()
+		seq := 0
+		readErr = eachIdentifier(args, in, func(id string, line int) error {
+			if !e.Submit(resolve.Job{Seq: seq, Line: line, ID: id}) {
+				return errStopped
+			}
+			seq++
+			return nil
+		})
+	}()
+
+	// pending holds results that finished before an earlier identifier
+	pending := map[int]resolve.Result{}
+	next := 0
+	for r := range e.Results() {
+		pending[r.Seq] = r
+		for {
+			j, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err := fn(j.ID, j.Line, j.Work, j.Err); err != nil {
+				return err
+			}
+		}
+	}
+	// every worker has finished, so the reader has too
+	return readErr
+}
+
+// inParallel calls fn for every item, n at a time, and returns once all of
+// them returned. Requests stay rate limited however many run; what fn
+// collects it guards itself or stores at its index.
+func inParallel[T any](n int, items []T, fn func(i int, item T)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(n, 1))
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i, item)
+		}()
+	}
+	wg.Wait()
+}
+
+// eachIdentifier calls fn for every argument. An argument of "-" stands for
+// the lines of in, which are handed to fn with their line number while in
+// is still being read. Blank lines and lines starting with # are skipped.
+func eachIdentifier(args []string, in io.Reader, fn func(id string, line int) error) error {
+	for _, arg := range args {
+		if arg != "-" {
+			if err := fn(arg, 0); err != nil {
+				return err
+			}
+			continue
+		}
+		s := bufio.NewScanner(in)
+		for line := 1; s.Scan(); line++ {
+			if id := strings.TrimSpace(s.Text()); id != "" && !strings.HasPrefix(id, "#") {
+				if err := fn(id, line); err != nil {
+					return err
+				}
+			}
+		}
+		if err := s.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
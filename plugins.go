@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// plugins are the resolvers registered from pluginsDir
+var plugins []resolve.Plugin
+
+// pluginsDir holds resolver executables, see resolve.Plugin
+func pluginsDir() string {
+	return dataDir("plugins")
+}
+
+// registerPlugins makes the plugins in dir available as resolvers. A
+// broken plugin is skipped with a warning instead of stopping bibgloss.
+func registerPlugins(dir string) {
+	found, err := resolve.FindPlugins(dir)
+	if err != nil {
+		slog.Warn("plugins unavailable", "dir", dir, "err", err)
+		return
+	}
+	for _, p := range found {
+		if err := resolve.RegisterPlugin(p); err != nil {
+			slog.Warn("plugin skipped", "err", err)
+			continue
+		}
+		slog.Debug("plugin registered", "resolver", p.Name, "path", p.Path)
+		plugins = append(plugins, p)
+	}
+}
+
+// withPlugins returns the resolver chain with the plugins it does not name
+// appended, so installing a plugin is enough to have it asked
+func withPlugins(chain []string) []string {
+	for _, p := range plugins {
+		if !slices.Contains(chain, p.Name) {
+			chain = append(slices.Clip(chain), p.Name)
+		}
+	}
+	return chain
+}
+
+// pluginNamed returns the registered plugin of a resolver name
+func pluginNamed(name string) (resolve.Plugin, bool) {
+	i := slices.IndexFunc(plugins, func(p resolve.Plugin) bool { return p.Name == name })
+	if i < 0 {
+		return resolve.Plugin{}, false
+	}
+	return plugins[i], true
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// profileConfig is a named library with its own settings, like
+// [profiles.thesis]. Settings it leaves empty are those of the config.
+type profileConfig struct {
+	Library             string   `toml:"library"`
+	Papers              string   `toml:"papers"`
+	KeyTemplate         string   `toml:"key_template"`
+	Format              string   `toml:"format"`
+	Style               string   `toml:"style"`
+	Glossaries          []string `toml:"glossaries"`
+	GlossaryLinks       *bool    `toml:"glossary_links"`
+	GlossaryKeyTemplate string   `toml:"glossary_key_template"`
+}
+
+// profileNames returns the names of the configured profiles, sorted
+func (c config) profileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// withProfile applies the profile called name over the settings it had
+// before any profile was applied. The empty name goes back to those.
+func (c config) withProfile(name string) (config, error) {
+	base := c
+	if c.unprofiled != nil {
+		base = *c.unprofiled
+	}
+	c.Library, c.Papers, c.KeyTemplate = base.Library, base.Papers, base.KeyTemplate
+	c.Format, c.Style = base.Format, base.Style
+	c.Glossaries, c.GlossaryLinks, c.GlossaryKeyTemplate = base.Glossaries, base.GlossaryLinks, base.GlossaryKeyTemplate
+	c.Profile, c.unprofiled = "", nil
+	if name == "" {
+		return c, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return c, fmt.Errorf("unknown profile %q, the config defines none", name)
+		}
+		return c, fmt.Errorf("unknown profile %q, the config defines %s", name, strings.Join(c.profileNames(), ", "))
+	}
+	if p.Library == "" {
+		return c, fmt.Errorf("profiles.%s needs a library", name)
+	}
+	c.Library = p.Library
+	for _, s := range []struct{ dst, src *string }{
+		{&c.Papers, &p.Papers},
+		{&c.KeyTemplate, &p.KeyTemplate},
+		{&c.Format, &p.Format},
+		{&c.Style, &p.Style},
+		{&c.GlossaryKeyTemplate, &p.GlossaryKeyTemplate},
+	} {
+		if *s.src != "" {
+			*s.dst = *s.src
+		}
+	}
+	if len(p.Glossaries) > 0 {
+		c.Glossaries = p.Glossaries
+	}
+	if p.GlossaryLinks != nil {
+		c.GlossaryLinks = *p.GlossaryLinks
+	}
+	c.Profile, c.unprofiled = name, &base
+	return c, c.validate()
+}
+
+// profileItem adapts a profile to the switcher list. The unnamed one is
+// the library of the config.
+type profileItem struct {
+	name    string
+	library string
+	current bool
+}
+
+func (i profileItem) Title() string {
+	title := i.name
+	if title == "" {
+		title = tr("default")
+	}
+	if i.current {
+		title += " ✓"
+	}
+	return title
+}
+
+func (i profileItem) Description() string { return i.library }
+func (i profileItem) FilterValue() string { return i.name + " " + i.library }
+
+func newProfileList() list.Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 80, 20)
+	l.Title = tr("Libraries")
+	l.FilterInput.Prompt = tr("Filter: ")
+	l.SetShowHelp(false)
+	l.DisableQuitKeybindings()
+	return l
+}
+
+// showProfiles opens the switcher on the library in use
+func (m *model) showProfiles() tea.Cmd {
+	if len(m.cfg.Profiles) == 0 {
+		m.message = tr("no profiles configured, see [profiles] in bibgloss config init")
+		return nil
+	}
+	base, _ := m.cfg.withProfile("")
+	items := []list.Item{profileItem{"", base.Library, m.cfg.Profile == ""}}
+	for _, name := range m.cfg.profileNames() {
+		items = append(items, profileItem{name, m.cfg.Profiles[name].Library, name == m.cfg.Profile})
+	}
+	m.profilesFrom = m.state
+	m.state = stateProfiles
+	m.err, m.message = nil, ""
+	m.profiles.ResetFilter()
+	cmd := m.profiles.SetItems(items)
+	for i, item := range items {
+		if item.(profileItem).current {
+			m.profiles.Select(i)
+		}
+	}
+	return cmd
+}
+
+// switchProfile makes the profile called name the one imports and the
+// library screen work with
+func (m *model) switchProfile(name string) tea.Cmd {
+	cfg, err := m.cfg.withProfile(name)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	m.cfg = cfg
+	m.entries, m.metrics, m.tagFilter = nil, nil, ""
+	m.state = m.profilesFrom
+	m.message = tr("switched to %s", m.cfg.Library)
+	if m.state == stateLibrary {
+		return tea.Batch(loadLibraryCmd(m.cfg.Library), loadMetricsCmd(m.cfg.Library))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// projectFile is the per-project settings file, read from the working
+// directory or the closest parent holding one
+const projectFile = ".bibgloss.toml"
+
+// projectSettings are the keys a project file may set. Credentials, sync
+// targets and everything else stay with the user's config, a cloned
+// repository cannot change them.
+var projectSettings = map[string]bool{
+	"library":               true,
+	"papers":                true,
+	"key_template":          true,
+	"format":                true,
+	"style":                 true,
+	"glossaries":            true,
+	"glossary_links":        true,
+	"glossary_key_template": true,
+}
+
+// projectPath returns the project file that applies in the working
+// directory, empty when there is none
+func projectPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return findProject(dir)
+}
+
+// findProject returns the project file of dir or of its closest parent
+// holding one
+func findProject(dir string) string {
+	for {
+		p := filepath.Join(dir, projectFile)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// applyProject layers a project file over cfg. Relative paths in it are
+// taken from the project root, so commands work from any subdirectory.
+func applyProject(cfg *config, path string) error {
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("%s: unknown setting %s", path, undecoded[0])
+	}
+	for _, key := range md.Keys() {
+		if !projectSettings[key[0]] {
+			return fmt.Errorf("%s: %s can only be set in %s", path, key[0], configPath())
+		}
+	}
+	root := filepath.Dir(path)
+	rel := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(root, p)
+	}
+	if md.IsDefined("library") {
+		cfg.Library = rel(cfg.Library)
+	}
+	if md.IsDefined("papers") {
+		cfg.Papers = rel(cfg.Papers)
+	}
+	// styles are named or given as a file
+	if md.IsDefined("style") && strings.HasSuffix(cfg.Style, ".csl") {
+		cfg.Style = rel(cfg.Style)
+	}
+	if md.IsDefined("glossaries") {
+		for i, g := range cfg.Glossaries {
+			cfg.Glossaries[i] = rel(g)
+		}
+	}
+	return nil
+}
+
+// projectTemplate is written by config init --project
+const projectTemplate = `# BibGloss project settings
+#
+# They override the user's config for every command run in this directory
+# or below it. Relative paths are taken from this directory.
+
+# library of the project
+library = "references.bib"
+
+# directory downloaded PDFs are stored in
+papers = "papers"
+
+# citation key template
+key_template = "{auth}{year}{title}"
+
+# glossary files; glossary add writes to the first, glossary list reads all
+glossaries = ["glossary.tex"]
+
+# add a glossary entry for every imported software or dataset
+glossary_links = false
+
+# key of glossary definitions added without one
+glossary_key_template = "{long}"
+`
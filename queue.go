@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// queueMsg is a pending identifier resolved, or found in the library as have
+type queueMsg struct {
+	id   string
+	work *Work
+	have string
+	err  error
+}
+
+// queueItem adapts a work waiting for import to the list component
+type queueItem struct {
+	id   string
+	work *Work
+}
+
+func (i queueItem) Title() string {
+	if t := i.work.Entry.Get("title"); t != "" {
+		return t
+	}
+	return i.id
+}
+
+func (i queueItem) Description() string {
+	parts := []string{i.work.Entry.Key}
+	if authors := bibtex.SplitAuthors(i.work.Entry.Get("author")); len(authors) > 0 {
+		parts = append(parts, authors[0])
+	}
+	if y := i.work.Entry.Get("year"); y != "" {
+		parts = append(parts, y)
+	}
+	return strings.Join(append(parts, i.id), " · ")
+}
+
+func (i queueItem) FilterValue() string {
+	return i.work.Entry.Get("title") + " " + i.work.Entry.Get("author") + " " + i.work.Entry.Key
+}
+
+func newQueueList() list.Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 80, 20)
+	l.Title = tr("Queue")
+	l.FilterInput.Prompt = tr("Filter: ")
+	l.SetShowHelp(false)
+	l.DisableQuitKeybindings()
+	return l
+}
+
+// batchIdentifiers returns the identifiers of pasted text with one per
+// line, or false when it is not several of them
+func batchIdentifiers(text string) ([]string, bool) {
+	var ids []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			ids = append(ids, line)
+		}
+	}
+	return ids, len(ids) > 1
+}
+
+// queueWork resolves a pending identifier and keys it like fetchWork,
+// unless the library already has its DOI. It does not go through the TUI's
+// engine, whose next result belongs to the input screen.
+func queueWork(cfg config, id string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := loadLibrary(cfg.Library)
+		if err != nil {
+			return queueMsg{id: id, err: err}
+		}
+		doi := resolve.CleanDOI(id)
+		if d, ok := resolve.ArXivDOI(id); ok {
+			doi = d
+		}
+		for _, e := range entries {
+			if strings.EqualFold(e.Get("doi"), doi) {
+				return queueMsg{id: id, have: e.Key}
+			}
+		}
+		w, err := resolve.Resolve(id, cfg.resolveOptions())
+		if err != nil {
+			return queueMsg{id: id, err: err}
+		}
+		w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(cfg.KeyTemplate, &w.Entry), library.Keys(entries))
+		return queueMsg{id: id, work: w}
+	}
+}
+
+// enqueue adds identifiers to the pending ones, which are resolved one
+// after another into the queue. Identifiers pending or queued already are
+// skipped.
+func (m *model) enqueue(ids ...string) tea.Cmd {
+	for _, id := range ids {
+		if !m.queued(id) && !slices.Contains(m.pending, id) {
+			m.pending = append(m.pending, id)
+		}
+	}
+	return m.resolveNext()
+}
+
+// resolveNext resolves the first pending identifier, unless one is being
+// resolved already
+func (m *model) resolveNext() tea.Cmd {
+	if m.resolving || len(m.pending) == 0 {
+		return nil
+	}
+	m.resolving = true
+	return queueWork(m.cfg, m.pending[0])
+}
+
+// onQueued queues a resolved identifier and goes on with the next one
+func (m *model) onQueued(msg queueMsg) tea.Cmd {
+	m.resolving = false
+	m.pending = slices.DeleteFunc(m.pending, func(id string) bool { return id == msg.id })
+	var cmd tea.Cmd
+	switch {
+	case msg.err != nil:
+		m.err = fmt.Errorf("%s: %w", msg.id, msg.err)
+		m.message = ""
+	case msg.have != "":
+		m.message = tr("%s is already in the library as %s", msg.id, msg.have)
+	case m.queued(msg.id):
+	default:
+		m.message = tr("queued %s, ctrl+y to import", msg.work.Entry.Key)
+		cmd = m.queue.InsertItem(len(m.queue.Items()), queueItem{msg.id, msg.work})
+	}
+	return tea.Batch(cmd, m.resolveNext())
+}
+
+// queued reports whether an identifier is waiting in the queue
+func (m *model) queued(id string) bool {
+	for _, it := range m.queue.Items() {
+		if it.(queueItem).id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// dequeue removes a work from the queue, reporting whether it was there
+func (m *model) dequeue(w *Work) bool {
+	for i, it := range m.queue.Items() {
+		if it.(queueItem).work == w {
+			m.queue.RemoveItem(i)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// relatedMsg carries the references and citing works of a library entry
+type relatedMsg struct {
+	key    string
+	papers []relatedPaper
+	err    error
+}
+
+// relatedPaper is a work the entry cites, or one citing it
+type relatedPaper struct {
+	resolve.Paper
+	reference bool
+}
+
+// relatedItem adapts a related work to the list component, have is the
+// key of the library entry with its DOI and marked selects it for I
+type relatedItem struct {
+	paper  relatedPaper
+	have   string
+	marked bool
+}
+
+func (i relatedItem) Title() string {
+	title := i.paper.Title
+	if title == "" {
+		title = i.paper.DOI
+	}
+	if i.marked {
+		return "● " + title
+	}
+	return title
+}
+
+func (i relatedItem) Description() string {
+	parts := []string{tr("cited by it")}
+	if !i.paper.reference {
+		parts[0] = tr("cites it")
+	}
+	if len(i.paper.Authors) > 0 {
+		parts = append(parts, i.paper.Authors[0])
+	}
+	if i.paper.Year > 0 {
+		parts = append(parts, fmt.Sprint(i.paper.Year))
+	}
+	if i.have != "" {
+		parts = append(parts, tr("in the library as %s", i.have))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (i relatedItem) FilterValue() string {
+	return i.paper.Title + " " + strings.Join(i.paper.Authors, " ") + " " + i.paper.Venue
+}
+
+func newRelatedList() list.Model {
+	l := list.New(nil, list.NewDefaultDelegate(), 80, 20)
+	l.Title = tr("Related")
+	l.FilterInput.Prompt = tr("Filter: ")
+	l.SetShowHelp(false)
+	l.DisableQuitKeybindings()
+	return l
+}
+
+// referenceList returns the works a DOI cites in the order of its
+// reference list from CrossRef, or from OpenAlex and Semantic Scholar when
+// CrossRef has none with a DOI
+func referenceList(doi string, opts resolve.Options) ([]resolve.Paper, error) {
+	papers, err := resolve.FetchReferences(doi)
+	if err == nil && len(papers) > 0 {
+		return papers, nil
+	}
+	if err != nil {
+		slog.Info("reference list lookup failed", "resolver", "crossref", "doi", doi, "err", err)
+	}
+	return referencedWorks(doi, opts)
+}
+
+// loadRelatedCmd looks up the references and, unless only those are asked
+// for, the citing works of an entry in the background. Either is shown when
+// the other lookup fails.
+func loadRelatedCmd(e *Entry, opts resolve.Options, referencesOnly bool) tea.Cmd {
+	key, doi := e.Key, e.Get("doi")
+	return func() tea.Msg {
+		if doi == "" {
+			return relatedMsg{key: key, err: errors.New(tr("%s has no DOI to look up related works by", key))}
+		}
+		refs, err := referenceList(doi, opts)
+		var citing []resolve.Paper
+		var err2 error
+		if referencesOnly {
+			err2 = err
+		} else {
+			citing, err2 = citingWorks(doi, opts)
+		}
+		if err != nil && err2 != nil {
+			return relatedMsg{key: key, err: err}
+		}
+		var papers []relatedPaper
+		for _, p := range refs {
+			papers = append(papers, relatedPaper{p, true})
+		}
+		for _, p := range citing {
+			papers = append(papers, relatedPaper{p, false})
+		}
+		if referencesOnly {
+			return relatedMsg{key: key, papers: papers}
+		}
+		return relatedMsg{key: key, papers: papers, err: errors.Join(err, err2)}
+	}
+}
+
+// relatedItems marks the related works the library has already, and those
+// marked for import by DOI
+func relatedItems(papers []relatedPaper, entries []Entry, marked map[string]bool) []list.Item {
+	keys := map[string]string{}
+	for _, e := range entries {
+		if doi := e.Get("doi"); doi != "" {
+			keys[strings.ToLower(doi)] = e.Key
+		}
+	}
+	items := make([]list.Item, len(papers))
+	for i, p := range papers {
+		doi := strings.ToLower(p.DOI)
+		items[i] = relatedItem{p, keys[doi], marked[doi]}
+	}
+	return items
+}
+
+// showRelated opens the related works of a library entry, or only the
+// reference list of the work shown in the detail view
+func (m *model) showRelated(e *Entry) tea.Cmd {
+	referencesOnly := m.state == stateDetail
+	m.relatedFrom, m.relatedWork, m.relatedPrev = m.state, m.work, m.prev
+	m.state = stateRelated
+	m.relatedKey, m.relatedPapers = e.Key, nil
+	m.relatedMarked = map[string]bool{}
+	m.related.ResetFilter()
+	m.err = nil
+	m.related.Title = tr("Related to %s", e.Key)
+	m.message = tr("looking up the works %s cites and those citing it…", e.Key)
+	if referencesOnly {
+		m.related.Title = tr("References of %s", e.Key)
+		m.message = tr("looking up the works %s cites…", e.Key)
+	}
+	return tea.Batch(m.related.SetItems(nil), loadRelatedCmd(e, m.cfg.resolveOptions(), referencesOnly))
+}
+
+// closeRelated goes back to the screen the related works were opened from
+func (m *model) closeRelated() {
+	m.message = ""
+	if m.relatedFrom != stateDetail {
+		m.state = stateLibrary
+		return
+	}
+	m.work, m.prev = m.relatedWork, m.relatedPrev
+	m.state = stateDetail
+	m.similar, m.compare = nil, 0
+	if m.prev != stateLibrary {
+		m.similar = similarEntries(m.entries, &m.work.Entry)
+	}
+	m.setDetail()
+}
+
+// markRelated toggles the mark of the selected related work, or with all
+// marks every one missing from the library
+func (m *model) markRelated(all bool) tea.Cmd {
+	if all {
+		for _, it := range m.related.Items() {
+			if item := it.(relatedItem); item.have == "" {
+				m.relatedMarked[strings.ToLower(item.paper.DOI)] = true
+			}
+		}
+	} else if item, ok := m.related.SelectedItem().(relatedItem); ok && item.have == "" {
+		doi := strings.ToLower(item.paper.DOI)
+		m.relatedMarked[doi] = !m.relatedMarked[doi]
+	}
+	return m.related.SetItems(relatedItems(m.relatedPapers, m.entries, m.relatedMarked))
+}
+
+// importMarked imports the marked related works that are not in the
+// library, one after another
+func (m *model) importMarked() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, it := range m.related.Items() {
+		if item := it.(relatedItem); item.marked && item.have == "" {
+			cmds = append(cmds, onConflict(importDOI(m.cfg, item.paper.DOI)))
+		}
+	}
+	if len(cmds) == 0 {
+		m.message = tr("nothing marked, space marks a work and a all missing ones")
+		return nil
+	}
+	m.relatedMarked = map[string]bool{}
+	m.err = nil
+	m.message = tr("importing %d works…", len(cmds))
+	return tea.Sequence(cmds...)
+}
+
+// importDOI resolves a DOI and imports it under its generated key, unless
+// the library has it already
+func importDOI(cfg config, doi string) tea.Cmd {
+	return func() tea.Msg {
+		msg := queueWork(cfg, doi)().(queueMsg)
+		switch {
+		case msg.err != nil:
+			return errMsg{fmt.Errorf("%s: %w", doi, msg.err)}
+		case msg.have != "":
+			return errMsg{errors.New(tr("%s is already in the library as %s", doi, msg.have))}
+		}
+		return importWork(cfg, msg.work)()
+	}
+}
+
+// parsePicks reads the numbers of works to import, like 1,3-7, counting
+// from 1 up to n, or all of them
+func parsePicks(spec string, n int) ([]int, error) {
+	if strings.TrimSpace(spec) == "all" {
+		picks := make([]int, n)
+		for i := range picks {
+			picks[i] = i + 1
+		}
+		return picks, nil
+	}
+	var picks []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(lo)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(hi)
+		}
+		if err != nil || from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("%q is not a number or range of 1 to %d", part, n)
+		}
+		for i := from; i <= to; i++ {
+			if !slices.Contains(picks, i) {
+				picks = append(picks, i)
+			}
+		}
+	}
+	return picks, nil
+}
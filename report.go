@@ -0,0 +1,206 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportOptions shape the reading list written by bibgloss report
+type reportOptions struct {
+	Title string
+	// Abstracts adds the abstract of every entry that has one, and its
+	// translation by bibgloss translate
+	Abstracts bool
+}
+
+// reportGroup is a tag of the report with its entries, oldest first
+type reportGroup struct {
+	Tag     string
+	Entries []Entry
+}
+
+// reportGroups sorts entries into a group per tag, JabRef groups counting
+// as tags. An entry is listed under each of its tags, untagged ones come
+// last.
+func reportGroups(entries []Entry) []reportGroup {
+	byTag := map[string]*reportGroup{}
+	var groups []*reportGroup
+	var untagged reportGroup
+	for _, e := range entries {
+		tags := parseTags(strings.Join(append(entryTags(&e), entryGroups(&e)...), ","))
+		if len(tags) == 0 {
+			untagged.Entries = append(untagged.Entries, e)
+		}
+		for _, t := range tags {
+			g := byTag[strings.ToLower(t)]
+			if g == nil {
+				g = &reportGroup{Tag: t}
+				byTag[strings.ToLower(t)] = g
+				groups = append(groups, g)
+			}
+			g.Entries = append(g.Entries, e)
+		}
+	}
+	slices.SortFunc(groups, func(a, b *reportGroup) int {
+		return cmp.Compare(strings.ToLower(a.Tag), strings.ToLower(b.Tag))
+	})
+	out := make([]reportGroup, 0, len(groups)+1)
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	if len(untagged.Entries) > 0 {
+		out = append(out, untagged)
+	}
+	for _, g := range out {
+		slices.SortStableFunc(g.Entries, compareYears)
+	}
+	return out
+}
+
+// compareYears orders entries by year, those without one last
+func compareYears(a, b Entry) int {
+	ya, erra := strconv.Atoi(a.Get("year"))
+	yb, errb := strconv.Atoi(b.Get("year"))
+	switch {
+	case erra != nil && errb != nil:
+		return 0
+	case erra != nil:
+		return 1
+	case errb != nil:
+		return -1
+	}
+	return cmp.Compare(ya, yb)
+}
+
+// noteSource is a note file and the character its headings start with
+type noteSource struct {
+	path   string
+	marker byte
+}
+
+// readerNotes returns what the reader wrote about an entry: its annote
+// field and the Notes section of its Obsidian and org-roam notes
+func readerNotes(cfg config, e Entry) (string, error) {
+	var parts []string
+	if a := strings.TrimSpace(e.Get("annote")); a != "" {
+		parts = append(parts, unbrace.Replace(a))
+	}
+	var files []noteSource
+	if cfg.Obsidian.enabled() {
+		files = append(files, noteSource{notePath(cfg.Obsidian, e), '#'})
+	}
+	if cfg.OrgRoam.enabled() {
+		files = append(files, noteSource{orgNotePath(cfg.OrgRoam, e), '*'})
+	}
+	for _, f := range files {
+		data, err := readFile(f.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if s := notesSection(string(data), f.marker); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// notesSection returns the text below the Notes heading of a note, up to
+// the next heading of the same or a higher level. marker is the character
+// headings start with, # in Markdown and * in org.
+func notesSection(note string, marker byte) string {
+	level := func(line string) int {
+		n := len(line) - len(strings.TrimLeft(line, string(marker)))
+		if n == 0 || n == len(line) || line[n] != ' ' {
+			return 0
+		}
+		return n
+	}
+	var b strings.Builder
+	at := 0
+	for _, line := range strings.Split(note, "\n") {
+		l := level(line)
+		switch {
+		case at == 0:
+			if l > 0 && strings.EqualFold(strings.TrimSpace(line[l:]), "notes") {
+				at = l
+			}
+			continue
+		case l > 0 && l <= at:
+			return strings.TrimSpace(b.String())
+		}
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// renderReport renders entries as a Markdown reading list grouped by tag,
+// with a pandoc title block so it converts to PDF as it is
+func renderReport(cfg config, entries []Entry, opts reportOptions) (string, error) {
+	var annotations map[string]annotation
+	if opts.Abstracts {
+		var err error
+		if annotations, err = loadAnnotations(cfg.Library); err != nil {
+			return "", err
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %s\ndate: %s\n---\n", strconv.Quote(opts.Title), time.Now().Format(time.DateOnly))
+	for _, g := range reportGroups(entries) {
+		heading := g.Tag
+		if heading == "" {
+			heading = "Untagged"
+		}
+		fmt.Fprintf(&b, "\n# %s (%d)\n", heading, len(g.Entries))
+		for _, e := range g.Entries {
+			title := unbrace.Replace(e.Get("title"))
+			if title == "" {
+				title = e.Key
+			}
+			fmt.Fprintf(&b, "\n## %s\n\n", title)
+			b.WriteString(reportCitation(e) + "\n")
+			if abstract := e.Get("abstract"); opts.Abstracts && abstract != "" {
+				fmt.Fprintf(&b, "\n> %s\n", strings.Join(strings.Fields(unbrace.Replace(abstract)), " "))
+			}
+			if a := annotations[e.Key]; a.Translation != "" {
+				fmt.Fprintf(&b, "\n> *Translated from %s:* %s\n", cmp.Or(a.From, "?"), strings.Join(strings.Fields(a.Translation), " "))
+			}
+			notes, err := readerNotes(cfg, e)
+			if err != nil {
+				return "", err
+			}
+			if notes != "" {
+				fmt.Fprintf(&b, "\n**Notes**\n\n%s\n", notes)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// reportCitation describes an entry in one line: authors, year, where it
+// appeared, the key and the link
+func reportCitation(e Entry) string {
+	var b strings.Builder
+	if authors := noteAuthors(e); len(authors) > 0 {
+		b.WriteString(joinList(authors) + " ")
+	}
+	if y := e.Get("year"); y != "" {
+		fmt.Fprintf(&b, "(%s). ", y)
+	}
+	if c := entryContainer(e); c != "" {
+		fmt.Fprintf(&b, "*%s*. ", c)
+	}
+	fmt.Fprintf(&b, "`%s`", e.Key)
+	if link := entryLink(&e); link != "" {
+		fmt.Fprintf(&b, " <%s>", link)
+	}
+	return b.String()
+}
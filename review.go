@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// assumeYes applies changes without showing them for review first
+var assumeYes bool
+
+// colorDiff highlights the lines of a unified diff
+func colorDiff(diff string) string {
+	var b strings.Builder
+	for _, l := range lines(diff) {
+		text := strings.TrimSuffix(l, "\n")
+		switch {
+		case strings.HasPrefix(text, "+++"), strings.HasPrefix(text, "---"):
+			text = titleStyle.Render(text)
+		case strings.HasPrefix(text, "@@"):
+			text = labelStyle.Render(text)
+		case strings.HasPrefix(text, "+"):
+			text = okStyle.Render(text)
+		case strings.HasPrefix(text, "-"):
+			text = errStyle.Render(text)
+		}
+		b.WriteString(text + "\n")
+	}
+	return b.String()
+}
+
+// errReapply asks for the command to run again on the files as they are now,
+// because one changed on disk while its changes were reviewed
+var errReapply = errors.New("reapply the changes")
+
+// confirmStaged shows the staged changes and writes them if the user agrees
+func confirmStaged(in io.Reader, out io.Writer) error {
+	var diff strings.Builder
+	if err := printStaged(&diff); err != nil {
+		return err
+	}
+	fmt.Fprint(out, colorDiff(diff.String()))
+	r := bufio.NewReader(in)
+	if !askYes(r, out, "Apply these changes? [y/N] ") {
+		fmt.Fprintln(out, "discarded")
+		return nil
+	}
+	err := commitStaged()
+	var changed *changedError
+	if !errors.As(err, &changed) {
+		return err
+	}
+	if !askYes(r, out, changed.Error()+". Reload it and apply the changes again? [y/N] ") {
+		fmt.Fprintln(out, "discarded")
+		return nil
+	}
+	return errReapply
+}
+
+// askYes prompts for a yes or no answer, no unless the user says otherwise
+func askYes(r *bufio.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprint(out, prompt)
+	answer, _ := r.ReadString('\n')
+	a := strings.ToLower(strings.TrimSpace(answer))
+	return a == "y" || a == "yes"
+}
+
+// commitStaged writes the staged files, backing up what they replace.
+// Nothing is written when one of them changed on disk since it was read,
+// and a write failing puts back the files written before it, so the
+// changes apply as a whole or not at all. The steps waiting for them run
+// once they did.
+func commitStaged() error {
+	for path := range staged {
+		if err := checkUnchanged(path); err != nil {
+			return err
+		}
+	}
+	changes := maps.Clone(staged)
+	labels := maps.Clone(stagedLabels)
+	clear(staged)
+	clear(stagedLabels)
+	dryRun = false
+	var done []*snapshot
+	for _, path := range slices.Sorted(maps.Keys(changes)) {
+		label := strings.Join(labels[path], ", ")
+		if label == "" {
+			label = "apply"
+		}
+		s, err := mutate(path, label, func() error {
+			// staged files may be new, like literature notes
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			return writeFile(path, changes[path])
+		})
+		if err != nil {
+			afterCommit = nil
+			for _, s := range slices.Backward(done) {
+				if rerr := s.restore(); rerr != nil {
+					err = errors.Join(err, rerr)
+				}
+			}
+			return err
+		}
+		done = append(done, s)
+	}
+	return runAfterCommit()
+}
+
+// pendingChange is a library change waiting for confirmation in the TUI
+type pendingChange struct {
+	label string
+	diff  string
+	apply tea.Cmd
+}
+
+type reviewMsg struct{ change pendingChange }
+
+// conflictMsg reports a change refused because the file changed on disk
+// since it was read. retry makes the change again once the user agrees to
+// reload the file.
+type conflictMsg struct {
+	err   *changedError
+	retry tea.Cmd
+}
+
+// onConflict turns a failure of apply over a file changed on disk into a
+// conflictMsg, so the user can decide whether to apply it again
+func onConflict(apply tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		msg := apply()
+		var changed *changedError
+		if err, ok := msg.(errMsg); ok && errors.As(err.error, &changed) {
+			return conflictMsg{changed, apply}
+		}
+		return msg
+	}
+}
+
+// reviewEdit previews replacing the entry stored under key with e, or
+// removing it for a nil e. apply performs the change once it is confirmed.
+func reviewEdit(path, key string, e *Entry, label string, apply tea.Cmd) tea.Cmd {
+	apply = onConflict(apply)
+	if assumeYes {
+		return apply
+	}
+	return func() tea.Msg {
+		data, err := readFile(path)
+		if err != nil {
+			return errMsg{err}
+		}
+		entries, err := bibtex.Parse(bytes.NewReader(data))
+		if err != nil {
+			return errMsg{err}
+		}
+		for i, old := range entries {
+			if old.Key == key {
+				edited := library.Splice(data, entries, map[int]*Entry{i: e})
+				return reviewMsg{pendingChange{label, unifiedDiff(path, string(data), string(edited)), apply}}
+			}
+		}
+		return errMsg{fmt.Errorf("%s: no entry with key %s", path, key)}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// risTypes maps RIS reference types to BibTeX entry types
+var risTypes = map[string]string{
+	"JOUR":   "article",
+	"EJOUR":  "article",
+	"MGZN":   "article",
+	"BOOK":   "book",
+	"EBOOK":  "book",
+	"CHAP":   "incollection",
+	"ECHAP":  "incollection",
+	"CONF":   "inproceedings",
+	"CPAPER": "inproceedings",
+	"THES":   "phdthesis",
+	"RPRT":   "techreport",
+}
+
+// risLine matches a "TY  - JOUR" tag line. Some exporters drop the
+// space before the dash or the value after it.
+var risLine = regexp.MustCompile(`^([A-Z][A-Z0-9])  ?-(?: (.*))?$`)
+
+var yearRe = regexp.MustCompile(`\d{4}`)
+
+// parseRIS reads the RIS export of Mendeley, EndNote, Zotero and most
+// publisher sites. Entries have no keys yet.
+func parseRIS(r io.Reader) ([]Entry, error) {
+	var out []Entry
+	var tags map[string][]string
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	last := ""
+	for s.Scan() {
+		l := strings.TrimRight(strings.TrimPrefix(s.Text(), "\ufeff"), " \r")
+		m := risLine.FindStringSubmatch(l)
+		if m == nil {
+			// continuation of a long value
+			if tags != nil && last != "" && l != "" {
+				v := tags[last]
+				v[len(v)-1] += " " + strings.TrimSpace(l)
+			}
+			continue
+		}
+		tag, value := m[1], strings.TrimSpace(m[2])
+		switch tag {
+		case "TY":
+			tags = map[string][]string{}
+		case "ER":
+			if tags != nil {
+				out = append(out, risEntry(tags))
+			}
+			tags = nil
+			continue
+		}
+		if tags == nil {
+			return out, errors.New("RIS tag outside of a record, missing TY")
+		}
+		tags[tag] = append(tags[tag], value)
+		last = tag
+	}
+	if err := s.Err(); err != nil {
+		return out, err
+	}
+	if tags != nil {
+		return out, errors.New("last RIS record is missing ER")
+	}
+	return out, nil
+}
+
+func risEntry(tags map[string][]string) Entry {
+	get := func(names ...string) string {
+		for _, n := range names {
+			if v := tags[n]; len(v) > 0 && v[0] != "" {
+				return v[0]
+			}
+		}
+		return ""
+	}
+	all := func(names ...string) []string {
+		var out []string
+		for _, n := range names {
+			for _, v := range tags[n] {
+				if v != "" {
+					out = append(out, v)
+				}
+			}
+		}
+		return out
+	}
+
+	e := Entry{Type: risTypes[get("TY")]}
+	if e.Type == "" {
+		e.Type = "misc"
+	}
+	e.Set("author", strings.Join(all("AU", "A1"), " and "))
+	e.Set("editor", strings.Join(all("A2", "ED"), " and "))
+	e.Set("title", get("TI", "T1"))
+	container := get("T2", "JF", "JO", "JA")
+	switch e.Type {
+	case "article":
+		e.Set("journal", container)
+	case "inproceedings", "incollection":
+		e.Set("booktitle", container)
+		if e.Type == "incollection" {
+			// T2 of a chapter is the book, its editors are A2
+			e.Set("title", get("TI", "T1", "CT"))
+		}
+	default:
+		e.Set("series", get("T3"))
+	}
+	e.Set("year", yearRe.FindString(get("PY", "Y1", "DA")))
+	e.Set("volume", get("VL"))
+	e.Set("number", get("IS"))
+	pages := get("SP")
+	if ep := get("EP"); ep != "" && ep != pages {
+		pages += "--" + ep
+	}
+	e.Set("pages", pages)
+	e.Set("publisher", get("PB"))
+	e.Set("address", get("CY"))
+	switch e.Type {
+	case "phdthesis":
+		e.Set("school", get("PB"))
+		e.Set("publisher", "")
+	case "techreport":
+		e.Set("institution", get("PB"))
+		e.Set("publisher", "")
+	}
+	if sn := get("SN"); sn != "" {
+		if e.Type == "book" || e.Type == "incollection" {
+			e.Set("isbn", sn)
+		} else {
+			e.Set("issn", sn)
+		}
+	}
+	e.Set("doi", resolve.CleanDOI(get("DO")))
+	e.Set("url", get("UR"))
+	e.Set("abstract", get("AB", "N2"))
+	e.Set("keywords", strings.Join(all("KW"), ", "))
+	e.Set("file", strings.Join(all("L1"), ";"))
+	return e
+}
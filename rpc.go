@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// JSON-RPC 2.0 error codes. Failures of a method are reported as
+// rpcAppError minus the exit code bibgloss would exit with.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcAppError       = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// rpcServer handles JSON-RPC requests framed with Content-Length headers,
+// like the language server protocol, or one per line
+type rpcServer struct {
+	cfg     config
+	methods map[string]func(params json.RawMessage) (any, error)
+	// lines switches to newline delimited messages
+	lines bool
+	// out is shared by the goroutines answering requests
+	outMu sync.Mutex
+	out   io.Writer
+	// libMu serializes library changes
+	libMu sync.Mutex
+}
+
+func newRPCServer(cfg config, out io.Writer) *rpcServer {
+	s := &rpcServer{cfg: cfg, out: out}
+	s.methods = map[string]func(json.RawMessage) (any, error){
+		"resolve":   s.resolve,
+		"search":    s.search,
+		"insertKey": s.insertKey,
+		"lint":      s.lint,
+		"shutdown":  func(json.RawMessage) (any, error) { return struct{}{}, nil },
+	}
+	return s
+}
+
+// serve answers requests from in until it ends or an exit notification
+// arrives. Requests are answered concurrently, so a slow lookup does not
+// hold up a search.
+func (s *rpcServer) serve(in io.Reader) error {
+	r := textproto.NewReader(bufio.NewReader(in))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		var body []byte
+		var err error
+		if s.lines {
+			body, err = readLine(r)
+		} else {
+			body, err = readFrame(r)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(rpcResponse{Error: &rpcError{rpcParseError, err.Error()}})
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(req)
+		}()
+	}
+}
+
+func (s *rpcServer) handle(req rpcRequest) {
+	res := rpcResponse{ID: req.ID}
+	method, ok := s.methods[req.Method]
+	switch {
+	case req.JSONRPC != "2.0":
+		res.Error = &rpcError{rpcInvalidRequest, `jsonrpc must be "2.0"`}
+	case !ok:
+		res.Error = &rpcError{rpcMethodNotFound, "unknown method " + req.Method}
+	default:
+		result, err := method(req.Params)
+		var re *rpcError
+		switch {
+		case errors.As(err, &re):
+			res.Error = re
+		case err != nil:
+			res.Error = &rpcError{rpcAppError - exitCode(err), err.Error()}
+		default:
+			res.Result = result
+		}
+	}
+	// notifications are not answered
+	if req.ID == nil {
+		return
+	}
+	s.reply(res)
+}
+
+func (s *rpcServer) reply(res rpcResponse) {
+	res.JSONRPC = "2.0"
+	if res.ID == nil {
+		res.ID = json.RawMessage("null")
+	}
+	body, err := json.Marshal(res)
+	if err != nil {
+		body, _ = json.Marshal(rpcResponse{JSONRPC: "2.0", ID: res.ID, Error: &rpcError{rpcAppError, err.Error()}})
+	}
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	if s.lines {
+		fmt.Fprintf(s.out, "%s\n", body)
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// readLine reads one newline delimited message, skipping blank lines
+func readLine(r *textproto.Reader) ([]byte, error) {
+	for {
+		l, err := r.R.ReadBytes('\n')
+		if l = bytes.TrimSpace(l); len(l) > 0 {
+			return l, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readFrame reads the headers and body of one message
+func readFrame(r *textproto.Reader) ([]byte, error) {
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(h) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	n, err := strconv.Atoi(h.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", h.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r.R, body)
+	return body, err
+}
+
+// params decodes the parameters of a method
+func params(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpcError{rpcInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+// resolve looks up {"identifier", "format"} and returns its record
+func (s *rpcServer) resolve(raw json.RawMessage) (any, error) {
+	var p struct {
+		Identifier string `json:"identifier"`
+		Format     string `json:"format"`
+	}
+	if err := params(raw, &p); err != nil {
+		return nil, err
+	}
+	w, err := resolve.Resolve(p.Identifier, s.cfg.resolveOptions())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := loadLibrary(s.cfg.Library)
+	if err != nil {
+		return nil, err
+	}
+	w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(s.cfg.KeyTemplate, &w.Entry), library.Keys(entries))
+	return newRecord(p.Identifier, 0, w, s.format(p.Format)), nil
+}
+
+// search returns the library entries matching {"query"}
+func (s *rpcServer) search(raw json.RawMessage) (any, error) {
+	var p struct {
+		Query string `json:"query"`
+	}
+	if err := params(raw, &p); err != nil {
+		return nil, err
+	}
+	x, err := openIndex(s.cfg.Library)
+	if err != nil {
+		return nil, err
+	}
+	records := []record{}
+	for _, e := range x.Search(p.Query) {
+		records = append(records, entryRecord(e, s.cfg.Format))
+	}
+	return records, nil
+}
+
+// insertKey returns the citation key for {"identifier"}, importing the
+// work into the library unless an entry with its DOI is already there
+func (s *rpcServer) insertKey(raw json.RawMessage) (any, error) {
+	var p struct {
+		Identifier string `json:"identifier"`
+	}
+	if err := params(raw, &p); err != nil {
+		return nil, err
+	}
+	e, imported, err := s.importIdentifier(p.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		Key      string `json:"key"`
+		Imported bool   `json:"imported"`
+	}{e.Key, imported}, nil
+}
+
+// importIdentifier resolves an identifier and appends it to the library.
+// An entry with the same DOI, or without one the same title and year, is
+// returned instead of importing it twice.
+func (s *rpcServer) importIdentifier(id string) (Entry, bool, error) {
+	w, err := resolve.Resolve(id, s.cfg.resolveOptions())
+	if err != nil {
+		return Entry{}, false, err
+	}
+	s.libMu.Lock()
+	defer s.libMu.Unlock()
+	// the server outlives edits of the library, each import starts from
+	// the file as it is
+	forgetFile(s.cfg.Library)
+	entries, err := loadLibrary(s.cfg.Library)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	doi := w.Entry.Get("doi")
+	title := library.NormalizeTitle(w.Entry.Get("title"))
+	for _, e := range entries {
+		switch {
+		case doi != "":
+			if strings.EqualFold(e.Get("doi"), doi) {
+				return e, false, nil
+			}
+		// arXiv and ISBN lookups may come without a DOI
+		case title != "" && library.NormalizeTitle(e.Get("title")) == title && entryYear(&e) == entryYear(&w.Entry):
+			return e, false, nil
+		}
+	}
+	keys := library.Keys(entries)
+	w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(s.cfg.KeyTemplate, &w.Entry), keys)
+	e := bibtex.Convert(w.Entry, s.cfg.Format)
+	if err := preImport(s.cfg, &e, w.Abstract, s.cfg.Library, keys); err != nil {
+		return Entry{}, false, err
+	}
+	if _, err := mutate(s.cfg.Library, "import "+e.Key, func() error {
+		return appendEntry(s.cfg.Library, &e)
+	}); err != nil {
+		return Entry{}, false, err
+	}
+	if err := syncImported(s.cfg, e, w.Abstract); err != nil {
+		slog.Warn("sync failed", "key", e.Key, "err", err)
+	}
+	return e, true, nil
+}
+
+// entryYear is the year of an entry, of a BibLaTeX date when it has no year
+func entryYear(e *Entry) string {
+	if y := e.Get("year"); y != "" {
+		return y
+	}
+	y, _, _ := strings.Cut(e.Get("date"), "-")
+	return y
+}
+
+// lint checks {"file"}, the library by default
+func (s *rpcServer) lint(raw json.RawMessage) (any, error) {
+	var p struct {
+		File string `json:"file"`
+	}
+	if err := params(raw, &p); err != nil {
+		return nil, err
+	}
+	if p.File == "" {
+		p.File = s.cfg.Library
+	}
+	entries, err := loadLibrary(p.File)
+	if err != nil {
+		return nil, err
+	}
+	type finding struct {
+		Key     string `json:"key"`
+		Message string `json:"message"`
+	}
+	findings := []finding{}
+	for _, i := range lintEntries(entries) {
+		findings = append(findings, finding{i.Key, i.Message})
+	}
+	return findings, nil
+}
+
+func (s *rpcServer) format(f string) string {
+	if _, ok := bibtex.Formats[f]; ok {
+		return f
+	}
+	return s.cfg.Format
+}
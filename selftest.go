@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// selfTest looks up a known identifier with one resolver and checks the
+// answer. run returns what it found, or errSkipped when the resolver is not
+// configured.
+type selfTest struct {
+	name string
+	run  func(opts resolve.Options) (string, error)
+}
+
+var errSkipped = errors.New("skipped")
+
+// selfTestDOI is LeCun, Bengio and Hinton, "Deep learning", Nature 2015:
+// registered with CrossRef, open access, and widely cited and discussed
+const selfTestDOI = "10.1038/nature14539"
+
+// selfTests are the checks of bibgloss selftest, in the order they run
+var selfTests = []selfTest{
+	{"crossref", func(resolve.Options) (string, error) {
+		e, _, err := resolve.FetchCrossref(selfTestDOI)
+		if err != nil {
+			return "", err
+		}
+		return expectTitle(e.Get("title"), "Deep learning")
+	}},
+	{"datacite", func(resolve.Options) (string, error) {
+		// arXiv registers its DOIs with DataCite
+		doi, _ := resolve.ArXivDOI("arXiv:1706.03762")
+		e, _, err := resolve.FetchDataCite(doi)
+		if err != nil {
+			return "", err
+		}
+		return expectTitle(e.Get("title"), "Attention Is All You Need")
+	}},
+	{"openalex", enricherTest("openalex", func(w *resolve.Work) bool { return w.Citations > 0 })},
+	{"unpaywall", func(opts resolve.Options) (string, error) {
+		if opts.Email == "" {
+			return "", fmt.Errorf("%w: no contact email configured", errSkipped)
+		}
+		return enricherTest("unpaywall", func(w *resolve.Work) bool { return w.OAStatus != "" })(opts)
+	}},
+	{"semanticscholar", enricherTest("semanticscholar", func(w *resolve.Work) bool { return w.Citations > 0 })},
+	{"openlibrary", func(resolve.Options) (string, error) {
+		editions, err := resolve.FetchEditions("9780262033848", "Introduction to Algorithms", "Cormen")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d editions of Introduction to Algorithms", len(editions)), nil
+	}},
+	{"orcid", func(resolve.Options) (string, error) {
+		// the record ORCID keeps for testing
+		dois, err := resolve.FetchORCIDWorks("0000-0002-1825-0097")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d DOIs of Josiah Carberry", len(dois)), nil
+	}},
+	{"patents", func(opts resolve.Options) (string, error) {
+		p, _ := resolve.ParsePatent("EP1000000")
+		w, err := resolve.ResolvePatent(p, opts)
+		if err != nil {
+			return "", err
+		}
+		if w.Entry.Get("title") == "" {
+			return "", errors.New("EP1000000 has no title")
+		}
+		return w.Entry.Get("title"), nil
+	}},
+	{"eventdata", func(opts resolve.Options) (string, error) {
+		mentions, total, err := resolve.FetchMentions(selfTestDOI, opts.Email)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d pages mentioning %s, of %d events", len(mentions), selfTestDOI, total), nil
+	}},
+}
+
+// enricherTest runs one enricher on a work of selfTestDOI, which ok checks
+func enricherTest(name string, ok func(w *resolve.Work) bool) func(resolve.Options) (string, error) {
+	return func(opts resolve.Options) (string, error) {
+		w := &resolve.Work{Citations: -1, Sources: map[string]string{}}
+		if err := resolve.Enrichers[name](w, selfTestDOI, opts); err != nil {
+			return "", err
+		}
+		if !ok(w) {
+			return "", fmt.Errorf("no data for %s", selfTestDOI)
+		}
+		detail := fmt.Sprintf("%d citations", w.Citations)
+		if w.OAStatus != "" {
+			detail = "open access: " + w.OAStatus
+		}
+		return detail, nil
+	}
+}
+
+// expectTitle checks a title is the one an identifier has
+func expectTitle(title, want string) (string, error) {
+	title = unbrace.Replace(title)
+	if !strings.EqualFold(title, want) {
+		return "", fmt.Errorf("got title %q, want %q", title, want)
+	}
+	return title, nil
+}
+
+// cassettePath is where selftest records the responses by default
+func cassettePath() string {
+	return filepath.Join(dataDir("cassettes"), "selftest.json")
+}
+
+// runSelfTests runs the self-tests named, all when none are, as checks of
+// bibgloss doctor
+func runSelfTests(cfg config, names []string) ([]check, error) {
+	for _, n := range names {
+		if !slices.ContainsFunc(selfTests, func(t selfTest) bool { return t.name == n }) {
+			known := make([]string, len(selfTests))
+			for i, t := range selfTests {
+				known[i] = t.name
+			}
+			return nil, withCode(exitInvalid, fmt.Errorf("unknown self-test %s, use %s", n, strings.Join(known, ", ")))
+		}
+	}
+	opts := cfg.resolveOptions()
+	var checks []check
+	for _, t := range selfTests {
+		if len(names) > 0 && !slices.Contains(names, t.name) {
+			continue
+		}
+		start := time.Now()
+		detail, err := t.run(opts)
+		took := time.Since(start).Round(time.Millisecond)
+		// the status of a refused request, like 403 Forbidden
+		status := 0
+		if he := (*resolve.HTTPError)(nil); errors.As(err, &he) {
+			status, _ = strconv.Atoi(strings.Fields(he.Status + " 0")[0])
+		}
+		switch {
+		case errors.Is(err, errSkipped):
+			checks = append(checks, check{t.name, checkWarn, err.Error(), "set email in the config or BIBGLOSS_EMAIL"})
+		case errors.Is(err, resolve.ErrNotRecorded):
+			checks = append(checks, check{t.name, checkFail, err.Error(), "record the cassette again with --record"})
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			checks = append(checks, check{t.name, checkFail, err.Error(), "check the API key in the config"})
+		case status == http.StatusTooManyRequests:
+			checks = append(checks, check{t.name, checkWarn, err.Error(), "you are rate limited, wait or configure an API key"})
+		case err != nil:
+			checks = append(checks, check{t.name, checkFail, err.Error(), "check your network connection, or whether the service changed its API"})
+		default:
+			checks = append(checks, check{t.name, checkOK, fmt.Sprintf("%s (%s)", detail, took), ""})
+		}
+	}
+	return checks, nil
+}
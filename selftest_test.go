@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// testdata/selftest.json holds the answers of every service to the
+// self-tests, trimmed to the fields the resolvers read. Replaying it checks
+// the resolvers still understand them.
+func replaySelfTests(t *testing.T) {
+	t.Helper()
+	c, err := resolve.ReplayCassette("testdata/selftest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() }) // nolint:errcheck
+}
+
+func TestSelfTestsReplay(t *testing.T) {
+	replaySelfTests(t)
+	cfg := config{Email: "me@example.org"}
+	checks, err := runSelfTests(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"crossref":        "Deep learning",
+		"datacite":        "Attention Is All You Need",
+		"openalex":        "open access: closed",
+		"unpaywall":       "open access: closed",
+		"semanticscholar": "49976 citations",
+		"openlibrary":     "3 editions of Introduction to Algorithms",
+		"orcid":           "2 DOIs of Josiah Carberry",
+		"patents":         "Apparatus for manufacturing green bricks for the brick manufacturing industry",
+		// the revisions of an article count once, citations not at all
+		"eventdata": "1 pages mentioning 10.1038/nature14539, of 3 events",
+	}
+	if len(checks) != len(selfTests) {
+		t.Fatalf("got %d checks, want %d", len(checks), len(selfTests))
+	}
+	for _, c := range checks {
+		t.Run(c.Name, func(t *testing.T) {
+			if c.Status != checkOK {
+				t.Fatalf("failed: %s", c.Detail)
+			}
+			// the detail ends in how long the test took
+			if detail, _, _ := strings.Cut(c.Detail, " ("); detail != want[c.Name] {
+				t.Errorf("got %q, want %q", detail, want[c.Name])
+			}
+		})
+	}
+}
+
+func TestSelfTestsNotRecorded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(path, []byte(`{"interactions": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := resolve.ReplayCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close() // nolint:errcheck
+	checks, err := runSelfTests(config{Email: "me@example.org"}, []string{"crossref", "orcid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range checks {
+		if c.Status != checkFail || !strings.Contains(c.Fix, "--record") {
+			t.Errorf("%s: got %+v, want a failure asking to record again", c.Name, c)
+		}
+	}
+	if _, err := runSelfTests(config{}, []string{"nosuch"}); err == nil {
+		t.Error("unknown self-test accepted")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// batchLimit caps the identifiers of one POST /batch request
+const batchLimit = 500
+
+// server answers the HTTP API of bibgloss serve
+type server struct {
+	cfg config
+}
+
+func newServer(cfg config) http.Handler {
+	s := &server{cfg}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /resolve/{doi...}", s.resolve)
+	mux.HandleFunc("POST /batch", s.batch)
+	mux.HandleFunc("GET /library/search", s.search)
+	mux.HandleFunc("GET /library/{key}", s.entry)
+	return logRequests(mux)
+}
+
+// resolve answers GET /resolve/{doi}?format=bibtex|biblatex|json
+func (s *server) resolve(w http.ResponseWriter, r *http.Request) {
+	format, err := s.format(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	work, err := resolve.Resolve(r.PathValue("doi"), s.cfg.resolveOptions())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.key(work); err != nil {
+		writeError(w, err)
+		return
+	}
+	if format == "json" {
+		writeJSON(w, http.StatusOK, newRecord(r.PathValue("doi"), 0, work, s.cfg.Format))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-bibtex; charset=utf-8")
+	fmt.Fprint(w, bibtex.Render(&work.Entry, format))
+}
+
+// batch answers POST /batch with a JSON array of records, one per
+// identifier. The body is {"identifiers": [...]} or one identifier per line.
+func (s *server) batch(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			Identifiers []string `json:"identifiers"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, withCode(exitInvalid, err))
+			return
+		}
+		ids = req.Identifiers
+	} else if err := eachIdentifier([]string{"-"}, r.Body, func(id string, line int) error {
+		ids = append(ids, id)
+		return nil
+	}); err != nil {
+		writeError(w, withCode(exitInvalid, err))
+		return
+	}
+	if len(ids) > batchLimit {
+		writeError(w, withCode(exitInvalid, fmt.Errorf("at most %d identifiers per batch", batchLimit)))
+		return
+	}
+
+	records := []record{}
+	err := resolveConcurrently(4, ids, strings.NewReader(""), s.cfg.resolveOptions(), func(id string, line int, work *Work, err error) error {
+		if err == nil {
+			err = s.key(work)
+		}
+		if err != nil {
+			records = append(records, record{Identifier: id, Error: err.Error()})
+			return nil
+		}
+		records = append(records, newRecord(id, 0, work, s.cfg.Format))
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// search answers GET /library/search?q=...
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	x, err := openIndex(s.cfg.Library)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	records := []record{}
+	for _, e := range x.Search(r.URL.Query().Get("q")) {
+		records = append(records, entryRecord(e, s.cfg.Format))
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// entry answers GET /library/{key}
+func (s *server) entry(w http.ResponseWriter, r *http.Request) {
+	e, err := findEntry(s.cfg.Library, r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entryRecord(e, s.cfg.Format))
+}
+
+// format reads the format query parameter, defaulting to the configured one
+func (s *server) format(r *http.Request) (string, error) {
+	f := r.URL.Query().Get("format")
+	if f == "" {
+		return s.cfg.Format, nil
+	}
+	if _, ok := bibtex.Formats[f]; !ok && f != "json" {
+		return "", withCode(exitInvalid, fmt.Errorf("unknown format %q", f))
+	}
+	return f, nil
+}
+
+// key gives a work the citation key it would get on import
+func (s *server) key(w *Work) error {
+	entries, err := loadLibrary(s.cfg.Library)
+	if err != nil {
+		return err
+	}
+	w.Entry.Key = bibtex.UniqueKey(bibtex.FormatKey(s.cfg.KeyTemplate, &w.Entry), library.Keys(entries))
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("writing response failed", "err", err)
+	}
+}
+
+// writeError answers with the HTTP status matching the exit code of err
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch exitCode(err) {
+	case exitNotFound:
+		status = http.StatusNotFound
+	case exitInvalid:
+		status = http.StatusBadRequest
+	case exitNetwork:
+		status = http.StatusBadGateway
+	}
+	if errors.Is(err, resolve.ErrOffline) {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{w, http.StatusOK}
+		h.ServeHTTP(rec, r)
+		slog.Info("request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
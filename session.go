@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// session is the state of the TUI saved on exit and offered for restore on
+// the next start, so an interrupted batch does not start over
+type session struct {
+	// Library is the absolute path of the library the session worked on,
+	// sessions of other libraries are not offered
+	Library string `json:"library"`
+	// Screen is the tab the TUI was on: input, library, inbox or queue
+	Screen string `json:"screen"`
+	// Input is the text of the DOI input
+	Input string `json:"input,omitempty"`
+	// Pending are the identifiers of the batch not resolved yet
+	Pending []string `json:"pending,omitempty"`
+	// Queue are the resolved works not imported yet, with the one shown
+	// in the detail view
+	Queue     []sessionWork `json:"queue,omitempty"`
+	TagFilter string        `json:"tag_filter,omitempty"`
+	Saved     time.Time     `json:"saved"`
+}
+
+// sessionWork is a work of the queue and the identifier it was resolved
+// from
+type sessionWork struct {
+	ID   string `json:"id"`
+	Work *Work  `json:"work"`
+}
+
+// sessionScreens names the tabs a session returns to
+var sessionScreens = map[state]string{
+	stateInput:   "input",
+	stateLibrary: "library",
+	stateInbox:   "inbox",
+	stateQueue:   "queue",
+}
+
+// sessionPath returns the session file next to the follow state
+func sessionPath() string {
+	return statePath("session.json")
+}
+
+// empty reports whether a session has nothing worth restoring
+func (s *session) empty() bool {
+	return len(s.Pending) == 0 && len(s.Queue) == 0 && s.Input == ""
+}
+
+// summary describes what restoring a session brings back
+func (s *session) summary() string {
+	var parts []string
+	if n := len(s.Pending); n > 0 {
+		parts = append(parts, tr("%d to resolve", n))
+	}
+	if n := len(s.Queue); n > 0 {
+		parts = append(parts, tr("%d to import", n))
+	}
+	if s.Input != "" {
+		parts = append(parts, fmt.Sprintf("%q", s.Input))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// loadSession reads the saved session of a library, nil when there is
+// none
+func loadSession(path, bib string) (*session, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if abs, _ := filepath.Abs(bib); s.Library != abs || s.empty() {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// saveSession writes a session, or removes the saved one when there is
+// nothing to restore
+func saveSession(path string, s *session) error {
+	if s.empty() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// session captures the state of the TUI. A session offered but neither
+// restored nor declined yet is kept as it is.
+func (m model) session() *session {
+	if m.restore != nil {
+		return m.restore
+	}
+	abs, _ := filepath.Abs(m.cfg.Library)
+	s := &session{
+		Library:   abs,
+		Input:     strings.TrimSpace(m.textInput.Value()),
+		Pending:   m.pending,
+		TagFilter: m.tagFilter,
+		Saved:     time.Now(),
+	}
+	screen := m.state
+	switch m.state {
+	case stateDetail:
+		screen = m.prev
+	case stateFetching:
+		screen = m.fetchFrom
+	case stateReview:
+		screen = stateLibrary
+	}
+	s.Screen = sessionScreens[screen]
+	if s.Screen == "" {
+		s.Screen = sessionScreens[stateInput]
+	}
+	for _, it := range m.queue.Items() {
+		s.Queue = append(s.Queue, sessionWork{it.(queueItem).id, it.(queueItem).work})
+	}
+	// a fetched work shown but not imported is kept with the queue
+	if m.state == stateDetail && m.prev != stateLibrary && m.prev != stateQueue && m.work != nil {
+		id := m.work.Entry.Get("doi")
+		if id == "" {
+			id = m.work.Entry.Key
+		}
+		s.Queue = append(s.Queue, sessionWork{id, m.work})
+	}
+	return s
+}
+
+// restoreSession brings back a saved session
+func (m *model) restoreSession(s *session) tea.Cmd {
+	m.textInput.SetValue(s.Input)
+	m.tagFilter = s.TagFilter
+	items := make([]list.Item, 0, len(s.Queue))
+	for _, w := range s.Queue {
+		items = append(items, queueItem{w.ID, w.Work})
+	}
+	cmds := []tea.Cmd{m.queue.SetItems(items), m.enqueue(s.Pending...)}
+	switch s.Screen {
+	case "library":
+		m.state = stateLibrary
+		cmds = append(cmds, loadLibraryCmd(m.cfg.Library), loadMetricsCmd(m.cfg.Library))
+	case "inbox":
+		m.state = stateInbox
+	case "queue":
+		m.state = stateQueue
+	}
+	m.message = tr("restored the session of %s", s.Saved.Format(time.DateTime))
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// similarEntries returns the library entries whose title is close to the
+// one of e, the closest first
+func similarEntries(entries []Entry, e *Entry) []Entry {
+	var out []Entry
+	for _, i := range library.SimilarTitles(entries, e.Get("title")) {
+		out = append(out, entries[i])
+	}
+	return out
+}
+
+// similarWarning names the library entries a work may duplicate
+func (m model) similarWarning() string {
+	keys := make([]string, len(m.similar))
+	for i, e := range m.similar {
+		keys[i] = e.Key
+	}
+	return tr("the library has a similar title as %s, c compares", joinList(keys))
+}
+
+// compareNext shows the next similar entry beside the work, and the work
+// alone after the last one
+func (m *model) compareNext() {
+	m.compare = (m.compare + 1) % (len(m.similar) + 1)
+	m.setDetail()
+	m.viewport.GotoTop()
+}
+
+// renderCompare renders the shown work next to the similar library entry
+// it is compared with
+func (m model) renderCompare() string {
+	half := (m.viewport.Width - 3) / 2
+	other := m.similar[m.compare-1]
+	left := labelStyle.Render(tr("new")) + "\n" + renderDetail(m.work, half)
+	right := labelStyle.Render(tr("in the library as %s", other.Key)) + "\n" + renderDetail(entryItem{entry: other}.work(), half)
+	height := max(lipgloss.Height(left), lipgloss.Height(right))
+	divider := strings.TrimSuffix(strings.Repeat(" "+labelStyle.Render("│")+" \n", height), "\n")
+	return lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(half).Render(left), divider, lipgloss.NewStyle().Width(half).Render(right))
+}
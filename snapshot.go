@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// snapshot is the content of a file before a mutation. Every write to the
+// library takes one: it is kept on disk as <file>.bak and on the in-session
+// undo stack.
+type snapshot struct {
+	path   string
+	data   []byte
+	exists bool
+	// label describes the change that undoing the snapshot reverts
+	label string
+}
+
+// takeSnapshot records the current content of path and refreshes its
+// backup file
+func takeSnapshot(path, label string) (*snapshot, error) {
+	s := &snapshot{path: path, label: label}
+	data, err := readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.data, s.exists = data, true
+	if dryRun {
+		return s, nil
+	}
+	if err := writeAtomic(path+".bak", data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// restore puts the file back into the recorded state
+func (s *snapshot) restore() error {
+	m := mutationLock(s.path)
+	m.Lock()
+	defer m.Unlock()
+	current, err := takeSnapshot(s.path, "")
+	if err != nil {
+		return err
+	}
+	if !s.exists {
+		err = removeFile(s.path)
+	} else {
+		err = writeFile(s.path, s.data)
+	}
+	if err == nil {
+		logChanges(s.path, "undo "+s.label, current)
+	}
+	return err
+}
+
+// mutate snapshots path and applies fn to it. The file stays locked from
+// the snapshot to the last write of fn, against other processes and other
+// mutations of this one. The snapshot is returned so the change can be
+// undone.
+func mutate(path, label string, fn func() error) (*snapshot, error) {
+	m := mutationLock(path)
+	m.Lock()
+	defer m.Unlock()
+	unlock, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	s, err := takeSnapshot(path, label)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(); err != nil {
+		return nil, err
+	}
+	logChanges(path, label, s)
+	return s, nil
+}
+
+type undoneMsg struct {
+	label   string
+	entries []Entry
+}
+
+// undo restores a snapshot and reloads the library
+func undo(library string, s *snapshot) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.restore(); err != nil {
+			return errMsg{err}
+		}
+		entries, err := loadLibrary(library)
+		if err != nil {
+			return errMsg{err}
+		}
+		return undoneMsg{s.label, entries}
+	}
+}
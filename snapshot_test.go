@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMutateConcurrent runs an edit that reads the library, then writes it
+// back slowly, next to an import. Both changes have to survive.
+func TestMutateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refs.bib")
+	if err := os.WriteFile(path, []byte("@misc{a,\n  title = {A}\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, errs[0] = mutate(path, "attach", func() error {
+			data, err := readFile(path)
+			close(read)
+			if err != nil {
+				return err
+			}
+			time.Sleep(100 * time.Millisecond)
+			return writeFile(path, bytes.Replace(data, []byte("{A}\n"), []byte("{A},\n  file = {a.pdf}\n"), 1))
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		<-read
+		_, errs[1] = mutate(path, "import b", func() error {
+			return appendEntry(path, &Entry{Type: "misc", Key: "b", Fields: []Field{{Name: "title", Value: "B"}}})
+		})
+	}()
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := loadLibrary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Get("file") != "a.pdf" || entries[1].Key != "b" {
+		t.Errorf("got %+v, want a with its file and b", entries)
+	}
+}
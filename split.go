@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// splitMinWidth is the narrowest terminal the library is shown in next to a
+// preview of the selected entry
+const splitMinWidth = 100
+
+// splitRatio bounds the share of the width the library list takes, in
+// percent, and how far < and > move the divider
+const (
+	splitDefault = 45
+	splitMin     = 20
+	splitMax     = 80
+	splitStep    = 5
+)
+
+// split reports whether the library is shown next to the preview
+func (m model) split() bool {
+	return m.width >= splitMinWidth
+}
+
+// layoutLibrary sizes the library list, and the preview when the terminal
+// is wide enough for both
+func (m *model) layoutLibrary() {
+	height := m.height - 2
+	if !m.split() {
+		m.list.SetSize(m.width, height)
+		m.previewFocus = false
+		return
+	}
+	left := m.width * m.splitRatio / 100
+	m.list.SetSize(left, height)
+	// the divider takes a column and a space on each side
+	m.preview.Width = m.width - left - 3
+	m.preview.Height = height
+	m.setPreview()
+}
+
+// resizeSplit moves the divider by step percent of the width
+func (m *model) resizeSplit(step int) {
+	m.splitRatio = min(max(m.splitRatio+step, splitMin), splitMax)
+	m.layoutLibrary()
+}
+
+// setPreview renders the selected entry into the preview, scrolled to the
+// top when the selection changed
+func (m *model) setPreview() {
+	if !m.split() {
+		return
+	}
+	item, ok := m.list.SelectedItem().(entryItem)
+	if !ok {
+		m.preview.SetContent("")
+		m.previewKey = ""
+		return
+	}
+	if m.previewBibTeX {
+		wrap := lipgloss.NewStyle().Width(m.preview.Width)
+		m.preview.SetContent(wrap.Render(bibtex.Render(&item.entry, m.cfg.Format)))
+	} else {
+		m.preview.SetContent(renderDetail(item.work(), m.preview.Width))
+	}
+	if item.entry.Key != m.previewKey {
+		m.preview.GotoTop()
+		m.previewKey = item.entry.Key
+	}
+}
+
+// splitView draws the library list and the preview side by side. The
+// divider is bold while the preview has the focus.
+func (m model) splitView() string {
+	left := lipgloss.NewStyle().Width(m.list.Width()).Render(m.list.View())
+	bar := labelStyle.Render("│")
+	if m.previewFocus {
+		bar = titleStyle.Render("┃")
+	}
+	divider := strings.TrimSuffix(strings.Repeat(" "+bar+" \n", m.preview.Height), "\n")
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, divider, m.preview.View())
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// dryRun keeps changes to the library and glossary in memory instead of
+// writing them
+var dryRun bool
+
+// staged holds the would-be content of the files changed in a dry run.
+// readFile sees it, so later steps of a command build on earlier ones.
+var staged = map[string][]byte{}
+
+// afterCommit are the steps of a dry run that reach beyond the files, like
+// pushing to Zotero or running hooks, by what they do. They wait for the
+// staged changes to be applied and are dropped with them.
+var afterCommit []pendingStep
+
+type pendingStep struct {
+	label string
+	run   func() error
+}
+
+// whenCommitted runs fn, or in a dry run once the staged changes are
+// applied
+func whenCommitted(label string, fn func() error) error {
+	if dryRun {
+		afterCommit = append(afterCommit, pendingStep{label, fn})
+		return nil
+	}
+	return fn()
+}
+
+// runAfterCommit runs the steps waiting for the staged changes, all of them
+// even when one fails
+func runAfterCommit() error {
+	steps := afterCommit
+	afterCommit = nil
+	var errs []error
+	for _, s := range steps {
+		errs = append(errs, s.run())
+	}
+	return errors.Join(errs...)
+}
+
+// readFile reads a file as the current run left it
+func readFile(path string) ([]byte, error) {
+	if data, ok := staged[path]; ok {
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		rememberFile(path, fileSum{true, sha256.Sum256(data)})
+	case errors.Is(err, fs.ErrNotExist):
+		rememberFile(path, fileSum{})
+	}
+	return data, err
+}
+
+// fileSum is the state of a file on disk, the checksum of its content when
+// it exists
+type fileSum struct {
+	exists bool
+	sum    [sha256.Size]byte
+}
+
+var (
+	sumsMu sync.Mutex
+	// sums are the files as this run first read them, or last wrote them.
+	// Writes check the disk against them, so an edit saved meanwhile, say by
+	// an editor, is not overwritten.
+	sums = map[string]fileSum{}
+)
+
+// rememberFile records the state a file was read in, unless an earlier read
+// did already
+func rememberFile(path string, s fileSum) {
+	sumsMu.Lock()
+	defer sumsMu.Unlock()
+	if _, ok := sums[path]; !ok {
+		sums[path] = s
+	}
+}
+
+// forgetFile makes the next read of path the state later writes are checked
+// against, for reloading a file that changed
+func forgetFile(path string) {
+	sumsMu.Lock()
+	defer sumsMu.Unlock()
+	delete(sums, path)
+}
+
+// forgetFiles does forgetFile for every file read so far
+func forgetFiles() {
+	sumsMu.Lock()
+	defer sumsMu.Unlock()
+	clear(sums)
+}
+
+// changedError refuses a write to a file that changed on disk since it was
+// read
+type changedError struct{ path string }
+
+func (e *changedError) Error() string {
+	return e.path + " changed on disk since bibgloss read it"
+}
+
+// checkUnchanged returns a *changedError when path differs from the state it
+// was read in. Files this run did not read are not checked.
+func checkUnchanged(path string) error {
+	sumsMu.Lock()
+	want, ok := sums[path]
+	sumsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	got, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return &changedError{path}
+	}
+	return nil
+}
+
+// hashFile returns the state of a file on disk
+func hashFile(path string) (fileSum, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileSum{}, nil
+	}
+	if err != nil {
+		return fileSum{}, err
+	}
+	defer f.Close() // nolint:errcheck
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fileSum{}, err
+	}
+	return fileSum{true, [sha256.Size]byte(h.Sum(nil))}, nil
+}
+
+// writeFile replaces the content of a file, or stages it in a dry run
+func writeFile(path string, data []byte) error {
+	if dryRun {
+		staged[path] = data
+		return nil
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkUnchanged(path); err != nil {
+		return err
+	}
+	if err := writeAtomic(path, data); err != nil {
+		return err
+	}
+	sumsMu.Lock()
+	sums[path] = fileSum{true, sha256.Sum256(data)}
+	sumsMu.Unlock()
+	return nil
+}
+
+// appendFile adds s to the end of a file, creating it if necessary. The
+// file is replaced as a whole like by writeFile, so a crash leaves either
+// the old content or the new one.
+func appendFile(path, s string) error {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	data, err := readFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return writeFile(path, append(slices.Clip(data), s...))
+}
+
+// removeFile deletes a file. In a dry run it only drops staged changes,
+// which is right for undoing the creation of a file.
+func removeFile(path string) error {
+	if dryRun {
+		delete(staged, path)
+		return nil
+	}
+	if err := checkUnchanged(path); err != nil {
+		return err
+	}
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	sumsMu.Lock()
+	sums[path] = fileSum{}
+	sumsMu.Unlock()
+	return nil
+}
+
+// printStaged writes a unified diff of every staged file against the disk
+func printStaged(w io.Writer) error {
+	paths := make([]string, 0, len(staged))
+	for path := range staged {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		old, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if _, err := io.WriteString(w, unifiedDiff(path, string(old), string(staged[path]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines splits text into lines, keeping their line breaks
+func lines(s string) []string {
+	l := strings.SplitAfter(s, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
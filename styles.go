@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// cslRepository serves the files of the official CSL style repository,
+// dependent styles live in its dependent directory
+const cslRepository = "https://raw.githubusercontent.com/citation-style-language/styles/master/"
+
+var (
+	cslTitleRe = regexp.MustCompile(`<title>([^<]*)</title>`)
+	cslLinkRe  = regexp.MustCompile(`<link\b[^>]*>`)
+	cslHrefRe  = regexp.MustCompile(`href="[^"]*/styles/([^"/]+)"`)
+	// cslNameRe matches the file names styles are published under
+	cslNameRe = regexp.MustCompile(`^[a-z0-9]+(?:[-.][a-z0-9]+)*$`)
+)
+
+// cslStyle is an installed style
+type cslStyle struct {
+	Name  string
+	Title string
+	// Parent is the independent style a dependent one renders with
+	Parent string
+}
+
+// dataDir returns $XDG_DATA_HOME/bibgloss/<name>, falling back to
+// ~/.local/share when the former is unset
+func dataDir(name string) string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "bibgloss", name)
+}
+
+// stylesDir holds the installed CSL styles
+func stylesDir() string {
+	return dataDir("styles")
+}
+
+// parseStyle reads the title and, for dependent styles, the parent of a
+// CSL file
+func parseStyle(name string, data []byte) cslStyle {
+	s := cslStyle{Name: name}
+	if m := cslTitleRe.FindSubmatch(data); m != nil {
+		s.Title = strings.TrimSpace(string(m[1]))
+	}
+	for _, link := range cslLinkRe.FindAll(data, -1) {
+		if !bytes.Contains(link, []byte(`rel="independent-parent"`)) {
+			continue
+		}
+		if m := cslHrefRe.FindSubmatch(link); m != nil {
+			s.Parent = string(m[1])
+		}
+	}
+	return s
+}
+
+// downloadStyle fetches a style from the repository, looking among the
+// dependent styles when there is no independent one of that name
+func downloadStyle(name string) ([]byte, error) {
+	if !cslNameRe.MatchString(name) {
+		return nil, withCode(exitInvalid, fmt.Errorf("%q is not a style name, names look like apa or ieee", name))
+	}
+	if resolve.Offline {
+		return nil, fmt.Errorf("%s: %w", name, resolve.ErrOffline)
+	}
+	for _, u := range []string{cslRepository + name + ".csl", cslRepository + "dependent/" + name + ".csl"} {
+		res, err := resolve.Client.Get(u)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close() // nolint:errcheck
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case res.StatusCode == http.StatusNotFound:
+			continue
+		case res.StatusCode != http.StatusOK:
+			return nil, &resolve.HTTPError{URL: u, Status: res.Status}
+		case !bytes.Contains(data, []byte("<style")):
+			return nil, fmt.Errorf("%s is not a CSL style", u)
+		}
+		return data, nil
+	}
+	return nil, withCode(exitNotFound, fmt.Errorf("style %s: %w", name, resolve.ErrNotFound))
+}
+
+// installStyle downloads a style into dir, along with the parent of a
+// dependent style unless it is installed already
+func installStyle(dir, name string, out io.Writer) error {
+	data, err := downloadStyle(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".csl"), data, 0o644); err != nil {
+		return err
+	}
+	s := parseStyle(name, data)
+	fmt.Fprintf(out, "installed %s (%s)\n", s.Name, s.Title)
+	if s.Parent == "" {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, s.Parent+".csl")); err == nil {
+		return nil
+	}
+	return installStyle(dir, s.Parent, out)
+}
+
+// installedStyles lists the styles in dir by name
+func installedStyles(dir string) ([]cslStyle, error) {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var styles []cslStyle
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), ".csl")
+		if !ok || f.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		styles = append(styles, parseStyle(name, data))
+	}
+	return styles, nil
+}
+
+// updateStyles downloads every installed style again. Failures are
+// reported and leave the installed file alone.
+func updateStyles(dir string, out io.Writer) error {
+	styles, err := installedStyles(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range styles {
+		if err := installStyle(dir, s.Name, out); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stylePath returns the CSL file to render with: a path to a .csl file as
+// it is, otherwise the installed style of that name. Dependent styles only
+// rename their parent, so the parent is used.
+func stylePath(dir, style string) (string, error) {
+	if filepath.Ext(style) == ".csl" {
+		return style, nil
+	}
+	styles, err := installedStyles(dir)
+	if err != nil {
+		return "", err
+	}
+	i := slices.IndexFunc(styles, func(s cslStyle) bool { return s.Name == style })
+	if i < 0 {
+		return "", withCode(exitNotFound, fmt.Errorf("style %s is not installed, run bibgloss styles add %s", style, style))
+	}
+	if p := styles[i].Parent; p != "" {
+		if !slices.ContainsFunc(styles, func(s cslStyle) bool { return s.Name == p }) {
+			return "", withCode(exitNotFound, fmt.Errorf("%s needs its parent style, run bibgloss styles add %s", style, p))
+		}
+		style = p
+	}
+	return filepath.Join(dir, style+".csl"), nil
+}
+
+// renderCitations formats entries as a bibliography in a CSL style with
+// pandoc's citeproc, to any output format pandoc writes
+func renderCitations(csl string, entries []Entry, to string) (string, error) {
+	pandoc, err := exec.LookPath("pandoc")
+	if err != nil {
+		return "", errors.New("rendering citations needs pandoc, see https://pandoc.org/installing.html")
+	}
+	bib, err := os.CreateTemp("", "bibgloss-*.bib")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(bib.Name()) // nolint:errcheck
+	for i := range entries {
+		fmt.Fprintln(bib, entries[i].BibTeX())
+	}
+	if err := bib.Close(); err != nil {
+		return "", err
+	}
+	cmd := exec.Command(pandoc, "--citeproc", "--csl", csl, "--bibliography", bib.Name(), "--wrap=none", "-f", "markdown", "-t", to)
+	cmd.Stdin = strings.NewReader("---\nnocite: '@*'\n---\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("pandoc: %s", msg)
+		}
+		return "", fmt.Errorf("pandoc: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+)
+
+// syncConfig is the git remote a team shares its library over
+type syncConfig struct {
+	// Remote is the git remote of the shared repository
+	Remote string `toml:"remote"`
+	// File is the library in the repository, the name of the library by
+	// default
+	File string `toml:"file"`
+	// Dir is the clone sync works in, one under the data directory by
+	// default
+	Dir string `toml:"dir"`
+}
+
+// syncDir returns the clone of a remote, $XDG_DATA_HOME/bibgloss/sync/<name>
+func (s syncConfig) syncDir() string {
+	if s.Dir != "" {
+		return s.Dir
+	}
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(s.Remote, "/")), ".git")
+	sum := sha256.Sum256([]byte(s.Remote))
+	return dataDir(filepath.Join("sync", fmt.Sprintf("%s-%x", name, sum[:4])))
+}
+
+// syncOptions control a sync of the shared library
+type syncOptions struct {
+	// NoPush commits the merge in the clone without pushing it
+	NoPush bool
+}
+
+// syncLibrary merges the library with the shared one on the git remote:
+// it fetches the remote, merges the edits made to the library since the
+// last sync into the entries there with library.Merge3, pushes the result
+// and writes it to the library. The last sync is the version the clone and
+// the remote have in common, so an earlier push that failed is merged again.
+func syncLibrary(cfg config, opts syncOptions, out io.Writer) error {
+	s := cfg.Sync
+	if s.Remote == "" {
+		return withCode(exitInvalid, errors.New("no remote to sync with, set sync.remote in the config"))
+	}
+	dir := s.syncDir()
+	file := s.File
+	if file == "" {
+		file = filepath.Base(cfg.Library)
+	}
+	// a fresh clone has no last sync, entries only the remote has are new
+	fresh := false
+	if _, err := os.Stat(filepath.Join(dir, ".git")); errors.Is(err, fs.ErrNotExist) {
+		fresh = true
+		if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "cloning %s into %s\n", s.Remote, dir)
+		if _, err := git(".", "clone", "--", s.Remote, dir); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if _, err := git(dir, "fetch", "origin"); err != nil {
+		return err
+	}
+
+	// an empty remote has no branch yet, the first sync creates it
+	upstream, err := git(dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		upstream = ""
+	}
+	var base, remote []byte
+	if upstream != "" {
+		if remote, err = gitShow(dir, upstream, file); err != nil {
+			return err
+		}
+		if common, err := git(dir, "merge-base", "HEAD", upstream); err == nil && !fresh {
+			if base, err = gitShow(dir, common, file); err != nil {
+				return err
+			}
+		}
+	}
+	local, err := readFile(cfg.Library)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	parse := func(name string, data []byte) ([]Entry, error) {
+		entries, err := bibtex.Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return entries, nil
+	}
+	baseEntries, err := parse(file+" as last synced", base)
+	if err != nil {
+		return err
+	}
+	localEntries, err := parse(cfg.Library, local)
+	if err != nil {
+		return err
+	}
+	remoteEntries, err := parse(s.Remote+" "+file, remote)
+	if err != nil {
+		return err
+	}
+	m := library.Merge3(baseEntries, localEntries, remoteEntries)
+	for _, n := range m.Notes {
+		fmt.Fprintln(out, n)
+	}
+	merged := library.Splice(remote, remoteEntries, m.Changes)
+	for _, e := range m.Added {
+		if jabrefMetaStart(merged) >= 0 {
+			merged = insertEntry(merged, &e)
+		} else {
+			merged = append(merged, "\n"+e.BibTeX()...)
+		}
+	}
+	pushed := len(m.Changes) + len(m.Added)
+	if dryRun {
+		fmt.Fprintf(out, "%d entries to push\n", pushed)
+		return writeFile(cfg.Library, merged)
+	}
+
+	if upstream != "" {
+		if _, err := git(dir, "reset", "--hard", upstream); err != nil {
+			return err
+		}
+	}
+	if pushed > 0 {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, merged, 0o644); err != nil {
+			return err
+		}
+		if _, err := git(dir, "add", "--", file); err != nil {
+			return err
+		}
+		if _, err := git(dir, "commit", "-m", fmt.Sprintf("Sync %d entries of %s", pushed, filepath.ToSlash(file))); err != nil {
+			return err
+		}
+	}
+	// the library takes the merge before it is pushed, so a push that fails
+	// is retried by the next sync instead of reading as deletions
+	if !bytes.Equal(bytes.TrimSpace(merged), bytes.TrimSpace(local)) {
+		if _, err := mutate(cfg.Library, "sync", func() error {
+			return writeFile(cfg.Library, merged)
+		}); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "updated", cfg.Library)
+	}
+	switch {
+	case pushed == 0:
+		fmt.Fprintln(out, "nothing to push")
+	case opts.NoPush:
+		fmt.Fprintf(out, "committed %d entries in %s\n", pushed, dir)
+	default:
+		if _, err := git(dir, "push", "-u", "origin", "HEAD"); err != nil {
+			return fmt.Errorf("%w, sync again to retry", err)
+		}
+		fmt.Fprintf(out, "pushed %d entries to %s\n", pushed, s.Remote)
+	}
+	return nil
+}
+
+// gitShow returns a file as it is in a commit of the clone, nothing when
+// the commit does not have it
+func gitShow(dir, commit, file string) ([]byte, error) {
+	if _, err := git(dir, "cat-file", "-e", commit+":"+file); err != nil {
+		return nil, nil
+	}
+	out, err := git(dir, "show", commit+":"+file)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out + "\n"), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// parseTags splits a keywords value on commas and semicolons, dropping
+// empty and duplicate tags
+func parseTags(s string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// entryTags returns the tags stored in the entry's keywords field
+func entryTags(e *Entry) []string {
+	return parseTags(e.Get("keywords"))
+}
+
+// hasTag reports whether the entry carries tag, ignoring case. Membership
+// in a JabRef group counts as a tag.
+func hasTag(e *Entry, tag string) bool {
+	for _, t := range append(entryTags(e), entryGroups(e)...) {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByTag returns the entries carrying tag, or all entries for an
+// empty tag
+func filterByTag(entries []Entry, tag string) []Entry {
+	if tag == "" {
+		return entries
+	}
+	var out []Entry
+	for i := range entries {
+		if hasTag(&entries[i], tag) {
+			out = append(out, entries[i])
+		}
+	}
+	return out
+}
+
+// withTags returns e with its keywords replaced by the tags in value
+func withTags(e Entry, value string) Entry {
+	e.Fields = append([]Field(nil), e.Fields...)
+	e.Set("keywords", strings.Join(parseTags(value), ", "))
+	return e
+}
+
+// saveTags replaces the keywords of e in the library and reloads it
+func saveTags(path string, e Entry, value string) tea.Cmd {
+	return func() tea.Msg {
+		s, err := mutate(path, tr("edit tags of %s", e.Key), func() error {
+			return editEntry(path, e.Key, func(e *Entry) { *e = withTags(*e, value) })
+		})
+		if err != nil {
+			return errMsg{err}
+		}
+		entries, err := loadLibrary(path)
+		if err != nil {
+			return errMsg{err}
+		}
+		return libraryMsg{entries, s}
+	}
+}
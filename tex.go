@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// citeRe matches \cite, \citep, \parencite, \nocite and friends with up to
+// two optional arguments, capturing the key list
+var citeRe = regexp.MustCompile(`\\[a-zA-Z]*cite[a-zA-Z]*\*?\s*(?:\[[^\]]*\]\s*){0,2}\{([^}]*)\}`)
+
+// texCitations returns the citation keys used in a LaTeX source, in order
+// of first use. Comments are ignored, as is the \nocite{*} wildcard.
+func texCitations(src string) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, m := range citeRe.FindAllStringSubmatch(stripTeXComments(src), -1) {
+		for _, k := range strings.Split(m[1], ",") {
+			k = strings.TrimSpace(k)
+			if k != "" && k != "*" && !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// stripTeXComments removes everything from an unescaped % to the end of
+// its line
+func stripTeXComments(src string) string {
+	var b strings.Builder
+	for _, l := range strings.SplitAfter(src, "\n") {
+		for i := 0; i < len(l); i++ {
+			if l[i] == '%' && (i == 0 || l[i-1] != '\\') {
+				if strings.HasSuffix(l, "\n") {
+					l = l[:i] + "\n"
+				} else {
+					l = l[:i]
+				}
+				break
+			}
+		}
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// loadCitations reads the citation keys of a .tex, .qmd or .Rmd file
+func loadCitations(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if markdownExts[filepath.Ext(path)] {
+		return markdownCitations(string(data)), nil
+	}
+	return texCitations(string(data)), nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// color themes selectable with theme
+const (
+	themeDefault = "default"
+	themeMono    = "mono"
+)
+
+// themes set the styles used by the TUI
+var themes = map[string]func(){
+	themeDefault: func() {
+		titleStyle = lipgloss.NewStyle().Bold(true)
+		labelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+		okStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+		errStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	},
+	themeMono: func() {
+		titleStyle = lipgloss.NewStyle().Bold(true)
+		labelStyle = lipgloss.NewStyle().Faint(true)
+		okStyle = lipgloss.NewStyle()
+		errStyle = lipgloss.NewStyle().Bold(true)
+	},
+}
+
+// applyTheme switches the TUI styles, falling back to the default theme
+func applyTheme(name string) {
+	if set, ok := themes[name]; ok {
+		set()
+		return
+	}
+	themes[themeDefault]()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// printTimings writes the --timings summary of a run
+func printTimings(w io.Writer, t *resolve.Timings) {
+	fmt.Fprintf(w, "\n%-18s %7s %7s %9s %9s %9s\n", "resolver", "lookups", "failed", "mean", "max", "total")
+	for _, r := range t.Resolvers() {
+		fmt.Fprintf(w, "%-18s %7d %7d %9s %9s %9s\n", r.Name, r.Lookups, r.Failures, round(r.Mean()), round(r.Max), round(r.Total))
+	}
+	hits, misses, retries, throttled := t.Counts()
+	rate := 0
+	if hits+misses > 0 {
+		rate = 100 * hits / (hits + misses)
+	}
+	fmt.Fprintf(w, "cache: %d of %d responses cached (%d%%)\n", hits, hits+misses, rate)
+	fmt.Fprintf(w, "retries: %d, waited for rate limits: %s\n", retries, round(throttled))
+	opened, reused, http2 := t.Connections()
+	fmt.Fprintf(w, "connections: %d opened, %d reused, %d responses over HTTP/2\n", opened, reused, http2)
+	if n := t.Rescheduled(); n > 0 {
+		fmt.Fprintf(w, "scheduling: %d requests sent to a less busy API\n", n)
+	}
+}
+
+// round shortens durations to what is worth reading in a summary
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(10 * time.Millisecond)
+	}
+	return d.Round(100 * time.Microsecond)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/library"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+// translateConfig picks the service abstracts are translated with
+type translateConfig struct {
+	// Service is deepl or libretranslate, empty when none is set up
+	Service string `toml:"service"`
+	// URL is the endpoint of the service, needed for LibreTranslate
+	URL string `toml:"url"`
+	// To is the language abstracts are translated into
+	To string `toml:"to"`
+}
+
+// annotation is what bibgloss notes about an entry for the reader. It is
+// not bibliographic data, so it is kept next to the library instead of in
+// it, and never ends up in a document's bibliography.
+type annotation struct {
+	// Translation is the abstract translated from the language From into To
+	Translation string    `json:"translation,omitempty"`
+	From        string    `json:"from,omitempty"`
+	To          string    `json:"to,omitempty"`
+	Service     string    `json:"service,omitempty"`
+	Updated     time.Time `json:"updated"`
+}
+
+// annotationsPath returns the sidecar of a library, refs.bib ->
+// refs.annotations.json
+func annotationsPath(library string) string {
+	return strings.TrimSuffix(library, filepath.Ext(library)) + ".annotations.json"
+}
+
+// loadAnnotations reads the annotations of a library, keyed by citation key
+func loadAnnotations(library string) (map[string]annotation, error) {
+	annotations := map[string]annotation{}
+	data, err := readFile(annotationsPath(library))
+	if errors.Is(err, fs.ErrNotExist) {
+		return annotations, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, fmt.Errorf("%s: %w", annotationsPath(library), err)
+	}
+	return annotations, nil
+}
+
+// saveAnnotations writes the annotations of the entries still in the library
+func saveAnnotations(path string, annotations map[string]annotation) error {
+	if entries, err := loadLibrary(path); err == nil {
+		keys := library.Keys(entries)
+		for key := range annotations {
+			if !keys[key] {
+				delete(annotations, key)
+			}
+		}
+	}
+	data, err := json.MarshalIndent(annotations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(annotationsPath(path), append(data, '\n'))
+}
+
+// translator translates text from one ISO 639-1 language into another
+type translator func(t translateConfig, apiKey, text, from, to string) (string, error)
+
+// translators are the services of translate.service
+var translators = map[string]translator{
+	"deepl":          translateDeepL,
+	"libretranslate": translateLibre,
+}
+
+// translateDeepL asks the DeepL API, the free one for free keys
+func translateDeepL(t translateConfig, apiKey, text, from, to string) (string, error) {
+	if apiKey == "" {
+		return "", errors.New("deepl: needs api_keys.deepl")
+	}
+	u := cmp.Or(t.URL, "https://api.deepl.com")
+	if t.URL == "" && strings.HasSuffix(apiKey, ":fx") {
+		u = "https://api-free.deepl.com"
+	}
+	body := map[string]any{"text": []string{text}, "target_lang": strings.ToUpper(to)}
+	if from != "" {
+		body["source_lang"] = strings.ToUpper(from)
+	}
+	var res struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	h := http.Header{"Authorization": {"DeepL-Auth-Key " + apiKey}}
+	if err := sendJSON("deepl", http.MethodPost, strings.TrimSuffix(u, "/")+"/v2/translate", h, body, &res); err != nil {
+		return "", err
+	}
+	if len(res.Translations) == 0 {
+		return "", errors.New("deepl: no translation returned")
+	}
+	return res.Translations[0].Text, nil
+}
+
+// translateLibre asks a LibreTranslate server
+func translateLibre(t translateConfig, apiKey, text, from, to string) (string, error) {
+	if t.URL == "" {
+		return "", errors.New("libretranslate: needs translate.url")
+	}
+	body := map[string]string{"q": text, "source": cmp.Or(from, "auto"), "target": to, "format": "text"}
+	if apiKey != "" {
+		body["api_key"] = apiKey
+	}
+	var res struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := sendJSON("libretranslate", http.MethodPost, strings.TrimSuffix(t.URL, "/")+"/translate", nil, body, &res); err != nil {
+		return "", err
+	}
+	return res.TranslatedText, nil
+}
+
+// entryAbstract returns the abstract of an entry, looked up by its DOI when
+// the library does not keep it
+func entryAbstract(cfg config, e Entry) (string, error) {
+	if a := e.Get("abstract"); a != "" {
+		return unbrace.Replace(a), nil
+	}
+	if e.Get("doi") == "" {
+		return "", nil
+	}
+	w, err := resolve.Resolve(e.Get("doi"), cfg.resolveOptions())
+	if err != nil {
+		return "", err
+	}
+	return w.Abstract, nil
+}
+
+// abstractLanguage returns the language code of an abstract, guessed from
+// the text or else taken from the language field of the entry. Works in
+// other languages often come with an English abstract, so the text goes
+// first.
+func abstractLanguage(e Entry, abstract string) string {
+	if code := bibtex.LanguageCode(bibtex.DetectLanguage(abstract)); code != "" {
+		return code
+	}
+	for _, f := range []string{"language", "langid"} {
+		if code := bibtex.LanguageCode(e.Get(f)); code != "" {
+			return code
+		}
+	}
+	return ""
+}
+
+// translateOutcome is what translating the abstract of an entry came to
+type translateOutcome struct {
+	Key string
+	annotation
+	// Skipped says why an entry was left alone, empty when it was translated
+	Skipped string
+}
+
+// translateAbstracts translates the abstracts of entries not in the target
+// language and records them in the annotations of the library. Translations
+// made already are kept unless force is set.
+func translateAbstracts(cfg config, entries []Entry, force bool) ([]translateOutcome, error) {
+	t := cfg.Translate
+	translate, ok := translators[t.Service]
+	if !ok {
+		return nil, withCode(exitInvalid, errors.New("no translation service configured, set translate.service to deepl or libretranslate"))
+	}
+	apiKey := map[string]string{"deepl": cfg.APIKeys.DeepL, "libretranslate": cfg.APIKeys.LibreTranslate}[t.Service]
+	to := bibtex.LanguageCode(t.To)
+	annotations, err := loadAnnotations(cfg.Library)
+	if err != nil {
+		return nil, err
+	}
+	var out []translateOutcome
+	var errs []error
+	changed := false
+	for _, e := range entries {
+		a := annotations[e.Key]
+		if a.Translation != "" && a.To == to && !force {
+			out = append(out, translateOutcome{e.Key, a, ""})
+			continue
+		}
+		abstract, err := entryAbstract(cfg, e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Key, err))
+			continue
+		}
+		if abstract == "" {
+			out = append(out, translateOutcome{e.Key, a, "no abstract"})
+			continue
+		}
+		from := abstractLanguage(e, abstract)
+		if from == to {
+			out = append(out, translateOutcome{e.Key, a, "already in " + bibtex.LanguageName(to)})
+			continue
+		}
+		text, err := translate(t, apiKey, abstract, from, to)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Key, err))
+			continue
+		}
+		a = annotation{Translation: text, From: from, To: to, Service: t.Service, Updated: time.Now().UTC()}
+		annotations[e.Key] = a
+		changed = true
+		out = append(out, translateOutcome{e.Key, a, ""})
+	}
+	slices.SortFunc(errs, func(a, b error) int { return cmp.Compare(a.Error(), b.Error()) })
+	if changed {
+		if err := saveAnnotations(cfg.Library, annotations); err != nil {
+			return out, err
+		}
+	}
+	return out, errors.Join(errs...)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+)
+
+// venueConfig is a [[venues]] table of the configuration
+type venueConfig struct {
+	// Name is the canonical booktitle
+	Name string `toml:"name"`
+	// Series is the short name of the conference series
+	Series string `toml:"series"`
+	// Match lists the names and acronyms the venue comes under
+	Match []string `toml:"match"`
+}
+
+// venues returns the configured venues followed by bibtex.DefaultVenues, so
+// that the configuration can override a default
+func (c config) venues() []bibtex.Venue {
+	var out []bibtex.Venue
+	for _, v := range c.Venues {
+		out = append(out, bibtex.Venue{Name: v.Name, Series: v.Series, Match: v.Match})
+	}
+	return append(out, bibtex.DefaultVenues...)
+}
+
+// venueChanges normalizes the booktitles of entries, returning the changed
+// ones by position in the library
+func venueChanges(entries []Entry, venues []bibtex.Venue) map[int]*Entry {
+	changes := map[int]*Entry{}
+	for i, e := range entries {
+		e.Fields = slices.Clone(e.Fields)
+		// the series goes with the booktitle, a locked one keeps both
+		if !bibtex.NormalizeVenue(&e, venues) || isLocked(&e, "booktitle") {
+			continue
+		}
+		keepLocked(entries[i], &e)
+		changes[i] = &e
+	}
+	return changes
+}
+
+// writeVenueChanges lists the booktitles venueChanges replaced
+func writeVenueChanges(w io.Writer, entries []Entry, changes map[int]*Entry) {
+	for i, e := range entries {
+		if c, ok := changes[i]; ok {
+			fmt.Fprintf(w, "%s  %s -> %s (%s)\n", e.Key, e.Get("booktitle"), c.Get("booktitle"), c.Get("series"))
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce collects the events of an editor saving several files
+const watchDebounce = 300 * time.Millisecond
+
+// watchOptions control what a watch does besides reporting
+type watchOptions struct {
+	// Notify sends desktop notifications
+	Notify bool
+	// Index is a citation completion index regenerated with the library
+	Index string
+	// ProjectBib fetches into the bibliography the project names instead
+	// of the library
+	ProjectBib bool
+}
+
+// citingSource reports whether a project file can cite: LaTeX sources,
+// Quarto and R Markdown documents
+func citingSource(path string) bool {
+	return filepath.Ext(path) == ".tex" || markdownExts[filepath.Ext(path)]
+}
+
+// watcher checks a LaTeX, Quarto or R Markdown project whenever its sources
+// or .bib files change
+type watcher struct {
+	dir  string
+	cfg  config
+	out  io.Writer
+	opts watchOptions
+	// tried holds the DOI citations already resolved, successfully or not,
+	// so a failing lookup is not repeated on every save
+	tried map[string]bool
+	// last is the previous report, notifications are only sent on changes
+	last []string
+}
+
+// watchProject runs until ctx is done
+func watchProject(ctx context.Context, dir string, cfg config, out io.Writer, opts watchOptions) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fw.Close() // nolint:errcheck
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return fw.Add(path)
+	})
+	if err != nil {
+		return err
+	}
+	if opts.ProjectBib {
+		if cfg.Library, err = projectBibliography(dir); err != nil {
+			return err
+		}
+	}
+	// the library may live outside of the project
+	if lib, err := filepath.Abs(cfg.Library); err == nil {
+		if abs, err := filepath.Abs(dir); err == nil && !strings.HasPrefix(lib, abs+string(filepath.Separator)) {
+			_ = fw.Add(filepath.Dir(lib))
+		}
+	}
+
+	w := &watcher{dir: dir, cfg: cfg, out: out, opts: opts, tried: map[string]bool{}}
+	fmt.Fprintf(out, "watching %s, ctrl+c to stop\n", dir)
+	w.check()
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if !citingSource(ev.Name) && filepath.Ext(ev.Name) != ".bib" && filepath.Base(ev.Name) != "_quarto.yml" {
+				continue
+			}
+			slog.Debug("file changed", "path", ev.Name, "op", ev.Op)
+			timer.Reset(watchDebounce)
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("watch failed", "err", err)
+		case <-timer.C:
+			w.check()
+		}
+	}
+}
+
+// check fetches missing DOI citations and reports everything else that
+// needs attention
+func (w *watcher) check() {
+	var report []string
+	cited, err := projectCitations(w.dir)
+	if err != nil {
+		report = append(report, err.Error())
+	}
+	entries, err := loadLibrary(w.cfg.Library)
+	if err != nil {
+		report = append(report, fmt.Sprintf("%s: %v", w.cfg.Library, err))
+	}
+	keys := citableKeys(entries)
+
+	for _, c := range cited {
+		if keys[c.key] {
+			continue
+		}
+		if looksLikeDOI(c.key) && !w.tried[c.key] {
+			w.tried[c.key] = true
+			work, err := fetchCitation(w.cfg, w.cfg.Library, c.key)
+			if err != nil {
+				report = append(report, fmt.Sprintf("%s: fetching %s: %v", c.file, c.key, err))
+				continue
+			}
+			w.log("fetched %s into %s", c.key, w.cfg.Library)
+			if err := syncImported(w.cfg, work.Entry, work.Abstract); err != nil {
+				w.log("%s: %v", c.key, err)
+			}
+			continue
+		}
+		report = append(report, fmt.Sprintf("%s: %s is not in %s", c.file, c.key, w.cfg.Library))
+	}
+	for _, i := range lintEntries(entries) {
+		report = append(report, fmt.Sprintf("%s: %s", w.cfg.Library, i))
+	}
+	if w.opts.Index != "" {
+		// read again, the index includes the citations just fetched
+		if entries, err := loadLibrary(w.cfg.Library); err == nil {
+			if err := writeCitationIndex(w.opts.Index, entries); err != nil {
+				report = append(report, fmt.Sprintf("%s: %v", w.opts.Index, err))
+			}
+		}
+	}
+
+	if slices.Equal(report, w.last) {
+		return
+	}
+	w.last = report
+	if len(report) == 0 {
+		w.log("all citations resolved, library clean")
+		return
+	}
+	for _, r := range report {
+		w.log("%s", r)
+	}
+	fmt.Fprint(w.out, "\a")
+	if w.opts.Notify {
+		if err := desktopNotify("bibgloss", fmt.Sprintf("%d problems in %s", len(report), w.dir)); err != nil {
+			slog.Warn("notification failed", "err", err)
+		}
+	}
+}
+
+type citation struct{ file, key string }
+
+// projectCitations collects the citation keys of all sources below dir
+func projectCitations(dir string) ([]citation, error) {
+	var out []citation
+	seen := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !citingSource(path) {
+			return nil
+		}
+		keys, err := loadCitations(path)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, citation{path, k})
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// fetchCitation resolves a DOI cited directly and stores it in library
+// under that key, so the citation works without editing the document
+func fetchCitation(cfg config, library, doi string) (*Work, error) {
+	w, err := resolve.Resolve(doi, cfg.resolveOptions())
+	if err != nil {
+		return nil, err
+	}
+	w.Entry.Key = doi
+	w.Entry = bibtex.Convert(w.Entry, cfg.Format)
+	if err := preImport(cfg, &w.Entry, w.Abstract, library, nil); err != nil {
+		return nil, err
+	}
+	// the document cites the DOI, hooks cannot rename the entry
+	w.Entry.Key = doi
+	// the watcher outlives edits of the library, each fetch appends to the
+	// file as it is
+	forgetFile(library)
+	_, err = mutate(library, "fetch "+doi, func() error {
+		return appendEntry(library, &w.Entry)
+	})
+	return w, err
+}
+
+func (w *watcher) log(format string, args ...any) {
+	fmt.Fprintf(w.out, "%s %s\n", time.Now().Format(time.TimeOnly), fmt.Sprintf(format, args...))
+}
+
+// looksLikeDOI reports whether a citation key is a bare DOI
+func looksLikeDOI(key string) bool {
+	return strings.HasPrefix(key, "10.") && strings.Contains(key, "/")
+}
+
+// desktopNotify shows a notification with the platform's notifier
+func desktopNotify(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", body, title))
+	case "windows":
+		// there is no notifier to call without extra tools
+		return nil
+	default:
+		cmd = exec.Command("notify-send", title, body)
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/arunoruto/BibGloss/pkg/bibtex"
+	"github.com/arunoruto/BibGloss/pkg/resolve"
+)
+
+const zoteroAPI = "https://api.zotero.org"
+
+// zoteroBatch is the most items the Zotero API creates per request
+const zoteroBatch = 50
+
+// zoteroConfig points at the Zotero library imported entries are mirrored to
+type zoteroConfig struct {
+	APIKey    string `toml:"api_key"`
+	LibraryID string `toml:"library_id"`
+	// LibraryType is user or group
+	LibraryType string `toml:"library_type"`
+}
+
+func (z zoteroConfig) enabled() bool {
+	return z.APIKey != "" && z.LibraryID != ""
+}
+
+// zoteroItemTypes maps BibTeX entry types to Zotero item types
+var zoteroItemTypes = map[string]string{
+	"article":       "journalArticle",
+	"book":          "book",
+	"inbook":        "bookSection",
+	"incollection":  "bookSection",
+	"inproceedings": "conferencePaper",
+	"phdthesis":     "thesis",
+	"mastersthesis": "thesis",
+	"thesis":        "thesis",
+	"techreport":    "report",
+	"report":        "report",
+}
+
+// zoteroItem converts an entry to a Zotero item in the given collections.
+// Values Zotero has no field for on the item type go to extra, the way
+// Better BibTeX reads them.
+func zoteroItem(e Entry, collections []string) map[string]any {
+	typ := zoteroItemTypes[e.Type]
+	if typ == "" {
+		typ = "document"
+	}
+	if collections == nil {
+		collections = []string{}
+	}
+	item := map[string]any{
+		"itemType":     typ,
+		"title":        e.Get("title"),
+		"creators":     zoteroCreators(e),
+		"date":         e.Get("date"),
+		"url":          e.Get("url"),
+		"abstractNote": e.Get("abstract"),
+		"collections":  collections,
+	}
+	if item["date"] == "" {
+		item["date"] = strings.Trim(e.Get("year")+"-"+e.Get("month"), "-")
+	}
+	extra := []string{"Citation Key: " + e.Key}
+	set := func(field, value string) {
+		if value != "" {
+			item[field] = value
+		}
+	}
+	container := e.Get("journal")
+	if container == "" {
+		container = e.Get("journaltitle")
+	}
+	switch typ {
+	case "journalArticle":
+		set("publicationTitle", container)
+		set("volume", e.Get("volume"))
+		set("issue", e.Get("number"))
+		set("pages", e.Get("pages"))
+		set("ISSN", e.Get("issn"))
+		set("DOI", e.Get("doi"))
+	case "conferencePaper":
+		set("proceedingsTitle", e.Get("booktitle"))
+		set("pages", e.Get("pages"))
+		set("publisher", e.Get("publisher"))
+		set("DOI", e.Get("doi"))
+	case "bookSection":
+		set("bookTitle", e.Get("booktitle"))
+		set("pages", e.Get("pages"))
+		set("publisher", e.Get("publisher"))
+		set("ISBN", e.Get("isbn"))
+	case "book":
+		set("publisher", e.Get("publisher"))
+		set("ISBN", e.Get("isbn"))
+	case "thesis":
+		set("university", e.Get("school"))
+	case "report":
+		set("institution", e.Get("institution"))
+	}
+	if doi := e.Get("doi"); doi != "" && item["DOI"] == nil {
+		extra = append(extra, "DOI: "+doi)
+	}
+	item["extra"] = strings.Join(extra, "\n")
+	var tags []map[string]string
+	for _, t := range entryTags(&e) {
+		tags = append(tags, map[string]string{"tag": t})
+	}
+	if tags != nil {
+		item["tags"] = tags
+	}
+	return item
+}
+
+// zoteroCreators splits the author and editor fields into Zotero creators
+func zoteroCreators(e Entry) []map[string]string {
+	creators := []map[string]string{}
+	for _, role := range []string{"author", "editor"} {
+		for _, name := range bibtex.SplitAuthors(e.Get(role)) {
+			c := map[string]string{"creatorType": role}
+			if family, given, ok := strings.Cut(name, ","); ok {
+				c["lastName"] = strings.TrimSpace(family)
+				c["firstName"] = strings.TrimSpace(given)
+			} else {
+				c["name"] = strings.Trim(name, "{}")
+			}
+			creators = append(creators, c)
+		}
+	}
+	return creators
+}
+
+// pushZotero creates Zotero items for entries, putting each into the
+// collections named like its tags. Missing collections are created, entries
+// the library has already are left out.
+func pushZotero(z zoteroConfig, entries []Entry) (created int, err error) {
+	missing, err := zoteroMissing(z, entries)
+	if err != nil || len(missing) == 0 {
+		return 0, err
+	}
+	collections, err := zoteroCollections(z)
+	if err != nil {
+		return 0, err
+	}
+	items := make([]map[string]any, 0, len(missing))
+	for _, e := range missing {
+		var keys []string
+		for _, tag := range entryTags(&e) {
+			key, ok := collections[strings.ToLower(tag)]
+			if !ok {
+				if key, err = zoteroCreateCollection(z, tag); err != nil {
+					return 0, err
+				}
+				collections[strings.ToLower(tag)] = key
+			}
+			keys = append(keys, key)
+		}
+		items = append(items, zoteroItem(e, keys))
+	}
+	for len(items) > 0 {
+		n := min(len(items), zoteroBatch)
+		var res zoteroWriteResult
+		if err := zoteroRequest(z, http.MethodPost, "/items", items[:n], &res); err != nil {
+			return created, err
+		}
+		created += len(res.Successful)
+		if err := res.err(); err != nil {
+			return created, err
+		}
+		items = items[n:]
+	}
+	return created, nil
+}
+
+// zoteroMissing returns the entries the Zotero library has no item of
+func zoteroMissing(z zoteroConfig, entries []Entry) ([]Entry, error) {
+	var missing []Entry
+	for _, e := range entries {
+		found, err := zoteroHas(z, e)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+	return missing, nil
+}
+
+// zoteroWriteResult is the answer to creating objects
+type zoteroWriteResult struct {
+	Successful map[string]struct {
+		Key string `json:"key"`
+	} `json:"successful"`
+	Failed map[string]struct {
+		Message string `json:"message"`
+	} `json:"failed"`
+}
+
+func (r zoteroWriteResult) err() error {
+	for i, f := range r.Failed {
+		return fmt.Errorf("zotero: object %s: %s", i, f.Message)
+	}
+	return nil
+}
+
+// zoteroHas reports whether the library has an item of the entry: one with
+// its DOI, or the citation key zoteroItem records in extra
+func zoteroHas(z zoteroConfig, e Entry) (bool, error) {
+	doi := e.Get("doi")
+	q := cmp.Or(doi, e.Key)
+	var res []struct {
+		Data struct {
+			DOI   string `json:"DOI"`
+			Extra string `json:"extra"`
+		} `json:"data"`
+	}
+	// qmode everything searches extra too
+	path := "/items?qmode=everything&itemType=-attachment&limit=" + fmt.Sprint(zoteroBatch) + "&q=" + url.QueryEscape(q)
+	if err := zoteroRequest(z, http.MethodGet, path, nil, &res); err != nil {
+		return false, err
+	}
+	for _, it := range res {
+		if doi != "" && strings.EqualFold(it.Data.DOI, doi) {
+			return true, nil
+		}
+		for _, l := range strings.Split(it.Data.Extra, "\n") {
+			k, v, _ := strings.Cut(l, ":")
+			v = strings.TrimSpace(v)
+			switch strings.TrimSpace(k) {
+			case "Citation Key":
+				if v == e.Key {
+					return true, nil
+				}
+			case "DOI":
+				if doi != "" && strings.EqualFold(v, doi) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// zoteroPage is how many collections are read per request, the most the
+// API answers with
+const zoteroPage = 100
+
+// zoteroCollections maps lower-cased collection names to their keys
+func zoteroCollections(z zoteroConfig) (map[string]string, error) {
+	out := map[string]string{}
+	for start := 0; ; start += zoteroPage {
+		var res []struct {
+			Key  string `json:"key"`
+			Data struct {
+				Name string `json:"name"`
+			} `json:"data"`
+		}
+		path := fmt.Sprintf("/collections?limit=%d&start=%d", zoteroPage, start)
+		if err := zoteroRequest(z, http.MethodGet, path, nil, &res); err != nil {
+			return nil, err
+		}
+		for _, c := range res {
+			out[strings.ToLower(c.Data.Name)] = c.Key
+		}
+		if len(res) < zoteroPage {
+			return out, nil
+		}
+	}
+}
+
+func zoteroCreateCollection(z zoteroConfig, name string) (string, error) {
+	var res zoteroWriteResult
+	if err := zoteroRequest(z, http.MethodPost, "/collections", []map[string]string{{"name": name}}, &res); err != nil {
+		return "", err
+	}
+	if err := res.err(); err != nil {
+		return "", err
+	}
+	return res.Successful["0"].Key, nil
+}
+
+// zoteroRequest calls the Zotero Web API for the configured library. The
+// answers are about the user's own data, so they are never cached.
+func zoteroRequest(z zoteroConfig, method, path string, body, v any) error {
+	if resolve.Offline {
+		return fmt.Errorf("zotero: %w", resolve.ErrOffline)
+	}
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	u := fmt.Sprintf("%s/%ss/%s%s", zoteroAPI, z.LibraryType, z.LibraryID, path)
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Zotero-API-Key", z.APIKey)
+	req.Header.Set("Zotero-API-Version", "3")
+	req.Header.Set("Content-Type", "application/json")
+	res, err := resolve.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("zotero: %w", err)
+	}
+	defer res.Body.Close() // nolint:errcheck
+	slog.Debug("response", "url", u, "status", res.StatusCode)
+	if res.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("zotero: %w", &resolve.HTTPError{URL: u, Status: res.Status + ", check api_key and its write access"})
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("zotero: %w", &resolve.HTTPError{URL: u, Status: res.Status})
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// syncImported mirrors a freshly imported entry to the configured targets.
+// The abstract is not part of the entry, notes still show it. Zotero and
+// the hooks are left alone until a dry run's changes are applied.
+func syncImported(cfg config, e Entry, abstract string) error {
+	var errs []error
+	if cfg.Zotero.enabled() {
+		errs = append(errs, whenCommitted("push "+e.Key+" to Zotero", func() error {
+			_, err := pushZotero(cfg.Zotero, []Entry{e})
+			return err
+		}))
+	}
+	if cfg.Obsidian.enabled() {
+		if _, err := writeNote(notePath(cfg.Obsidian, e), literatureNote(cfg.Obsidian, e, abstract)); err != nil {
+			errs = append(errs, fmt.Errorf("obsidian: %w", err))
+		}
+	}
+	if cfg.OrgRoam.enabled() {
+		if _, err := writeNote(orgNotePath(cfg.OrgRoam, e), orgNote(cfg.OrgRoam, e, abstract)); err != nil {
+			errs = append(errs, fmt.Errorf("org-roam: %w", err))
+		}
+	}
+	if len(cfg.Hooks.PostImport) > 0 {
+		errs = append(errs, whenCommitted("run the post-import hooks on "+e.Key, func() error {
+			return postImport(cfg, e, abstract, cfg.Library)
+		}))
+	}
+	if cfg.GlossaryLinks {
+		if _, err := linkGlossary(cfg.Glossaries[0], e, abstract); err != nil {
+			errs = append(errs, fmt.Errorf("glossary: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}